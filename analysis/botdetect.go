@@ -39,8 +39,6 @@ const (
 	minPrevNumRequestsSampleSize = 10
 	bufferCleanupProbability     = 0.1
 	bufferCleanupMaxAge          = time.Hour * 6
-	suspiciousRecordsThreshold   = 0.6
-	suspiciousRecordsMinRequests = 10
 	fullBufferMaxAge             = time.Hour * 5
 )
 
@@ -51,6 +49,9 @@ type SuspiciousReqCounter struct {
 }
 
 func (src SuspiciousReqCounter) SuspicRatio() float64 {
+	if src.NumAny == 0 {
+		return 0
+	}
 	return float64(src.NumSuspic) / float64(src.NumAny)
 }
 
@@ -108,12 +109,31 @@ func (state *BotAnalysisState) Report() map[string]any {
 // 1) high increase in traffic (a respective ratio is defined in config)
 // 2) outlier IPs with too high ratio on the recent traffic
 //
-// Both are reported via e-mail.
+// Both are reported via e-mail and optionally also exported
+// via a configured BotCandidateExporter.
 type BotAnalyzer[T AnalyzableRecord] struct {
 	appType       string
 	conf          *load.BufferConf
 	realtimeClock bool
 	notifier      notifications.Notifier
+	exporter      BotCandidateExporter
+}
+
+// exportCandidates passes detected bot candidates to a configured
+// exporter (if any). The export runs in a separate goroutine.
+func (analyzer *BotAnalyzer[T]) exportCandidates(candidates []BotCandidate) {
+	if analyzer.exporter == nil || len(candidates) == 0 {
+		return
+	}
+	go func() {
+		if err := analyzer.exporter.ExportBotCandidates(candidates); err != nil {
+			log.Error().
+				Err(err).
+				Str("appType", analyzer.appType).
+				Int("numCandidates", len(candidates)).
+				Msg("failed to export bot candidates")
+		}
+	}()
 }
 
 func (analyzer *BotAnalyzer[T]) isIgnoredIP(ip net.IP) bool {
@@ -126,6 +146,7 @@ func (analyzer *BotAnalyzer[T]) getOutlierRecords(
 	state *BotAnalysisState,
 	sortedItems collections.BinTree[*ReqCalcItem],
 	checkInterval time.Duration,
+	currTime time.Time,
 	isSuspicTrafficIncrease bool,
 	trafficIncrease float64,
 ) error {
@@ -160,11 +181,27 @@ func (analyzer *BotAnalyzer[T]) getOutlierRecords(
 			Msg("found outlier IP requests - going to report")
 
 		ipReportMetadata := make([]IPReport, len(outlierRecords))
+		candidates := make([]BotCandidate, len(outlierRecords))
 		var ipListing strings.Builder
 		for i, susp := range outlierRecords {
 			ipListing.WriteString(fmt.Sprintf("%s (%dx)\n", susp.IP, susp.Count))
 			ipReportMetadata[i] = IPReport{IP: susp.IP, Freq: susp.Count}
+			candidates[i] = BotCandidate{
+				AppType:         analyzer.appType,
+				IP:              susp.IP,
+				Reason:          BotReasonIPOutlier,
+				NumRequests:     susp.Count,
+				Threshold:       threshold,
+				SuspiciousRatio: state.FullBufferIPProps.Get(susp.IP).SuspicRatio(),
+				KnownIP:         susp.Known,
+				IntervalStart:   state.LastCheck,
+				IntervalEnd:     currTime,
+			}
+			if isSuspicTrafficIncrease {
+				candidates[i].TrafficIncrease = trafficIncrease
+			}
 		}
+		analyzer.exportCandidates(candidates)
 
 		var trafficNote string
 		if isSuspicTrafficIncrease {
@@ -280,13 +317,15 @@ func (analyzer *BotAnalyzer[T]) testAndReportSuspicRequestIPs(
 	avgRequests /= float64(len(lastPeriodCounter))
 	// here we want to avoid situations when avg of requests per IP is small so any
 	// IP with some suspicious requests is mostly meaningless
-	numRequestsThreshold := maths.Max(avgRequests, suspiciousRecordsMinRequests)
+	numRequestsThreshold := maths.Max(
+		avgRequests, float64(analyzer.conf.BotDetection.GetSuspiciousReqMinRequests()))
+	suspicRatioThreshold := analyzer.conf.BotDetection.GetSuspiciousReqRatioThreshold()
 	sortedItems := collections.BinTree[*ReqCalcItem]{}
 	suspicRequestsIP := make(map[string]int)
 	for _, v := range lastPeriodCounter {
 		sortedItems.Add(v)
 		fullBufferInfo := state.FullBufferIPProps.Get(v.IP)
-		if fullBufferInfo.SuspicRatio() >= suspiciousRecordsThreshold &&
+		if fullBufferInfo.SuspicRatio() >= suspicRatioThreshold &&
 			v.Count >= int(numRequestsThreshold) {
 			suspicRequestsIP[v.IP] = v.Count
 		}
@@ -305,6 +344,26 @@ func (analyzer *BotAnalyzer[T]) testAndReportSuspicRequestIPs(
 	}
 
 	if len(suspicRequestsIP) > 0 {
+		candidates := make([]BotCandidate, 0, len(suspicRequestsIP))
+		for ip, count := range suspicRequestsIP {
+			cand := BotCandidate{
+				AppType:         analyzer.appType,
+				IP:              ip,
+				Reason:          BotReasonSuspiciousRequests,
+				NumRequests:     count,
+				Threshold:       int(numRequestsThreshold),
+				SuspiciousRatio: state.FullBufferIPProps.Get(ip).SuspicRatio(),
+				KnownIP: collections.SliceContains[string](
+					analyzer.conf.BotDetection.BlocklistIP, ip),
+				IntervalStart: state.LastCheck,
+				IntervalEnd:   currTime,
+			}
+			if isSuspicTrafficIncrease {
+				cand.TrafficIncrease = trafficIncrease
+			}
+			candidates = append(candidates, cand)
+		}
+		analyzer.exportCandidates(candidates)
 		go func() {
 			var trafficNote string
 			msgArgs := make(map[string]any)
@@ -403,7 +462,7 @@ func (analyzer *BotAnalyzer[T]) Preprocess(
 		tState, prevRecs, checkInterval, currTime, isSuspicTrafficIncrease, trafficIncrease)
 
 	err := analyzer.getOutlierRecords(
-		tState, sortedItems, checkInterval, isSuspicTrafficIncrease, trafficIncrease)
+		tState, sortedItems, checkInterval, currTime, isSuspicTrafficIncrease, trafficIncrease)
 	if err == maths.ErrTooSmallDataset {
 		return ans
 	}
@@ -425,11 +484,13 @@ func NewBotAnalyzer[T AnalyzableRecord](
 	conf *load.BufferConf,
 	realtimeClock bool,
 	emailNotifier notifications.Notifier,
+	botExporter BotCandidateExporter,
 ) *BotAnalyzer[T] {
 	return &BotAnalyzer[T]{
 		appType:       appType,
 		conf:          conf,
 		realtimeClock: realtimeClock,
 		notifier:      emailNotifier,
+		exporter:      botExporter,
 	}
 }
@@ -0,0 +1,53 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysis
+
+import (
+	"time"
+)
+
+const (
+
+	// BotReasonSuspiciousRequests marks IPs with a high ratio
+	// of suspicious requests
+	BotReasonSuspiciousRequests = "suspiciousRequests"
+
+	// BotReasonIPOutlier marks IPs with an unusually high
+	// number of requests
+	BotReasonIPOutlier = "ipOutlier"
+)
+
+// BotCandidate describes an IP address detected as a possible bot
+// within a single analysis interval
+type BotCandidate struct {
+	AppType         string    `json:"appType"`
+	IP              string    `json:"ip"`
+	Reason          string    `json:"reason"`
+	NumRequests     int       `json:"numRequests"`
+	Threshold       int       `json:"threshold"`
+	SuspiciousRatio float64   `json:"suspiciousRatio"`
+	KnownIP         bool      `json:"knownIp"`
+	TrafficIncrease float64   `json:"trafficIncrease,omitempty"`
+	IntervalStart   time.Time `json:"intervalStart"`
+	IntervalEnd     time.Time `json:"intervalEnd"`
+}
+
+// BotCandidateExporter stores detected bot candidates
+// (e.g. to a database) for further processing
+type BotCandidateExporter interface {
+	ExportBotCandidates(candidates []BotCandidate) error
+}
@@ -84,14 +84,11 @@ func (analyzer *ClusteringAnalyzer[T]) Preprocess(
 			items = append(items, item)
 		})
 		if len(items) > 0 {
-			clustered := clustering.Analyze(
-				analyzer.conf.ClusteringDBScan.MinDensity,
-				analyzer.conf.ClusteringDBScan.Epsilon,
-				items,
-			)
+			minDensity, epsilon := analyzer.conf.ClusteringDBScan.ParamsAt(rec.GetTime())
+			clustered := clustering.Analyze(minDensity, epsilon, items)
 			log.Debug().
-				Int("minDensity", analyzer.conf.ClusteringDBScan.MinDensity).
-				Float64("epsilon", analyzer.conf.ClusteringDBScan.Epsilon).
+				Int("minDensity", minDensity).
+				Float64("epsilon", epsilon).
 				Time("firstRecord", items[0].GetTime()).
 				Time("lastRecord", items[len(items)-1].GetTime()).
 				Int("numAnalyzedRecords", len(items)).
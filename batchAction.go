@@ -17,44 +17,73 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"klogproc/analysis"
 	"klogproc/config"
+	"klogproc/geodb"
 	"klogproc/load/batch"
 	"klogproc/logbuffer"
 	"klogproc/notifications"
 	"klogproc/save"
+	"klogproc/save/clickhouse"
+	"klogproc/save/couchdb"
+	"klogproc/save/csv"
 	"klogproc/save/elastic"
 	"klogproc/save/influx"
+	"klogproc/save/loki"
+	"klogproc/save/stdout"
 	"klogproc/servicelog"
 	"klogproc/trfactory"
 	"klogproc/users"
+	"os"
 	"reflect"
 	"sync"
 	"time"
 
 	"github.com/czcorpus/cnc-gokit/collections"
-	"github.com/oschwald/geoip2-golang"
 	"github.com/rs/zerolog/log"
 )
 
+// batchSink is a consumer of one of the batch action's outputs
+type batchSink struct {
+	confirms <-chan save.ConfirmMsg
+	// errMsg is logged along with a write error (empty = errors are not logged)
+	errMsg string
+	// isStorage tells whether the confirmations should be considered
+	// when deciding about the success of the run (see batch.WriteConfirmations)
+	isStorage bool
+}
+
 func runBatchAction(
 	conf *config.Main,
 	options *ProcessOptions,
-	geoDB *geoip2.Reader,
+	geoDB *geodb.Reader,
 	userMap *users.UserMap,
 	finishEvent chan<- bool,
 ) {
+	if options.strictSchema {
+		if conf.LogFiles.ParsingMode.IsStrict() {
+			log.Warn().Msg("-strict-schema has no effect in the strict parsing mode (unknown fields are errors)")
+
+		} else {
+			conf.LogFiles.ParsingMode = servicelog.ParsingModeStrictSchema
+		}
+	}
 	// For debugging e-mail notification, you can pass `conf.EmailNotification`
 	// as the first argument and use the "batch" mode to tune log processing.
-	nullMailNot, _ := notifications.NewNotifier(nil, conf.ConomiNotification, conf.TimezoneLocation())
+	nullMailNot, err := notifications.NewNotifier(
+		nil, conf.ConomiNotification, nil, conf.TimezoneLocation())
+	if err != nil {
+		log.Fatal().Msgf("Failed to initialize notifier: %s", err)
+	}
 	lt, err := trfactory.GetLogTransformer(
-		conf.LogFiles.AppType,
-		conf.LogFiles.Version,
-		conf.LogFiles.Buffer,
-		userMap,
-		conf.LogFiles.ExcludeIPList,
-		false,
-		nullMailNot,
+		conf.LogFiles.ProcessingConf,
+		trfactory.TransformerDeps{
+			UserMap:     userMap,
+			Notifier:    nullMailNot,
+			BotExporter: newBotCandidateExporter(conf, options),
+		},
 	)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to run batch action")
@@ -76,10 +105,19 @@ func runBatchAction(
 	}
 
 	if conf.LogFiles.Buffer != nil {
+		stateDir := conf.LogFiles.LogBufferStateDir
+		if options.reindex {
+			// reindexing must not affect the persisted analysis state
+			stateDir, err = os.MkdirTemp("", "klogproc-reindex")
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to create temporary buffer state directory")
+			}
+			defer os.RemoveAll(stateDir)
+		}
 		buffStorage = logbuffer.NewStorage[servicelog.InputRecord, logbuffer.SerializableState](
 			conf.LogFiles.Buffer,
-			options.worklogReset,
-			conf.LogFiles.LogBufferStateDir,
+			options.worklogReset && !options.reindex,
+			stateDir,
 			conf.LogFiles.SrcPath,
 			stateFactory,
 		)
@@ -95,72 +133,204 @@ func runBatchAction(
 		)
 	}
 
+	actionFilter, err := servicelog.NewActionFilter(
+		conf.LogFiles.AppType, conf.IgnoreActions[conf.LogFiles.AppType])
+	if err != nil {
+		log.Fatal().Msgf("Failed to initialize action filter: %s", err)
+	}
 	processor := &CNKLogProcessor{
 		geoIPDb:        geoDB,
 		chunkSize:      conf.ElasticSearch.PushChunkSize,
 		appType:        conf.LogFiles.AppType,
 		appVersion:     conf.LogFiles.Version,
+		instanceID:     conf.InstanceID,
+		instanceIDInID: conf.InstanceIDInRecordID,
 		logTransformer: lt,
 		anonymousUsers: conf.AnonymousUsers,
+		anonymizeIP:    conf.AnonymizeIP,
 		skipAnalysis:   conf.LogFiles.SkipAnalysis,
 		logBuffer:      buffStorage,
+		uaMatcher:      newUserAgentMatcher(conf.LogFiles.Buffer),
+		actionFilter:   actionFilter,
+		sampleRate:     conf.LogFiles.SampleRate,
 	}
 	channelWriteES := make(chan *servicelog.BoundOutputRecord, conf.ElasticSearch.PushChunkSize*2)
 	channelWriteInflux := make(chan *servicelog.BoundOutputRecord, conf.InfluxDB.PushChunkSize)
-	worklog := batch.NewWorklog(conf.LogFiles.WorklogPath)
-	log.Info().Msgf("using worklog %s", conf.LogFiles.WorklogPath)
-	if options.worklogReset {
-		log.Printf("truncated worklog %v", worklog)
-		err := worklog.Reset()
-		if err != nil {
-			log.Fatal().Msgf("unable to initialize worklog: %s", err)
+	channelWriteClickHouse := make(chan *servicelog.BoundOutputRecord, conf.ClickHouse.PushChunkSize)
+	channelWriteStdout := make(chan *servicelog.BoundOutputRecord)
+	channelWriteCSV := make(chan *servicelog.BoundOutputRecord)
+	channelWriteLoki := make(chan *servicelog.BoundOutputRecord, conf.Loki.PushChunkSize)
+	channelWriteCouchDB := make(chan *servicelog.BoundOutputRecord, conf.CouchDB.PushChunkSize)
+	var worklog *batch.Worklog
+	var minTimestamp int64
+	if options.reindex {
+		// reindexing uses the datetime range only and the worklog is neither read
+		// nor written so it can run along with regular batch/tail processing
+		minTimestamp = options.datetimeRange.From.Unix()
+		conf.LogFiles.PartiallyMatchingFiles = true
+		log.Info().
+			Time("from", *options.datetimeRange.From).
+			Msg("reindexing records, worklog is not used")
+
+	} else {
+		worklog = batch.NewWorklog(conf.LogFiles.WorklogPath)
+		log.Info().Msgf("using worklog %s", conf.LogFiles.WorklogPath)
+		if options.worklogReset {
+			log.Printf("truncated worklog %v", worklog)
+			err := worklog.Reset()
+			if err != nil {
+				log.Fatal().Msgf("unable to initialize worklog: %s", err)
+			}
 		}
+		minTimestamp = worklog.GetLastRecord()
 	}
-	defer worklog.Save()
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-	if options.dryRun || options.analysisOnly {
-		ch1 := save.RunWriteConsumer(channelWriteES, !options.analysisOnly)
-		go func() {
-			for range ch1 {
-			}
-			wg.Done()
-		}()
-		ch2 := save.RunWriteConsumer(channelWriteInflux, !options.analysisOnly)
-		go func() {
-			for range ch2 {
-			}
-			wg.Done()
-		}()
+	ctx := context.Background()
+	if options.maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.maxDuration)
+		defer cancel()
+		log.Info().Dur("maxDuration", options.maxDuration).Msg("batch run duration limited")
+	}
+
+	var diffStats elastic.DiffStats
+	// confirmations of the storage backends (stdout and CSV errors
+	// are encoding problems a rerun would not fix)
+	var confirmations batch.WriteConfirmations
+	var sinks []batchSink
+	if options.dryRunDiff {
+		if !conf.ElasticSearch.IsConfigured() {
+			log.Fatal().Msg("the dry-run-diff mode requires ElasticSearch to be configured")
+		}
+		sinks = append(sinks, batchSink{confirms: elastic.RunDiffConsumer(
+			conf.LogFiles.AppType, &conf.ElasticSearch, channelWriteES, &diffStats, options.diffJSON)})
+		for _, ch := range []chan *servicelog.BoundOutputRecord{
+			channelWriteInflux, channelWriteClickHouse, channelWriteStdout,
+			channelWriteCSV, channelWriteLoki, channelWriteCouchDB} {
+			sinks = append(sinks, batchSink{confirms: save.RunWriteConsumer(ch, false)})
+		}
+		log.Warn().Msg("using dry-run-diff mode, differences go to stdout")
+
+	} else if options.dryRun || options.analysisOnly {
+		sinks = []batchSink{
+			{confirms: save.RunWriteConsumer(channelWriteES, !options.analysisOnly)},
+			{confirms: save.RunWriteConsumer(channelWriteInflux, !options.analysisOnly)},
+		}
+		for _, ch := range []chan *servicelog.BoundOutputRecord{
+			channelWriteClickHouse, channelWriteStdout, channelWriteCSV,
+			channelWriteLoki, channelWriteCouchDB} {
+			sinks = append(sinks, batchSink{confirms: save.RunWriteConsumer(ch, false)})
+		}
 		log.Warn().Msg("using dry-run mode, output goes to stdout")
 
 	} else {
-		ch1 := elastic.RunWriteConsumer(conf.LogFiles.AppType, &conf.ElasticSearch, channelWriteES)
-		ch2 := influx.RunWriteConsumer(&conf.InfluxDB, channelWriteInflux)
-		go func() {
-			for confirm := range ch1 {
-				if confirm.Error != nil {
-					log.Error().Err(confirm.Error).Msg("failed to save data to ElasticSearch database")
-					// TODO
+		sinks = []batchSink{
+			{
+				confirms: elastic.RunWriteConsumer(
+					ctx, conf.LogFiles.AppType, &conf.ElasticSearch, channelWriteES),
+				errMsg:    "failed to save data to ElasticSearch database",
+				isStorage: true,
+			},
+			{
+				confirms:  influx.RunWriteConsumer(&conf.InfluxDB, channelWriteInflux),
+				errMsg:    "failed to save data to InfluxDB database",
+				isStorage: true,
+			},
+			{
+				confirms:  clickhouse.RunWriteConsumer(&conf.ClickHouse, channelWriteClickHouse),
+				errMsg:    "failed to save data to ClickHouse database",
+				isStorage: true,
+			},
+			{
+				confirms: stdout.RunWriteConsumer(&conf.Stdout, channelWriteStdout),
+				errMsg:   "failed to write data to stdout",
+			},
+			{
+				confirms: csv.RunWriteConsumer(&conf.CSV, channelWriteCSV),
+				errMsg:   "failed to write data to CSV file",
+			},
+			{
+				confirms:  loki.RunWriteConsumer(&conf.Loki, channelWriteLoki),
+				errMsg:    "failed to push data to Loki",
+				isStorage: true,
+			},
+			{
+				confirms:  couchdb.RunWriteConsumer(&conf.CouchDB, channelWriteCouchDB),
+				errMsg:    "failed to save data to CouchDB database",
+				isStorage: true,
+			},
+		}
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(sinks))
+	for _, sink := range sinks {
+		go func(sink batchSink) {
+			for confirm := range sink.confirms {
+				if sink.isStorage {
+					confirmations.Add(confirm)
 				}
-			}
-			wg.Done()
-		}()
-		go func() {
-			for confirm := range ch2 {
-				if confirm.Error != nil {
-					log.Error().Err(confirm.Error).Msg("Failed to save data to InfluxDB database")
-					// TODO
+				if confirm.Error != nil && sink.errMsg != "" {
+					log.Error().Err(confirm.Error).Msg(sink.errMsg)
 				}
 			}
 			wg.Done()
-		}()
+		}(sink)
 	}
-	proc := batch.CreateLogFileProcFunc(processor, options.datetimeRange, channelWriteES, channelWriteInflux)
-	proc(conf.LogFiles, worklog.GetLastRecord())
+	proc := batch.CreateLogFileProcFunc(
+		processor, options.datetimeRange,
+		channelWriteES, channelWriteInflux, channelWriteClickHouse, channelWriteStdout,
+		channelWriteCSV, channelWriteLoki, channelWriteCouchDB)
+	result := proc(ctx, conf.LogFiles, minTimestamp)
+	// all the output channels are closed now so once the consumers
+	// finish, all the writes have been confirmed
 	wg.Wait()
-	log.Info().Msgf("Ignored %d non-loggable entries (bots, static files etc.)", processor.numNonLoggable)
+	if options.reindex {
+		if confirmations.HasFailures() {
+			log.Error().
+				Err(confirmations.FirstError()).
+				Int("numFailedWrites", confirmations.NumFailed()).
+				Msg("some data failed to be written during reindexing - please run the reindexing again")
+
+		} else if result.Interrupted {
+			log.Warn().
+				Time("resumeTime", time.Unix(result.ResumeTime, 0)).
+				Msg("reindexing exceeded its max. duration - use the resume time as -from-time to continue")
+		}
+
+	} else if confirmations.HasFailures() {
+		// we cannot tell which records have been lost so the worklog
+		// is kept as is and the next run reprocesses the same data
+		// (records are stored with idempotent IDs)
+		log.Error().
+			Err(confirmations.FirstError()).
+			Int("numFailedWrites", confirmations.NumFailed()).
+			Msg("some data failed to be written - worklog not advanced, next run will start from the previous checkpoint")
+
+	} else if result.Interrupted {
+		log.Warn().
+			Dur("maxDuration", options.maxDuration).
+			Time("lastRecordTime", time.Unix(result.LastRecordTime, 0)).
+			Msg("batch run exceeded its max. duration, stopped - next run will continue from the first unfinished file")
+		// the checkpoint points to the start of the first unfinished file
+		// so it is selected again (re-read records keep their IDs)
+		if result.ResumeTime > -1 {
+			if err := worklog.SaveTimestamp(result.ResumeTime); err != nil {
+				log.Error().Err(err).Msg("failed to save worklog")
+			}
+		}
+
+	} else if err := worklog.Save(); err != nil {
+		log.Error().Err(err).Msg("failed to save worklog")
+	}
+	if options.dryRunDiff {
+		fmt.Printf("\nsummary - %s\n", diffStats)
+	}
+	ignored := processor.ignored.Counts()
+	ignored.Add(result.Ignored)
+	log.Info().
+		Int64("total", ignored.Total()).
+		Any("reasons", ignored).
+		Msg("ignored entries")
 	stateData := buffStorage.GetStateData(time.Now())
 	if stateData != nil && !reflect.ValueOf(stateData).IsNil() {
 		log.Debug().Any("report", buffStorage.GetStateData(time.Now()).Report()).Msg("state report")
@@ -47,6 +47,13 @@ func wrapInputRecords(input []servicelog.InputRecord) []dbscan.Point {
 	return ans
 }
 
+// ClusterMembersReceiver is implemented by records interested
+// in all the records of the cluster they represent (e.g. to calculate
+// session metrics)
+type ClusterMembersReceiver interface {
+	SetClusterMembers(members []servicelog.InputRecord)
+}
+
 func Analyze(
 	minDensity int, epsilon float64, input []servicelog.InputRecord,
 ) []servicelog.InputRecord {
@@ -56,6 +63,13 @@ func Analyze(
 	for i, cl := range clusters {
 		rec := (cl[0].(ClusterableRecord)).rec
 		rec.SetCluster(len(cl))
+		if tRec, ok := rec.(ClusterMembersReceiver); ok {
+			members := make([]servicelog.InputRecord, len(cl))
+			for j, item := range cl {
+				members[j] = item.(ClusterableRecord).rec
+			}
+			tRec.SetClusterMembers(members)
+		}
 		ans[i] = rec
 	}
 	return ans
@@ -16,15 +16,27 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"time"
 
 	"klogproc/common"
 	"klogproc/fsop"
 	"klogproc/load/batch"
+	"klogproc/load/httpin"
 	"klogproc/load/tail"
+	"klogproc/monitoring"
+	"klogproc/notifications"
+	"klogproc/save/clickhouse"
+	"klogproc/save/couchdb"
+	"klogproc/save/csv"
+	"klogproc/save/deadletter"
 	"klogproc/save/elastic"
 	"klogproc/save/influx"
+	"klogproc/save/loki"
+	"klogproc/save/stdout"
+	"klogproc/servicelog"
 
 	"github.com/czcorpus/cnc-gokit/mail"
 	conomiClient "github.com/czcorpus/conomi/client"
@@ -41,26 +53,89 @@ const (
 	ActionHelp             = "help"
 	ActionVersion          = "version"
 	ActionTestNotification = "test-notification"
+	ActionValidateConfig   = "validate-config"
+	ActionStats            = "stats"
+	ActionCount            = "count"
+	ActionHTTP             = "http"
+	ActionTransformIndex   = "transform-index"
+	ActionReindex          = "reindex"
+	ActionReplay           = "replay"
 
 	DefaultTimeZone = "Europe/Prague"
+
+	defaultGeoIPReloadIntervalSecs = 300
 )
 
 // Main describes klogproc's configuration
 type Main struct {
-	LogFiles           *batch.Conf                    `json:"logFiles"`
-	LogTail            *tail.Conf                     `json:"logTail"`
-	GeoIPDbPath        string                         `json:"geoIpDbPath"`
-	AnonymousUsers     []int                          `json:"anonymousUsers"`
-	LogPath            string                         `json:"logPath"`
-	LogLevel           string                         `json:"logLevel"`
-	CustomConfDir      string                         `json:"customConfDir"`
-	RecUpdate          elastic.DocUpdConf             `json:"recordUpdate"`
-	RecRemove          elastic.DocRemConf             `json:"recordRemove"`
-	ElasticSearch      elastic.ConnectionConf         `json:"elasticSearch"`
-	InfluxDB           influx.ConnectionConf          `json:"influxDb"`
-	EmailNotification  *mail.NotificationConf         `json:"emailNotification"`
-	ConomiNotification *conomiClient.ConomiClientConf `json:"conomiNotification"`
-	TimeZone           string                         `json:"timeZone"`
+	LogFiles            *batch.Conf                    `json:"logFiles"`
+	LogTail             *tail.Conf                     `json:"logTail"`
+	HTTPIngest          *httpin.Conf                   `json:"httpIngest"`
+	GeoIPDbPath         string                         `json:"geoIpDbPath"`
+	AnonymousUsers      []int                          `json:"anonymousUsers"`
+	LogPath             string                         `json:"logPath"`
+	LogLevel            string                         `json:"logLevel"`
+	CustomConfDir       string                         `json:"customConfDir"`
+	RecUpdate           elastic.DocUpdConf             `json:"recordUpdate"`
+	RecRemove           elastic.DocRemConf             `json:"recordRemove"`
+	IndexTransform      *elastic.IndexTransformConf    `json:"indexTransform"`
+	ElasticSearch       elastic.ConnectionConf         `json:"elasticSearch"`
+	InfluxDB            influx.ConnectionConf          `json:"influxDb"`
+	ClickHouse          clickhouse.ConnectionConf      `json:"clickHouse"`
+	Loki                loki.ConnectionConf            `json:"loki"`
+	CouchDB             couchdb.CouchConf              `json:"couchDb"`
+	Stdout              stdout.Conf                    `json:"stdout"`
+	CSV                 csv.Conf                       `json:"csv"`
+	DeadLetter          deadletter.Conf                `json:"deadLetter"`
+	EmailNotification   *mail.NotificationConf         `json:"emailNotification"`
+	ConomiNotification  *conomiClient.ConomiClientConf `json:"conomiNotification"`
+	WebhookNotification *notifications.WebhookConf     `json:"webhookNotification"`
+	TimeZone            string                         `json:"timeZone"`
+	Monitoring          monitoring.Conf                `json:"monitoring"`
+
+	// AnonymizeIP specifies whether client IP addresses should be masked
+	// (the last octet for IPv4, the last 80 bits for IPv6) before storing.
+	// GeoIP lookup is still performed using the full address.
+	AnonymizeIP bool `json:"anonymizeIp"`
+
+	// InstanceID optionally identifies this klogproc instance (node).
+	// If set, it is stored along with each record as `instanceId`.
+	InstanceID string `json:"instanceId"`
+
+	// InstanceIDInRecordID specifies whether InstanceID should be
+	// incorporated into record IDs so that otherwise identical records
+	// from different instances do not overwrite each other. Please note
+	// that enabling this changes IDs of all the newly stored records.
+	InstanceIDInRecordID bool `json:"instanceIdInRecordId"`
+
+	// TimeZoneForLogs specifies whether `timeZone` should be also used
+	// to interpret datetime values of log records without explicit time zone
+	// information. Files with their own `tzShift` are not affected.
+	TimeZoneForLogs bool `json:"timeZoneForLogs"`
+
+	// IgnoreActions specifies (per app type) actions which should not
+	// be stored (e.g. health checks). Values are either exact action names
+	// or regular expressions enclosed in slashes (e.g. `/^ajax_/`).
+	IgnoreActions map[string][]string `json:"ignoreActions"`
+
+	// GeoIPReloadIntervalSecs specifies how often (in the `tail` and `http`
+	// actions) klogproc checks whether the GeoIP database file has changed
+	// and reloads it. If zero, a default value is used. A negative value
+	// disables reloading.
+	GeoIPReloadIntervalSecs int `json:"geoIpReloadIntervalSecs"`
+}
+
+// GeoIPReloadInterval returns a configured interval of GeoIP database
+// file checks (or a default one if not configured). Zero means the database
+// should not be reloaded.
+func (c *Main) GeoIPReloadInterval() time.Duration {
+	if c.GeoIPReloadIntervalSecs < 0 {
+		return 0
+	}
+	if c.GeoIPReloadIntervalSecs == 0 {
+		return time.Duration(defaultGeoIPReloadIntervalSecs) * time.Second
+	}
+	return time.Duration(c.GeoIPReloadIntervalSecs) * time.Second
 }
 
 // HasInfluxOut tests whether an InfluxDB
@@ -77,40 +152,121 @@ func (c *Main) TimezoneLocation() *time.Location {
 	return loc
 }
 
-// Validate checks for some essential config properties
+// Validate checks for some essential config properties and returns
+// all the found problems. Some missing optional values are replaced
+// by their defaults.
 // TODO test additional important items
-func Validate(conf *Main, action string) {
-	var err error
+func Validate(conf *Main, action string) []error {
+	ans := make([]error, 0, 10)
+	addErr := func(prefix string, err error) {
+		ans = append(ans, fmt.Errorf("%s: %w", prefix, err))
+	}
 	if conf.ElasticSearch.IsConfigured() {
-		err = conf.ElasticSearch.Validate()
-		if err != nil {
-			log.Fatal().Msgf("%s", err)
+		if err := conf.ElasticSearch.Validate(); err != nil {
+			addErr("elasticSearch", err)
 		}
 	}
 	if conf.InfluxDB.IsConfigured() {
-		err = conf.InfluxDB.Validate()
-		if err != nil {
-			log.Fatal().Msgf("%s", err)
+		if err := conf.InfluxDB.Validate(); err != nil {
+			addErr("influxDb", err)
+		}
+	}
+	if conf.ClickHouse.IsConfigured() {
+		if err := conf.ClickHouse.Validate(); err != nil {
+			addErr("clickHouse", err)
+		}
+	}
+	if conf.Loki.IsConfigured() {
+		if err := conf.Loki.Validate(); err != nil {
+			addErr("loki", err)
+		}
+	}
+	if conf.CouchDB.IsConfigured() {
+		if err := conf.CouchDB.Validate(); err != nil {
+			addErr("couchDb", err)
+		}
+	}
+	if conf.InstanceIDInRecordID && conf.InstanceID == "" {
+		ans = append(ans, errors.New("instanceIdInRecordId requires instanceId to be set"))
+	}
+	if conf.CSV.IsConfigured() {
+		if err := conf.CSV.Validate(); err != nil {
+			addErr("csv", err)
+		}
+		if action == ActionTail || action == ActionHTTP {
+			log.Warn().Msg("CSV output is supported only in the `batch` action, ignoring")
+		}
+	}
+	if conf.DeadLetter.IsConfigured() {
+		if err := conf.DeadLetter.Validate(&conf.ElasticSearch); err != nil {
+			addErr("deadLetter", err)
 		}
 	}
 	if !fsop.IsFile(conf.GeoIPDbPath) {
-		log.Fatal().Msgf("Invalid GeoIPDbPath: '%s'", conf.GeoIPDbPath)
+		ans = append(ans, fmt.Errorf("geoIpDbPath: file '%s' not found", conf.GeoIPDbPath))
 	}
 	if action == ActionBatch && conf.LogFiles == nil {
-		log.Fatal().Msg("missing configuration data for the `batch` action")
+		ans = append(ans, errors.New("missing configuration data for the `batch` action"))
+	}
+	if action == ActionReindex && conf.LogFiles == nil {
+		ans = append(ans, errors.New("missing configuration data (logFiles) for the `reindex` action"))
+	}
+	if action == ActionReplay {
+		if conf.LogFiles == nil {
+			ans = append(ans, errors.New("missing configuration data (logFiles) for the `replay` action"))
+		}
+		if !conf.ElasticSearch.IsConfigured() {
+			ans = append(ans, errors.New("the `replay` action requires ElasticSearch to be configured"))
+		}
 	}
 	if action == ActionTail && conf.LogTail == nil {
-		log.Fatal().Msg("missing configuration data for the `tail` action")
+		ans = append(ans, errors.New("missing configuration data for the `tail` action"))
 	}
 	if conf.LogTail != nil {
-		err := conf.LogTail.Validate()
-		if err != nil {
-			log.Fatal().Err(err).Msg("failed to validate `tail` action configuration")
+		if err := conf.LogTail.ValidateGlobals(); err != nil {
+			addErr("logTail", err)
+		}
+		if _, err := conf.LogTail.FullFiles(); err != nil {
+			addErr("logTail.files", err)
+		}
+		for i, fc := range conf.LogTail.Files {
+			if err := fc.Validate(); err != nil {
+				addErr(fmt.Sprintf("logTail.files[%d]", i), err)
+			}
+		}
+	}
+	if action == ActionTransformIndex {
+		if !conf.ElasticSearch.IsConfigured() {
+			ans = append(ans, errors.New("the `transform-index` action requires ElasticSearch to be configured"))
+		}
+		if !conf.IndexTransform.IsConfigured() {
+			ans = append(ans, errors.New("missing configuration data for the `transform-index` action"))
+		}
+	}
+	if conf.IndexTransform.IsConfigured() {
+		if err := conf.IndexTransform.Validate(); err != nil {
+			addErr("indexTransform", err)
+		}
+	}
+	if action == ActionHTTP && conf.HTTPIngest == nil {
+		ans = append(ans, errors.New("missing configuration data for the `http` action"))
+	}
+	if err := conf.Monitoring.Validate(); err != nil {
+		addErr("monitoring", err)
+	}
+	if conf.HTTPIngest != nil {
+		if err := conf.HTTPIngest.Validate(); err != nil {
+			addErr("httpIngest", err)
 		}
 	}
 	if conf.LogFiles != nil {
 		if err := conf.LogFiles.Validate(); err != nil {
-			log.Fatal().Err(err).Msg("logFiles validation error")
+			addErr("logFiles", err)
+		}
+	}
+	for appType, patterns := range conf.IgnoreActions {
+		if _, err := servicelog.NewActionFilter(appType, patterns); err != nil {
+			addErr(fmt.Sprintf("ignoreActions (%s)", appType), err)
 		}
 	}
 	if conf.TimeZone == "" {
@@ -118,6 +274,32 @@ func Validate(conf *Main, action string) {
 		log.Warn().Str("timezone", conf.TimeZone).
 			Msg("timeZone not specified, using default")
 	}
+	if _, err := time.LoadLocation(conf.TimeZone); err != nil {
+		addErr("timeZone", err)
+
+	} else if conf.TimeZoneForLogs {
+		conf.applyLogsTimeZone()
+	}
+	return ans
+}
+
+// applyLogsTimeZone sets the configured time zone as
+// a time correction of all the log files without own `tzShift`
+func (c *Main) applyLogsTimeZone() {
+	loc := c.TimezoneLocation()
+	if c.LogFiles != nil {
+		c.LogFiles.TZShift = c.LogFiles.TZShift.OrLocation(loc)
+	}
+	if c.LogTail != nil {
+		for i := range c.LogTail.Files {
+			c.LogTail.Files[i].TZShift = c.LogTail.Files[i].TZShift.OrLocation(loc)
+		}
+	}
+	if c.HTTPIngest != nil {
+		for i := range c.HTTPIngest.Apps {
+			c.HTTPIngest.Apps[i].TZShift = c.HTTPIngest.Apps[i].TZShift.OrLocation(loc)
+		}
+	}
 }
 
 // Load loads main configuration (either from a local fs or via http(s))
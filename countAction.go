@@ -0,0 +1,146 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"klogproc/config"
+	"klogproc/load/batch"
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext018"
+
+	"github.com/rs/zerolog/log"
+)
+
+const countDayFormat = "2006-01-02"
+
+type recordCountKey struct {
+	appType string
+	day     string
+}
+
+// recordCountTable contains numbers of processable records
+// per app type and day
+type recordCountTable map[recordCountKey]int
+
+// print writes the table sorted by app type and day followed
+// by a line with totals. Ignored records cannot be attributed
+// to any day so they are reported separately by reason (including
+// parse errors). Aggregate records (see `queryAggregates`) are
+// derived from the processable ones so they are not counted in
+// the table either.
+func (t recordCountTable) print(out io.Writer, ignored servicelog.IgnoredCounts, numAggregates int) {
+	keys := make([]recordCountKey, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].appType != keys[j].appType {
+			return keys[i].appType < keys[j].appType
+		}
+		return keys[i].day < keys[j].day
+	})
+	var total int
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "app type\tday\tprocessable\t")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%d\t\n", k.appType, k.day, t[k])
+		total += t[k]
+	}
+	fmt.Fprintf(w, "total\t\t%d\t\n", total)
+	w.Flush()
+	if numAggregates > 0 {
+		fmt.Fprintf(out, "\naggregate records (not included above): %d\n", numAggregates)
+	}
+	reasons := make([]servicelog.IgnoredReason, 0, len(ignored))
+	for k := range ignored {
+		reasons = append(reasons, k)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		return reasons[i] < reasons[j]
+	})
+	fmt.Fprintf(out, "\nignored (not included above): %d\n", ignored.Total())
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "    %s\t%d\t\n", reason, ignored[reason])
+	}
+	w.Flush()
+}
+
+// countResult is an outcome of runCountConsumer
+type countResult struct {
+	counts        recordCountTable
+	numAggregates int
+}
+
+// runCountConsumer counts records coming from the incoming channel
+// (i.e. the ones which would be written by the `batch` action).
+// The result is sent once the incoming channel is closed.
+func runCountConsumer(incoming <-chan *servicelog.BoundOutputRecord) <-chan countResult {
+	ans := make(chan countResult, 1)
+	go func() {
+		res := countResult{counts: make(recordCountTable)}
+		for rec := range incoming {
+			if strings.HasSuffix(rec.GetType(), kontext018.AggregateTypeSuffix) {
+				res.numAggregates++
+				continue
+			}
+			res.counts[recordCountKey{
+				appType: rec.GetType(),
+				day:     rec.GetTime().Format(countDayFormat),
+			}]++
+		}
+		ans <- res
+		close(ans)
+	}()
+	return ans
+}
+
+// runCountAction runs the batch processing of configured log file(s)
+// (logFiles section) without writing anything and prints numbers
+// of processable records per app type and day along with numbers
+// of ignored records by reason.
+func runCountAction(conf *config.Main, options *ProcessOptions) {
+	if conf.LogFiles == nil {
+		log.Fatal().Msg("missing configuration data (logFiles) for the `count` action")
+	}
+	if err := conf.LogFiles.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("logFiles validation error")
+	}
+	processor, err := newInspectionLogProcessor(conf)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to run count action")
+	}
+	channelCount := make(chan *servicelog.BoundOutputRecord)
+	countRes := runCountConsumer(channelCount)
+	proc := batch.CreateLogFileProcFunc(processor, options.datetimeRange, channelCount)
+	// note: similarly to the `stats` action, the worklog is ignored
+	// and all the records from configured files are counted
+	result := proc(context.Background(), conf.LogFiles, math.MinInt64)
+	res := <-countRes
+	ignored := processor.ignored.Counts()
+	ignored.Add(result.Ignored)
+	res.counts.print(os.Stdout, ignored, res.numAggregates)
+}
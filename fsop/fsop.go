@@ -75,6 +75,21 @@ func GetFileProps(filePath string) (inode int64, size int64, err error) {
 	if err != nil {
 		return -1, -1, err
 	}
+	return fileInfoProps(st, filePath)
+}
+
+// GetOpenFileProps is a variant of GetFileProps for an already opened
+// file. This is useful e.g. in case the file has been renamed or deleted
+// since opening (the inode remains the same).
+func GetOpenFileProps(f *os.File) (inode int64, size int64, err error) {
+	st, err := f.Stat()
+	if err != nil {
+		return -1, -1, err
+	}
+	return fileInfoProps(st, f.Name())
+}
+
+func fileInfoProps(st os.FileInfo, filePath string) (inode int64, size int64, err error) {
 	stat, ok := st.Sys().(*syscall.Stat_t)
 	if !ok {
 		return -1, -1, fmt.Errorf("Problem using syscall.Stat_t for file %s", filePath)
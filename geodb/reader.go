@@ -0,0 +1,128 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geodb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+	"github.com/rs/zerolog/log"
+)
+
+// Reader is a GeoIP database reader which reopens the database
+// once its file changes (e.g. after a regular MaxMind update)
+// so a long-running process can use the new data without restart.
+// It is safe for concurrent use.
+type Reader struct {
+	path  string
+	db    *geoip2.Reader
+	mtime time.Time
+	mutex sync.RWMutex
+}
+
+// City returns geographical data for the IP address
+// using the current version of the database
+func (r *Reader) City(ipAddress net.IP) (*geoip2.City, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.db == nil {
+		return nil, fmt.Errorf("GeoIP database %s is not open", r.path)
+	}
+	return r.db.City(ipAddress)
+}
+
+// ReloadIfChanged reopens the database in case its file has been
+// modified since the last (re)load. The current database is replaced
+// only if the new one is opened successfully. The returned flag tells
+// whether the database has been replaced.
+func (r *Reader) ReloadIfChanged() (bool, error) {
+	finfo, err := os.Stat(r.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to reload GeoIP database: %w", err)
+	}
+	r.mutex.RLock()
+	changed := !finfo.ModTime().Equal(r.mtime)
+	r.mutex.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	db, err := geoip2.Open(r.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to reload GeoIP database: %w", err)
+	}
+	r.mutex.Lock()
+	prev := r.db
+	r.db = db
+	r.mtime = finfo.ModTime()
+	r.mutex.Unlock()
+	if prev != nil {
+		prev.Close()
+	}
+	return true, nil
+}
+
+// WatchChanges periodically checks the database file and reloads it
+// in case it has changed. A failed reload is logged and the previous
+// database is kept. The function blocks until ctx is cancelled.
+func (r *Reader) WatchChanges(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.ReloadIfChanged()
+			if err != nil {
+				log.Error().Err(err).Str("path", r.path).Msg("keeping the previous GeoIP database")
+
+			} else if reloaded {
+				log.Info().Str("path", r.path).Msg("reloaded GeoIP database")
+			}
+		}
+	}
+}
+
+// Close closes the current database
+func (r *Reader) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.db == nil {
+		return nil
+	}
+	err := r.db.Close()
+	r.db = nil
+	return err
+}
+
+// Open opens a GeoIP database file
+func Open(path string) (*Reader, error) {
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &Reader{path: path, db: db, mtime: finfo.ModTime()}, nil
+}
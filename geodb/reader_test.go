@@ -0,0 +1,69 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package geodb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMissingFile(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
+
+func TestOpenInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.mmdb")
+	assert.NoError(t, os.WriteFile(path, []byte("foo"), 0644))
+	_, err := Open(path)
+	assert.Error(t, err)
+}
+
+func TestReloadUnchangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.mmdb")
+	assert.NoError(t, os.WriteFile(path, []byte("foo"), 0644))
+	finfo, err := os.Stat(path)
+	assert.NoError(t, err)
+	r := &Reader{path: path, mtime: finfo.ModTime()}
+	reloaded, err := r.ReloadIfChanged()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+}
+
+func TestFailedReloadKeepsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.mmdb")
+	assert.NoError(t, os.WriteFile(path, []byte("foo"), 0644))
+	prevMtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &Reader{path: path, mtime: prevMtime}
+	reloaded, err := r.ReloadIfChanged()
+	assert.Error(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, prevMtime, r.mtime)
+	// the next check must try to reload the file again
+	_, err = r.ReloadIfChanged()
+	assert.Error(t, err)
+}
+
+func TestCityWithoutDatabase(t *testing.T) {
+	r := &Reader{path: "db.mmdb"}
+	_, err := r.City(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"klogproc/config"
+	"klogproc/geodb"
+	"klogproc/load/httpin"
+	"klogproc/monitoring"
+	"klogproc/save"
+	"klogproc/servicelog"
+	"klogproc/users"
+
+	"github.com/rs/zerolog/log"
+)
+
+// httpIngestProcessor processes records posted via HTTP using
+// the same processing as for tailed files (see tailProcessor). Each request
+// is handled as a single "check" and the processor waits for all the records
+// to be written so the client can be informed about possible failures.
+type httpIngestProcessor struct {
+	tp *tailProcessor
+	// mutex serializes requests of the same app type
+	// (parsers and buffers are not meant for concurrent use)
+	mutex sync.Mutex
+}
+
+func (hp *httpIngestProcessor) ProcessLines(lines []string) (httpin.Result, error) {
+	hp.mutex.Lock()
+	defer hp.mutex.Unlock()
+	numErrors := hp.tp.NumErrors()
+	confirmChan, writer := hp.tp.OnCheckStart()
+	writeErr := make(chan error, 1)
+	go func() {
+		var err error
+		for msg := range confirmChan {
+			if confirm, ok := msg.(save.ConfirmMsg); ok && confirm.Error != nil && err == nil {
+				err = confirm.Error
+			}
+		}
+		writeErr <- err
+	}()
+	for i, line := range lines {
+		hp.tp.OnEntry(writer, line, servicelog.LogRange{Line: int64(i + 1)})
+	}
+	hp.tp.OnCheckStop(writer)
+	ans := httpin.Result{
+		NumLines:  len(lines),
+		NumErrors: int(hp.tp.NumErrors() - numErrors),
+	}
+	return ans, <-writeErr
+}
+
+func runHTTPAction(
+	conf *config.Main,
+	options *ProcessOptions,
+	geoDB *geodb.Reader,
+	userMap *users.UserMap,
+	finishEvt chan bool,
+) {
+	// the processing is shared with the `tail` action which
+	// reads some of the properties from its own configuration
+	procConf := *conf
+	procConf.LogTail = conf.HTTPIngest.TailConf()
+	logBuffers := make(map[string]servicelog.ServiceLogBuffer)
+	processors := make(map[string]httpin.Processor)
+	tailProcessors := make([]*tailProcessor, 0, len(conf.HTTPIngest.Apps))
+	for _, app := range conf.HTTPIngest.Apps {
+		if options.onlyAppType != "" && app.AppType != options.onlyAppType {
+			continue
+		}
+		tp := newTailProcessor(app.FileConf(), procConf, geoDB, userMap, logBuffers, options)
+		processors[app.AppType] = &httpIngestProcessor{tp: tp}
+		tailProcessors = append(tailProcessors, tp)
+	}
+	if len(processors) == 0 {
+		log.Error().Msgf("no apps of app type %s configured", options.onlyAppType)
+		finishEvt <- true
+		return
+	}
+	if conf.Monitoring.IsConfigured() {
+		monitoring.NewServer(&conf.Monitoring).Start()
+	}
+	srv := httpin.NewServer(conf.HTTPIngest, processors)
+	srv.Start()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+	log.Warn().Msg("Caught signal, shutting down HTTP ingestion")
+	srv.Stop()
+	for _, tp := range tailProcessors {
+		tp.OnQuit()
+	}
+	finishEvt <- true
+}
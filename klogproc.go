@@ -63,6 +63,36 @@ func updateRecords(conf *config.Main, options *ProcessOptions) {
 	}
 }
 
+func transformIndex(conf *config.Main, options *ProcessOptions) {
+	transformer, err := elastic.NewDocTransformer(conf.IndexTransform)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize record transformer")
+	}
+	defer transformer.Close()
+	client := elastic.NewIndexClient(&conf.ElasticSearch, conf.IndexTransform.SourceIndex)
+	filters := make([]*elastic.DocFilter, len(conf.IndexTransform.Filters))
+	for i := range conf.IndexTransform.Filters {
+		filters[i] = &conf.IndexTransform.Filters[i]
+	}
+	if len(filters) == 0 {
+		filters = append(filters, nil)
+	}
+	for _, filter := range filters {
+		totalWritten, err := client.TransformIndex(
+			conf.IndexTransform.TargetIndex, filter, transformer,
+			conf.ElasticSearch.ScrollTTL, conf.IndexTransform.ChunkSize(), options.dryRun)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Failed to transform records (written so far: %d)", totalWritten)
+		}
+		if options.dryRun {
+			log.Info().Msgf("%d items would be written to %s", totalWritten, conf.IndexTransform.TargetIndex)
+
+		} else {
+			log.Info().Msgf("Written %d items to %s", totalWritten, conf.IndexTransform.TargetIndex)
+		}
+	}
+}
+
 func removeRecords(conf *config.Main, options *ProcessOptions) {
 	client := elastic.NewClient(&conf.ElasticSearch)
 	for _, remConf := range conf.RecRemove.Filters {
@@ -141,7 +171,12 @@ func setupLog(path, level string) {
 
 func setup(confPath, action string) *config.Main {
 	conf := config.Load(confPath)
-	config.Validate(conf, action)
+	if errs := config.Validate(conf, action); len(errs) > 0 {
+		for _, err := range errs {
+			log.Error().Err(err).Msg("invalid configuration")
+		}
+		log.Fatal().Int("numProblems", len(errs)).Msg("failed to validate configuration")
+	}
 	llevel := "info"
 	if conf.LogLevel != "" {
 		llevel = conf.LogLevel
@@ -152,11 +187,18 @@ func setup(confPath, action string) *config.Main {
 
 func main() {
 	procOpts := new(ProcessOptions)
-	flag.BoolVar(&procOpts.dryRun, "dry-run", false, "Do not write data (only for manual updates - batch, docupdate, keyremove)")
+	flag.BoolVar(&procOpts.dryRun, "dry-run", false, "Do not write data (only for manual updates - batch, docupdate, keyremove, transform-index, replay)")
+	flag.BoolVar(&procOpts.dryRunDiff, "dry-run-diff", false, "In batch mode, do not write data but compare them with the ones stored in ElasticSearch")
+	flag.BoolVar(&procOpts.diffJSON, "dry-run-diff-json", false, "In the dry-run-diff mode, print the differences as JSON lines")
 	flag.BoolVar(&procOpts.worklogReset, "worklog-reset", false, "Use the provided worklog but reset it first")
 	fromTimestamp := flag.String("from-time", "", "Batch process only the records with datetime greater or equal to this time (UNIX timestamp, or YYYY-MM-DDTHH:mm:ss\u00B1hh:mm)")
+	sinceTime := flag.String("since", "", "Batch process only the records not older than this duration relative to the current time (e.g. 48h, 7d, 2w); cannot be combined with -from-time")
 	toTimestamp := flag.String("to-time", "", "Batch process only the records with datetime less or equal to this UNIX timestamp, or YYYY-MM-DDTHH:mm:ss\u00B1hh:mm)")
+	flag.StringVar(&procOpts.onlyAppType, "only-apptype", "", "In tail (http) mode, process only files (records) of the specified app type")
 	flag.BoolVar(&procOpts.analysisOnly, "analysis-only", false, "In batch mode, analyze logs for bots etc.")
+	flag.DurationVar(&procOpts.maxDuration, "max-duration", 0, "In batch mode, stop processing gracefully once the run exceeds the duration (e.g. 90m) and save the progress to the worklog")
+	flag.BoolVar(&procOpts.strictSchema, "strict-schema", false, "In batch mode, log a warning for each record field unknown to the parser (currently supported by KonText 0.18)")
+	flag.BoolVar(&procOpts.statsJSON, "stats-json", false, "In stats mode, print the statistics as JSON")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Klogproc - an utility for parsing and sending CNC app logs to ElasticSearch & InfluxDB\n\nUsage:\n\t%s [options] [action] [config.json]\n\nAavailable actions:\n\t%s\n\nOptions:\n",
@@ -164,10 +206,17 @@ func main() {
 				os.Args[0]),
 			strings.Join([]string{
 				config.ActionBatch,
+				config.ActionReindex,
+				config.ActionReplay,
 				config.ActionTail,
 				config.ActionRedis,
+				config.ActionHTTP,
 				config.ActionDocupdate,
 				config.ActionKeyremove,
+				config.ActionTransformIndex,
+				config.ActionValidateConfig,
+				config.ActionStats,
+				config.ActionCount,
 				config.ActionHelp,
 				config.ActionVersion,
 			}, ", "))
@@ -176,7 +225,7 @@ func main() {
 	flag.Parse()
 
 	var err error
-	procOpts.datetimeRange, err = batch.NewDateTimeRange(fromTimestamp, toTimestamp)
+	procOpts.datetimeRange, err = batch.NewDateTimeRange(fromTimestamp, toTimestamp, sinceTime)
 	if err != nil {
 		log.Fatal().Msgf("%s", err)
 	}
@@ -193,17 +242,31 @@ func main() {
 	case config.ActionDocremove:
 		conf = setup(flag.Arg(1), action)
 		removeRecords(conf, procOpts)
+	case config.ActionTransformIndex:
+		conf = setup(flag.Arg(1), action)
+		transformIndex(conf, procOpts)
 	case config.ActionKeyremove:
 		conf = setup(flag.Arg(1), action)
 		removeKeyFromRecords(conf, procOpts)
-	case config.ActionBatch, config.ActionTail, config.ActionRedis:
+	case config.ActionReplay:
+		conf = setup(flag.Arg(1), action)
+		runReplayAction(conf, procOpts)
+	case config.ActionReindex:
+		if procOpts.datetimeRange.From == nil {
+			log.Fatal().Msg("the reindex action requires -from-time or -since (and optionally -to-time)")
+		}
+		conf = setup(flag.Arg(1), action)
+		log.Print(startingServiceMsg)
+		processLogs(conf, action, procOpts)
+	case config.ActionBatch, config.ActionTail, config.ActionRedis, config.ActionHTTP:
 		conf = setup(flag.Arg(1), action)
 		log.Print(startingServiceMsg)
 		processLogs(conf, action, procOpts)
 	case config.ActionTestNotification:
 		conf = setup(flag.Arg(1), action)
 		notifier, err := notifications.NewNotifier(
-			conf.EmailNotification, conf.ConomiNotification, conf.TimezoneLocation())
+			conf.EmailNotification, conf.ConomiNotification, conf.WebhookNotification,
+			conf.TimezoneLocation())
 		if err != nil {
 			log.Fatal().Err(err).Msg("failed to initialize notifier for testing")
 		}
@@ -213,6 +276,18 @@ func main() {
 			map[string]any{"app": "klogproc", "dt": time.Now().In(conf.TimezoneLocation())},
 			"This is just a testing notification triggered by running `klogproc test-notification`",
 		)
+	case config.ActionValidateConfig:
+		setupLog("", "info")
+		conf = config.Load(flag.Arg(1))
+		runValidateConfigAction(conf)
+	case config.ActionStats:
+		setupLog("", "warn")
+		conf = config.Load(flag.Arg(1))
+		runStatsAction(conf, procOpts)
+	case config.ActionCount:
+		setupLog("", "warn")
+		conf = config.Load(flag.Arg(1))
+		runCountAction(conf, procOpts)
 	case config.ActionVersion:
 		fmt.Printf("Klogproc %s\nbuild date: %s\nlast commit: %s\n", version, build, gitCommit)
 	default:
@@ -0,0 +1,79 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import "fmt"
+
+// names of access log fields with a special meaning
+// (other names are stored in ParsedAccessLog.Extra)
+const (
+	FieldIPAddress = "ipAddress"
+	FieldIdent     = "ident"
+	FieldUsername  = "username"
+	FieldDatetime  = "datetime"
+	FieldRequest   = "request"
+	FieldStatus    = "status"
+	FieldBytes     = "bytes"
+	FieldReferrer  = "referrer"
+	FieldUserAgent = "userAgent"
+	FieldProcTime  = "procTime"
+)
+
+// DefaultFieldSpec describes the combined log format with an appended
+// request processing time (`rt=...`)
+var DefaultFieldSpec = FieldSpec{
+	FieldIPAddress,
+	FieldIdent,
+	FieldUsername,
+	FieldDatetime,
+	FieldRequest,
+	FieldStatus,
+	FieldBytes,
+	FieldReferrer,
+	FieldUserAgent,
+	FieldProcTime,
+}
+
+// FieldSpec specifies names of access log fields in the order they
+// appear in a line. Fields with other than the predefined names
+// (e.g. `requestId`) are stored in ParsedAccessLog.Extra.
+type FieldSpec []string
+
+// Validate tests whether the spec contains all the fields required
+// to create an input record and whether all the names are unique.
+// An empty spec is valid (the DefaultFieldSpec is used instead).
+func (spec FieldSpec) Validate() error {
+	if len(spec) == 0 {
+		return nil
+	}
+	used := make(map[string]bool)
+	for _, name := range spec {
+		if name == "" {
+			return fmt.Errorf("invalid access log field spec: empty field name")
+		}
+		if used[name] {
+			return fmt.Errorf("invalid access log field spec: duplicate field %s", name)
+		}
+		used[name] = true
+	}
+	for _, name := range []string{FieldIPAddress, FieldDatetime, FieldRequest} {
+		if !used[name] {
+			return fmt.Errorf("invalid access log field spec: missing required field %s", name)
+		}
+	}
+	return nil
+}
@@ -22,8 +22,6 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-
-	"github.com/rs/zerolog/log"
 )
 
 func testOpenQuot(c byte) byte {
@@ -57,30 +55,47 @@ func getProcTime(procTimeExpr string) (float32, error) {
 	return -1, fmt.Errorf("failed to parse proc. time %s", procTimeExpr)
 }
 
-// LineParser is a parser for reading KonText application logs
-type LineParser struct{}
+// LineParser is a parser for reading KonText application logs.
+// In the strict parsing mode, lines with extra tokens, unterminated
+// quoted tokens or a missing request processing time are rejected.
+type LineParser struct {
+	mode   servicelog.ParsingMode
+	fields FieldSpec
+}
+
+func (lp *LineParser) getFields() FieldSpec {
+	if len(lp.fields) == 0 {
+		return DefaultFieldSpec
+	}
+	return lp.fields
+}
 
-func (lp *LineParser) updateTokenAt(items []string, i int, value string) error {
+// setTokenAt sets a token at the position i. Tokens beyond the field spec
+// are appended (or rejected in the strict mode).
+func (lp *LineParser) setTokenAt(items []string, i int, value string) ([]string, error) {
 	if i < len(items) {
 		items[i] = value
-		return nil
+		return items, nil
+	}
+	if lp.mode.IsStrict() {
+		return items, fmt.Errorf("unexpected extra token %s", value)
 	}
-	log.Error().
-		Str("line", strings.Join(items, " ")).
-		Msgf("Apache log tokenizer failed to process line")
+	return append(items, value), nil
+}
 
-	return fmt.Errorf("failed to get token [%d] (num. available: [%d])", i, len(items))
+func isFieldSeparator(r rune) bool {
+	return r == ' ' || r == '\t'
 }
 
+// tokenize splits a line into tokens separated by spaces or tabs. The returned
+// slice contains at least as many items as there are fields in the spec.
 func (lp *LineParser) tokenize(s string) ([]string, error) {
-	items := make([]string, 10)
+	items := make([]string, len(lp.getFields()))
 	currQuoted := make([]string, 0, 30)
 	var currQuotChar byte
+	var err error
 	parsedPos := 0
-	for _, item := range strings.Split(s, " ") {
-		if len(item) == 0 {
-			continue
-		}
+	for _, item := range strings.FieldsFunc(s, isFieldSeparator) {
 		if currQuotChar == 0 {
 			closeChar := testOpenQuot(item[0])
 			if closeChar != 0 && item[len(item)-1] != closeChar {
@@ -88,23 +103,28 @@ func (lp *LineParser) tokenize(s string) ([]string, error) {
 				currQuotChar = item[0]
 
 			} else if closeChar != 0 && item[len(item)-1] == closeChar {
+				value := ""
 				if len(item) > 1 {
-					err := lp.updateTokenAt(items, parsedPos, item[1:len(item)-1])
-					if err != nil {
-						return []string{}, err
-					}
+					value = item[1 : len(item)-1]
+				}
+				items, err = lp.setTokenAt(items, parsedPos, value)
+				if err != nil {
+					return []string{}, err
 				}
 				parsedPos++
 
-			} else if closeChar == 0 && parsedPos < len(items) {
-				items[parsedPos] = item // TODO use updateTokenAt() here too?
+			} else {
+				items, err = lp.setTokenAt(items, parsedPos, item)
+				if err != nil {
+					return []string{}, err
+				}
 				parsedPos++
 			}
 
 		} else {
 			if isCloseQuot(currQuotChar, item[len(item)-1]) {
 				currQuoted = append(currQuoted, item[:len(item)-1])
-				err := lp.updateTokenAt(items, parsedPos, strings.Join(currQuoted, " "))
+				items, err = lp.setTokenAt(items, parsedPos, strings.Join(currQuoted, " "))
 				if err != nil {
 					return []string{}, err
 				}
@@ -117,6 +137,15 @@ func (lp *LineParser) tokenize(s string) ([]string, error) {
 			}
 		}
 	}
+	if lp.mode.IsStrict() {
+		if currQuotChar != 0 {
+			return []string{}, fmt.Errorf("unterminated quoted token")
+		}
+		if parsedPos < len(items) {
+			return []string{}, fmt.Errorf(
+				"missing tokens (expected %d, found %d)", len(items), parsedPos)
+		}
+	}
 	return items, nil
 }
 
@@ -130,9 +159,15 @@ type ParsedAccessLog struct {
 	HTTPVersion string
 	Path        string
 	URLArgs     url.Values
+	Status      int
 	Referrer    string
 	UserAgent   string
 	ProcTime    float32
+
+	// Extra contains fields not known to the parser (i.e. fields with
+	// custom names in the field spec and trailing fields beyond the spec
+	// which are stored under their zero-based position, e.g. `10`)
+	Extra map[string]string
 }
 
 // ParseLine parses a HTTP access log format line
@@ -147,6 +182,8 @@ type ParsedAccessLog struct {
 //  7. "https://www.korpus.cz/ske/css/jquery-ui.min.css"
 //  8. "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Ubuntu Chromium/76.0.3809.100 Chrome/76.0.3809.100 Safari/537.36"
 //  9. rt=0.012
+//
+// The fields (and their order) can be changed via a custom FieldSpec.
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*ParsedAccessLog, error) {
 	ans := &ParsedAccessLog{}
 	var err error
@@ -155,14 +192,49 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*ParsedAccessLog, erro
 	if err != nil {
 		return nil, servicelog.NewLineParsingError(lineNum, err.Error())
 	}
+	fields := lp.getFields()
+	var request, procTime, status string
+	for i, token := range tokens {
+		var name string
+		if i < len(fields) {
+			name = fields[i]
 
-	ans.IPAddress = tokens[0]
-	ans.Username = tokens[2]
-	ans.Datetime = tokens[3]
-	urlBlock := strings.Split(tokens[4], " ")
+		} else {
+			name = strconv.Itoa(i)
+		}
+		switch name {
+		case FieldIPAddress:
+			ans.IPAddress = token
+		case FieldUsername:
+			ans.Username = token
+		case FieldDatetime:
+			ans.Datetime = token
+		case FieldRequest:
+			request = token
+		case FieldReferrer:
+			ans.Referrer = token
+		case FieldUserAgent:
+			ans.UserAgent = token
+		case FieldProcTime:
+			procTime = token
+		case FieldStatus:
+			status = token
+		case FieldIdent, FieldBytes:
+			// not used
+		default:
+			if ans.Extra == nil {
+				ans.Extra = make(map[string]string)
+			}
+			ans.Extra[name] = token
+		}
+	}
+	urlBlock := strings.Split(request, " ")
 
 	var parsedURL *url.URL
-	if len(urlBlock) == 3 {
+	if len(urlBlock) != 3 && lp.mode.IsStrict() {
+		return nil, servicelog.NewLineParsingError(lineNum, "invalid request block")
+
+	} else if len(urlBlock) == 3 {
 		ans.HTTPMethod = urlBlock[0]
 		ans.HTTPVersion = urlBlock[2]
 		parsedURL, err = url.Parse(urlBlock[1])
@@ -177,8 +249,18 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*ParsedAccessLog, erro
 			return nil, servicelog.NewLineParsingError(lineNum, err.Error())
 		}
 	}
-	ans.Referrer = tokens[7]
-	ans.UserAgent = tokens[8]
-	ans.ProcTime, err = getProcTime(tokens[9])
+	if status != "" && status != "-" {
+		ans.Status, err = strconv.Atoi(status)
+		if err != nil && lp.mode.IsStrict() {
+			return nil, servicelog.NewLineParsingError(lineNum, fmt.Sprintf("invalid status %s", status))
+		}
+	}
+	ans.ProcTime, err = getProcTime(procTime)
 	return ans, err
 }
+
+// NewLineParser is a factory for LineParser. In case fields
+// is empty, the DefaultFieldSpec is used.
+func NewLineParser(mode servicelog.ParsingMode, fields FieldSpec) *LineParser {
+	return &LineParser{mode: mode, fields: fields}
+}
@@ -17,8 +17,11 @@
 package accesslog
 
 import (
+	"strings"
 	"testing"
 
+	"klogproc/servicelog"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,3 +52,73 @@ func TestRandomEntryWithoutRt(t *testing.T) {
 	assert.Equal(t, 10, len(tokens))
 	assert.Equal(t, "", tokens[len(tokens)-1])
 }
+
+func TestStrictModeRejectsMissingRt(t *testing.T) {
+	_, err := NewLineParser(servicelog.ParsingModeStrict, nil).ParseLine(entry2, 1)
+	assert.Error(t, err)
+	_, err = NewLineParser(servicelog.ParsingModeLenient, nil).ParseLine(entry2, 1)
+	assert.NoError(t, err)
+}
+
+func TestStrictModeRejectsExtraTokens(t *testing.T) {
+	line := entry1 + " extra"
+	_, err := NewLineParser(servicelog.ParsingModeStrict, nil).ParseLine(line, 1)
+	assert.Error(t, err)
+	parsed, err := NewLineParser(servicelog.ParsingModeLenient, nil).ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.465), parsed.ProcTime)
+
+	parsed, err = NewLineParser(servicelog.ParsingModeStrict, nil).ParseLine(entry1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "janedoe", parsed.Username)
+}
+
+func TestTabSeparatedEntry(t *testing.T) {
+	line := "10.0.3.50\t-\tjanedoe\t[17/May/2021:06:36:36 +0200]\t\"GET /foo?a=1 HTTP/2.0\"\t200\t9218\t\"-\"\t\"Mozilla/5.0 (X11)\"\trt=0.465"
+	parsed, err := NewLineParser(servicelog.ParsingModeStrict, nil).ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.3.50", parsed.IPAddress)
+	assert.Equal(t, "/foo", parsed.Path)
+	assert.Equal(t, "Mozilla/5.0 (X11)", parsed.UserAgent)
+	assert.Equal(t, float32(0.465), parsed.ProcTime)
+}
+
+func TestCustomFieldSpec(t *testing.T) {
+	fields := append(FieldSpec{}, DefaultFieldSpec...)
+	fields = append(fields, "requestId")
+	line := entry1 + ` "a8f3-11"`
+	parsed, err := NewLineParser(servicelog.ParsingModeStrict, fields).ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.465), parsed.ProcTime)
+	assert.Equal(t, map[string]string{"requestId": "a8f3-11"}, parsed.Extra)
+
+	parsed, err = NewLineParser(servicelog.ParsingModeLenient, nil).ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.465), parsed.ProcTime)
+	assert.Equal(t, map[string]string{"10": "a8f3-11"}, parsed.Extra)
+}
+
+func TestFieldSpecValidate(t *testing.T) {
+	assert.NoError(t, FieldSpec{}.Validate())
+	assert.NoError(t, DefaultFieldSpec.Validate())
+	assert.Error(t, FieldSpec{FieldIPAddress, FieldDatetime}.Validate())
+	assert.Error(t, FieldSpec{FieldIPAddress, FieldDatetime, FieldRequest, FieldRequest}.Validate())
+}
+
+func TestParseStatus(t *testing.T) {
+	rec, err := NewLineParser(servicelog.ParsingModeLenient, nil).ParseLine(entry1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, rec.Status)
+
+	line := strings.Replace(entry1, `HTTP/2.0" 200 9218`, `HTTP/2.0" - 9218`, 1)
+	rec, err = NewLineParser(servicelog.ParsingModeStrict, nil).ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rec.Status)
+
+	line = strings.Replace(entry1, `HTTP/2.0" 200 9218`, `HTTP/2.0" foo 9218`, 1)
+	_, err = NewLineParser(servicelog.ParsingModeStrict, nil).ParseLine(line, 1)
+	assert.Error(t, err)
+	rec, err = NewLineParser(servicelog.ParsingModeLenient, nil).ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rec.Status)
+}
@@ -20,22 +20,27 @@
 
 package alarm
 
-import "github.com/rs/zerolog/log"
+import (
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
 
 // BatchProcAlarm is a pseudo-alarm for batch processing which just
 // logs information about total number of logged errors during processing.
+// The alarm can be shared by concurrently processed files.
 type BatchProcAlarm struct {
-	numErr int
+	numErr atomic.Int64
 }
 
 func (bpa *BatchProcAlarm) OnError(message string) {
-	bpa.numErr++
+	bpa.numErr.Add(1)
 }
 
 func (bpa *BatchProcAlarm) Evaluate() {
-	log.Info().Msgf("number of logged errors: %d", bpa.numErr)
+	log.Info().Msgf("number of logged errors: %d", bpa.numErr.Load())
 }
 
 func (bpa *BatchProcAlarm) Reset() {
-	bpa.numErr = 0
+	bpa.numErr.Store(0)
 }
@@ -0,0 +1,165 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"klogproc/notifications"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RatioAlarmConf configures an alarm triggered by a high ratio
+// of failed lines (instead of an absolute number of logged errors)
+type RatioAlarmConf struct {
+
+	// MaxFailedPercent is the percentage of failed lines (0, 100]
+	// which, once exceeded, triggers the alarm
+	MaxFailedPercent float64 `json:"maxFailedPercent"`
+
+	// WindowSecs specifies a time window the ratio is evaluated within
+	WindowSecs int `json:"windowSecs"`
+
+	// MinLines is the minimum number of lines within a window
+	// for the ratio to be evaluated (to prevent alarms caused
+	// by a few broken lines at quiet times)
+	MinLines int `json:"minLines"`
+}
+
+func (conf *RatioAlarmConf) Validate() error {
+	if conf.MaxFailedPercent <= 0 || conf.MaxFailedPercent > 100 {
+		return errors.New("errorRatioAlarm.maxFailedPercent must be from the interval (0, 100]")
+	}
+	if conf.WindowSecs <= 0 {
+		return errors.New("errorRatioAlarm.windowSecs must be a positive number")
+	}
+	if conf.MinLines < 0 {
+		return errors.New("errorRatioAlarm.minLines must be a non-negative number")
+	}
+	return nil
+}
+
+// TailRatioAlarm counts processed and failed lines and in case the ratio
+// of failed lines within a time window exceeds a defined threshold,
+// a notification is triggered. Errors logged by the watched applications
+// (OnError) are not considered.
+type TailRatioAlarm struct {
+	conf        RatioAlarmConf
+	notifier    notifications.Notifier
+	fileInfo    tailFileDescriber
+	windowStart time.Time
+	numLines    int
+	numFailed   int
+	now         func() time.Time
+	mutex       sync.Mutex
+}
+
+// OnError is ignored by the alarm
+func (tra *TailRatioAlarm) OnError(message string) {}
+
+// OnLineProcessed registers a successfully processed line
+func (tra *TailRatioAlarm) OnLineProcessed() {
+	tra.mutex.Lock()
+	tra.numLines++
+	tra.mutex.Unlock()
+}
+
+// OnLineFailed registers a line which failed to be processed
+func (tra *TailRatioAlarm) OnLineFailed() {
+	tra.mutex.Lock()
+	tra.numLines++
+	tra.numFailed++
+	tra.mutex.Unlock()
+}
+
+// Evaluate tests whether the current window is over and if so, it
+// calculates the failed lines ratio and sends a notification in case
+// the ratio exceeds the configured limit. Then a new window is started.
+func (tra *TailRatioAlarm) Evaluate() {
+	tra.mutex.Lock()
+	defer tra.mutex.Unlock()
+	now := tra.now()
+	if now.Sub(tra.windowStart) < time.Duration(tra.conf.WindowSecs)*time.Second {
+		return
+	}
+	numLines, numFailed := tra.numLines, tra.numFailed
+	tra.resetWindow(now)
+	if numLines == 0 || numLines < tra.conf.MinLines {
+		return
+	}
+	failedPercent := float64(numFailed) / float64(numLines) * 100
+	if failedPercent <= tra.conf.MaxFailedPercent {
+		return
+	}
+	msg := strings.Builder{}
+	msg.WriteString(fmt.Sprintf(
+		"<p>Too many failed lines in file %s: %d of %d lines (%.2f%%, limit %.2f%%) during the last %d seconds.</p>",
+		tra.fileInfo.GetPath(), numFailed, numLines, failedPercent, tra.conf.MaxFailedPercent,
+		tra.conf.WindowSecs))
+	msg.WriteString("<p>(this message was automatically generated by Klogproc)</p>")
+	subj := fmt.Sprintf("Klogproc ERROR RATE alarm for file %s (type %s)", tra.fileInfo.GetPath(),
+		tra.fileInfo.GetAppType())
+	log.Info().Msgf("sending error rate alarm notification for %s", tra.fileInfo.GetPath())
+	err := tra.notifier.SendNotification(
+		tra.fileInfo.GetAppType(),
+		subj,
+		map[string]any{
+			"appType":       tra.fileInfo.GetAppType(),
+			"filePath":      tra.fileInfo.GetPath(),
+			"lineCount":     numLines,
+			"failedCount":   numFailed,
+			"failedPercent": failedPercent,
+		},
+		msg.String(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("")
+	}
+}
+
+func (tra *TailRatioAlarm) resetWindow(now time.Time) {
+	tra.windowStart = now
+	tra.numLines = 0
+	tra.numFailed = 0
+}
+
+// Reset clears the whole state of the alarm.
+func (tra *TailRatioAlarm) Reset() {
+	tra.mutex.Lock()
+	tra.resetWindow(tra.now())
+	tra.mutex.Unlock()
+}
+
+// NewTailRatioAlarm is a recommended factory for TailRatioAlarm type
+func NewTailRatioAlarm(
+	conf RatioAlarmConf,
+	fileInfo tailFileDescriber,
+	notifier notifications.Notifier,
+) *TailRatioAlarm {
+	return &TailRatioAlarm{
+		conf:        conf,
+		notifier:    notifier,
+		fileInfo:    fileInfo,
+		windowStart: time.Now(),
+		now:         time.Now,
+	}
+}
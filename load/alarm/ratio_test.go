@@ -0,0 +1,108 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testFileInfo struct{}
+
+func (fi testFileInfo) GetPath() string {
+	return "/var/log/app.log"
+}
+
+func (fi testFileInfo) GetAppType() string {
+	return "kontext"
+}
+
+type testNotifier struct {
+	metadata []map[string]any
+}
+
+func (n *testNotifier) SendNotification(tag, subject string, metadata map[string]any, paragraphs ...string) error {
+	n.metadata = append(n.metadata, metadata)
+	return nil
+}
+
+func newTestRatioAlarm(now *time.Time, notifier *testNotifier) *TailRatioAlarm {
+	ans := NewTailRatioAlarm(
+		RatioAlarmConf{MaxFailedPercent: 10, WindowSecs: 60, MinLines: 10},
+		testFileInfo{},
+		notifier,
+	)
+	ans.now = func() time.Time { return *now }
+	ans.windowStart = *now
+	return ans
+}
+
+func TestTailRatioAlarmFires(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	notifier := &testNotifier{}
+	a := newTestRatioAlarm(&now, notifier)
+	for i := 0; i < 16; i++ {
+		a.OnLineProcessed()
+	}
+	for i := 0; i < 4; i++ {
+		a.OnLineFailed()
+	}
+	a.Evaluate() // window not finished yet
+	assert.Len(t, notifier.metadata, 0)
+	now = now.Add(time.Minute)
+	a.Evaluate()
+	assert.Len(t, notifier.metadata, 1)
+	assert.Equal(t, 20, notifier.metadata[0]["lineCount"])
+	assert.Equal(t, 4, notifier.metadata[0]["failedCount"])
+	assert.Equal(t, 20.0, notifier.metadata[0]["failedPercent"])
+	// a new window has started
+	now = now.Add(time.Minute)
+	a.Evaluate()
+	assert.Len(t, notifier.metadata, 1)
+}
+
+func TestTailRatioAlarmBelowLimits(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	notifier := &testNotifier{}
+	a := newTestRatioAlarm(&now, notifier)
+	for i := 0; i < 95; i++ {
+		a.OnLineProcessed()
+	}
+	for i := 0; i < 5; i++ {
+		a.OnLineFailed()
+	}
+	now = now.Add(time.Minute)
+	a.Evaluate()
+	assert.Len(t, notifier.metadata, 0)
+
+	// too few lines to evaluate the ratio
+	for i := 0; i < 5; i++ {
+		a.OnLineFailed()
+	}
+	now = now.Add(time.Minute)
+	a.Evaluate()
+	assert.Len(t, notifier.metadata, 0)
+}
+
+func TestRatioAlarmConfValidate(t *testing.T) {
+	assert.NoError(t, (&RatioAlarmConf{MaxFailedPercent: 5, WindowSecs: 300}).Validate())
+	assert.Error(t, (&RatioAlarmConf{MaxFailedPercent: 0, WindowSecs: 300}).Validate())
+	assert.Error(t, (&RatioAlarmConf{MaxFailedPercent: 120, WindowSecs: 300}).Validate())
+	assert.Error(t, (&RatioAlarmConf{MaxFailedPercent: 5}).Validate())
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appconf
+
+import (
+	"fmt"
+
+	"klogproc/fsop"
+	"klogproc/load"
+	"klogproc/load/accesslog"
+	"klogproc/servicelog"
+)
+
+// ProcessingConf contains options specifying how records of a single
+// application are parsed and transformed. It is shared by all the ways
+// records are read (`batch` files, `tail` files and `http` ingestion) and
+// it is intended to be embedded in their respective configurations (the JSON
+// properties remain at the same level as the embedding configuration's ones).
+type ProcessingConf struct {
+	AppType string `json:"appType"`
+
+	// Version represents a major and minor version signature as used in semantic versioning
+	// (e.g. 0.15, 1.2)
+	Version       string                   `json:"version"`
+	TZShift       servicelog.TZShift       `json:"tzShift"`
+	Buffer        *load.BufferConf         `json:"buffer"`
+	ExcludeIPList servicelog.ExcludeIPList `json:"excludeIpList"`
+
+	// ExcludeStatus specifies HTTP status codes (e.g. `404`), ranges
+	// (`500-599`) or classes (`4xx`) of records which should be ignored.
+	// Applicable to app types with access-log based records (ske, wag 0.6,
+	// mapka 1 and 2).
+	ExcludeStatus servicelog.ExcludeStatusList `json:"excludeStatus"`
+
+	// QueryAggregates enables an additional aggregate record (keyed by
+	// corpus, action and day) for each KonText 0.18 record.
+	QueryAggregates bool `json:"queryAggregates"`
+
+	// ConversionActions specifies actions which should be marked
+	// as "conversions" in output records (currently supported
+	// by KonText and SkE)
+	ConversionActions servicelog.ConversionActionList `json:"conversionActions"`
+
+	// TraceIDField is an optional dot-separated path of a source log field
+	// containing a trace/correlation ID (e.g. `args.trace_id`). Currently
+	// supported by KonText 0.18.
+	TraceIDField string `json:"traceIdField"`
+
+	// InputFormat specifies how individual lines are decoded. By default,
+	// a parser native to the app type is used. With `jsonl`, each line
+	// is expected to be a full JSON record (supported only by app types
+	// with JSON-based logs).
+	InputFormat string `json:"inputFormat"`
+
+	// ParsingMode specifies how strictly lines are parsed (`lenient` - default,
+	// `strict` or `strictSchema`). In the strict mode, borderline conditions like
+	// extra tokens or unknown fields are reported as errors. The `strictSchema` mode
+	// is lenient but unknown fields are logged as warnings (KonText 0.18 only).
+	ParsingMode servicelog.ParsingMode `json:"parsingMode"`
+
+	// StoreRawInput specifies that each stored record should also
+	// contain the original log line (as the `rawInput` field) so it
+	// can be processed again later (see the `replay` action)
+	StoreRawInput bool `json:"storeRawInput"`
+
+	// ClientIP optionally specifies which request header is trusted
+	// to contain a client IP and which entry of a forwarded-for chain
+	// is used (see servicelog.ClientIPConf). By default, the order
+	// HTTP_X_FORWARDED_FOR, HTTP_REMOTE_ADDR, REMOTE_ADDR is used (where
+	// supported by the app type).
+	ClientIP *servicelog.ClientIPConf `json:"clientIp"`
+
+	// AccessLogFields optionally specifies names and order of fields
+	// in logs based on the HTTP access log format (see accesslog.FieldSpec).
+	// By default, the combined log format followed by `rt=...` is expected.
+	AccessLogFields accesslog.FieldSpec `json:"accessLogFields"`
+
+	// ScriptPath specifies an optional Lua script defining a `transform(input)`
+	// function called for each transformed record. The script can set output
+	// properties via `set_output_property(name, value)`. Currently supported
+	// by KonText 0.18.
+	ScriptPath string `json:"scriptPath"`
+
+	// PathTemplates specifies rules for creating low-cardinality templates
+	// of request paths (stored as `pathTemplate` along with the raw path).
+	// Currently supported by the `nginxjson` app type.
+	PathTemplates servicelog.PathTemplateRules `json:"pathTemplates"`
+
+	// FilterScriptPath specifies an optional Lua script defining
+	// a `filter(record)` and/or `should_record(input)` function.
+	// Records for which the function returns false are ignored (before
+	// any further analysis). The `filter` function is supported by all
+	// the app types, `should_record` currently by KonText 0.18.
+	FilterScriptPath string `json:"filterScriptPath"`
+
+	// SampleRate optionally specifies a fraction (0.0 - 1.0) of transformed
+	// records to be stored. Records are selected deterministically based on
+	// their ID, the other ones are ignored. By default, all the records are stored.
+	SampleRate servicelog.SampleRate `json:"sampleRate"`
+
+	// ProcTimeThreshold optionally specifies a minimum processing time
+	// (`minProcTime`) of records to be considered real requests and
+	// what to do with the other ones (`trivialRecordPolicy`)
+	servicelog.ProcTimeThreshold
+}
+
+// Validate checks the processing options. The buffer configuration
+// is not validated as its allowed form depends on the embedding
+// configuration (e.g. shared buffer references).
+func (pc *ProcessingConf) Validate() error {
+	if err := load.ValidateInputFormat(pc.InputFormat); err != nil {
+		return err
+	}
+	if err := pc.ParsingMode.Validate(); err != nil {
+		return err
+	}
+	if err := pc.AccessLogFields.Validate(); err != nil {
+		return err
+	}
+	if err := pc.ClientIP.Validate(); err != nil {
+		return err
+	}
+	if err := pc.PathTemplates.Validate(); err != nil {
+		return err
+	}
+	if pc.ScriptPath != "" && !fsop.IsFile(pc.ScriptPath) {
+		return fmt.Errorf("script %s not found", pc.ScriptPath)
+	}
+	if err := pc.ProcTimeThreshold.Validate(); err != nil {
+		return err
+	}
+	if err := pc.SampleRate.Validate(); err != nil {
+		return err
+	}
+	if err := pc.ExcludeStatus.Validate(); err != nil {
+		return err
+	}
+	if pc.FilterScriptPath != "" && !fsop.IsFile(pc.FilterScriptPath) {
+		return fmt.Errorf("filter script %s not found", pc.FilterScriptPath)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import "klogproc/servicelog"
+
+// clientIPConfLineParser passes a client IP configuration to records
+// (implementing servicelog.ClientIPConfReceiver) parsed by a wrapped parser
+type clientIPConfLineParser struct {
+	lp   LineParser
+	conf *servicelog.ClientIPConf
+}
+
+// ParseLine parses a passed line of a respective log
+func (parser *clientIPConfLineParser) ParseLine(s string, lineNum int64) (servicelog.InputRecord, error) {
+	rec, err := parser.lp.ParseLine(s, lineNum)
+	if err != nil {
+		return rec, err
+	}
+	if tRec, ok := rec.(servicelog.ClientIPConfReceiver); ok {
+		tRec.SetClientIPConf(parser.conf)
+	}
+	return rec, nil
+}
+
+// WrapWithClientIPConf makes parsed records determine their client IP
+// based on the provided configuration. With no configuration, the parser
+// is returned unchanged (i.e. the default precedence is used).
+func WrapWithClientIPConf(lp LineParser, conf *servicelog.ClientIPConf) LineParser {
+	if conf == nil {
+		return lp
+	}
+	return &clientIPConfLineParser{lp: lp, conf: conf}
+}
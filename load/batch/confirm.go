@@ -0,0 +1,69 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"sync"
+
+	"klogproc/save"
+)
+
+// WriteConfirmations collects confirmations of writes to
+// the configured outputs so the batch worklog can be advanced
+// only in case all the processed records have been written.
+type WriteConfirmations struct {
+	mutex      sync.Mutex
+	numWritten int
+	numFailed  int
+	firstErr   error
+}
+
+// Add registers a single confirmation message
+func (wc *WriteConfirmations) Add(msg save.ConfirmMsg) {
+	wc.mutex.Lock()
+	defer wc.mutex.Unlock()
+	if msg.Error != nil {
+		wc.numFailed++
+		if wc.firstErr == nil {
+			wc.firstErr = msg.Error
+		}
+		return
+	}
+	wc.numWritten++
+}
+
+// HasFailures tests whether any of the writes failed
+func (wc *WriteConfirmations) HasFailures() bool {
+	wc.mutex.Lock()
+	defer wc.mutex.Unlock()
+	return wc.numFailed > 0
+}
+
+// NumFailed returns number of failed writes (chunks or records
+// based on the respective output)
+func (wc *WriteConfirmations) NumFailed() int {
+	wc.mutex.Lock()
+	defer wc.mutex.Unlock()
+	return wc.numFailed
+}
+
+// FirstError returns the first reported write error (if any)
+func (wc *WriteConfirmations) FirstError() error {
+	wc.mutex.Lock()
+	defer wc.mutex.Unlock()
+	return wc.firstErr
+}
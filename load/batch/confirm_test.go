@@ -0,0 +1,41 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"errors"
+	"testing"
+
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteConfirmations(t *testing.T) {
+	var wc WriteConfirmations
+	wc.Add(save.ConfirmMsg{FilePath: "/var/log/app.log", Position: servicelog.LogRange{SeekEnd: 100, Written: true}})
+	assert.False(t, wc.HasFailures())
+	assert.NoError(t, wc.FirstError())
+
+	err1 := errors.New("bulk insert failed")
+	wc.Add(save.ConfirmMsg{FilePath: "/var/log/app.log", Position: servicelog.LogRange{SeekEnd: 200}, Error: err1})
+	wc.Add(save.ConfirmMsg{FilePath: "/var/log/app.log", Error: errors.New("other")})
+	assert.True(t, wc.HasFailures())
+	assert.Equal(t, 2, wc.NumFailed())
+	assert.Equal(t, err1, wc.FirstError())
+}
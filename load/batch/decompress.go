@@ -0,0 +1,85 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// logFile is an opened (and possibly decompressed) log file
+type logFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressor (if any) and the underlying file
+func (lf *logFile) Close() error {
+	var ans error
+	for _, c := range lf.closers {
+		if err := c.Close(); err != nil && ans == nil {
+			ans = err
+		}
+	}
+	return ans
+}
+
+// openLogFile opens a log file (or an S3 object in case of an `s3://` URI)
+// for reading. If autoDecompress is true, files with the `.gz`, `.bz2`
+// and `.zst` extensions are transparently decompressed.
+func openLogFile(filePath string, autoDecompress bool) (io.ReadCloser, error) {
+	var f io.ReadCloser
+	var err error
+	if isS3URI(filePath) {
+		f, err = openS3Object(filePath)
+
+	} else {
+		f, err = os.Open(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !autoDecompress {
+		return f, nil
+	}
+	switch {
+	case strings.HasSuffix(filePath, ".gz"):
+		rd, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open gzip file %s: %w", filePath, err)
+		}
+		return &logFile{Reader: rd, closers: []io.Closer{rd, f}}, nil
+	case strings.HasSuffix(filePath, ".bz2"):
+		return &logFile{Reader: bzip2.NewReader(f), closers: []io.Closer{f}}, nil
+	case strings.HasSuffix(filePath, ".zst"):
+		rd, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open zstd file %s: %w", filePath, err)
+		}
+		return &logFile{Reader: rd, closers: []io.Closer{rd.IOReadCloser(), f}}, nil
+	default:
+		return f, nil
+	}
+}
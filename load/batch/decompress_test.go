@@ -0,0 +1,94 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"klogproc/servicelog"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCompressedLog = "2017-01-31 19:19:22,930 [QUERY] INFO: {}\n"
+
+func writeCompressed(t *testing.T, path string, wrap func(io.Writer) (io.WriteCloser, error)) {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	w, err := wrap(f)
+	assert.NoError(t, err)
+	_, err = w.Write([]byte(testCompressedLog))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+}
+
+func readAllLogFile(t *testing.T, path string, autoDecompress bool) string {
+	f, err := openLogFile(path, autoDecompress)
+	assert.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestOpenLogFileGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "application.log.gz")
+	writeCompressed(t, path, func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	})
+	assert.Equal(t, testCompressedLog, readAllLogFile(t, path, true))
+}
+
+func TestOpenLogFileZstd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "application.log.zst")
+	writeCompressed(t, path, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+	assert.Equal(t, testCompressedLog, readAllLogFile(t, path, true))
+}
+
+func TestOpenLogFileAutoDecompressDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "application.log.zst")
+	assert.NoError(t, os.WriteFile(path, []byte(testCompressedLog), 0644))
+	assert.Equal(t, testCompressedLog, readAllLogFile(t, path, false))
+	_, err := io.ReadAll(func() io.Reader {
+		f, err := openLogFile(path, true)
+		assert.NoError(t, err)
+		return f
+	}())
+	assert.Error(t, err)
+}
+
+func TestLogFileMatchesCompressed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "application.log.zst")
+	writeCompressed(t, path, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+	tz := servicelog.NewTZShiftMinutes(0)
+	matches, err := LogFileMatches(path, 1485890000, true, tz, '\n', true)
+	assert.NoError(t, err)
+	assert.True(t, matches)
+	matches, err = LogFileMatches(path, 1485900000, true, tz, '\n', true)
+	assert.NoError(t, err)
+	assert.False(t, matches)
+}
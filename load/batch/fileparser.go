@@ -23,35 +23,84 @@ package batch
 
 import (
 	"bufio"
+	"context"
+	"io"
+	"klogproc/load"
 	"klogproc/servicelog"
-	"os"
 	"path/filepath"
 
 	"github.com/rs/zerolog/log"
 )
 
-// newParser creates a new instance of the Parser.
-// tzShift can be used to correct an incorrectly stored datetime
-func newParser(path string, tzShift int, appType string, version string, appErrRegister servicelog.AppErrorRegister) *Parser {
-	f, err := os.Open(path)
+// newParser creates a new instance of the Parser for the file
+// specified by path, configured according to conf.
+// conf.TZShift can be used to correct an incorrectly stored datetime
+func newParser(
+	path string,
+	conf *Conf,
+	delim byte,
+	appErrRegister servicelog.AppErrorRegister,
+) (*Parser, error) {
+	f, err := openLogFile(path, !conf.DisableAutoDecompression)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	sc, sizeTracker := newRecordScanner(f, delim)
+	lineParser, err := NewLineParser(
+		conf.AppType, conf.Version, conf.TraceIDField, conf.ParsingMode, conf.AccessLogFields,
+		appErrRegister)
 	if err != nil {
-		panic(err)
+		f.Close()
+		return nil, err
 	}
-	sc := bufio.NewScanner(f)
-	lineParser, err := NewLineParser(appType, version, appErrRegister)
+	lineParser, err = WrapWithInputFormat(lineParser, conf.InputFormat, conf.AppType, conf.Version)
 	if err != nil {
-		panic(err) // TODO
+		f.Close()
+		return nil, err
 	}
+	lineParser, err = WrapWithFraming(lineParser, conf.InputFraming)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	lineParser = WrapWithClientIPConf(lineParser, conf.ClientIP)
 	return &Parser{
-		recType:    appType,
-		fr:         sc,
-		tzShift:    tzShift,
-		fileName:   filepath.Base(f.Name()),
-		lineParser: lineParser,
+		recType:       conf.AppType,
+		fr:            sc,
+		recordSize:    sizeTracker,
+		src:           f,
+		tzShift:       conf.TZShift,
+		fileName:      filepath.Base(path),
+		lineParser:    lineParser,
+		idStrategy:    conf.RecordIDStrategy,
+		idSourceKey:   conf.RecordIDSourceKey,
+		storeRawInput: conf.StoreRawInput,
+	}, nil
+}
+
+// recordSizeTracker wraps a bufio.SplitFunc and remembers how many bytes
+// the last returned record consumed. This includes the delimiter except
+// for the last record of a file which does not end with the delimiter.
+type recordSizeTracker struct {
+	split    bufio.SplitFunc
+	lastSize int
+}
+
+func (t *recordSizeTracker) scan(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := t.split(data, atEOF)
+	if token != nil {
+		t.lastSize = advance
 	}
+	return advance, token, err
+}
+
+// newRecordScanner creates a scanner splitting src into records separated
+// by delim along with a tracker providing the size of the last scanned record
+func newRecordScanner(src io.Reader, delim byte) (*bufio.Scanner, *recordSizeTracker) {
+	tracker := &recordSizeTracker{split: load.ScanRecords(delim)}
+	sc := bufio.NewScanner(src)
+	sc.Split(tracker.scan)
+	return sc, tracker
 }
 
 // LineParser represents an object able to parse an individual
@@ -65,22 +114,81 @@ type LineParser interface {
 // this information is also required to process the log properly.
 type Parser struct {
 	fr         *bufio.Scanner
+	recordSize *recordSizeTracker
+	src        io.Closer
 	fileName   string
-	tzShift    int
+	tzShift    servicelog.TZShift
 	lineParser LineParser
 	recType    string
+	idStrategy servicelog.RecordIDStrategy
+
+	// idSourceKey - see Conf.RecordIDSourceKey
+	idSourceKey string
+
+	// storeRawInput specifies whether the original lines
+	// are attached to the output records
+	storeRawInput bool
+}
+
+// Close closes the source file (if any)
+func (p *Parser) Close() error {
+	if p.src != nil {
+		return p.src.Close()
+	}
+	return nil
 }
 
 // Parse runs the parsing process based on provided minimum accepted record
 // time, record type (which is just passed to ElasticSearch) and a
 // provided LogInterceptor).
-func (p *Parser) Parse(fromTimestamp int64, proc LogItemProcessor, datetimeRange DatetimeRange, outputs ...chan *servicelog.BoundOutputRecord) {
+// In case the ctx is cancelled, the parsing stops before the next line
+// and the result is marked as interrupted. The resume time of such a result
+// is derived from the first line of the file (the same way the file selection
+// does it) so a next run selects the file again.
+func (p *Parser) Parse(
+	ctx context.Context,
+	fromTimestamp int64,
+	proc LogItemProcessor,
+	datetimeRange DatetimeRange,
+	outputs ...chan *servicelog.BoundOutputRecord,
+) ProcResult {
+	ans := ProcResult{LastRecordTime: -1, ResumeTime: -1, Ignored: make(servicelog.IgnoredCounts)}
+	var offset int64
+	fileStartTime := int64(-1)
 	for i := int64(0); p.fr.Scan(); i++ {
-		rec, err := p.lineParser.ParseLine(p.fr.Text(), i)
+		select {
+		case <-ctx.Done():
+			ans.Interrupted = true
+			if fileStartTime > -1 {
+				ans.ResumeTime = fileStartTime
+				if fromTimestamp > ans.ResumeTime {
+					ans.ResumeTime = fromTimestamp
+				}
+			}
+			return ans
+		default:
+		}
+		ans.NumLines++
+		filePos := servicelog.LogRange{
+			SeekStart: offset,
+			SeekEnd:   offset + int64(p.recordSize.lastSize),
+			Line:      i + 1,
+		}
+		offset = filePos.SeekEnd
+		line := load.NormalizeLine(p.fr.Text(), i == 0)
+		if i == 0 {
+			fileStartTime, _ = importTimeFromLine(line, p.tzShift)
+		}
+		rec, err := p.lineParser.ParseLine(line, i)
+		if err == servicelog.ErrEmptyLine {
+			ans.Ignored[servicelog.IgnoredReasonEmptyLine]++
+			continue
+		}
 		if err == nil {
 			recTime := rec.GetTime()
 			if datetimeRange.From != nil && recTime.Before(*datetimeRange.From) {
 				log.Info().Msgf("Skipping line %d (timestamp: %v) due to required time range", i, recTime)
+				ans.Ignored[servicelog.IgnoredReasonOutOfTimeRange]++
 				continue
 			}
 			if datetimeRange.To != nil && recTime.After(*datetimeRange.To) {
@@ -88,16 +196,38 @@ func (p *Parser) Parse(fromTimestamp int64, proc LogItemProcessor, datetimeRange
 					i, recTime, datetimeRange.To)
 				break
 			}
+			if fileStartTime == -1 {
+				fileStartTime = recTime.Unix()
+			}
 			if recTime.Unix() >= fromTimestamp {
-				outRecs := proc.ProcItem(rec, p.tzShift)
-				for _, outRec := range outRecs {
+				if recTime.Unix() > ans.LastRecordTime {
+					ans.LastRecordTime = recTime.Unix()
+				}
+				outRecs := proc.ProcItem(rec, p.tzShift.MinutesAt(recTime))
+				var rawInput string
+				if p.storeRawInput {
+					rawInput = p.fr.Text()
+				}
+				for j, outRec := range outRecs {
 					for _, output := range outputs {
-						output <- &servicelog.BoundOutputRecord{Rec: outRec, FilePath: p.fileName}
+						output <- &servicelog.BoundOutputRecord{
+							Rec:               outRec,
+							FilePath:          p.fileName,
+							FilePos:           filePos,
+							InstanceID:        proc.GetInstanceID(),
+							InstanceIDInRecID: proc.InstanceIDInRecID(),
+							IDStrategy:        p.idStrategy,
+							IDSourceKey:       p.idSourceKey,
+							OutputIdx:         j,
+							RawInput:          rawInput,
+						}
 					}
 				}
 			}
 
 		} else {
+			ans.NumParseErrors++
+			ans.Ignored[servicelog.IgnoredReasonParseError]++
 			switch tErr := err.(type) {
 			case servicelog.LineParsingError:
 				log.Info().Msgf("file %s, %s", p.fileName, tErr)
@@ -107,4 +237,5 @@ func (p *Parser) Parse(fromTimestamp int64, proc LogItemProcessor, datetimeRange
 
 		}
 	}
+	return ans
 }
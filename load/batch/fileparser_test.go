@@ -0,0 +1,162 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"klogproc/load/appconf"
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext013"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lineRecordingParser struct {
+	lines []string
+}
+
+func (lp *lineRecordingParser) ParseLine(s string, lineNum int64) (servicelog.InputRecord, error) {
+	lp.lines = append(lp.lines, s)
+	return nil, errors.New("recorded only")
+}
+
+func TestParserStripsBOMAndCRLF(t *testing.T) {
+	lp := &lineRecordingParser{}
+	fr, recordSize := newRecordScanner(strings.NewReader("\ufeff{\"a\":1}\r\n{\"b\":2}\t\r\n"), '\n')
+	p := &Parser{
+		fr:         fr,
+		recordSize: recordSize,
+		fileName:   "test.log",
+		lineParser: lp,
+	}
+	result := p.Parse(context.Background(), 0, nil, DatetimeRange{})
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, lp.lines)
+	assert.Equal(t, int64(2), result.NumLines)
+	assert.Equal(t, int64(2), result.NumParseErrors)
+	assert.Equal(t, int64(2), result.Ignored[servicelog.IgnoredReasonParseError])
+}
+
+func TestParserStopsOnCancelledContext(t *testing.T) {
+	lp := &lineRecordingParser{}
+	fr, recordSize := newRecordScanner(strings.NewReader("a\nb\n"), '\n')
+	p := &Parser{
+		fr:         fr,
+		recordSize: recordSize,
+		fileName:   "test.log",
+		lineParser: lp,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := p.Parse(ctx, 0, nil, DatetimeRange{})
+	assert.True(t, result.Interrupted)
+	assert.Equal(t, int64(-1), result.LastRecordTime)
+	assert.Empty(t, lp.lines)
+}
+
+type singleRecordProcessor struct{}
+
+func (p *singleRecordProcessor) ProcItem(logRec servicelog.InputRecord, tzShiftMin int) []servicelog.OutputRecord {
+	return []servicelog.OutputRecord{&kontext013.OutputRecord{}}
+}
+func (p *singleRecordProcessor) GetAppType() string      { return servicelog.AppTypeKontext }
+func (p *singleRecordProcessor) GetAppVersion() string   { return "0.13" }
+func (p *singleRecordProcessor) GetInstanceID() string   { return "" }
+func (p *singleRecordProcessor) InstanceIDInRecID() bool { return false }
+
+func TestParserPositionsWithoutFinalDelimiter(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "logs", "application.log.1"))
+	assert.NoError(t, err)
+	lines := strings.Split(string(data), "\n")[:2]
+	path := filepath.Join(t.TempDir(), "application.log")
+	content := strings.Join(lines, "\n")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	conf := &Conf{ProcessingConf: appconf.ProcessingConf{AppType: servicelog.AppTypeKontext, Version: "0.13"}}
+	p, err := newParser(path, conf, '\n', nil)
+	assert.NoError(t, err)
+	defer p.Close()
+	out := make(chan *servicelog.BoundOutputRecord, 2)
+	p.Parse(context.Background(), -1, &singleRecordProcessor{}, DatetimeRange{}, out)
+	close(out)
+	var positions []servicelog.LogRange
+	for rec := range out {
+		positions = append(positions, rec.FilePos)
+	}
+	firstSize := int64(len(lines[0]) + 1)
+	assert.Equal(
+		t,
+		[]servicelog.LogRange{
+			{SeekStart: 0, SeekEnd: firstSize, Line: 1},
+			{SeekStart: firstSize, SeekEnd: int64(len(content)), Line: 2},
+		},
+		positions,
+	)
+}
+
+type cancellingProcessor struct {
+	numItems    int
+	cancelAfter int
+	cancel      context.CancelFunc
+}
+
+func (p *cancellingProcessor) ProcItem(logRec servicelog.InputRecord, tzShiftMin int) []servicelog.OutputRecord {
+	p.numItems++
+	if p.numItems == p.cancelAfter {
+		p.cancel()
+	}
+	return []servicelog.OutputRecord{}
+}
+func (p *cancellingProcessor) GetAppType() string      { return servicelog.AppTypeKontext }
+func (p *cancellingProcessor) GetAppVersion() string   { return "0.13" }
+func (p *cancellingProcessor) GetInstanceID() string   { return "" }
+func (p *cancellingProcessor) InstanceIDInRecID() bool { return false }
+
+func TestInterruptedRunResumesFromUnfinishedFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"application.log.1", "application.log.2"} {
+		data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "logs", name))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+	conf := &Conf{
+		SrcPath:        dir,
+		ProcessingConf: appconf.ProcessingConf{AppType: servicelog.AppTypeKontext, Version: "0.13"},
+	}
+
+	// stop in the middle of the second file (each file has 4 records)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	proc := &cancellingProcessor{cancelAfter: 6, cancel: cancel}
+	result := CreateLogFileProcFunc(proc, DatetimeRange{})(ctx, conf, -1)
+	assert.True(t, result.Interrupted)
+	assert.Equal(t, 6, proc.numItems)
+
+	// with the strict match, the resumed run must select the unfinished file
+	files := getFilesInDir(dir, result.ResumeTime, true, conf.TZShift, '\n', true, nil)
+	assert.Equal(t, []string{filepath.Join(dir, "application.log.2")}, files)
+
+	proc = &cancellingProcessor{}
+	result = CreateLogFileProcFunc(proc, DatetimeRange{})(context.Background(), conf, result.ResumeTime)
+	assert.False(t, result.Interrupted)
+	assert.Equal(t, 4, proc.numItems)
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"sync"
+)
+
+// fileResult is an outcome of processing of a single file
+type fileResult struct {
+	ProcResult
+
+	// started is true if the file processing started
+	started bool
+}
+
+// processFiles processes files by a pool of numWorkers workers (at least
+// one). Files are taken in the provided order. Once the ctx is cancelled,
+// no other file is started. The returned results have the same order
+// as the files.
+func processFiles(
+	ctx context.Context,
+	files []string,
+	numWorkers int,
+	procFile func(file string) ProcResult,
+) []fileResult {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	results := make([]fileResult, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(numWorkers)
+	for w := 0; w < numWorkers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].started = true
+				results[i].ProcResult = procFile(files[i])
+			}
+		}()
+	}
+loop:
+	for i := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break loop
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// mergeFileResults merges results of individual files. As files are sorted
+// by time, the last record time is taken only from the longest prefix
+// of fully processed files and the first one not fully processed (if any).
+// The resume time (a checkpoint a next run continues from) points to the
+// start of the first file not fully processed. If the file has not been
+// started at all (or no line has been read from it), the last record time
+// of the fully processed prefix is used instead. This makes sure that
+// an interrupted concurrent processing cannot skip unprocessed records
+// of files preceding the ones already processed, nor the unprocessed rest
+// of a partially processed file.
+func mergeFileResults(results []fileResult) ProcResult {
+	ans := ProcResult{LastRecordTime: -1, ResumeTime: -1}
+	prefixComplete := true
+	for _, res := range results {
+		item := res.ProcResult
+		if !res.started {
+			item = ProcResult{LastRecordTime: -1, ResumeTime: -1, Interrupted: true}
+		}
+		if prefixComplete && item.Interrupted {
+			ans.ResumeTime = item.ResumeTime
+			if ans.ResumeTime == -1 {
+				ans.ResumeTime = ans.LastRecordTime
+			}
+		}
+		if !prefixComplete {
+			item.LastRecordTime = -1
+		}
+		ans.merge(item)
+		if item.Interrupted {
+			prefixComplete = false
+		}
+	}
+	return ans
+}
@@ -0,0 +1,224 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"klogproc/load/appconf"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessFilesConcurrently(t *testing.T) {
+	files := []string{"a", "b", "c", "d", "e"}
+	var mu sync.Mutex
+	var processed []string
+	results := processFiles(context.Background(), files, 3, func(file string) ProcResult {
+		mu.Lock()
+		processed = append(processed, file)
+		mu.Unlock()
+		return ProcResult{LastRecordTime: int64(file[0]), NumLines: 1}
+	})
+	assert.ElementsMatch(t, files, processed)
+	for i, res := range results {
+		assert.True(t, res.started)
+		assert.Equal(t, int64(files[i][0]), res.LastRecordTime)
+	}
+	merged := mergeFileResults(results)
+	assert.False(t, merged.Interrupted)
+	assert.Equal(t, int64('e'), merged.LastRecordTime)
+	assert.Equal(t, int64(5), merged.NumLines)
+}
+
+func TestProcessFilesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := processFiles(ctx, []string{"a", "b"}, 2, func(file string) ProcResult {
+		return ProcResult{LastRecordTime: 100}
+	})
+	merged := mergeFileResults(results)
+	assert.True(t, merged.Interrupted)
+	assert.Equal(t, int64(-1), merged.LastRecordTime)
+}
+
+func TestMergeFileResultsHighWaterMark(t *testing.T) {
+	merged := mergeFileResults([]fileResult{
+		{started: true, ProcResult: ProcResult{LastRecordTime: 10, NumLines: 2}},
+		{started: true, ProcResult: ProcResult{LastRecordTime: 15, ResumeTime: -1, NumLines: 3, Interrupted: true}},
+		{started: true, ProcResult: ProcResult{LastRecordTime: 30, NumLines: 4}},
+		{started: false},
+	})
+	assert.True(t, merged.Interrupted)
+	assert.Equal(t, int64(15), merged.LastRecordTime)
+	assert.Equal(t, int64(10), merged.ResumeTime)
+	assert.Equal(t, int64(9), merged.NumLines)
+
+	merged = mergeFileResults([]fileResult{
+		{started: true, ProcResult: ProcResult{LastRecordTime: 10, ResumeTime: -1}},
+		{started: true, ProcResult: ProcResult{LastRecordTime: 15, ResumeTime: 12, Interrupted: true}},
+		{started: true, ProcResult: ProcResult{LastRecordTime: 30, ResumeTime: -1}},
+	})
+	assert.Equal(t, int64(12), merged.ResumeTime)
+
+	merged = mergeFileResults([]fileResult{
+		{started: true, ProcResult: ProcResult{LastRecordTime: 10}},
+		{started: false},
+		{started: true, ProcResult: ProcResult{LastRecordTime: 30}},
+	})
+	assert.True(t, merged.Interrupted)
+	assert.Equal(t, int64(10), merged.LastRecordTime)
+	assert.Equal(t, int64(10), merged.ResumeTime)
+}
+
+// blockingProcessor cancels the processing in the middle of the first
+// (older) file once the second file has been fully processed
+type blockingProcessor struct {
+	splitTime    time.Time
+	numNewerRecs int
+	newerDone    chan struct{}
+	cancel       context.CancelFunc
+	mu           sync.Mutex
+	numOlder     int
+	numNewer     int
+}
+
+func (p *blockingProcessor) ProcItem(logRec servicelog.InputRecord, tzShiftMin int) []servicelog.OutputRecord {
+	p.mu.Lock()
+	if logRec.GetTime().Before(p.splitTime) {
+		p.numOlder++
+		if p.numOlder == 2 {
+			p.mu.Unlock()
+			<-p.newerDone
+			p.cancel()
+			return []servicelog.OutputRecord{}
+		}
+
+	} else {
+		p.numNewer++
+		if p.numNewer == p.numNewerRecs {
+			close(p.newerDone)
+		}
+	}
+	p.mu.Unlock()
+	return []servicelog.OutputRecord{}
+}
+func (p *blockingProcessor) GetAppType() string      { return servicelog.AppTypeKontext }
+func (p *blockingProcessor) GetAppVersion() string   { return "0.13" }
+func (p *blockingProcessor) GetInstanceID() string   { return "" }
+func (p *blockingProcessor) InstanceIDInRecID() bool { return false }
+
+func TestConcurrentInterruptedRunResumesFromUnfinishedFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"application.log.1", "application.log.2"} {
+		data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "logs", name))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+	conf := &Conf{
+		SrcPath:        dir,
+		ProcessingConf: appconf.ProcessingConf{AppType: servicelog.AppTypeKontext, Version: "0.13"},
+		Concurrency:    2,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	proc := &blockingProcessor{
+		splitTime:    time.Date(2017, 1, 31, 19, 24, 0, 0, time.UTC),
+		newerDone:    make(chan struct{}),
+		cancel:       cancel,
+		numNewerRecs: 4,
+	}
+	result := CreateLogFileProcFunc(proc, DatetimeRange{})(ctx, conf, -1)
+	assert.True(t, result.Interrupted)
+	assert.Equal(t, 4, proc.numNewer)
+
+	// the checkpoint must not skip the unfinished older file even if
+	// the newer one has been fully processed
+	startTime, err := importTimeFromLine("2017-01-31 19:19:22,930", conf.TZShift)
+	assert.NoError(t, err)
+	assert.Equal(t, startTime, result.ResumeTime)
+	files := getFilesInDir(dir, result.ResumeTime, true, conf.TZShift, '\n', true, nil)
+	assert.Contains(t, files, filepath.Join(dir, "application.log.1"))
+}
+
+type benchProcessor struct{}
+
+func (p *benchProcessor) ProcItem(logRec servicelog.InputRecord, tzShiftMin int) []servicelog.OutputRecord {
+	return []servicelog.OutputRecord{}
+}
+func (p *benchProcessor) GetAppType() string      { return servicelog.AppTypeTreq }
+func (p *benchProcessor) GetAppVersion() string   { return "" }
+func (p *benchProcessor) GetInstanceID() string   { return "" }
+func (p *benchProcessor) InstanceIDInRecID() bool { return false }
+
+func createBenchFiles(b *testing.B, numFiles, numLines int) string {
+	dir := b.TempDir()
+	var manifest strings.Builder
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < numFiles; i++ {
+		var data strings.Builder
+		for j := 0; j < numLines; j++ {
+			dt := start.Add(time.Duration(i*numLines+j) * time.Second).Format("2006-01-02T15:04:05-07:00")
+			data.WriteString(fmt.Sprintf(
+				"%s\t127.0.0.1\t1531\tD\tcs\ten\t1\t0\tCORE\t0\t0\tmocnost%d\n", dt, j))
+		}
+		path := filepath.Join(dir, fmt.Sprintf("treq-%03d.log", i))
+		if err := os.WriteFile(path, []byte(data.String()), 0644); err != nil {
+			b.Fatal(err)
+		}
+		manifest.WriteString(path + "\n")
+	}
+	manifestPath := filepath.Join(dir, "files.txt")
+	if err := os.WriteFile(manifestPath, []byte(manifest.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return manifestPath
+}
+
+func BenchmarkCreateLogFileProcFunc(b *testing.B) {
+	manifestPath := createBenchFiles(b, 16, 5000)
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency%d", concurrency), func(b *testing.B) {
+			conf := &Conf{
+				SrcPath:           manifestPath,
+				SrcPathIsManifest: true,
+				ProcessingConf:    appconf.ProcessingConf{AppType: servicelog.AppTypeTreq},
+				Concurrency:       concurrency,
+			}
+			for i := 0; i < b.N; i++ {
+				out := make(chan *servicelog.BoundOutputRecord)
+				proc := CreateLogFileProcFunc(&benchProcessor{}, DatetimeRange{}, out)
+				go func() {
+					for range out {
+					}
+				}()
+				res := proc(context.Background(), conf, -1)
+				if res.NumLines != 16*5000 {
+					b.Fatalf("unexpected number of lines: %d", res.NumLines)
+				}
+			}
+		})
+	}
+}
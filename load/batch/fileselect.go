@@ -22,17 +22,21 @@ package batch
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"klogproc/fsop"
 	"klogproc/load"
 	"klogproc/load/alarm"
+	"klogproc/load/appconf"
 	"klogproc/servicelog"
 
 	"github.com/czcorpus/cnc-gokit/fs"
@@ -42,31 +46,123 @@ import (
 var (
 	datetimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}\s[012]\d:[0-5]\d:[0-5]\d)[\.,]\d+`)
 	tzRangePattern  = regexp.MustCompile(`^\d+$`)
+	relTimePattern  = regexp.MustCompile(`^(\d+)([dw])$`)
 )
 
 // Conf represents a configuration for a single batch task. Currently it is not
 // possible to have configured multiple tasks in a single file. (TODO)
 type Conf struct {
-	SrcPath                string                   `json:"srcPath"`
-	PartiallyMatchingFiles bool                     `json:"partiallyMatchingFiles"`
-	WorklogPath            string                   `json:"worklogPath"`
-	LogBufferStateDir      string                   `json:"logBufferStateDir"`
-	AppType                string                   `json:"appType"`
-	Buffer                 *load.BufferConf         `json:"buffer"`
-	ExcludeIPList          servicelog.ExcludeIPList `json:"excludeIpList"`
-
-	// Version represents a major and minor version signature as used in semantic versioning
-	// (e.g. 0.15, 1.2)
-	Version        string `json:"version"`
-	NumErrorsAlarm int    `json:"numErrorsAlarm"`
-	TZShift        int    `json:"tzShift"`
-	SkipAnalysis   bool   `json:"skipAnalysis"`
+	// SrcPath specifies a log file, a directory with log files or a manifest
+	// (see SrcPathIsManifest). It can be also an `s3://bucket/prefix` URI - in such
+	// case objects under the prefix are processed (credentials are obtained via
+	// the standard AWS configuration chain).
+	SrcPath string `json:"srcPath"`
+
+	// SrcPathIsManifest specifies that SrcPath refers to a manifest file
+	// listing log files to be processed (one path per line, relative paths
+	// are resolved against the manifest's directory). The files are processed
+	// in the listed order, without any time-based file matching.
+	SrcPathIsManifest bool `json:"srcPathIsManifest"`
+
+	// ExcludePatterns specifies glob patterns (see path.Match) of file
+	// names which should be ignored when scanning a directory (e.g. `*.tmp`).
+	// The patterns are matched against file names, not full paths.
+	ExcludePatterns []string `json:"excludePatterns"`
+
+	PartiallyMatchingFiles bool   `json:"partiallyMatchingFiles"`
+	WorklogPath            string `json:"worklogPath"`
+	LogBufferStateDir      string `json:"logBufferStateDir"`
+
+	appconf.ProcessingConf
+
+	// InputFraming specifies an optional envelope each log line is wrapped
+	// in (e.g. `syslog5424`). By default, lines are parsed directly.
+	InputFraming string `json:"inputFraming"`
+
+	// RecordIDStrategy specifies how IDs of stored records are created
+	// (`natural` - default, or `fileOffset`). With `fileOffset`, IDs are
+	// derived from the file name and the position of the record in the
+	// file so reprocessing of the same file does not create duplicates.
+	RecordIDStrategy servicelog.RecordIDStrategy `json:"recordIdStrategy"`
+
+	// RecordIDSourceKey - see tail.FileConf.RecordIDSourceKey. Please note
+	// that processed files of the same name are rejected with the
+	// `fileOffset` strategy as a single key cannot distinguish them.
+	RecordIDSourceKey string `json:"recordIdSourceKey"`
+
+	// Concurrency specifies how many files are processed in parallel
+	// (each file with its own parser). Records of concurrently processed files
+	// are written interleaved so analyses depending on the order of records
+	// (e.g. bot detection) may be less precise. Zero or one means sequential
+	// processing.
+	Concurrency int `json:"concurrency"`
+
+	// DisableAutoDecompression disables transparent decompression of files
+	// with the `.gz`, `.bz2` and `.zst` extensions (for plaintext files
+	// which happen to have such an extension).
+	DisableAutoDecompression bool `json:"disableAutoDecompression"`
+
+	// RecordDelimiter specifies how records are separated in the files.
+	// Supported values are `newline` (default), `nul` and any single
+	// character.
+	RecordDelimiter string `json:"recordDelimiter"`
+
+	NumErrorsAlarm int  `json:"numErrorsAlarm"`
+	SkipAnalysis   bool `json:"skipAnalysis"`
 }
 
 func (conf *Conf) Validate() error {
-	if pathExists := fs.PathExists(conf.SrcPath); !pathExists {
+	if isS3URI(conf.SrcPath) {
+		if _, _, err := parseS3URI(conf.SrcPath); err != nil {
+			return fmt.Errorf("failed to validate batch file processing srcPath: %w", err)
+		}
+		if conf.SrcPathIsManifest {
+			return errors.New("failed to validate batch file processing srcPath: manifest cannot be read from S3")
+		}
+
+	} else if pathExists := fs.PathExists(conf.SrcPath); !pathExists {
 		return errors.New("failed to validate batch file processing srcPath: path does not exist")
 	}
+	if conf.SrcPathIsManifest {
+		files, err := readManifest(conf.SrcPath)
+		if err != nil {
+			return fmt.Errorf("failed to validate batch file processing manifest: %w", err)
+		}
+		for _, f := range files {
+			if !fsop.IsFile(f) {
+				return fmt.Errorf(
+					"failed to validate batch file processing manifest: %s is not a file", f)
+			}
+		}
+		if err := conf.validateRecordIDSources(files); err != nil {
+			return fmt.Errorf("failed to validate batch file processing manifest: %w", err)
+		}
+	}
+	if err := load.ValidateInputFraming(conf.InputFraming); err != nil {
+		return fmt.Errorf("failed to validate batch file processing: %w", err)
+	}
+	if err := conf.ProcessingConf.Validate(); err != nil {
+		return fmt.Errorf("failed to validate batch file processing: %w", err)
+	}
+	if conf.InputFormat == load.InputFormatJSONL && !supportsJSONL(conf.AppType, conf.Version) {
+		return fmt.Errorf(
+			"failed to validate batch file processing: input format %s is not supported for %s (version %s)",
+			conf.InputFormat, conf.AppType, conf.Version)
+	}
+	if err := load.ValidateRecordDelimiter(conf.RecordDelimiter); err != nil {
+		return fmt.Errorf("failed to validate batch file processing: %w", err)
+	}
+	if err := conf.RecordIDStrategy.Validate(); err != nil {
+		return fmt.Errorf("failed to validate batch file processing: %w", err)
+	}
+	for _, pattern := range conf.ExcludePatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("failed to validate batch file processing: invalid exclude pattern %s: %w", pattern, err)
+		}
+	}
+	if conf.Concurrency < 0 {
+		return fmt.Errorf("failed to validate batch file processing: concurrency must be a non-negative number")
+	}
 	if conf.Buffer != nil {
 		return conf.Buffer.Validate()
 	}
@@ -96,23 +192,68 @@ func importTimeRangeEntry(v string) (time.Time, error) {
 	return t, nil
 }
 
-func NewDateTimeRange(fromTimestamp, toTimestamp *string) (DatetimeRange, error) {
+// importRelativeTimeEntry imports a relative time information as expected
+// in the since CMD arg and resolves it against `now`. It should be either
+// a Go duration (e.g. 48h, 90m) or a number of days or weeks (e.g. 7d, 2w).
+func importRelativeTimeEntry(v string, now time.Time) (time.Time, error) {
+	if srch := relTimePattern.FindStringSubmatch(v); srch != nil {
+		num, err := strconv.Atoi(srch[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse relative time: %w", err)
+		}
+		if srch[2] == "w" {
+			num *= 7
+		}
+		return now.AddDate(0, 0, -num), nil
+	}
+	dur, err := time.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(
+			"unrecognized relative time format. Must be a duration (e.g. 48h) or a number of days or weeks (e.g. 7d, 2w)")
+	}
+	if dur < 0 {
+		return time.Time{}, fmt.Errorf("relative time must not be negative")
+	}
+	return now.Add(-dur), nil
+}
+
+// NewDateTimeRange creates a datetime range from CMD args. The lower bound
+// can be specified either as an absolute time (fromTimestamp) or relatively
+// to the current time (since) but not both.
+func NewDateTimeRange(fromTimestamp, toTimestamp, since *string) (DatetimeRange, error) {
+	return newDateTimeRange(*fromTimestamp, *toTimestamp, *since, time.Now())
+}
+
+func newDateTimeRange(fromTimestamp, toTimestamp, since string, now time.Time) (DatetimeRange, error) {
 	ans := DatetimeRange{}
-	if *fromTimestamp != "" {
-		fromTime, err := importTimeRangeEntry(*fromTimestamp)
+	if fromTimestamp != "" && since != "" {
+		return ans, fmt.Errorf("from-time and since cannot be used together")
+	}
+	if fromTimestamp != "" {
+		fromTime, err := importTimeRangeEntry(fromTimestamp)
+		if err != nil {
+			return ans, err
+		}
+		ans.From = &fromTime
+
+	} else if since != "" {
+		fromTime, err := importRelativeTimeEntry(since, now)
 		if err != nil {
 			return ans, err
 		}
 		ans.From = &fromTime
 	}
 
-	if *toTimestamp != "" {
-		toTime, err := importTimeRangeEntry(*toTimestamp)
+	if toTimestamp != "" {
+		toTime, err := importTimeRangeEntry(toTimestamp)
 		if err != nil {
 			return ans, err
 		}
 		ans.To = &toTime
 	}
+	if ans.From != nil && ans.To != nil && ans.From.After(*ans.To) {
+		return ans, fmt.Errorf("invalid time range: lower bound is after the upper bound")
+	}
 	return ans, nil
 }
 
@@ -121,13 +262,12 @@ func NewDateTimeRange(fromTimestamp, toTimestamp *string) (DatetimeRange, error)
 // it must be passed here to produce proper datetime.
 //
 // In case of an error, -1 is returned along with the error
-// tzShift is in minutes
-func importTimeFromLine(lineStr string, tzShiftMin int) (int64, error) {
+func importTimeFromLine(lineStr string, tzShift servicelog.TZShift) (int64, error) {
 	srch := datetimePattern.FindStringSubmatch(lineStr)
 	var err error
 	if len(srch) > 0 {
 		if t, err := time.Parse("2006-01-02 15:04:05", srch[1]); err == nil {
-			return t.Unix() + int64(tzShiftMin*60), nil
+			return t.Unix() + int64(tzShift.MinutesAt(t)*60), nil
 		}
 	}
 	return -1, err
@@ -141,28 +281,106 @@ func importTimeFromLine(lineStr string, tzShiftMin int) (int64, error) {
 // The function expects that the first line on any log file contains proper
 // log record which should be OK (KonText also writes multi-line error dumps
 // to the log but it always starts with a proper datetime information).
-func LogFileMatches(filePath string, minTimestamp int64, strictMatch bool, tzShiftMin int) (bool, error) {
-	f, err := os.Open(filePath)
+// With autoDecompress, compressed files are read through a respective decompressor.
+func LogFileMatches(
+	filePath string,
+	minTimestamp int64,
+	strictMatch bool,
+	tzShift servicelog.TZShift,
+	delim byte,
+	autoDecompress bool,
+) (bool, error) {
+	f, err := openLogFile(filePath, autoDecompress)
 	if err != nil {
 		return false, err
 	}
+	defer f.Close()
 	rd := bufio.NewScanner(f)
+	rd.Split(load.ScanRecords(delim))
 	rd.Scan()
-	line := rd.Text()
-	startTime, err := importTimeFromLine(line, tzShiftMin)
+	line := load.NormalizeLine(rd.Text(), true)
+	startTime, err := importTimeFromLine(line, tzShift)
 	if err != nil {
 		return false, err
 	}
 
 	if startTime < minTimestamp && !strictMatch {
-		startTime = fsop.GetFileMtime(filePath)
+		if isS3URI(filePath) {
+			startTime = getS3ObjectMtime(filePath)
+
+		} else {
+			startTime = fsop.GetFileMtime(filePath)
+		}
 	}
 
 	return startTime >= minTimestamp, nil
 }
 
+// readManifest reads a list of files from a manifest file (one path
+// per line, empty lines are ignored)
+func readManifest(manifestPath string) ([]string, error) {
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return []string{}, err
+	}
+	defer f.Close()
+	ans := make([]string, 0, 20)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		item := strings.TrimSpace(sc.Text())
+		if item == "" {
+			continue
+		}
+		if !filepath.IsAbs(item) {
+			item = filepath.Join(filepath.Dir(manifestPath), item)
+		}
+		ans = append(ans, item)
+	}
+	return ans, sc.Err()
+}
+
+// validateRecordIDSources tests whether the `fileOffset` record IDs
+// of the files are unique. As the RecordIDSourceKey is the same for all
+// the processed files, files of the same name (e.g. from different
+// directories listed in a manifest) always collide.
+func (conf *Conf) validateRecordIDSources(files []string) error {
+	if conf.RecordIDStrategy != servicelog.RecordIDStrategyFileOffset {
+		return nil
+	}
+	used := make(map[string]string)
+	for _, f := range files {
+		src := servicelog.FileOffsetSource(conf.RecordIDSourceKey, f)
+		if prev, ok := used[src]; ok {
+			return fmt.Errorf(
+				"files %s and %s would produce colliding record IDs - please process them separately with distinct recordIdSourceKey",
+				prev, f)
+		}
+		used[src] = f
+	}
+	return nil
+}
+
+// isExcludedFile tests whether a file name matches any of the provided
+// glob patterns (invalid patterns are rejected by Conf.Validate)
+func isExcludedFile(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if match, err := path.Match(pattern, name); err == nil && match {
+			return true
+		}
+	}
+	return false
+}
+
 // getFilesInDir lists all the matching log files
-func getFilesInDir(dirPath string, minTimestamp int64, strictMatch bool, tzShiftMin int) []string {
+func getFilesInDir(
+	dirPath string,
+	minTimestamp int64,
+	strictMatch bool,
+	tzShift servicelog.TZShift,
+	delim byte,
+	autoDecompress bool,
+	excludePatterns []string,
+) []string {
 	tmp, err := os.ReadDir(dirPath)
 	var ans []string
 	if err == nil {
@@ -173,7 +391,12 @@ func getFilesInDir(dirPath string, minTimestamp int64, strictMatch bool, tzShift
 			if !fsop.IsFile(logPath) {
 				continue
 			}
-			matches, merr := LogFileMatches(logPath, minTimestamp, strictMatch, tzShiftMin)
+			if isExcludedFile(item.Name(), excludePatterns) {
+				log.Debug().Str("file", logPath).Msg("skipping file matching an exclude pattern")
+				continue
+			}
+			matches, merr := LogFileMatches(
+				logPath, minTimestamp, strictMatch, tzShift, delim, autoDecompress)
 			if merr != nil {
 				log.Error().Err(merr).Msgf("Failed to check log file %s", logPath)
 
@@ -192,10 +415,57 @@ type LogItemProcessor interface {
 	ProcItem(logRec servicelog.InputRecord, tzShiftMin int) []servicelog.OutputRecord
 	GetAppType() string
 	GetAppVersion() string
+	GetInstanceID() string
+	InstanceIDInRecID() bool
+}
+
+// ProcResult describes an outcome of a LogFileProcFunc run
+type ProcResult struct {
+
+	// Interrupted is true if the processing has been stopped
+	// (via context) before all the files were processed
+	Interrupted bool
+
+	// LastRecordTime is a UNIX timestamp of the latest processed
+	// record (or -1 if no record has been processed)
+	LastRecordTime int64
+
+	// ResumeTime is a UNIX timestamp a next run should continue from
+	// in case the processing has been interrupted. It points to the start
+	// of the first unfinished file so the file is selected (and re-read)
+	// again - unlike the LastRecordTime which would make a strict file
+	// selection skip the rest of the file. The value is -1 if no progress
+	// has been made.
+	ResumeTime int64
+
+	// NumLines is the number of read lines
+	NumLines int64
+
+	// NumParseErrors is the number of lines the parser failed to parse
+	NumParseErrors int64
+
+	// Ignored contains numbers of lines skipped by the parser
+	// (empty lines, parsing errors, records out of the time range)
+	Ignored servicelog.IgnoredCounts
+}
+
+func (pr *ProcResult) merge(other ProcResult) {
+	if other.LastRecordTime > pr.LastRecordTime {
+		pr.LastRecordTime = other.LastRecordTime
+	}
+	pr.NumLines += other.NumLines
+	pr.NumParseErrors += other.NumParseErrors
+	if len(other.Ignored) > 0 {
+		if pr.Ignored == nil {
+			pr.Ignored = make(servicelog.IgnoredCounts)
+		}
+		pr.Ignored.Add(other.Ignored)
+	}
+	pr.Interrupted = pr.Interrupted || other.Interrupted
 }
 
 // LogFileProcFunc is a function for batch/tail processing of file-based logs
-type LogFileProcFunc = func(conf *Conf, minTimestamp int64)
+type LogFileProcFunc = func(ctx context.Context, conf *Conf, minTimestamp int64) ProcResult
 
 // CreateLogFileProcFunc connects a defined log transformer with output channels and
 // returns a customized function for file/directory processing.
@@ -204,15 +474,55 @@ func CreateLogFileProcFunc(
 	datetimeRange DatetimeRange,
 	destChans ...chan *servicelog.BoundOutputRecord,
 ) LogFileProcFunc {
-	return func(conf *Conf, minTimestamp int64) {
+	return func(ctx context.Context, conf *Conf, minTimestamp int64) ProcResult {
+		ans := ProcResult{LastRecordTime: -1, ResumeTime: -1}
+		delim, err := load.ParseRecordDelimiter(conf.RecordDelimiter)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to process batch files")
+			for _, ch := range destChans {
+				close(ch)
+			}
+			return ans
+		}
 		var files []string
-		if fsop.IsDir(conf.SrcPath) {
-			files = getFilesInDir(conf.SrcPath, minTimestamp, !conf.PartiallyMatchingFiles, conf.TZShift)
+		if conf.SrcPathIsManifest {
+			files, err = readManifest(conf.SrcPath)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to read batch files manifest")
+				for _, ch := range destChans {
+					close(ch)
+				}
+				return ans
+			}
+
+		} else if isS3URI(conf.SrcPath) {
+			files, err = getFilesInS3(
+				conf.SrcPath, minTimestamp, !conf.PartiallyMatchingFiles, conf.TZShift, delim,
+				!conf.DisableAutoDecompression, conf.ExcludePatterns)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to list batch files in S3")
+				for _, ch := range destChans {
+					close(ch)
+				}
+				return ans
+			}
+
+		} else if fsop.IsDir(conf.SrcPath) {
+			files = getFilesInDir(
+				conf.SrcPath, minTimestamp, !conf.PartiallyMatchingFiles, conf.TZShift, delim,
+				!conf.DisableAutoDecompression, conf.ExcludePatterns)
 
 		} else {
 			files = []string{conf.SrcPath}
 		}
 		log.Info().Msgf("Found %d file(s) to process in %s", len(files), conf.SrcPath)
+		if err := conf.validateRecordIDSources(files); err != nil {
+			log.Error().Err(err).Msg("failed to process batch files")
+			for _, ch := range destChans {
+				close(ch)
+			}
+			return ans
+		}
 		var procAlarm servicelog.AppErrorRegister
 		if conf.NumErrorsAlarm > 0 {
 			procAlarm = &alarm.BatchProcAlarm{}
@@ -220,17 +530,39 @@ func CreateLogFileProcFunc(
 		} else {
 			procAlarm = &alarm.NullAlarm{}
 		}
-		if conf.TZShift != 0 {
-			log.Info().Msgf("Found time-zone correction %d minutes", conf.TZShift)
+		if !conf.TZShift.IsZero() {
+			log.Info().Msgf("Found time-zone correction %s", conf.TZShift)
+		}
+		if conf.Concurrency > 1 {
+			log.Info().Int("concurrency", conf.Concurrency).Msg("processing files concurrently")
 		}
-		for _, file := range files {
-			p := newParser(file, conf.TZShift, processor.GetAppType(), processor.GetAppVersion(), procAlarm)
-			p.Parse(minTimestamp, processor, datetimeRange, destChans...)
+		results := processFiles(ctx, files, conf.Concurrency, func(file string) ProcResult {
+			p, err := newParser(file, conf, delim, procAlarm)
+			if err != nil {
+				log.Error().Err(err).Str("file", file).Msg("failed to open log file, skipping")
+				return ProcResult{LastRecordTime: -1, ResumeTime: -1}
+			}
+			defer p.Close()
+			return p.Parse(ctx, minTimestamp, processor, datetimeRange, destChans...)
+		})
+		ans = mergeFileResults(results)
+		if ans.Interrupted {
+			var numUnprocessed int
+			for _, res := range results {
+				if !res.started || res.Interrupted {
+					numUnprocessed++
+				}
+			}
+			log.Warn().
+				Err(ctx.Err()).
+				Int("unprocessedFiles", numUnprocessed).
+				Msg("batch processing interrupted")
 		}
 		for _, ch := range destChans {
 			close(ch)
 		}
 		procAlarm.Evaluate()
 		procAlarm.Reset()
+		return ans
 	}
 }
@@ -20,6 +20,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGetFilesInDir(t *testing.T) {
@@ -31,8 +36,96 @@ func TestGetFilesInDir(t *testing.T) {
 	// this should cause the function to return only two latest log files
 	limit := int64(1485890776)
 	// TODO we can test realiably only strict mode
-	files := getFilesInDir(filepath.Join(rootDir, "..", "..", "testdata", "logs"), limit, true, 1)
+	files := getFilesInDir(filepath.Join(rootDir, "..", "..", "testdata", "logs"), limit, true, servicelog.NewTZShiftMinutes(1), '\n', true, nil)
 	if len(files) != 2 {
 		t.Errorf("Invalid number of files detected - expected 2, found %d ", len(files))
 	}
 }
+
+func TestGetFilesInDirExcludePatterns(t *testing.T) {
+	rootDir, err := os.Getwd()
+	if err != nil {
+		t.Fail()
+	}
+	logDir := filepath.Join(rootDir, "..", "..", "testdata", "logs")
+	all := getFilesInDir(logDir, -1, false, servicelog.NewTZShiftMinutes(0), '\n', true, nil)
+	assert.NotEmpty(t, all)
+	excluded := filepath.Base(all[0])
+	files := getFilesInDir(logDir, -1, false, servicelog.NewTZShiftMinutes(0), '\n', true, []string{excluded})
+	assert.Len(t, files, len(all)-1)
+	assert.NotContains(t, files, all[0])
+}
+
+func TestIsExcludedFile(t *testing.T) {
+	patterns := []string{"*.tmp", "*.idx"}
+	assert.True(t, isExcludedFile("access.log.tmp", patterns))
+	assert.True(t, isExcludedFile("access.log.idx", patterns))
+	assert.False(t, isExcludedFile("access.log", patterns))
+	assert.False(t, isExcludedFile("access.log", nil))
+}
+
+func TestImportTimeFromLineDST(t *testing.T) {
+	tzShift, err := servicelog.NewTZShiftLocation("Europe/Prague")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// before the spring-forward transition (CET, UTC+1)
+	ts, err := importTimeFromLine("2024-03-31 01:30:00,123 INFO foo", tzShift)
+	if err != nil || ts != time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC).Unix() {
+		t.Errorf("unexpected result %d, %v", ts, err)
+	}
+	// after the spring-forward transition (CEST, UTC+2)
+	ts, err = importTimeFromLine("2024-03-31 03:30:00,123 INFO foo", tzShift)
+	if err != nil || ts != time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC).Unix() {
+		t.Errorf("unexpected result %d, %v", ts, err)
+	}
+	// after the fall-back transition (CET, UTC+1)
+	ts, err = importTimeFromLine("2024-10-27 03:30:00,123 INFO foo", tzShift)
+	if err != nil || ts != time.Date(2024, 10, 27, 2, 30, 0, 0, time.UTC).Unix() {
+		t.Errorf("unexpected result %d, %v", ts, err)
+	}
+}
+
+func TestNewDateTimeRangeSince(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	rng, err := newDateTimeRange("", "", "48h", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC), *rng.From)
+	assert.Nil(t, rng.To)
+
+	rng, err = newDateTimeRange("", "", "7d", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC), *rng.From)
+
+	rng, err = newDateTimeRange("", "", "2w", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 4, 26, 12, 0, 0, 0, time.UTC), *rng.From)
+}
+
+func TestNewDateTimeRangeSinceWithTo(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	rng, err := newDateTimeRange("", "1715256000", "2d", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC), *rng.From)
+	assert.Equal(t, int64(1715256000), rng.To.Unix())
+
+	_, err = newDateTimeRange("", "1715256000", "10h", now)
+	assert.Error(t, err)
+}
+
+func TestNewDateTimeRangeSinceInvalid(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	_, err := newDateTimeRange("1715256000", "", "2d", now)
+	assert.Error(t, err)
+	_, err = newDateTimeRange("", "", "yesterday", now)
+	assert.Error(t, err)
+	_, err = newDateTimeRange("", "", "-5h", now)
+	assert.Error(t, err)
+}
+
+func TestConfValidateExcludePatterns(t *testing.T) {
+	conf := Conf{SrcPath: t.TempDir(), ExcludePatterns: []string{"*.tmp"}}
+	assert.NoError(t, conf.Validate())
+	conf.ExcludePatterns = append(conf.ExcludePatterns, "[")
+	assert.Error(t, conf.Validate())
+}
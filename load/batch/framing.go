@@ -0,0 +1,123 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"klogproc/load"
+	"klogproc/servicelog"
+)
+
+var (
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID (STRUCTURED-DATA and MSG follow)
+	syslog5424HeaderRegexp = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) `)
+)
+
+// skipStructuredData returns the rest of the line after
+// the STRUCTURED-DATA part of an RFC5424 message
+func skipStructuredData(s string) (string, error) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], nil
+	}
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		var inQuotes, closed bool
+		for i++; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+
+			} else if s[i] == '"' {
+				inQuotes = !inQuotes
+
+			} else if s[i] == ']' && !inQuotes {
+				closed = true
+				i++
+				break
+			}
+		}
+		if !closed {
+			return "", fmt.Errorf("unterminated structured data element")
+		}
+	}
+	if i == 0 {
+		return "", fmt.Errorf("invalid structured data")
+	}
+	return s[i:], nil
+}
+
+// parseSyslog5424 splits an RFC5424 syslog line into envelope
+// metadata (currently just the timestamp) and the message itself
+func parseSyslog5424(line string) (servicelog.FramingMetadata, string, error) {
+	var meta servicelog.FramingMetadata
+	header := syslog5424HeaderRegexp.FindStringSubmatch(line)
+	if len(header) == 0 {
+		return meta, "", fmt.Errorf("invalid syslog header")
+	}
+	if header[3] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, header[3])
+		if err != nil {
+			return meta, "", fmt.Errorf("invalid syslog timestamp: %w", err)
+		}
+		meta.Timestamp = t
+	}
+	rest, err := skipStructuredData(line[len(header[0]):])
+	if err != nil {
+		return meta, "", err
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	rest = strings.TrimPrefix(rest, "\ufeff")
+	return meta, rest, nil
+}
+
+// syslog5424LineParser strips RFC5424 syslog envelope and passes the
+// message to a wrapped parser. Extracted envelope metadata are passed
+// to records implementing servicelog.FramingMetadataReceiver.
+type syslog5424LineParser struct {
+	lp LineParser
+}
+
+// ParseLine parses a passed line of a respective log
+func (parser *syslog5424LineParser) ParseLine(s string, lineNum int64) (servicelog.InputRecord, error) {
+	meta, msg, err := parseSyslog5424(s)
+	if err != nil {
+		return nil, servicelog.NewLineParsingError(lineNum, err.Error())
+	}
+	rec, err := parser.lp.ParseLine(msg, lineNum)
+	if err != nil {
+		return rec, err
+	}
+	if tRec, ok := rec.(servicelog.FramingMetadataReceiver); ok {
+		tRec.SetFramingMetadata(meta)
+	}
+	return rec, nil
+}
+
+// WrapWithFraming adds an envelope processing to the provided
+// parser based on the `framing` value (see load.InputFraming* constants).
+func WrapWithFraming(lp LineParser, framing string) (LineParser, error) {
+	switch framing {
+	case load.InputFramingNone:
+		return lp, nil
+	case load.InputFramingSyslog5424:
+		return &syslog5424LineParser{lp: lp}, nil
+	}
+	return nil, fmt.Errorf("unsupported input framing: %s", framing)
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"klogproc/load/appconf"
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext018"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSyslog5424(t *testing.T) {
+	meta, msg, err := parseSyslog5424(`<134>1 2024-01-02T03:04:05Z host1 app - - - {"a": 1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), meta.Timestamp)
+	assert.Equal(t, `{"a": 1}`, msg)
+}
+
+func TestParseSyslog5424StructuredData(t *testing.T) {
+	meta, msg, err := parseSyslog5424(
+		`<134>1 - - app 123 ID47 [exampleSDID@32473 iut="3" eventSource="App ]"][x@1 a="b"] {"a": 1}`)
+	assert.NoError(t, err)
+	assert.True(t, meta.Timestamp.IsZero())
+	assert.Equal(t, `{"a": 1}`, msg)
+}
+
+func TestParseSyslog5424Invalid(t *testing.T) {
+	_, _, err := parseSyslog5424(`{"a": 1}`)
+	assert.Error(t, err)
+	_, _, err = parseSyslog5424(`<134>1 - - app 123 ID47 [unterminated {"a": 1}`)
+	assert.Error(t, err)
+}
+
+func TestSyslogFramingFallbackTime(t *testing.T) {
+	lp, err := WrapWithFraming(&kontext018LineParser{lp: kontext018.NewLineParser("", servicelog.ParsingModeLenient)}, "syslog5424")
+	assert.NoError(t, err)
+	rec, err := lp.ParseLine(
+		`<134>1 2024-01-02T03:04:05Z host1 kontext - - - {"logger": "QUERY", "action": "view"}`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), rec.GetTime())
+}
+
+func TestNewParserInvalidConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\n"), 0644))
+	conf := &Conf{
+		ProcessingConf: appconf.ProcessingConf{AppType: servicelog.AppTypeKontext, Version: "0.18"},
+		InputFraming:   "xml",
+	}
+	_, err := newParser(path, conf, '\n', nil)
+	assert.Error(t, err)
+	conf = &Conf{ProcessingConf: appconf.ProcessingConf{AppType: "unknown"}}
+	_, err = newParser(path, conf, '\n', nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"klogproc/load"
+	"klogproc/servicelog"
+)
+
+// jsonlLineParser handles logs where each line is a full JSON-encoded
+// record. Empty lines are skipped (servicelog.ErrEmptyLine), malformed
+// JSON is reported as servicelog.LineParsingError with the line number.
+// Decoding of the record itself is left to the app type's parser which
+// must be JSON-based.
+type jsonlLineParser struct {
+	lp LineParser
+}
+
+// ParseLine parses a passed line of a respective log
+func (parser *jsonlLineParser) ParseLine(s string, lineNum int64) (servicelog.InputRecord, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, servicelog.ErrEmptyLine
+	}
+	if !json.Valid([]byte(s)) {
+		return nil, servicelog.NewLineParsingError(lineNum, "malformed JSON record")
+	}
+	return parser.lp.ParseLine(s, lineNum)
+}
+
+// supportsJSONL tests whether the provided app type's parser
+// decodes JSON-encoded records
+func supportsJSONL(appType, version string) bool {
+	switch appType {
+	case servicelog.AppTypeKontext, servicelog.AppTypeKontextAPI:
+		return version == "0.18"
+	case servicelog.AppTypeKwords:
+		return version == "2"
+	case servicelog.AppTypeMapka:
+		return version == "3"
+	case servicelog.AppTypeWag:
+		return version == "0.7"
+	case servicelog.AppTypeAPIGuard, servicelog.AppTypeKorpusDB, servicelog.AppTypeMasm,
+		servicelog.AppTypeMquery, servicelog.AppTypeMquerySRU, servicelog.AppTypeNginxJSON,
+		servicelog.AppTypeWsserver, servicelog.AppTypeAkalex, servicelog.AppTypeCalc,
+		servicelog.AppTypeLists, servicelog.AppTypeQuitaUp, servicelog.AppTypeGramatikat:
+		return true
+	}
+	return false
+}
+
+// WrapWithInputFormat adapts the provided app type's parser to the
+// `format` (see load.InputFormat* constants).
+func WrapWithInputFormat(lp LineParser, format, appType, version string) (LineParser, error) {
+	switch format {
+	case load.InputFormatDefault:
+		return lp, nil
+	case load.InputFormatJSONL:
+		if !supportsJSONL(appType, version) {
+			return nil, fmt.Errorf(
+				"input format %s is not supported for %s (version %s)", format, appType, version)
+		}
+		return &jsonlLineParser{lp: lp}, nil
+	}
+	return nil, fmt.Errorf("unsupported input format: %s", format)
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"klogproc/load/appconf"
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext018"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLLineParser(t *testing.T) {
+	lp, err := WrapWithInputFormat(
+		&kontext018LineParser{lp: kontext018.NewLineParser("", servicelog.ParsingModeLenient)}, "jsonl", servicelog.AppTypeKontext, "0.18")
+	assert.NoError(t, err)
+
+	rec, err := lp.ParseLine(`{"logger": "QUERY", "date": "2024-02-11T11:02:31.880", "action": "view"}`, 1)
+	assert.NoError(t, err)
+	assert.True(t, rec.IsProcessable())
+
+	_, err = lp.ParseLine("  ", 2)
+	assert.ErrorIs(t, err, servicelog.ErrEmptyLine)
+
+	_, err = lp.ParseLine(`{"logger": "QUERY"`, 3)
+	tErr, ok := err.(servicelog.LineParsingError)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), tErr.LineNumber)
+}
+
+func TestJSONLUnsupportedAppType(t *testing.T) {
+	lp, err := NewLineParser(servicelog.AppTypeKontext, "0.15", "", servicelog.ParsingModeLenient, nil, nil)
+	assert.NoError(t, err)
+	_, err = WrapWithInputFormat(lp, "jsonl", servicelog.AppTypeKontext, "0.15")
+	assert.Error(t, err)
+	_, err = WrapWithInputFormat(lp, "xml", servicelog.AppTypeKontext, "0.15")
+	assert.Error(t, err)
+}
+
+func TestNewParserUnsupportedInputFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\n"), 0644))
+	conf := &Conf{ProcessingConf: appconf.ProcessingConf{
+		AppType: servicelog.AppTypeKontext, Version: "0.15", InputFormat: "jsonl"}}
+	_, err := newParser(path, conf, '\n', nil)
+	assert.Error(t, err)
+}
+
+func TestConfValidateUnsupportedInputFormat(t *testing.T) {
+	conf := Conf{
+		SrcPath: t.TempDir(),
+		ProcessingConf: appconf.ProcessingConf{
+			AppType: servicelog.AppTypeKontext, Version: "0.15", InputFormat: "jsonl"},
+	}
+	assert.Error(t, conf.Validate())
+	conf.Version = "0.18"
+	assert.NoError(t, conf.Validate())
+}
+
+func TestParserSkipsEmptyJSONLLines(t *testing.T) {
+	lp := &lineRecordingParser{}
+	fr, recordSize := newRecordScanner(strings.NewReader("{\"a\":1}\n\n{\"b\":2}\n"), '\n')
+	p := &Parser{
+		fr:         fr,
+		recordSize: recordSize,
+		fileName:   "test.log",
+		lineParser: &jsonlLineParser{lp: lp},
+	}
+	result := p.Parse(context.Background(), 0, nil, DatetimeRange{})
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, lp.lines)
+	assert.Equal(t, int64(3), result.NumLines)
+	// only the two non-empty lines are reported by lineRecordingParser
+	assert.Equal(t, int64(2), result.NumParseErrors)
+}
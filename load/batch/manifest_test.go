@@ -0,0 +1,75 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadManifestKeepsOrderAndResolvesRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "files.txt")
+	err := os.WriteFile(
+		manifest, []byte("b.log\n\n  /var/log/a.log  \nsub/c.log\n"), 0644)
+	assert.NoError(t, err)
+	files, err := readManifest(manifest)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			filepath.Join(dir, "b.log"),
+			"/var/log/a.log",
+			filepath.Join(dir, "sub", "c.log"),
+		},
+		files,
+	)
+}
+
+func TestConfValidateManifestMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.log"), []byte{}, 0644))
+	manifest := filepath.Join(dir, "files.txt")
+	assert.NoError(t, os.WriteFile(manifest, []byte("a.log\n"), 0644))
+	conf := Conf{SrcPath: manifest, SrcPathIsManifest: true}
+	assert.NoError(t, conf.Validate())
+
+	assert.NoError(t, os.WriteFile(manifest, []byte("a.log\nmissing.log\n"), 0644))
+	assert.Error(t, conf.Validate())
+}
+
+func TestConfValidateManifestRecordIDCollision(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, sub, "access.log"), []byte{}, 0644))
+	}
+	manifest := filepath.Join(dir, "files.txt")
+	assert.NoError(t, os.WriteFile(manifest, []byte("a/access.log\nb/access.log\n"), 0644))
+	conf := Conf{SrcPath: manifest, SrcPathIsManifest: true}
+	assert.NoError(t, conf.Validate())
+
+	conf.RecordIDStrategy = servicelog.RecordIDStrategyFileOffset
+	assert.Error(t, conf.Validate())
+	conf.RecordIDSourceKey = "node1"
+	assert.Error(t, conf.Validate())
+}
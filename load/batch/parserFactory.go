@@ -19,6 +19,7 @@ package batch
 import (
 	"fmt"
 
+	"klogproc/load/accesslog"
 	"klogproc/servicelog"
 	"klogproc/servicelog/apiguard"
 	"klogproc/servicelog/kontext013"
@@ -34,6 +35,7 @@ import (
 	"klogproc/servicelog/morfio"
 	"klogproc/servicelog/mquery"
 	"klogproc/servicelog/mquerysru"
+	"klogproc/servicelog/nginxjson"
 	"klogproc/servicelog/shiny"
 	"klogproc/servicelog/ske"
 	"klogproc/servicelog/syd"
@@ -266,14 +268,36 @@ func (parser *mquerySRULineParser) ParseLine(s string, lineNum int64) (servicelo
 
 // ------------------------------------
 
-// NewLineParser creates a parser for individual lines of a respective appType
-func NewLineParser(appType string, version string, appErrRegister servicelog.AppErrorRegister) (LineParser, error) {
+type nginxJSONLineParser struct {
+	lp *nginxjson.LineParser
+}
+
+func (parser *nginxJSONLineParser) ParseLine(s string, lineNum int64) (servicelog.InputRecord, error) {
+	return parser.lp.ParseLine(s, lineNum)
+}
+
+// ------------------------------------
+
+// NewLineParser creates a parser for individual lines of a respective appType.
+// The traceIDField argument is optional and currently used only by KonText 0.18.
+// The parsingMode is consulted by parsers of JSON-based logs and by the ones
+// based on the HTTP access log format (other parsers always work in the
+// lenient mode). The accessLogFields argument is optional and used only
+// by parsers based on the HTTP access log format.
+func NewLineParser(
+	appType string,
+	version string,
+	traceIDField string,
+	parsingMode servicelog.ParsingMode,
+	accessLogFields accesslog.FieldSpec,
+	appErrRegister servicelog.AppErrorRegister,
+) (LineParser, error) {
 	switch appType {
 	case servicelog.AppTypeAPIGuard:
-		return &apiguardLineParser{lp: &apiguard.LineParser{}}, nil
+		return &apiguardLineParser{lp: apiguard.NewLineParser(parsingMode)}, nil
 	case servicelog.AppTypeAkalex, servicelog.AppTypeCalc, servicelog.AppTypeLists,
 		servicelog.AppTypeQuitaUp, servicelog.AppTypeGramatikat:
-		return &shinyLineParser{lp: &shiny.LineParser{}}, nil
+		return &shinyLineParser{lp: shiny.NewLineParser(parsingMode)}, nil
 	case servicelog.AppTypeKontext, servicelog.AppTypeKontextAPI:
 		switch version {
 		case "0.13", "0.14":
@@ -281,7 +305,7 @@ func NewLineParser(appType string, version string, appErrRegister servicelog.App
 		case "0.15", "0.16", "0.17":
 			return &kontext015LineParser{lp: kontext015.NewLineParser(appErrRegister)}, nil
 		case "0.18":
-			return &kontext018LineParser{lp: kontext018.NewLineParser()}, nil
+			return &kontext018LineParser{lp: kontext018.NewLineParser(traceIDField, parsingMode)}, nil
 		default:
 			return nil, fmt.Errorf("cannot find parser - unsupported version of KonText specified: %s", version)
 		}
@@ -290,27 +314,27 @@ func NewLineParser(appType string, version string, appErrRegister servicelog.App
 		case "1":
 			return &kwordsLineParser{lp: &kwords.LineParser{}}, nil
 		case "2":
-			return &kwords2LineParser{lp: &kwords2.LineParser{}}, nil
+			return &kwords2LineParser{lp: kwords2.NewLineParser(parsingMode)}, nil
 		default:
 			return nil, fmt.Errorf("cannot find parser - unsupported version of KWords specified: %s", version)
 		}
 	case servicelog.AppTypeKorpusDB:
-		return &korpusDBLineParser{lp: &korpusdb.LineParser{}}, nil
+		return &korpusDBLineParser{lp: korpusdb.NewLineParser(parsingMode)}, nil
 	case servicelog.AppTypeMapka:
 		switch version {
 		case "1":
-			return &mapkaLineParser{lp: &mapka.LineParser{}}, nil
+			return &mapkaLineParser{lp: mapka.NewLineParser(parsingMode, accessLogFields)}, nil
 		case "2":
-			return &mapka2LineParser{lp: &mapka2.LineParser{}}, nil
+			return &mapka2LineParser{lp: mapka2.NewLineParser(parsingMode, accessLogFields)}, nil
 		case "3":
-			return &mapka3LineParser{lp: &mapka3.LineParser{}}, nil
+			return &mapka3LineParser{lp: mapka3.NewLineParser(parsingMode)}, nil
 		default:
 			return nil, fmt.Errorf("cannot find parser - unsupported version of Mapka specified: %s", version)
 		}
 	case servicelog.AppTypeMorfio:
 		return &morfioLineParser{lp: &morfio.LineParser{}}, nil
 	case servicelog.AppTypeSke:
-		return &skeLineParser{lp: &ske.LineParser{}}, nil
+		return &skeLineParser{lp: ske.NewLineParser(parsingMode, accessLogFields)}, nil
 	case servicelog.AppTypeSyd:
 		return &sydLineParser{lp: &syd.LineParser{}}, nil
 	case servicelog.AppTypeTreq:
@@ -318,20 +342,22 @@ func NewLineParser(appType string, version string, appErrRegister servicelog.App
 	case servicelog.AppTypeWag:
 		switch version {
 		case "0.6":
-			return &wag06LineParser{lp: &wag06.LineParser{}}, nil
+			return &wag06LineParser{lp: wag06.NewLineParser(parsingMode, accessLogFields)}, nil
 		case "0.7":
-			return &wag07LineParser{lp: &wag07.LineParser{}}, nil
+			return &wag07LineParser{lp: wag07.NewLineParser(parsingMode)}, nil
 		default:
 			return nil, fmt.Errorf("cannot find parser - unsupported version of WaG specified: %s", version)
 		}
 	case servicelog.AppTypeWsserver:
-		return &wsserverLineParser{lp: &wsserver.LineParser{}}, nil
+		return &wsserverLineParser{lp: wsserver.NewLineParser(parsingMode)}, nil
 	case servicelog.AppTypeMasm:
-		return &masmLineParser{lp: &masm.LineParser{}}, nil
+		return &masmLineParser{lp: masm.NewLineParser(appErrRegister, parsingMode)}, nil
 	case servicelog.AppTypeMquery:
-		return &mqueryLineParser{lp: &mquery.LineParser{}}, nil
+		return &mqueryLineParser{lp: mquery.NewLineParser(appErrRegister, parsingMode)}, nil
 	case servicelog.AppTypeMquerySRU:
-		return &mquerySRULineParser{lp: &mquerysru.LineParser{}}, nil
+		return &mquerySRULineParser{lp: mquerysru.NewLineParser(appErrRegister, parsingMode)}, nil
+	case servicelog.AppTypeNginxJSON:
+		return &nginxJSONLineParser{lp: nginxjson.NewLineParser(parsingMode)}, nil
 	default:
 		return nil, fmt.Errorf("Parser not found for application type %s", appType)
 	}
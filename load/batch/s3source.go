@@ -0,0 +1,182 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"klogproc/servicelog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	s3URIPrefix = "s3://"
+)
+
+// s3API is a subset of the S3 client API needed to read log files
+type s3API interface {
+	ListObjectsV2(
+		ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options),
+	) (*s3.ListObjectsV2Output, error)
+	GetObject(
+		ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options),
+	) (*s3.GetObjectOutput, error)
+	HeadObject(
+		ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options),
+	) (*s3.HeadObjectOutput, error)
+}
+
+var (
+	s3Client     s3API
+	s3ClientErr  error
+	s3ClientOnce sync.Once
+)
+
+// getS3Client returns a shared S3 client configured via the standard
+// AWS configuration chain (env. variables, shared config files, instance
+// or task roles etc.)
+func getS3Client() (s3API, error) {
+	s3ClientOnce.Do(func() {
+		cfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			s3ClientErr = fmt.Errorf("failed to load AWS configuration: %w", err)
+			return
+		}
+		s3Client = s3.NewFromConfig(cfg)
+	})
+	return s3Client, s3ClientErr
+}
+
+// isS3URI tests whether the path refers to an S3 bucket (`s3://bucket/prefix`)
+func isS3URI(filePath string) bool {
+	return strings.HasPrefix(filePath, s3URIPrefix)
+}
+
+// parseS3URI splits an `s3://bucket/key` URI into a bucket and a key
+// (or a prefix in case of a "directory")
+func parseS3URI(uri string) (bucket string, key string, err error) {
+	if !isS3URI(uri) {
+		return "", "", fmt.Errorf("not an S3 URI: %s", uri)
+	}
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(uri, s3URIPrefix), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("missing bucket in S3 URI %s", uri)
+	}
+	return bucket, key, nil
+}
+
+// openS3Object opens a stream of an S3 object specified by its URI
+func openS3Object(uri string) (io.ReadCloser, error) {
+	bucket, key, err := parseS3URI(uri)
+	if err != nil {
+		return nil, err
+	}
+	client, err := getS3Client()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := client.GetObject(
+		context.Background(), &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open S3 object %s: %w", uri, err)
+	}
+	return obj.Body, nil
+}
+
+// getS3ObjectMtime returns UNIX time of the last modification
+// of an S3 object (or -1 in case of an error)
+func getS3ObjectMtime(uri string) int64 {
+	bucket, key, err := parseS3URI(uri)
+	if err != nil {
+		return -1
+	}
+	client, err := getS3Client()
+	if err != nil {
+		return -1
+	}
+	head, err := client.HeadObject(
+		context.Background(), &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil || head.LastModified == nil {
+		return -1
+	}
+	return head.LastModified.Unix()
+}
+
+// getFilesInS3 is an S3 variant of getFilesInDir. It lists objects
+// under the prefix specified by the URI and returns URIs of the ones
+// matching the minTimestamp (see LogFileMatches).
+func getFilesInS3(
+	uri string,
+	minTimestamp int64,
+	strictMatch bool,
+	tzShift servicelog.TZShift,
+	delim byte,
+	autoDecompress bool,
+	excludePatterns []string,
+) ([]string, error) {
+	bucket, prefix, err := parseS3URI(uri)
+	if err != nil {
+		return []string{}, err
+	}
+	client, err := getS3Client()
+	if err != nil {
+		return []string{}, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ans := make([]string, 0, 20)
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(context.Background())
+		if err != nil {
+			return []string{}, fmt.Errorf("failed to list objects in %s: %w", uri, err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if key == "" || strings.HasSuffix(key, "/") {
+				continue
+			}
+			objURI := s3URIPrefix + bucket + "/" + key
+			if isExcludedFile(path.Base(key), excludePatterns) {
+				log.Debug().Str("file", objURI).Msg("skipping file matching an exclude pattern")
+				continue
+			}
+			matches, merr := LogFileMatches(
+				objURI, minTimestamp, strictMatch, tzShift, delim, autoDecompress)
+			if merr != nil {
+				log.Error().Err(merr).Msgf("Failed to check log file %s", objURI)
+
+			} else if matches {
+				ans = append(ans, objURI)
+			}
+		}
+	}
+	return ans, nil
+}
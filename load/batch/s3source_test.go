@@ -0,0 +1,165 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"klogproc/servicelog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeS3Object struct {
+	data    []byte
+	modTime time.Time
+}
+
+type fakeS3 struct {
+	bucket  string
+	objects map[string]fakeS3Object
+}
+
+func (f *fakeS3) ListObjectsV2(
+	ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options),
+) (*s3.ListObjectsV2Output, error) {
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		if strings.HasPrefix(k, aws.ToString(params.Prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	ans := &s3.ListObjectsV2Output{}
+	for _, k := range keys {
+		ans.Contents = append(ans.Contents, types.Object{Key: aws.String(k)})
+	}
+	return ans, nil
+}
+
+func (f *fakeS3) GetObject(
+	ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options),
+) (*s3.GetObjectOutput, error) {
+	obj, ok := f.objects[aws.ToString(params.Key)]
+	if !ok || aws.ToString(params.Bucket) != f.bucket {
+		return nil, errors.New("no such key")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(obj.data))}, nil
+}
+
+func (f *fakeS3) HeadObject(
+	ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options),
+) (*s3.HeadObjectOutput, error) {
+	obj, ok := f.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &s3.HeadObjectOutput{LastModified: aws.Time(obj.modTime)}, nil
+}
+
+func useFakeS3(t *testing.T, fake *fakeS3) {
+	s3ClientOnce.Do(func() {})
+	orig := s3Client
+	s3Client = fake
+	t.Cleanup(func() { s3Client = orig })
+}
+
+func gzipData(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestParseS3URI(t *testing.T) {
+	bucket, key, err := parseS3URI("s3://logs/kontext/2024")
+	assert.NoError(t, err)
+	assert.Equal(t, "logs", bucket)
+	assert.Equal(t, "kontext/2024", key)
+	bucket, key, err = parseS3URI("s3://logs")
+	assert.NoError(t, err)
+	assert.Equal(t, "logs", bucket)
+	assert.Equal(t, "", key)
+	_, _, err = parseS3URI("s3:///kontext")
+	assert.Error(t, err)
+}
+
+func TestGetFilesInS3(t *testing.T) {
+	useFakeS3(t, &fakeS3{
+		bucket: "logs",
+		objects: map[string]fakeS3Object{
+			"kontext/app.log.1": {data: []byte("2024-01-01 10:00:00,123 INFO old\n")},
+			"kontext/app.log.2.gz": {
+				data: gzipData(t, "2024-02-01 10:00:00,123 INFO new\n"),
+			},
+			"kontext/app.log.tmp": {data: []byte("2024-02-01 10:00:00,123 INFO tmp\n")},
+			"other/app.log":       {data: []byte("2024-02-01 10:00:00,123 INFO other\n")},
+		},
+	})
+	minTimestamp := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).Unix()
+	files, err := getFilesInS3(
+		"s3://logs/kontext", minTimestamp, true, servicelog.NewTZShiftMinutes(0), '\n', true,
+		[]string{"*.tmp"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://logs/kontext/app.log.2.gz"}, files)
+
+	rd, err := openLogFile(files[0], true)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(rd)
+	assert.NoError(t, err)
+	assert.NoError(t, rd.Close())
+	assert.Equal(t, "2024-02-01 10:00:00,123 INFO new\n", string(data))
+}
+
+func TestGetFilesInS3MtimeFallback(t *testing.T) {
+	useFakeS3(t, &fakeS3{
+		bucket: "logs",
+		objects: map[string]fakeS3Object{
+			"app.log": {
+				data:    []byte("2024-01-01 10:00:00,123 INFO old\n"),
+				modTime: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	})
+	minTimestamp := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC).Unix()
+	files, err := getFilesInS3(
+		"s3://logs", minTimestamp, false, servicelog.NewTZShiftMinutes(0), '\n', true, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"s3://logs/app.log"}, files)
+}
+
+func TestConfValidateS3(t *testing.T) {
+	conf := Conf{SrcPath: "s3://logs/kontext"}
+	assert.NoError(t, conf.Validate())
+	conf.SrcPathIsManifest = true
+	assert.Error(t, conf.Validate())
+	conf = Conf{SrcPath: "s3://"}
+	assert.Error(t, conf.Validate())
+}
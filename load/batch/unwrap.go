@@ -0,0 +1,62 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"fmt"
+	"regexp"
+
+	"klogproc/load"
+	"klogproc/servicelog"
+)
+
+// unwrapLineParser extracts the payload of each line using
+// a regular expression and passes it to a wrapped parser
+type unwrapLineParser struct {
+	lp       LineParser
+	rx       *regexp.Regexp
+	groupIdx int
+}
+
+// ParseLine parses a passed line of a respective log
+func (parser *unwrapLineParser) ParseLine(s string, lineNum int64) (servicelog.InputRecord, error) {
+	if s == "" {
+		return parser.lp.ParseLine(s, lineNum)
+	}
+	srch := parser.rx.FindStringSubmatch(s)
+	if len(srch) == 0 {
+		return nil, servicelog.NewLineParsingError(lineNum, "line does not match the unwrap pattern")
+	}
+	return parser.lp.ParseLine(srch[parser.groupIdx], lineNum)
+}
+
+// WrapWithUnwrapPattern adds payload extraction (see load.CompileUnwrapPattern)
+// to the provided parser. With an empty pattern, the parser is returned as is.
+func WrapWithUnwrapPattern(lp LineParser, pattern string) (LineParser, error) {
+	rx, err := load.CompileUnwrapPattern(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap parser: %w", err)
+	}
+	if rx == nil {
+		return lp, nil
+	}
+	return &unwrapLineParser{
+		lp:       lp,
+		rx:       rx,
+		groupIdx: rx.SubexpIndex(load.UnwrapPayloadGroup),
+	}, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"testing"
+
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext018"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSyslogUnwrapPattern = `^<\d{1,3}>1 \S+ \S+ \S+ \S+ \S+ \S+ (?P<payload>\{.*\})$`
+
+func newTestKontextParser() LineParser {
+	return &kontext018LineParser{lp: kontext018.NewLineParser("", servicelog.ParsingModeLenient)}
+}
+
+func TestUnwrapSyslogWrappedJSON(t *testing.T) {
+	lp, err := WrapWithUnwrapPattern(newTestKontextParser(), testSyslogUnwrapPattern)
+	assert.NoError(t, err)
+	rec, err := lp.ParseLine(
+		`<134>1 2024-01-01T10:00:00Z host1 kontext - - - {"logger": "QUERY", "action": "view", "date": "2024-01-01T10:00:00.000000"}`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "view", rec.(*kontext018.QueryInputRecord).Action)
+
+	_, err = lp.ParseLine(`{"logger": "QUERY", "action": "view"}`, 2)
+	assert.Error(t, err)
+}
+
+func TestUnwrapPlainLine(t *testing.T) {
+	plain := newTestKontextParser()
+	lp, err := WrapWithUnwrapPattern(plain, "")
+	assert.NoError(t, err)
+	assert.Same(t, plain, lp)
+	rec, err := lp.ParseLine(`{"logger": "QUERY", "action": "view", "date": "2024-01-01T10:00:00.000000"}`, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "view", rec.(*kontext018.QueryInputRecord).Action)
+}
+
+func TestUnwrapInvalidPattern(t *testing.T) {
+	_, err := WrapWithUnwrapPattern(newTestKontextParser(), `^<\d+>1 (.*)$`)
+	assert.Error(t, err)
+	_, err = WrapWithUnwrapPattern(newTestKontextParser(), `(?P<payload>`)
+	assert.Error(t, err)
+}
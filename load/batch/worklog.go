@@ -86,6 +86,14 @@ func (w *Worklog) GetLastRecord() int64 {
 // Save saves the worklog by writing actual UNIX timestamp
 // to the log file.
 func (w *Worklog) Save() error {
+	return w.SaveTimestamp(time.Now().Unix())
+}
+
+// SaveTimestamp saves the worklog by writing the provided
+// UNIX timestamp to the log file. This is used to checkpoint
+// an interrupted processing so a next run can continue
+// from the last processed record.
+func (w *Worklog) SaveTimestamp(ts int64) error {
 	f, err := os.OpenFile(w.filePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to open worklog file for writing: %s", err))
@@ -93,7 +101,7 @@ func (w *Worklog) Save() error {
 	defer f.Close()
 	writer := bufio.NewWriter(f)
 	defer writer.Flush()
-	_, err = writer.WriteString(fmt.Sprintf("%d\n", time.Now().Unix()))
+	_, err = writer.WriteString(fmt.Sprintf("%d\n", ts))
 	return err
 }
 
@@ -18,17 +18,64 @@ package load
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	DfltPrevNumReqsSampleSize = 10
+	// UserAgentRegexpPrefix marks user agent patterns (see BotDetectionConf)
+	// which are regular expressions instead of plain substrings
+	UserAgentRegexpPrefix = "re:"
+
+	DfltPrevNumReqsSampleSize       = 10
+	DfltSuspiciousReqRatioThreshold = 0.6
+	DfltSuspiciousReqMinRequests    = 10
 )
 
 type ClusteringDBScanConf struct {
 	MinDensity int     `json:"minDensity"`
 	Epsilon    float64 `json:"epsilon"`
+
+	// HourRanges optionally specifies different parameters for
+	// some parts of a day (e.g. to reflect denser daytime traffic).
+	// In case no range matches a record, MinDensity and Epsilon
+	// are used.
+	HourRanges []ClusteringDBScanHourRange `json:"hourRanges"`
+}
+
+// ClusteringDBScanHourRange specifies DBSCAN parameters for
+// records with time (hour) within [FromHour, ToHour). In case
+// FromHour > ToHour, the range spans over midnight (e.g. 22 - 6).
+// Hours are taken from record times as they are (i.e. typically UTC).
+type ClusteringDBScanHourRange struct {
+	FromHour   int     `json:"fromHour"`
+	ToHour     int     `json:"toHour"`
+	MinDensity int     `json:"minDensity"`
+	Epsilon    float64 `json:"epsilon"`
+}
+
+// Contains tests whether the hour is within the range
+func (hr ClusteringDBScanHourRange) Contains(hour int) bool {
+	if hr.FromHour <= hr.ToHour {
+		return hour >= hr.FromHour && hour < hr.ToHour
+	}
+	return hour >= hr.FromHour || hour < hr.ToHour
+}
+
+// ParamsAt returns DBSCAN parameters (minDensity, epsilon) applicable
+// to records created at the time t. The first matching hour range
+// is used, otherwise default values are returned.
+func (c *ClusteringDBScanConf) ParamsAt(t time.Time) (int, float64) {
+	for _, hr := range c.HourRanges {
+		if hr.Contains(t.Hour()) {
+			return hr.MinDensity, hr.Epsilon
+		}
+	}
+	return c.MinDensity, c.Epsilon
 }
 
 type BotDetectionConf struct {
@@ -56,6 +103,61 @@ type BotDetectionConf struct {
 	TrafficReportingThreshold float64 `json:"trafficReportingThreshold"`
 
 	PrevNumReqsSampleSize int `json:"prevNumReqsSampleSize"`
+
+	// SuspiciousReqRatioThreshold specifies a minimum ratio of suspicious
+	// requests (out of all the requests of an IP in the buffer) for the IP
+	// to be reported. If zero, DfltSuspiciousReqRatioThreshold is used.
+	SuspiciousReqRatioThreshold float64 `json:"suspiciousReqRatioThreshold"`
+
+	// SuspiciousReqMinRequests specifies a minimum number of requests
+	// an IP must produce within an analysis interval to be reported
+	// as suspicious (the actual threshold is the maximum of this value
+	// and the average number of requests per IP).
+	// If zero, DfltSuspiciousReqMinRequests is used.
+	SuspiciousReqMinRequests int `json:"suspiciousReqMinRequests"`
+
+	// UserAgentSubstrings specifies (case-insensitive) substrings
+	// of user agents of bots. Records produced by matching agents
+	// are not stored. If empty, no bot records are filtered out this way
+	// (bots are still detected by the analysis, see `bots.default.json`).
+	// Items prefixed by UserAgentRegexpPrefix are treated as regular expressions.
+	UserAgentSubstrings []string `json:"userAgentSubstrings"`
+
+	// MonitorSubstrings specifies (case-insensitive) substrings
+	// of user agents of monitoring tools. Records produced by matching
+	// agents are not stored. If empty, no monitoring records are filtered
+	// out this way. Items prefixed by UserAgentRegexpPrefix are treated
+	// as regular expressions.
+	MonitorSubstrings []string `json:"monitorSubstrings"`
+}
+
+func validateUserAgentPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, UserAgentRegexpPrefix) {
+			if _, err := regexp.Compile(strings.TrimPrefix(p, UserAgentRegexpPrefix)); err != nil {
+				return fmt.Errorf("invalid user agent pattern %s: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetSuspiciousReqRatioThreshold returns configured or default
+// suspicious requests ratio threshold
+func (bdc *BotDetectionConf) GetSuspiciousReqRatioThreshold() float64 {
+	if bdc.SuspiciousReqRatioThreshold == 0 {
+		return DfltSuspiciousReqRatioThreshold
+	}
+	return bdc.SuspiciousReqRatioThreshold
+}
+
+// GetSuspiciousReqMinRequests returns configured or default
+// min. number of requests of a suspicious IP
+func (bdc *BotDetectionConf) GetSuspiciousReqMinRequests() int {
+	if bdc.SuspiciousReqMinRequests == 0 {
+		return DfltSuspiciousReqMinRequests
+	}
+	return bdc.SuspiciousReqMinRequests
 }
 
 type BufferConf struct {
@@ -73,6 +175,11 @@ type BufferConf struct {
 	AnalysisIntervalSecs int                   `json:"analysisIntervalSecs"`
 	ClusteringDBScan     *ClusteringDBScanConf `json:"clusteringDbScan"`
 	BotDetection         *BotDetectionConf     `json:"botDetection"`
+
+	// MaxRecordsInMemory limits the number of buffered records kept
+	// in memory. Once exceeded, the oldest records are moved to a temporary
+	// on-disk segment. Zero means no limit.
+	MaxRecordsInMemory int `json:"maxRecordsInMemory"`
 }
 
 func (bc *BufferConf) IsShared() bool {
@@ -99,6 +206,10 @@ func (bc *BufferConf) Validate() error {
 		return errors.New(
 			"failed to validate batch file processing buffer: analysisIntervalSecs must be > 0")
 	}
+	if bc.MaxRecordsInMemory < 0 {
+		return errors.New(
+			"failed to validate batch file processing buffer: maxRecordsInMemory must be >= 0")
+	}
 	if bc.ClusteringDBScan != nil {
 		if bc.ClusteringDBScan.Epsilon <= 0 {
 			return errors.New(
@@ -108,6 +219,17 @@ func (bc *BufferConf) Validate() error {
 			return errors.New(
 				"failed to validate batch file processing buffer: clusteringDbScan.minDensity must be > 0")
 		}
+		for i, hr := range bc.ClusteringDBScan.HourRanges {
+			if hr.FromHour < 0 || hr.FromHour > 23 || hr.ToHour < 1 || hr.ToHour > 24 ||
+				hr.FromHour == hr.ToHour {
+				return fmt.Errorf(
+					"failed to validate batch file processing buffer: invalid clusteringDbScan.hourRanges[%d] hours", i)
+			}
+			if hr.Epsilon <= 0 || hr.MinDensity <= 0 {
+				return fmt.Errorf(
+					"failed to validate batch file processing buffer: clusteringDbScan.hourRanges[%d] epsilon and minDensity must be > 0", i)
+			}
+		}
 	}
 	if bc.BotDetection != nil {
 		if bc.BotDetection.PrevNumReqsSampleSize == 0 {
@@ -119,6 +241,19 @@ func (bc *BufferConf) Validate() error {
 		} else if bc.BotDetection.PrevNumReqsSampleSize < 0 {
 			return errors.New("failed to validate botDetection.prevNumReqsSampleSize, must be > 0")
 		}
+		if bc.BotDetection.SuspiciousReqRatioThreshold < 0 || bc.BotDetection.SuspiciousReqRatioThreshold > 1 {
+			return errors.New(
+				"failed to validate botDetection.suspiciousReqRatioThreshold, must be between 0 and 1")
+		}
+		if bc.BotDetection.SuspiciousReqMinRequests < 0 {
+			return errors.New("failed to validate botDetection.suspiciousReqMinRequests, must be >= 0")
+		}
+		if err := validateUserAgentPatterns(bc.BotDetection.UserAgentSubstrings); err != nil {
+			return fmt.Errorf("failed to validate botDetection.userAgentSubstrings: %w", err)
+		}
+		if err := validateUserAgentPatterns(bc.BotDetection.MonitorSubstrings); err != nil {
+			return fmt.Errorf("failed to validate botDetection.monitorSubstrings: %w", err)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,87 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotDetectionThresholdDefaults(t *testing.T) {
+	conf := &BotDetectionConf{}
+	assert.Equal(t, DfltSuspiciousReqRatioThreshold, conf.GetSuspiciousReqRatioThreshold())
+	assert.Equal(t, DfltSuspiciousReqMinRequests, conf.GetSuspiciousReqMinRequests())
+
+	conf = &BotDetectionConf{SuspiciousReqRatioThreshold: 0.8, SuspiciousReqMinRequests: 50}
+	assert.Equal(t, 0.8, conf.GetSuspiciousReqRatioThreshold())
+	assert.Equal(t, 50, conf.GetSuspiciousReqMinRequests())
+}
+
+func TestBotDetectionThresholdValidation(t *testing.T) {
+	conf := &BufferConf{
+		HistoryLookupItems:   100,
+		AnalysisIntervalSecs: 60,
+		BotDetection:         &BotDetectionConf{SuspiciousReqRatioThreshold: 1.5},
+	}
+	assert.Error(t, conf.Validate())
+	conf.BotDetection.SuspiciousReqRatioThreshold = 0.7
+	assert.NoError(t, conf.Validate())
+	conf.BotDetection.SuspiciousReqMinRequests = -1
+	assert.Error(t, conf.Validate())
+}
+
+func TestClusteringDBScanParamsAt(t *testing.T) {
+	conf := &ClusteringDBScanConf{
+		MinDensity: 5,
+		Epsilon:    1.0,
+		HourRanges: []ClusteringDBScanHourRange{
+			{FromHour: 8, ToHour: 18, MinDensity: 10, Epsilon: 0.5},
+			{FromHour: 22, ToHour: 6, MinDensity: 3, Epsilon: 2.0},
+		},
+	}
+	minDensity, epsilon := conf.ParamsAt(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, 10, minDensity)
+	assert.Equal(t, 0.5, epsilon)
+
+	minDensity, epsilon = conf.ParamsAt(time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC))
+	assert.Equal(t, 3, minDensity)
+	assert.Equal(t, 2.0, epsilon)
+
+	minDensity, epsilon = conf.ParamsAt(time.Date(2024, 5, 1, 18, 0, 0, 0, time.UTC))
+	assert.Equal(t, 5, minDensity)
+	assert.Equal(t, 1.0, epsilon)
+}
+
+func TestClusteringDBScanHourRangesValidation(t *testing.T) {
+	conf := &BufferConf{
+		HistoryLookupItems:   100,
+		AnalysisIntervalSecs: 60,
+		ClusteringDBScan: &ClusteringDBScanConf{
+			MinDensity: 5,
+			Epsilon:    1.0,
+			HourRanges: []ClusteringDBScanHourRange{{FromHour: 8, ToHour: 18, MinDensity: 10, Epsilon: 0.5}},
+		},
+	}
+	assert.NoError(t, conf.Validate())
+	conf.ClusteringDBScan.HourRanges[0].ToHour = 25
+	assert.Error(t, conf.Validate())
+	conf.ClusteringDBScan.HourRanges[0].ToHour = 18
+	conf.ClusteringDBScan.HourRanges[0].Epsilon = 0
+	assert.Error(t, conf.Validate())
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+)
+
+const (
+	// RecordDelimiterNewline separates records by the `\n` character (default)
+	RecordDelimiterNewline = "newline"
+
+	// RecordDelimiterNUL separates records by the NUL byte
+	RecordDelimiterNUL = "nul"
+)
+
+// ParseRecordDelimiter converts a configured record delimiter into
+// a byte used to split input data. Supported are named delimiters
+// (`newline`, `nul`) and any single byte string. An empty value
+// means newline.
+func ParseRecordDelimiter(v string) (byte, error) {
+	switch v {
+	case "", RecordDelimiterNewline:
+		return '\n', nil
+	case RecordDelimiterNUL:
+		return 0, nil
+	}
+	if len(v) == 1 {
+		return v[0], nil
+	}
+	return 0, fmt.Errorf("unsupported record delimiter: %s", v)
+}
+
+// ValidateRecordDelimiter tests whether the provided record delimiter
+// is supported
+func ValidateRecordDelimiter(v string) error {
+	_, err := ParseRecordDelimiter(v)
+	return err
+}
+
+// ScanRecords creates a bufio.SplitFunc splitting data by the provided
+// delimiter. It behaves just like bufio.ScanLines in case of the newline
+// delimiter (the trailing `\r` is left to NormalizeLine).
+func ScanRecords(delim byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.IndexByte(data, delim); i >= 0 {
+			return i + 1, data[0:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
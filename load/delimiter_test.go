@@ -0,0 +1,49 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRecordDelimiter(t *testing.T) {
+	d, err := ParseRecordDelimiter("")
+	assert.NoError(t, err)
+	assert.Equal(t, byte('\n'), d)
+	d, err = ParseRecordDelimiter(RecordDelimiterNUL)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0), d)
+	d, err = ParseRecordDelimiter("|")
+	assert.NoError(t, err)
+	assert.Equal(t, byte('|'), d)
+	_, err = ParseRecordDelimiter("||")
+	assert.Error(t, err)
+}
+
+func TestScanRecords(t *testing.T) {
+	sc := bufio.NewScanner(strings.NewReader("a\nb\x00c\x00d"))
+	sc.Split(ScanRecords(0))
+	var ans []string
+	for sc.Scan() {
+		ans = append(ans, sc.Text())
+	}
+	assert.Equal(t, []string{"a\nb", "c", "d"}, ans)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// UnwrapPayloadGroup is a name of the capture group of an unwrap
+// pattern which contains the actual payload of a line
+const UnwrapPayloadGroup = "payload"
+
+const (
+	// InputFramingNone means log lines are directly the payload
+	// to be parsed (default)
+	InputFramingNone = ""
+
+	// InputFramingSyslog5424 means each log line is wrapped
+	// in an RFC5424 syslog header
+	InputFramingSyslog5424 = "syslog5424"
+)
+
+// ValidateInputFraming tests whether the provided framing
+// identifier is supported
+func ValidateInputFraming(framing string) error {
+	switch framing {
+	case InputFramingNone, InputFramingSyslog5424:
+		return nil
+	}
+	return fmt.Errorf("unsupported input framing: %s", framing)
+}
+
+// CompileUnwrapPattern compiles a regular expression extracting
+// the payload of wrapped log lines (via the UnwrapPayloadGroup
+// named group). An empty pattern produces nil.
+func CompileUnwrapPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	rx, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unwrap pattern: %w", err)
+	}
+	if rx.SubexpIndex(UnwrapPayloadGroup) < 0 {
+		return nil, fmt.Errorf("unwrap pattern must contain a named group (?P<%s>...)", UnwrapPayloadGroup)
+	}
+	return rx, nil
+}
+
+const (
+	// InputFormatDefault means lines are parsed by the parser
+	// native to the respective app type (default)
+	InputFormatDefault = ""
+
+	// InputFormatJSONL means each (non-empty) line is a full
+	// JSON-encoded record
+	InputFormatJSONL = "jsonl"
+)
+
+// ValidateInputFormat tests whether the provided input
+// format identifier is supported
+func ValidateInputFormat(format string) error {
+	switch format {
+	case InputFormatDefault, InputFormatJSONL:
+		return nil
+	}
+	return fmt.Errorf("unsupported input format: %s", format)
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpin
+
+import (
+	"errors"
+	"fmt"
+
+	"klogproc/load/alarm"
+	"klogproc/load/appconf"
+	"klogproc/load/tail"
+	"klogproc/servicelog"
+)
+
+const (
+	defaultMaxRequestBodyBytes = 10 * 1024 * 1024
+
+	// SourceIDPrefix is used to create pseudo file paths identifying
+	// records posted via HTTP (e.g. in logs and metrics)
+	SourceIDPrefix = "http:"
+)
+
+// AppConf configures processing of records of a single
+// application posted via HTTP
+type AppConf struct {
+	appconf.ProcessingConf
+}
+
+// SourceID returns a pseudo file path identifying records of the app
+func (ac *AppConf) SourceID() string {
+	return SourceIDPrefix + ac.AppType
+}
+
+// FileConf converts the configuration into a `tail` file configuration
+// so the posted records can be processed the same way as tailed files.
+// Please note that records posted via HTTP have no stable position
+// so the `natural` record ID strategy is always used.
+func (ac *AppConf) FileConf() tail.FileConf {
+	return tail.FileConf{
+		Path:             ac.SourceID(),
+		ProcessingConf:   ac.ProcessingConf,
+		RecordIDStrategy: servicelog.RecordIDStrategyNatural,
+	}
+}
+
+func (ac *AppConf) Validate() error {
+	if ac.AppType == "" {
+		return errors.New("failed to validate httpIngest app - missing appType")
+	}
+	if err := ac.ProcessingConf.Validate(); err != nil {
+		return fmt.Errorf("failed to validate httpIngest app %s: %w", ac.AppType, err)
+	}
+	if ac.Buffer != nil {
+		if ac.Buffer.IsReference() {
+			return fmt.Errorf("failed to validate httpIngest app %s - buffer references are not supported", ac.AppType)
+		}
+		return ac.Buffer.Validate()
+	}
+	return nil
+}
+
+// Conf wraps all the configuration for the 'http' action
+type Conf struct {
+
+	// ListenAddress specifies an address (e.g. `localhost:8090`)
+	// the ingestion server listens on.
+	ListenAddress string `json:"listenAddress"`
+
+	// AuthToken is a shared secret clients must provide
+	// via the `Authorization: Bearer <token>` header.
+	AuthToken string `json:"authToken"`
+
+	// MaxRequestBodyBytes limits the size of a single request.
+	// If zero, a default value (10 MiB) is used.
+	MaxRequestBodyBytes int64 `json:"maxRequestBodyBytes"`
+
+	LogBufferStateDir       string    `json:"logBufferStateDir"`
+	NumErrorsAlarm          int       `json:"numErrorsAlarm"`
+	ErrCountTimeRangeSecs   int       `json:"errCountTimeRangeSecs"`
+	ParseErrorLogWindowSecs int       `json:"parseErrorLogWindowSecs"`
+	Apps                    []AppConf `json:"apps"`
+
+	// ErrorRatioAlarm - see tail.Conf.ErrorRatioAlarm
+	ErrorRatioAlarm *alarm.RatioAlarmConf `json:"errorRatioAlarm"`
+}
+
+// MaxRequestBody returns the configured maximum request
+// size or a default value
+func (conf *Conf) MaxRequestBody() int64 {
+	if conf.MaxRequestBodyBytes == 0 {
+		return defaultMaxRequestBodyBytes
+	}
+	return conf.MaxRequestBodyBytes
+}
+
+// TailConf provides a `tail` configuration with properties shared
+// by the processing of records of all the configured apps.
+func (conf *Conf) TailConf() *tail.Conf {
+	return &tail.Conf{
+		LogBufferStateDir:       conf.LogBufferStateDir,
+		NumErrorsAlarm:          conf.NumErrorsAlarm,
+		ErrCountTimeRangeSecs:   conf.ErrCountTimeRangeSecs,
+		ParseErrorLogWindowSecs: conf.ParseErrorLogWindowSecs,
+		ErrorRatioAlarm:         conf.ErrorRatioAlarm,
+	}
+}
+
+func (conf *Conf) Validate() error {
+	if conf.ListenAddress == "" {
+		return errors.New("httpIngest.listenAddress not specified")
+	}
+	if conf.AuthToken == "" {
+		return errors.New("httpIngest.authToken not specified")
+	}
+	if conf.MaxRequestBodyBytes < 0 {
+		return errors.New("httpIngest.maxRequestBodyBytes must be a non-negative number")
+	}
+	if len(conf.Apps) == 0 {
+		return errors.New("httpIngest.apps - no apps configured")
+	}
+	if conf.ErrorRatioAlarm != nil {
+		if err := conf.ErrorRatioAlarm.Validate(); err != nil {
+			return fmt.Errorf("httpIngest.%w", err)
+		}
+	}
+	used := make(map[string]bool)
+	for i := range conf.Apps {
+		if err := conf.Apps[i].Validate(); err != nil {
+			return err
+		}
+		if used[conf.Apps[i].AppType] {
+			return fmt.Errorf("httpIngest.apps - duplicate app type %s", conf.Apps[i].AppType)
+		}
+		used[conf.Apps[i].AppType] = true
+		if conf.Apps[i].Buffer != nil && conf.LogBufferStateDir == "" {
+			return errors.New("httpIngest.logBufferStateDir must be set when using buffers")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpin
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	shutdownTimeoutSecs = 10
+
+	// IngestPath is a path prefix for posting records. The app type
+	// is specified either as the next path segment (`/ingest/kontext`)
+	// or via the AppTypeHeader.
+	IngestPath = "/ingest"
+
+	// AppTypeHeader is an alternative way of specifying the app type
+	// of posted records
+	AppTypeHeader = "X-Klogproc-App-Type"
+)
+
+// Result describes the outcome of processing a single request
+type Result struct {
+	NumLines  int `json:"numLines"`
+	NumErrors int `json:"numErrors"`
+}
+
+// Processor processes records of a single app type
+type Processor interface {
+
+	// ProcessLines processes all the lines of a single request
+	// and returns once the respective records are written. An error
+	// means that (some of) the records could not be written.
+	ProcessLines(lines []string) (Result, error)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server is an HTTP server accepting newline-delimited
+// JSON (ndjson) log records
+type Server struct {
+	srv        *http.Server
+	conf       *Conf
+	processors map[string]Processor
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Error().Err(err).Msg("failed to write HTTP ingestion response")
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, msg string) {
+	s.writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func (s *Server) isAuthorized(req *http.Request) bool {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.conf.AuthToken)) == 1
+}
+
+// appType obtains the app type either from the URL path
+// or from the AppTypeHeader
+func (s *Server) appType(req *http.Request) string {
+	appType := strings.Trim(strings.TrimPrefix(req.URL.Path, IngestPath), "/")
+	if appType == "" {
+		appType = req.Header.Get(AppTypeHeader)
+	}
+	return appType
+}
+
+func (s *Server) handleIngest(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	if !s.isAuthorized(req) {
+		s.writeError(w, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+	appType := s.appType(req)
+	if appType == "" {
+		s.writeError(w, http.StatusBadRequest, "missing app type")
+		return
+	}
+	processor, ok := s.processors[appType]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "unknown app type "+appType)
+		return
+	}
+	maxBody := s.conf.MaxRequestBody()
+	sc := bufio.NewScanner(http.MaxBytesReader(w, req.Body, maxBody))
+	sc.Buffer(make([]byte, 0, 64*1024), int(maxBody))
+	lines := make([]string, 0, 100)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			s.writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		s.writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+	result, err := processor.ProcessLines(lines)
+	if err != nil {
+		log.Error().Err(err).Str("appType", appType).Msg("failed to write records posted via HTTP")
+		s.writeError(w, http.StatusBadGateway, "failed to write records: "+err.Error())
+		return
+	}
+	s.writeJSON(w, http.StatusOK, result)
+}
+
+// Handler returns the HTTP handler of the server
+func (s *Server) Handler() http.Handler {
+	return s.srv.Handler
+}
+
+// Start runs the server in a separate goroutine
+func (s *Server) Start() {
+	go func() {
+		log.Info().Str("address", s.srv.Addr).Msg("starting HTTP ingestion server")
+		err := s.srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP ingestion server failed")
+		}
+	}()
+}
+
+// Stop gracefully shuts down the server (i.e. it
+// waits for the running requests to finish)
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), time.Duration(shutdownTimeoutSecs)*time.Second)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to shut down HTTP ingestion server")
+	}
+}
+
+// NewServer creates a new ingestion server with processors
+// mapped by app types. To actually run it, Start() must be called.
+func NewServer(conf *Conf, processors map[string]Processor) *Server {
+	s := &Server{
+		conf:       conf,
+		processors: processors,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(IngestPath, s.handleIngest)
+	mux.HandleFunc(IngestPath+"/", s.handleIngest)
+	s.srv = &http.Server{
+		Addr:    conf.ListenAddress,
+		Handler: mux,
+	}
+	return s
+}
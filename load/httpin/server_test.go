@@ -0,0 +1,132 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"klogproc/load/appconf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingProcessor struct {
+	lines []string
+	err   error
+}
+
+func (p *recordingProcessor) ProcessLines(lines []string) (Result, error) {
+	p.lines = append(p.lines, lines...)
+	return Result{NumLines: len(lines)}, p.err
+}
+
+func newTestServer() (*Server, *recordingProcessor) {
+	proc := &recordingProcessor{}
+	conf := &Conf{
+		ListenAddress:       "localhost:0",
+		AuthToken:           "secret",
+		MaxRequestBodyBytes: 100,
+	}
+	return NewServer(conf, map[string]Processor{"kontext": proc}), proc
+}
+
+func post(srv *Server, path, token, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIngestByPath(t *testing.T) {
+	srv, proc := newTestServer()
+	rec := post(srv, "/ingest/kontext", "secret", "{\"a\":1}\r\n\n{\"b\":2}", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"numLines":2,"numErrors":0}`, rec.Body.String())
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, proc.lines)
+}
+
+func TestIngestByHeader(t *testing.T) {
+	srv, proc := newTestServer()
+	rec := post(srv, "/ingest", "secret", `{"a":1}`, map[string]string{AppTypeHeader: "kontext"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{`{"a":1}`}, proc.lines)
+
+	rec = post(srv, "/ingest", "secret", `{"a":1}`, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestIngestRejectsInvalidRequests(t *testing.T) {
+	srv, proc := newTestServer()
+	assert.Equal(t, http.StatusUnauthorized, post(srv, "/ingest/kontext", "", `{}`, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(srv, "/ingest/kontext", "wrong", `{}`, nil).Code)
+	assert.Equal(t, http.StatusNotFound, post(srv, "/ingest/syd", "secret", `{}`, nil).Code)
+	assert.Equal(
+		t,
+		http.StatusRequestEntityTooLarge,
+		post(srv, "/ingest/kontext", "secret", strings.Repeat("{}\n", 50), nil).Code,
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/ingest/kontext", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Empty(t, proc.lines)
+}
+
+func TestIngestWriteError(t *testing.T) {
+	srv, proc := newTestServer()
+	proc.err = errors.New("ES unavailable")
+	rec := post(srv, "/ingest/kontext", "secret", `{}`, nil)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Contains(t, rec.Body.String(), "ES unavailable")
+}
+
+func TestConfValidate(t *testing.T) {
+	conf := Conf{
+		ListenAddress: "localhost:8090",
+		AuthToken:     "secret",
+		Apps:          []AppConf{{ProcessingConf: appconf.ProcessingConf{AppType: "kontext", Version: "0.18"}}},
+	}
+	assert.NoError(t, conf.Validate())
+	assert.Equal(t, int64(defaultMaxRequestBodyBytes), conf.MaxRequestBody())
+
+	conf.Apps = append(conf.Apps, AppConf{ProcessingConf: appconf.ProcessingConf{AppType: "kontext"}})
+	assert.Error(t, conf.Validate())
+	conf.Apps = conf.Apps[:1]
+
+	conf.AuthToken = ""
+	assert.Error(t, conf.Validate())
+}
+
+func TestAppConfFileConf(t *testing.T) {
+	ac := AppConf{ProcessingConf: appconf.ProcessingConf{AppType: "kontext", Version: "0.18"}}
+	fc := ac.FileConf()
+	assert.Equal(t, "http:kontext", fc.Path)
+	assert.Equal(t, "kontext", fc.AppType)
+	assert.Equal(t, "0.18", fc.Version)
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"strings"
+	"unicode"
+)
+
+const utf8BOM = "\ufeff"
+
+// NormalizeLine prepares a raw log line for parsing. For the first
+// line of a file, a possible UTF-8 BOM is removed. For all the lines,
+// trailing whitespace (including `\r` from Windows line endings)
+// is removed.
+func NormalizeLine(line string, isFirstLine bool) string {
+	if isFirstLine {
+		line = strings.TrimPrefix(line, utf8BOM)
+	}
+	return strings.TrimRightFunc(line, unicode.IsSpace)
+}
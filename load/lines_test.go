@@ -0,0 +1,37 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package load
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeLineBOM(t *testing.T) {
+	assert.Equal(t, `{"a": 1}`, NormalizeLine("\ufeff{\"a\": 1}", true))
+	assert.Equal(t, "\ufeff{\"a\": 1}", NormalizeLine("\ufeff{\"a\": 1}", false))
+}
+
+func TestNormalizeLineCRLF(t *testing.T) {
+	assert.Equal(t, `{"a": 1}`, NormalizeLine("{\"a\": 1}\r", false))
+	assert.Equal(t, `{"a": 1}`, NormalizeLine("\ufeff{\"a\": 1} \t\r", true))
+}
+
+func TestNormalizeLineKeepsLeadingSpace(t *testing.T) {
+	assert.Equal(t, "  foo", NormalizeLine("  foo\r", false))
+}
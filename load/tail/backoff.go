@@ -0,0 +1,100 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"errors"
+	"time"
+)
+
+// ParseErrorBackoffConf configures suspending of file reading
+// in case the file suddenly contains mostly unparseable data
+// (e.g. a crash dump of the logging application).
+type ParseErrorBackoffConf struct {
+
+	// MinLines is the minimum number of lines read within a single
+	// check before the error ratio is evaluated
+	MinLines int `json:"minLines"`
+
+	// MaxErrorRatio is the ratio of failed lines (0, 1] considered
+	// to be a "parse error storm"
+	MaxErrorRatio float64 `json:"maxErrorRatio"`
+
+	// CooldownSecs specifies how long the file won't be read
+	// once a parse error storm is detected
+	CooldownSecs int `json:"cooldownSecs"`
+}
+
+func (conf *ParseErrorBackoffConf) Validate() error {
+	if conf.MinLines <= 0 {
+		return errors.New("logTail.parseErrorBackoff.minLines must be a positive number")
+	}
+	if conf.MaxErrorRatio <= 0 || conf.MaxErrorRatio > 1 {
+		return errors.New("logTail.parseErrorBackoff.maxErrorRatio must be from the interval (0, 1]")
+	}
+	if conf.CooldownSecs <= 0 {
+		return errors.New("logTail.parseErrorBackoff.cooldownSecs must be a positive number")
+	}
+	return nil
+}
+
+// parseStormDetector watches the ratio of failed lines within
+// a single check and decides whether the reading should be
+// suspended for a while.
+type parseStormDetector struct {
+	conf           *ParseErrorBackoffConf
+	numLines       int
+	numErrors      int
+	suspendedUntil time.Time
+	now            func() time.Time
+}
+
+// startCheck resets line counters. It returns false
+// in case the reading is still suspended.
+func (d *parseStormDetector) startCheck() bool {
+	d.numLines = 0
+	d.numErrors = 0
+	return !d.now().Before(d.suspendedUntil)
+}
+
+// registerLine adds a processed line and returns true in case
+// the error ratio exceeded the limit (which also starts the cooldown).
+func (d *parseStormDetector) registerLine(isError bool) bool {
+	d.numLines++
+	if isError {
+		d.numErrors++
+	}
+	if d.numLines < d.conf.MinLines ||
+		float64(d.numErrors)/float64(d.numLines) < d.conf.MaxErrorRatio {
+		return false
+	}
+	d.suspendedUntil = d.now().Add(time.Duration(d.conf.CooldownSecs) * time.Second)
+	return true
+}
+
+// newParseStormDetector creates a detector for the provided
+// configuration. In case the conf is nil, nil is returned
+// (which means no detection).
+func newParseStormDetector(conf *ParseErrorBackoffConf) *parseStormDetector {
+	if conf == nil {
+		return nil
+	}
+	return &parseStormDetector{
+		conf: conf,
+		now:  time.Now,
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"sync"
+
+	"klogproc/servicelog"
+)
+
+// EntryProcessFunc processes a single line and returns a function
+// writing the results (e.g. sending records to a LogDataWriter)
+type EntryProcessFunc func(item string, logPosition servicelog.LogRange) (write func())
+
+// orderedJob is a line passed to an OrderedPool worker
+type orderedJob struct {
+	item        string
+	logPosition servicelog.LogRange
+	// prev is closed once the previous line has been written
+	prev <-chan struct{}
+	// done is closed once the line has been written
+	done chan struct{}
+}
+
+// OrderedPool processes lines concurrently using a number of workers
+// while the results are written in the order of the lines. This way,
+// write confirmations cannot move the worklog position beyond lines
+// which have not been written yet (which would lose them in case
+// klogproc is stopped).
+//
+// Methods Add, Drain and Close are expected to be called from a single
+// goroutine (i.e. the one reading the file).
+type OrderedPool struct {
+	jobs chan orderedJob
+	// last is closed once the most recently added line has been written
+	last chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Add passes a line to the pool
+func (p *OrderedPool) Add(item string, logPosition servicelog.LogRange) {
+	done := make(chan struct{})
+	p.jobs <- orderedJob{item: item, logPosition: logPosition, prev: p.last, done: done}
+	p.last = done
+}
+
+// Drain waits for all the lines added so far to be written
+func (p *OrderedPool) Drain() {
+	<-p.last
+}
+
+// Close waits for all the lines to be written and stops the workers
+func (p *OrderedPool) Close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// NewOrderedPool creates a pool of numWorkers workers calling process
+// for each added line
+func NewOrderedPool(numWorkers int, process EntryProcessFunc) *OrderedPool {
+	ans := &OrderedPool{
+		jobs: make(chan orderedJob, numWorkers*2),
+		last: make(chan struct{}),
+	}
+	close(ans.last)
+	ans.wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			for job := range ans.jobs {
+				write := process(job.item, job.logPosition)
+				<-job.prev
+				write()
+				close(job.done)
+			}
+			ans.wg.Done()
+		}()
+	}
+	return ans
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowerEarlierLines makes earlier lines take more time
+// to process so workers finish them out of order
+func slowerEarlierLines(logPosition servicelog.LogRange) {
+	time.Sleep(time.Duration(10-logPosition.Line%10) * time.Millisecond)
+}
+
+func TestOrderedPoolWritesInOrder(t *testing.T) {
+	var written []int64
+	pool := NewOrderedPool(4, func(item string, logPosition servicelog.LogRange) func() {
+		slowerEarlierLines(logPosition)
+		return func() {
+			written = append(written, logPosition.Line)
+		}
+	})
+	expected := make([]int64, 0, 30)
+	for i := int64(1); i <= 30; i++ {
+		pool.Add(fmt.Sprintf("line %d", i), servicelog.LogRange{Line: i})
+		expected = append(expected, i)
+	}
+	pool.Close()
+	assert.Equal(t, expected, written)
+}
+
+func TestOrderedPoolDrain(t *testing.T) {
+	var mu sync.Mutex
+	var written []int64
+	pool := NewOrderedPool(3, func(item string, logPosition servicelog.LogRange) func() {
+		slowerEarlierLines(logPosition)
+		return func() {
+			mu.Lock()
+			written = append(written, logPosition.Line)
+			mu.Unlock()
+		}
+	})
+	defer pool.Close()
+	pool.Drain() // nothing added yet
+	for i := int64(1); i <= 5; i++ {
+		pool.Add("x", servicelog.LogRange{Line: i})
+	}
+	pool.Drain()
+	mu.Lock()
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, written)
+	mu.Unlock()
+}
+
+// pooledProcessor passes lines to an OrderedPool the same way
+// the tail action does with transform workers configured
+type pooledProcessor struct {
+	lineRecordingProcessor
+	pool      *OrderedPool
+	mu        sync.Mutex
+	confirmed []servicelog.LogRange
+}
+
+func (p *pooledProcessor) OnCheckStart() (LineProcConfirmChan, *LogDataWriter) {
+	confirm := make(LineProcConfirmChan)
+	writer := &LogDataWriter{Elastic: make(chan *servicelog.BoundOutputRecord, 10)}
+	go func() {
+		for rec := range writer.Elastic {
+			rec.FilePos.Written = true
+			p.mu.Lock()
+			p.confirmed = append(p.confirmed, rec.FilePos)
+			p.mu.Unlock()
+			confirm <- save.ConfirmMsg{FilePath: rec.FilePath, Position: rec.FilePos}
+		}
+		close(confirm)
+	}()
+	p.pool = NewOrderedPool(4, func(item string, logPosition servicelog.LogRange) func() {
+		slowerEarlierLines(logPosition)
+		return func() {
+			writer.Elastic <- &servicelog.BoundOutputRecord{FilePath: p.path, FilePos: logPosition}
+		}
+	})
+	return confirm, writer
+}
+
+func (p *pooledProcessor) OnEntry(writer *LogDataWriter, item string, logPosition servicelog.LogRange) {
+	p.lineRecordingProcessor.OnEntry(writer, item, logPosition)
+	p.pool.Add(item, logPosition)
+}
+
+func (p *pooledProcessor) OnCheckStop(writer *LogDataWriter) {
+	p.pool.Close()
+	close(writer.Elastic)
+}
+
+func TestRunCheckWithOrderedPool(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	var content strings.Builder
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&content, "line %02d\n", i)
+	}
+	assert.NoError(t, os.WriteFile(logPath, []byte(content.String()), 0644))
+	wlPath := filepath.Join(dir, "worklog.json")
+	wl := NewWorklog(wlPath)
+	assert.NoError(t, wl.Init())
+	_, err := wl.ResetFile(logPath)
+	assert.NoError(t, err)
+
+	proc := &pooledProcessor{lineRecordingProcessor: lineRecordingProcessor{path: logPath}}
+	rdr, err := NewReader(proc, wl.GetData(logPath))
+	assert.NoError(t, err)
+	runCheck(context.Background(), []*FileTailReader{rdr}, wl)
+	wl.Close()
+
+	// every line must be written and confirmed in the order of lines
+	assert.Len(t, proc.confirmed, 40)
+	for i, pos := range proc.confirmed {
+		assert.Equal(t, int64(i+1), pos.Line)
+	}
+	wl2 := NewWorklog(wlPath)
+	assert.NoError(t, wl2.Init())
+	defer wl2.Close()
+	stored := wl2.GetData(logPath)
+	assert.Equal(t, proc.confirmed[len(proc.confirmed)-1], stored)
+	assert.Equal(t, int64(content.Len()), stored.SeekEnd)
+}
@@ -18,10 +18,13 @@ package tail
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"os"
+	"time"
 
 	"klogproc/fsop"
+	"klogproc/load"
 	"klogproc/servicelog"
 
 	"github.com/rs/zerolog/log"
@@ -32,11 +35,75 @@ import (
 // 1) file changes only by appending new lines
 // 2) during normal operation, the inode of the file remains the same
 // 3) change of inode means we start reading a new file from the beginning
+// (but before that, the remaining lines of the original - rotated - file
+// are read using the still open file handle)
 type FileTailReader struct {
 	processor    FileTailProcessor
 	internalSeek int64
 	file         *os.File
 	filePath     string
+
+	// stormDetector is an optional detector of parse error storms
+	// (see ParseErrorBackoffConf)
+	stormDetector *parseStormDetector
+
+	// pollSched optionally delays reads within a check
+	// (see pollScheduler)
+	pollSched *pollScheduler
+
+	// lineNum is the number of lines before internalSeek
+	lineNum int64
+
+	// lastPosition is the position of the last line read
+	// from the current file
+	lastPosition servicelog.LogRange
+
+	// lastGrowth is the time new lines were last read
+	// (used by the EOF marker detection)
+	lastGrowth time.Time
+
+	// eofMarkerEmitted tells whether OnEOF has been called since
+	// the last growth of the file
+	eofMarkerEmitted bool
+}
+
+// countLines returns number of lines (delimited by `delim`) in the first
+// `limit` bytes of a file. It is used to restore line numbering in case we
+// do not have the information stored (e.g. older worklog).
+func countLines(filePath string, limit int64, delim byte) (int64, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	rd := bufio.NewReader(io.LimitReader(f, limit))
+	var ans int64
+	for {
+		_, err := rd.ReadBytes(delim)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return ans, err
+		}
+		ans++
+	}
+	return ans, nil
+}
+
+// restoreLineNum sets internal line counter based on a known line
+// number (or by counting lines up to the provided seek position
+// if the line is unknown)
+func (ftw *FileTailReader) restoreLineNum(linesBefore int64, seek int64) error {
+	if linesBefore >= 0 && (linesBefore > 0 || seek == 0) {
+		ftw.lineNum = linesBefore
+		return nil
+	}
+	var err error
+	ftw.lineNum, err = countLines(ftw.filePath, seek, ftw.processor.RecordDelimiter())
+	return err
 }
 
 // AppType returns app type identifier (kontext, syd, treq,...)
@@ -53,19 +120,37 @@ func (ftw *FileTailReader) Processor() FileTailProcessor {
 	return ftw.processor
 }
 
-// ApplyNewContent calls a provided function to newly added lines
+// ApplyNewContent calls a provided function to newly added lines.
+// Once the context is cancelled, no more lines are read (the ones
+// already passed to the processor are not affected).
 func (ftw *FileTailReader) ApplyNewContent(
+	ctx context.Context,
 	processor FileTailProcessor,
 	dataWriter *LogDataWriter,
 	prevPosition servicelog.LogRange,
 ) error {
+	if ftw.stormDetector != nil && !ftw.stormDetector.startCheck() {
+		log.Debug().
+			Str("logFile", ftw.filePath).
+			Time("suspendedUntil", ftw.stormDetector.suspendedUntil).
+			Msg("file reading suspended due to parse errors")
+		return nil
+	}
 	currInode, _, err := fsop.GetFileProps(processor.FilePath())
 	if err != nil {
 		return err
 	}
-	newPosition := servicelog.LogRange{SeekEnd: -1, Inode: currInode}
 	if currInode != prevPosition.Inode {
+		if err := ftw.drainRotatedFile(ctx, processor, dataWriter, prevPosition); err != nil {
+			log.Error().
+				Err(err).
+				Str("logFile", ftw.filePath).
+				Msg("failed to read remaining lines of a rotated file")
+		}
 		ftw.internalSeek = 0
+		ftw.lineNum = 0
+		ftw.lastPosition = servicelog.LogRange{}
+		ftw.lastGrowth = time.Time{}
 		ftw.file.Close()
 		ftw.file, err = os.Open(ftw.processor.FilePath())
 		if err != nil {
@@ -74,6 +159,9 @@ func (ftw *FileTailReader) ApplyNewContent(
 
 	} else if !prevPosition.Written {
 		ftw.internalSeek = prevPosition.SeekStart
+		if err := ftw.restoreLineNum(prevPosition.Line-1, ftw.internalSeek); err != nil {
+			return err
+		}
 		log.Warn().Msgf("FileTailReader(%s) updated internalSeek position to %d due to unsaved last record", ftw.filePath, prevPosition.SeekStart)
 		ftw.file.Seek(ftw.internalSeek, io.SeekStart)
 
@@ -87,32 +175,224 @@ func (ftw *FileTailReader) ApplyNewContent(
 			}
 		}
 		ftw.internalSeek = prevPosition.SeekEnd
+		if err := ftw.restoreLineNum(prevPosition.Line, ftw.internalSeek); err != nil {
+			return err
+		}
 		ftw.file.Seek(ftw.internalSeek, io.SeekStart)
 		log.Warn().Msgf("FileTailReader[%s] updated internalSeek position to %d due to updated position status", ftw.filePath, ftw.internalSeek)
 	}
 
+	numLines, err := ftw.readLines(
+		ctx, processor, dataWriter, currInode, ftw.processor.MaxLinesPerCheck(), ftw.stormDetector)
+	if err != nil {
+		return err
+	}
+	if numLines == ftw.processor.MaxLinesPerCheck() {
+		log.Warn().
+			Int("maxLinesPerCheck", ftw.processor.MaxLinesPerCheck()).
+			Str("logFile", ftw.filePath).
+			Str("name", ftw.AppType()).
+			Msg("tail processor hit the maxLinesPerCheck limit")
+	}
+	return ftw.checkEOF(processor, dataWriter, currInode, prevPosition, numLines)
+}
+
+// checkEOF calls processor's OnEOF in case the file has been fully read
+// and it has not grown for the configured period. The method is expected
+// to be called after each read of new content (numLines is the number
+// of lines read). For each "version" of the file (i.e. until it grows
+// again or it is rotated), OnEOF is called only once.
+// Please note that the state is not persistent so after klogproc restart,
+// the marker is emitted again.
+func (ftw *FileTailReader) checkEOF(
+	processor FileTailProcessor,
+	dataWriter *LogDataWriter,
+	inode int64,
+	prevPosition servicelog.LogRange,
+	numLines int,
+) error {
+	idleSecs := processor.EOFMarkerIdleSecs()
+	if idleSecs <= 0 {
+		return nil
+	}
+	if numLines > 0 || ftw.lastGrowth.IsZero() {
+		ftw.lastGrowth = time.Now()
+		ftw.eofMarkerEmitted = false
+		return nil
+	}
+	if ftw.eofMarkerEmitted || time.Since(ftw.lastGrowth) < time.Duration(idleSecs)*time.Second {
+		return nil
+	}
+	lastPos := ftw.lastPosition
+	if lastPos.Inode != inode {
+		// nothing read since start, we rely on the worklog
+		lastPos = prevPosition
+	}
+	if lastPos.SeekEnd <= 0 {
+		return nil
+	}
+	_, size, err := fsop.GetOpenFileProps(ftw.file)
+	if err != nil {
+		return err
+	}
+	if size > lastPos.SeekEnd {
+		// there is an incomplete line at the end of the file
+		return nil
+	}
+	processor.OnEOF(dataWriter, lastPos)
+	ftw.eofMarkerEmitted = true
+	return nil
+}
+
+// drainRotatedFile reads lines added to the original file since the last
+// check in case the file has been rotated (renamed and replaced by a new
+// one). This is possible as the reader's file handle still refers to the
+// original inode. In case the handle does not match the last known position
+// (e.g. klogproc has been restarted after the rotation), nothing is read.
+func (ftw *FileTailReader) drainRotatedFile(
+	ctx context.Context,
+	processor FileTailProcessor,
+	dataWriter *LogDataWriter,
+	prevPosition servicelog.LogRange,
+) error {
+	if ftw.file == nil || prevPosition.Inode <= 0 {
+		return nil
+	}
+	inode, size, err := fsop.GetOpenFileProps(ftw.file)
+	if err != nil {
+		return err
+	}
+	if inode != prevPosition.Inode {
+		return nil
+	}
+	seek, lineNum := prevPosition.SeekEnd, prevPosition.Line
+	if !prevPosition.Written {
+		seek, lineNum = prevPosition.SeekStart, prevPosition.Line-1
+	}
+	if seek < 0 || seek >= size {
+		return nil
+	}
+	if _, err := ftw.file.Seek(seek, io.SeekStart); err != nil {
+		return err
+	}
+	ftw.internalSeek = seek
+	if lineNum > 0 {
+		ftw.lineNum = lineNum
+	}
+	numLines, err := ftw.readLines(ctx, processor, dataWriter, inode, -1, nil)
+	log.Info().
+		Str("logFile", ftw.filePath).
+		Int64("inode", inode).
+		Int("numLines", numLines).
+		Msg("finished reading rotated file")
+	return err
+}
+
+// readLines reads at most maxLines lines (or all the available
+// ones in case maxLines is negative) from the current position
+// and passes them to the processor. In case a storm detector is
+// provided and it detects too many errors, the reading stops. The same
+// applies for a cancelled context.
+// The number of read lines is returned.
+//
+// As the buffered reader may read ahead, the file position is reset to
+// the end of the last processed line once the reading is done (otherwise,
+// not yet processed data - including an incomplete last line - would be
+// skipped by the next check).
+func (ftw *FileTailReader) readLines(
+	ctx context.Context,
+	processor FileTailProcessor,
+	dataWriter *LogDataWriter,
+	inode int64,
+	maxLines int,
+	detector *parseStormDetector,
+) (numLines int, err error) {
+	defer func() {
+		if ftw.internalSeek < 0 {
+			return
+		}
+		if _, seekErr := ftw.file.Seek(ftw.internalSeek, io.SeekStart); seekErr != nil && err == nil {
+			err = seekErr
+		}
+	}()
+	newPosition := servicelog.LogRange{SeekEnd: -1, Inode: inode}
 	sc := bufio.NewReader(ftw.file)
 	var i int
-	for i = 0; i < ftw.processor.MaxLinesPerCheck(); i++ {
+	for i = 0; maxLines < 0 || i < maxLines; i++ {
+		if ctx.Err() != nil {
+			log.Info().
+				Str("logFile", ftw.filePath).
+				Int("numLines", i).
+				Msg("file reading interrupted")
+			break
+		}
+		if !ftw.waitForWriter(ctx, dataWriter) {
+			log.Info().
+				Str("logFile", ftw.filePath).
+				Int("numLines", i).
+				Msg("file reading interrupted while waiting for outputs")
+			break
+		}
 		newPosition.SeekStart = ftw.internalSeek
-		rawLine, err := sc.ReadBytes('\n')
+		rawLine, err := sc.ReadBytes(processor.RecordDelimiter())
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return err
+			return i, err
 		}
 		newPosition.SeekEnd = newPosition.SeekStart + int64(len(rawLine))
 		ftw.internalSeek = newPosition.SeekEnd
-		processor.OnEntry(dataWriter, string(rawLine[:len(rawLine)-1]), newPosition)
+		ftw.lineNum++
+		newPosition.Line = ftw.lineNum
+		ftw.lastPosition = newPosition
+		numErrors := processor.NumErrors()
+		processor.OnEntry(
+			dataWriter,
+			load.NormalizeLine(string(rawLine[:len(rawLine)-1]), newPosition.SeekStart == 0),
+			newPosition,
+		)
+		if detector != nil && detector.registerLine(processor.NumErrors() > numErrors) {
+			log.Warn().
+				Str("logFile", ftw.filePath).
+				Int("numLines", detector.numLines).
+				Int("numErrors", detector.numErrors).
+				Int("cooldownSecs", detector.conf.CooldownSecs).
+				Msg("too many line processing errors, suspending file reading")
+			return i + 1, nil
+		}
 	}
-	if i == ftw.processor.MaxLinesPerCheck() {
-		log.Warn().
-			Int("maxLinesPerCheck", ftw.processor.MaxLinesPerCheck()).
-			Str("logFile", ftw.filePath).
-			Str("name", ftw.AppType()).
-			Msg("tail processor hit the maxLinesPerCheck limit")
+	return i, nil
+}
+
+// waitForWriter blocks until the dataWriter is able to accept new
+// records. This prevents the reader from parsing (and buffering) more data
+// than the outputs are able to write (e.g. with a slow ElasticSearch during
+// a long catch-up). The returned value is false in case the ctx
+// has been cancelled while waiting.
+func (ftw *FileTailReader) waitForWriter(ctx context.Context, dataWriter *LogDataWriter) bool {
+	if !dataWriter.Saturated() {
+		return true
+	}
+	log.Debug().Str("logFile", ftw.filePath).Msg("outputs saturated, pausing file reading")
+	ticker := time.NewTicker(backpressurePollInterval)
+	defer ticker.Stop()
+	for dataWriter.Saturated() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	log.Debug().Str("logFile", ftw.filePath).Msg("outputs available again, resuming file reading")
+	return true
+}
+
+// close closes the watched file
+func (ftw *FileTailReader) close() {
+	if ftw.file != nil {
+		ftw.file.Close()
+		ftw.file = nil
 	}
-	return nil
 }
 
 // NewReader creates a new file reader instance
@@ -0,0 +1,310 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"klogproc/fsop"
+	"klogproc/load"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lineRecordingProcessor struct {
+	path        string
+	lines       []int64
+	items       []string
+	delim       string
+	numErrors   int64
+	eofIdleSecs int
+	eofMarkers  []servicelog.LogRange
+}
+
+func (p *lineRecordingProcessor) AppType() string            { return "test" }
+func (p *lineRecordingProcessor) FilePath() string           { return p.path }
+func (p *lineRecordingProcessor) MaxLinesPerCheck() int      { return 100 }
+func (p *lineRecordingProcessor) CheckIntervalSecs() int     { return 10 }
+func (p *lineRecordingProcessor) OnCheckStop(*LogDataWriter) {}
+func (p *lineRecordingProcessor) OnQuit()                    {}
+func (p *lineRecordingProcessor) EOFMarkerIdleSecs() int     { return p.eofIdleSecs }
+
+func (p *lineRecordingProcessor) OnEOF(writer *LogDataWriter, lastPosition servicelog.LogRange) {
+	p.eofMarkers = append(p.eofMarkers, lastPosition)
+}
+
+func (p *lineRecordingProcessor) NumErrors() int64 {
+	return p.numErrors
+}
+
+func (p *lineRecordingProcessor) RecordDelimiter() byte {
+	delim, _ := load.ParseRecordDelimiter(p.delim)
+	return delim
+}
+
+func (p *lineRecordingProcessor) OnCheckStart() (LineProcConfirmChan, *LogDataWriter) {
+	return nil, nil
+}
+
+func (p *lineRecordingProcessor) OnEntry(writer *LogDataWriter, item string, logPosition servicelog.LogRange) {
+	p.lines = append(p.lines, logPosition.Line)
+	p.items = append(p.items, item)
+	// for testing purposes, lines starting with '#' are considered invalid
+	if strings.HasPrefix(item, "#") {
+		p.numErrors++
+	}
+}
+
+func TestReaderLineNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\nb\nc\n"), 0644))
+	proc := &lineRecordingProcessor{path: path}
+	rdr, err := NewReader(proc, servicelog.LogRange{})
+	assert.NoError(t, err)
+	err = rdr.ApplyNewContent(context.Background(), proc, nil, servicelog.LogRange{Inode: -1})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, proc.lines)
+
+	// continue with a new reader from a worklog position without line info
+	inode, _, err := fsop.GetFileProps(path)
+	assert.NoError(t, err)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	f.WriteString("d\n")
+	f.Close()
+	proc.lines = nil
+	prev := servicelog.LogRange{Inode: inode, SeekStart: 4, SeekEnd: 6, Written: true}
+	rdr, err = NewReader(proc, prev)
+	assert.NoError(t, err)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []int64{4}, proc.lines)
+
+	// rotation (inode change) resets the counter
+	rotated := path + ".new"
+	assert.NoError(t, os.WriteFile(rotated, []byte("x\ny\n"), 0644))
+	assert.NoError(t, os.Rename(rotated, path))
+	proc.lines = nil
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, servicelog.LogRange{Inode: inode, SeekEnd: 8, Line: 4, Written: true}))
+	assert.Equal(t, []int64{1, 2}, proc.lines)
+}
+
+func TestReaderStripsBOMAndCRLF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("\ufeff{\"a\":1}\r\n{\"b\":2} \r\n\ufeffc\n"), 0644))
+	proc := &lineRecordingProcessor{path: path}
+	rdr, err := NewReader(proc, servicelog.LogRange{})
+	assert.NoError(t, err)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, servicelog.LogRange{Inode: -1}))
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`, "\ufeffc"}, proc.items)
+}
+
+func TestReaderDrainsRotatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\nb\n"), 0644))
+	inode, _, err := fsop.GetFileProps(path)
+	assert.NoError(t, err)
+	proc := &lineRecordingProcessor{path: path}
+	prev := servicelog.LogRange{Inode: inode, Written: true}
+	rdr, err := NewReader(proc, prev)
+	assert.NoError(t, err)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []string{"a", "b"}, proc.items)
+
+	// lines written right before the rotation
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	f.WriteString("c\nd\n")
+	f.Close()
+	assert.NoError(t, os.Rename(path, path+".1"))
+	assert.NoError(t, os.WriteFile(path, []byte("e\nf\n"), 0644))
+
+	proc.items = nil
+	proc.lines = nil
+	prev = servicelog.LogRange{Inode: inode, SeekStart: 2, SeekEnd: 4, Line: 2, Written: true}
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []string{"c", "d", "e", "f"}, proc.items)
+	assert.Equal(t, []int64{3, 4, 1, 2}, proc.lines)
+}
+
+func TestReaderNULDelimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\nb\x00c\x00d"), 0644))
+	proc := &lineRecordingProcessor{path: path, delim: load.RecordDelimiterNUL}
+	rdr, err := NewReader(proc, servicelog.LogRange{})
+	assert.NoError(t, err)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, servicelog.LogRange{Inode: -1}))
+	// the last record is not terminated yet
+	assert.Equal(t, []string{"a\nb", "c"}, proc.items)
+	assert.Equal(t, []int64{1, 2}, proc.lines)
+}
+
+func TestReaderParseErrorBackoff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\n#1\n#2\n#3\n#4\nb\n"), 0644))
+	inode, _, err := fsop.GetFileProps(path)
+	assert.NoError(t, err)
+	proc := &lineRecordingProcessor{path: path}
+	prev := servicelog.LogRange{Inode: inode, Written: true}
+	rdr, err := NewReader(proc, prev)
+	assert.NoError(t, err)
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	rdr.stormDetector = newParseStormDetector(
+		&ParseErrorBackoffConf{MinLines: 3, MaxErrorRatio: 0.6, CooldownSecs: 60})
+	rdr.stormDetector.now = func() time.Time { return now }
+
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	// 2 of 3 lines failed => suspended
+	assert.Equal(t, []string{"a", "#1", "#2"}, proc.items)
+
+	prev = servicelog.LogRange{Inode: inode, SeekStart: 5, SeekEnd: 8, Line: 3, Written: true}
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []string{"a", "#1", "#2"}, proc.items)
+
+	// after the cooldown, the reading continues
+	now = now.Add(31 * time.Second)
+	proc.items = nil
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []string{"#3", "#4", "b"}, proc.items)
+}
+
+func TestReaderKeepsIncompleteLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\nb"), 0644))
+	inode, _, err := fsop.GetFileProps(path)
+	assert.NoError(t, err)
+	proc := &lineRecordingProcessor{path: path}
+	prev := servicelog.LogRange{Inode: inode, Written: true}
+	rdr, err := NewReader(proc, prev)
+	assert.NoError(t, err)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []string{"a"}, proc.items)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	f.WriteString("c\n")
+	f.Close()
+	prev = servicelog.LogRange{Inode: inode, SeekStart: 0, SeekEnd: 2, Line: 1, Written: true}
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []string{"a", "bc"}, proc.items)
+}
+
+func TestReaderEOFMarker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("a\nb\n"), 0644))
+	proc := &lineRecordingProcessor{path: path, eofIdleSecs: 60}
+	rdr, err := NewReader(proc, servicelog.LogRange{})
+	assert.NoError(t, err)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, servicelog.LogRange{Inode: -1}))
+	inode, _, err := fsop.GetFileProps(path)
+	assert.NoError(t, err)
+	prev := servicelog.LogRange{Inode: inode, SeekStart: 2, SeekEnd: 4, Line: 2, Written: true}
+
+	// the file has not been idle long enough
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Empty(t, proc.eofMarkers)
+
+	rdr.lastGrowth = time.Now().Add(-time.Minute)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Equal(t, []servicelog.LogRange{{Inode: inode, SeekStart: 2, SeekEnd: 4, Line: 2}}, proc.eofMarkers)
+
+	// the file grows again => a new marker once it is idle
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	f.WriteString("c\n")
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Len(t, proc.eofMarkers, 1)
+	prev = servicelog.LogRange{Inode: inode, SeekStart: 4, SeekEnd: 6, Line: 3, Written: true}
+
+	// an incomplete line prevents the marker
+	f.WriteString("d")
+	rdr.lastGrowth = time.Now().Add(-time.Minute)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Len(t, proc.eofMarkers, 1)
+
+	f.WriteString("\n")
+	f.Close()
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	prev = servicelog.LogRange{Inode: inode, SeekStart: 6, SeekEnd: 8, Line: 4, Written: true}
+	rdr.lastGrowth = time.Now().Add(-time.Minute)
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, nil, prev))
+	assert.Len(t, proc.eofMarkers, 2)
+	assert.Equal(t, int64(8), proc.eofMarkers[1].SeekEnd)
+	assert.Equal(t, int64(4), proc.eofMarkers[1].Line)
+}
+
+// forwardingProcessor passes each line to the Elastic channel
+// of the data writer
+type forwardingProcessor struct {
+	lineRecordingProcessor
+	mu sync.Mutex
+}
+
+func (p *forwardingProcessor) OnEntry(writer *LogDataWriter, item string, logPosition servicelog.LogRange) {
+	p.mu.Lock()
+	p.lineRecordingProcessor.OnEntry(writer, item, logPosition)
+	p.mu.Unlock()
+	writer.Elastic <- &servicelog.BoundOutputRecord{FilePath: p.path, FilePos: logPosition}
+}
+
+func (p *forwardingProcessor) numLines() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.lines)
+}
+
+func TestReaderBackpressure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Repeat("a\n", 100)), 0644))
+	proc := &forwardingProcessor{lineRecordingProcessor: lineRecordingProcessor{path: path}}
+	rdr, err := NewReader(proc, servicelog.LogRange{})
+	assert.NoError(t, err)
+	writer := &LogDataWriter{Elastic: make(chan *servicelog.BoundOutputRecord, 10)}
+
+	// with a stalled consumer, the reader stops once the channel is almost full
+	// and it still respects cancellation
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, rdr.ApplyNewContent(ctx, proc, writer, servicelog.LogRange{Inode: -1}))
+	assert.Equal(t, 9, proc.numLines())
+	assert.Len(t, writer.Elastic, 9)
+
+	// once the consumer runs, the rest of the file is read
+	done := make(chan int)
+	go func() {
+		var n int
+		for range writer.Elastic {
+			n++
+		}
+		done <- n
+	}()
+	inode, _, err := fsop.GetFileProps(path)
+	assert.NoError(t, err)
+	prev := servicelog.LogRange{Inode: inode, SeekStart: 16, SeekEnd: 18, Line: 9, Written: true}
+	assert.NoError(t, rdr.ApplyNewContent(context.Background(), proc, writer, prev))
+	close(writer.Elastic)
+	assert.Equal(t, 100, <-done)
+	assert.Equal(t, 100, proc.numLines())
+}
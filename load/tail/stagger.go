@@ -0,0 +1,85 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+const (
+	// maxPollStaggerRatio specifies a max. offset of file's reads
+	// (relative to the check interval)
+	maxPollStaggerRatio = 0.5
+
+	// maxPollJitterRatio specifies a max. additional random delay
+	// of each read (relative to the check interval)
+	maxPollJitterRatio = 0.1
+)
+
+// pollScheduler spreads reads of individual files within a check
+// so that files watched together do not cause synchronized IO and
+// write spikes. Each file is read with a stable offset (a fraction
+// of the check interval derived from the file path) and a small
+// jitter. Both values are deterministic for a given path.
+type pollScheduler struct {
+	offset    time.Duration
+	maxJitter time.Duration
+	rnd       *rand.Rand
+}
+
+// nextDelay returns a delay of the next read
+func (ps *pollScheduler) nextDelay() time.Duration {
+	if ps.maxJitter <= 0 {
+		return ps.offset
+	}
+	return ps.offset + time.Duration(ps.rnd.Int63n(int64(ps.maxJitter)))
+}
+
+// wait waits for the next read. In case the ctx is cancelled
+// in the meantime, false is returned.
+func (ps *pollScheduler) wait(ctx context.Context) bool {
+	if ps == nil {
+		return true
+	}
+	timer := time.NewTimer(ps.nextDelay())
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newPollScheduler creates a scheduler for a file. In case the interval
+// is zero, nil is returned (i.e. no delays are applied).
+func newPollScheduler(filePath string, interval time.Duration) *pollScheduler {
+	if interval <= 0 {
+		return nil
+	}
+	h := fnv.New64a()
+	h.Write([]byte(filePath))
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+	return &pollScheduler{
+		offset:    time.Duration(rnd.Float64() * maxPollStaggerRatio * float64(interval)),
+		maxJitter: time.Duration(maxPollJitterRatio * float64(interval)),
+		rnd:       rnd,
+	}
+}
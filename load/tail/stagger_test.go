@@ -0,0 +1,65 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollSchedulerDeterministic(t *testing.T) {
+	interval := 60 * time.Second
+	ps1 := newPollScheduler("/var/log/kontext/query.log", interval)
+	ps2 := newPollScheduler("/var/log/kontext/query.log", interval)
+	ps3 := newPollScheduler("/var/log/wag/access.log", interval)
+	assert.Equal(t, ps1.offset, ps2.offset)
+	assert.NotEqual(t, ps1.offset, ps3.offset)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, ps1.nextDelay(), ps2.nextDelay())
+	}
+}
+
+func TestPollSchedulerBounds(t *testing.T) {
+	interval := 10 * time.Second
+	for _, path := range []string{"/a.log", "/b.log", "/c.log", "/d.log"} {
+		ps := newPollScheduler(path, interval)
+		assert.GreaterOrEqual(t, ps.offset, time.Duration(0))
+		assert.Less(t, ps.offset, interval/2)
+		for i := 0; i < 20; i++ {
+			delay := ps.nextDelay()
+			assert.GreaterOrEqual(t, delay, ps.offset)
+			assert.Less(t, delay, ps.offset+interval/10)
+		}
+	}
+}
+
+func TestPollSchedulerDisabled(t *testing.T) {
+	var ps *pollScheduler = newPollScheduler("/a.log", 0)
+	assert.Nil(t, ps)
+	assert.True(t, ps.wait(context.Background()))
+}
+
+func TestPollSchedulerWaitCancelled(t *testing.T) {
+	ps := newPollScheduler("/a.log", time.Hour)
+	ps.offset = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, ps.wait(ctx))
+}
@@ -17,15 +17,21 @@
 package tail
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"klogproc/fsop"
 	"klogproc/load"
+	"klogproc/load/alarm"
+	"klogproc/load/appconf"
 	"klogproc/save"
 	"klogproc/servicelog"
 
@@ -34,26 +40,116 @@ import (
 )
 
 const (
-	defaultTickerIntervalSecs = 60
+	defaultTickerIntervalSecs     = 60
+	defaultShutdownTimeoutSecs    = 30
+	defaultGlobRescanIntervalSecs = 60
+	defaultWorklogRetentionSecs   = 86400
+
+	// backpressureHighWatermark is a relative fill level of an output
+	// channel at which the reader stops reading new lines
+	backpressureHighWatermark = 0.9
+
+	// backpressurePollInterval specifies how often a blocked reader
+	// checks whether it can continue
+	backpressurePollInterval = 50 * time.Millisecond
 )
 
 // FileConf represents a configuration for a single
 // log file to be watched
 type FileConf struct {
-	Path    string `json:"path"`
-	AppType string `json:"appType"`
-	// Version represents a major and minor version signature as used in semantic versioning
-	// (e.g. 0.15, 1.2)
-	Version       string                   `json:"version"`
-	TZShift       int                      `json:"tzShift"`
-	Buffer        *load.BufferConf         `json:"buffer"`
-	ExcludeIPList servicelog.ExcludeIPList `json:"excludeIpList"`
+
+	// Path is either a path of a file or a glob pattern (e.g.
+	// `/var/log/kontext/*.log`) matching possibly multiple files.
+	// In the latter case, all the matching files are watched using
+	// the same configuration (see Conf.FullFiles).
+	Path string `json:"path"`
+
+	appconf.ProcessingConf
+
+	// InputFraming specifies an optional envelope each log line is wrapped
+	// in (e.g. `syslog5424`). By default, lines are parsed directly.
+	InputFraming string `json:"inputFraming"`
+
+	// UnwrapPattern is an optional regular expression applied to each line
+	// before parsing. The named group `payload` is then passed to the parser
+	// (e.g. `^<\d+>1 \S+ \S+ \S+ \S+ \S+ \S+ (?P<payload>\{.*\})$`). Lines
+	// not matching the pattern are reported as parsing errors.
+	UnwrapPattern string `json:"unwrapPattern"`
+
+	// RecordIDStrategy specifies how IDs of stored records are created
+	// (`natural` - default, or `fileOffset`). With `fileOffset`, IDs are
+	// derived from the file name and the position of the record in the
+	// file so reprocessing of the same file does not create duplicates.
+	RecordIDStrategy servicelog.RecordIDStrategy `json:"recordIdStrategy"`
+
+	// RecordIDSourceKey is an optional key distinguishing files of the same
+	// name (e.g. `/var/log/a/access.log` and `/var/log/b/access.log`) in IDs
+	// created by the `fileOffset` strategy. Without it, such files would get
+	// the same IDs and overwrite each other's records.
+	RecordIDSourceKey string `json:"recordIdSourceKey"`
+
+	// RecordDelimiter specifies how records are separated in the file.
+	// Supported values are `newline` (default), `nul` and any single
+	// character.
+	RecordDelimiter string `json:"recordDelimiter"`
+
+	// EOFMarkerIdleSecs enables emitting of a synthetic "end of file"
+	// record (see servicelog.EOFMarker) once the file is fully read and
+	// it has not grown for the specified number of seconds. This is
+	// intended for files written once and then closed. Zero (default)
+	// means no markers are emitted.
+	EOFMarkerIdleSecs int `json:"eofMarkerIdleSecs"`
+
+	// TransformWorkers specifies a number of goroutines parsing and
+	// transforming read lines concurrently. Values 0 and 1 (default) mean
+	// that lines are processed synchronously by the reader. With more
+	// workers, the order of written records may differ from the order
+	// of lines in the file.
+	TransformWorkers int `json:"transformWorkers"`
+
+	// InactivityLimitSecs overrides the global monitoring inactivity
+	// limit used by the `/health` endpoint for this file
+	InactivityLimitSecs int `json:"inactivityLimitSecs"`
+}
+
+// IsGlob tests whether Path is a glob pattern
+func (fc *FileConf) IsGlob() bool {
+	return strings.ContainsAny(fc.Path, "*?[")
 }
 
 func (fc *FileConf) Validate() error {
-	if pathExists := fs.PathExists(fc.Path); !pathExists {
+	if fc.IsGlob() {
+		if _, err := filepath.Match(fc.Path, ""); err != nil {
+			return fmt.Errorf("failed to validate FileConf for %s: %w", fc.Path, err)
+		}
+
+	} else if pathExists := fs.PathExists(fc.Path); !pathExists {
 		return fmt.Errorf("failed to validate FileConf for %s - path does not exist	", fc.Path)
 	}
+	if err := load.ValidateInputFraming(fc.InputFraming); err != nil {
+		return fmt.Errorf("failed to validate FileConf for %s: %w", fc.Path, err)
+	}
+	if _, err := load.CompileUnwrapPattern(fc.UnwrapPattern); err != nil {
+		return fmt.Errorf("failed to validate FileConf for %s: %w", fc.Path, err)
+	}
+	if err := fc.ProcessingConf.Validate(); err != nil {
+		return fmt.Errorf("failed to validate FileConf for %s: %w", fc.Path, err)
+	}
+	if err := load.ValidateRecordDelimiter(fc.RecordDelimiter); err != nil {
+		return fmt.Errorf("failed to validate FileConf for %s: %w", fc.Path, err)
+	}
+	if err := fc.RecordIDStrategy.Validate(); err != nil {
+		return fmt.Errorf("failed to validate FileConf for %s: %w", fc.Path, err)
+	}
+	if fc.InactivityLimitSecs < 0 {
+		return fmt.Errorf("failed to validate FileConf for %s - inactivityLimitSecs must be a non-negative number", fc.Path)
+	}
+	if fc.TransformWorkers < 0 {
+		return fmt.Errorf("failed to validate FileConf for %s - transformWorkers must be a non-negative number", fc.Path)
+	}
+	if fc.EOFMarkerIdleSecs < 0 {
+		return fmt.Errorf("failed to validate FileConf for %s - eofMarkerIdleSecs must be a non-negative number", fc.Path)
+	}
 	if fc.Buffer != nil && !fc.Buffer.IsReference() {
 		return fc.Buffer.Validate()
 	}
@@ -77,6 +173,138 @@ type Conf struct {
 	Files                 []FileConf `json:"files"`
 	NumErrorsAlarm        int        `json:"numErrorsAlarm"`
 	ErrCountTimeRangeSecs int        `json:"errCountTimeRangeSecs"`
+
+	// ErrorRatioAlarm optionally replaces the alarm based on the number
+	// of logged errors by an alarm triggered when the ratio of lines
+	// failed to be processed exceeds a configured limit
+	ErrorRatioAlarm *alarm.RatioAlarmConf `json:"errorRatioAlarm"`
+
+	// ParseErrorLogWindowSecs specifies a time window for aggregating
+	// line parsing errors into a single log summary. Zero means
+	// that each error is logged individually.
+	ParseErrorLogWindowSecs int `json:"parseErrorLogWindowSecs"`
+
+	// ParseErrorBackoff optionally enables suspending of file reading
+	// in case most of the read lines cannot be processed
+	ParseErrorBackoff *ParseErrorBackoffConf `json:"parseErrorBackoff"`
+
+	// GlobRescanIntervalSecs specifies how often glob patterns in file paths
+	// are expanded again so newly created matching files are watched too.
+	// If zero, a default value is used.
+	GlobRescanIntervalSecs int `json:"globRescanIntervalSecs"`
+
+	// ShutdownTimeoutSecs specifies how long klogproc waits (after
+	// receiving a termination signal) for the pending records to be written
+	// before it exits. Positions of records not written within the period
+	// are not stored in the worklog. If zero, a default value is used.
+	ShutdownTimeoutSecs int `json:"shutdownTimeoutSecs"`
+
+	// DisablePollStagger disables spreading of file reads within
+	// a check. By default, each file is read with a stable offset
+	// (up to a half of `intervalSecs`, derived from the file path)
+	// and a small random jitter so files do not cause IO and write
+	// spikes by being read at the same time.
+	DisablePollStagger bool `json:"disablePollStagger"`
+
+	// CompactWorklog enables removing of worklog records of files which
+	// no longer exist (e.g. rotated files which have been deleted) so
+	// the worklog does not grow unbounded.
+	CompactWorklog bool `json:"compactWorklog"`
+
+	// WorklogRetentionSecs specifies how long a file must be missing
+	// before its worklog record is removed (see CompactWorklog). This
+	// prevents removing files which are absent just during a rotation.
+	// If zero, a default value is used.
+	WorklogRetentionSecs int `json:"worklogRetentionSecs"`
+}
+
+// worklogRetention returns a configured retention of records
+// of missing files (or a default one if not configured)
+func (conf *Conf) worklogRetention() time.Duration {
+	if conf.WorklogRetentionSecs == 0 {
+		return time.Duration(defaultWorklogRetentionSecs) * time.Second
+	}
+	return time.Duration(conf.WorklogRetentionSecs) * time.Second
+}
+
+// checkInterval returns a configured interval of file checks
+// (or a default one if not configured)
+func (conf *Conf) checkInterval() time.Duration {
+	if conf.IntervalSecs == 0 {
+		return time.Duration(defaultTickerIntervalSecs) * time.Second
+	}
+	return time.Duration(conf.IntervalSecs) * time.Second
+}
+
+// pollStaggerInterval returns an interval file reads should be
+// spread over (zero if the feature is disabled)
+func (conf *Conf) pollStaggerInterval() time.Duration {
+	if conf.DisablePollStagger {
+		return 0
+	}
+	return conf.checkInterval()
+}
+
+// ShutdownTimeout returns a configured shutdown timeout
+// (or a default one if not configured)
+func (conf *Conf) ShutdownTimeout() time.Duration {
+	if conf.ShutdownTimeoutSecs == 0 {
+		return time.Duration(defaultShutdownTimeoutSecs) * time.Second
+	}
+	return time.Duration(conf.ShutdownTimeoutSecs) * time.Second
+}
+
+// GlobRescanInterval returns a configured interval of glob patterns
+// expansion (or a default one if not configured)
+func (conf *Conf) GlobRescanInterval() time.Duration {
+	if conf.GlobRescanIntervalSecs == 0 {
+		return time.Duration(defaultGlobRescanIntervalSecs) * time.Second
+	}
+	return time.Duration(conf.GlobRescanIntervalSecs) * time.Second
+}
+
+// HasGlobs tests whether any of the configured files is
+// specified by a glob pattern
+func (conf *Conf) HasGlobs() bool {
+	for _, fc := range conf.Files {
+		if fc.IsGlob() {
+			return true
+		}
+	}
+	return false
+}
+
+// expandGlobs replaces items with glob patterns by items of the matching
+// files (with all the other properties inherited). Directories are skipped
+// and each file is included only once (the first configuration wins).
+func expandGlobs(files []FileConf) []FileConf {
+	ans := make([]FileConf, 0, len(files))
+	used := make(map[string]bool)
+	for _, fc := range files {
+		if !fc.IsGlob() {
+			if !used[filepath.Clean(fc.Path)] {
+				ans = append(ans, fc)
+				used[filepath.Clean(fc.Path)] = true
+			}
+			continue
+		}
+		matches, err := filepath.Glob(fc.Path)
+		if err != nil {
+			// patterns are validated so this should not happen
+			log.Error().Err(err).Str("pattern", fc.Path).Msg("failed to expand file path pattern")
+			continue
+		}
+		for _, m := range matches {
+			if used[filepath.Clean(m)] || !fsop.IsFile(m) {
+				continue
+			}
+			item := fc
+			item.Path = m
+			ans = append(ans, item)
+			used[filepath.Clean(m)] = true
+		}
+	}
+	return ans
 }
 
 // FullFiles provides a slice of `FileConf` with items where
@@ -85,6 +313,9 @@ type Conf struct {
 // buffer between file processors and the buffer is configured
 // only for one of the processors (which is reasonable as
 // otherwise, there would be quite lot of rendundant conf. data)
+// Items with glob patterns are expanded to the currently matching files.
+// Files which would produce colliding `fileOffset` record IDs are rejected
+// (see FileConf.RecordIDSourceKey).
 func (conf *Conf) FullFiles() ([]FileConf, error) {
 	buffConfs := make(map[string]*load.BufferConf)
 	for _, v := range conf.Files {
@@ -104,14 +335,40 @@ func (conf *Conf) FullFiles() ([]FileConf, error) {
 			ans[i].Buffer = conf
 		}
 	}
+	ans = expandGlobs(ans)
+	if err := validateRecordIDSources(ans); err != nil {
+		return []FileConf{}, err
+	}
 	return ans, nil
 }
 
+// validateRecordIDSources tests whether the `fileOffset` record IDs of
+// the files (with expanded globs) are unique. Files of the same app type
+// and with the same name must be distinguished by RecordIDSourceKey.
+func validateRecordIDSources(files []FileConf) error {
+	used := make(map[string]string)
+	for _, fc := range files {
+		if fc.RecordIDStrategy != servicelog.RecordIDStrategyFileOffset {
+			continue
+		}
+		key := fc.AppType + "#" + servicelog.FileOffsetSource(fc.RecordIDSourceKey, fc.Path)
+		if prev, ok := used[key]; ok {
+			return fmt.Errorf(
+				"files %s and %s would produce colliding record IDs - please set distinct recordIdSourceKey",
+				prev, fc.Path)
+		}
+		used[key] = fc.Path
+	}
+	return nil
+}
+
 func (conf *Conf) RequiresMailConfiguration() bool {
-	return conf.NumErrorsAlarm > 0 && conf.ErrCountTimeRangeSecs > 0
+	return conf.NumErrorsAlarm > 0 && conf.ErrCountTimeRangeSecs > 0 || conf.ErrorRatioAlarm != nil
 }
 
-func (conf *Conf) Validate() error {
+// ValidateGlobals validates all the configuration except
+// for individual files
+func (conf *Conf) ValidateGlobals() error {
 	if conf.IntervalSecs < 10 {
 		return errors.New("logTail.intervalSecs must be at least 10")
 	}
@@ -132,6 +389,35 @@ func (conf *Conf) Validate() error {
 	if !isd {
 		return errors.New("logTail.logBufferStateDir does not seem to be a directory")
 	}
+	if conf.ParseErrorLogWindowSecs < 0 {
+		return errors.New("logTail.parseErrorLogWindowSecs must be a non-negative number")
+	}
+	if conf.WorklogRetentionSecs < 0 {
+		return errors.New("logTail.worklogRetentionSecs must be a non-negative number")
+	}
+	if conf.ParseErrorBackoff != nil {
+		if err := conf.ParseErrorBackoff.Validate(); err != nil {
+			return err
+		}
+	}
+	if conf.ErrorRatioAlarm != nil {
+		if err := conf.ErrorRatioAlarm.Validate(); err != nil {
+			return fmt.Errorf("logTail.%w", err)
+		}
+	}
+	if conf.GlobRescanIntervalSecs < 0 {
+		return errors.New("logTail.globRescanIntervalSecs must be a non-negative number")
+	}
+	if conf.ShutdownTimeoutSecs < 0 {
+		return errors.New("logTail.shutdownTimeoutSecs must be a non-negative number")
+	}
+	return nil
+}
+
+func (conf *Conf) Validate() error {
+	if err := conf.ValidateGlobals(); err != nil {
+		return err
+	}
 	for _, fc := range conf.Files {
 		if err := fc.Validate(); err != nil {
 			return fmt.Errorf("logTail.files validation error: %w", err)
@@ -148,9 +434,39 @@ type LineProcConfirmChan chan interface{}
 // in the previous check, both runs can independently write
 // their data.
 type LogDataWriter struct {
-	Elastic chan *servicelog.BoundOutputRecord
-	Influx  chan *servicelog.BoundOutputRecord
-	Ignored chan save.IgnoredItemMsg
+	Elastic    chan *servicelog.BoundOutputRecord
+	Influx     chan *servicelog.BoundOutputRecord
+	ClickHouse chan *servicelog.BoundOutputRecord
+	Loki       chan *servicelog.BoundOutputRecord
+	CouchDB    chan *servicelog.BoundOutputRecord
+	Stdout     chan *servicelog.BoundOutputRecord
+	Ignored    chan save.IgnoredItemMsg
+
+	// DeadLetter receives lines which could not be parsed
+	// or transformed (as opposed to intentionally ignored ones)
+	DeadLetter chan save.DeadLetterMsg
+}
+
+// RecordSinks returns channels of all the record outputs
+// (i.e. without Ignored and DeadLetter)
+func (w *LogDataWriter) RecordSinks() []chan *servicelog.BoundOutputRecord {
+	return []chan *servicelog.BoundOutputRecord{
+		w.Elastic, w.Influx, w.ClickHouse, w.Loki, w.CouchDB, w.Stdout}
+}
+
+// Saturated tells whether any of the buffered output channels is
+// (almost) full, i.e. the respective consumer does not keep up
+// with reading. Unbuffered channels are not considered.
+func (w *LogDataWriter) Saturated() bool {
+	if w == nil {
+		return false
+	}
+	for _, ch := range w.RecordSinks() {
+		if cap(ch) > 0 && float64(len(ch)) >= float64(cap(ch))*backpressureHighWatermark {
+			return true
+		}
+	}
+	return false
 }
 
 // FileTailProcessor specifies an object which is able to utilize all
@@ -162,6 +478,18 @@ type FileTailProcessor interface {
 	MaxLinesPerCheck() int
 	CheckIntervalSecs() int
 
+	// RecordDelimiter returns a byte separating individual
+	// records (lines) in the file
+	RecordDelimiter() byte
+
+	// NumErrors returns the number of lines the processor
+	// failed to process so far
+	NumErrors() int64
+
+	// EOFMarkerIdleSecs returns for how long a fully read file must
+	// not grow before OnEOF is called. Zero disables the feature.
+	EOFMarkerIdleSecs() int
+
 	// OnCheckStart marks start of logged file check
 	// it returns a writer for storing converted adata
 	// and also a channel where confirmations of writes
@@ -171,12 +499,27 @@ type FileTailProcessor interface {
 	// OnEntry is called on each processed line
 	OnEntry(writer *LogDataWriter, item string, logPosition servicelog.LogRange)
 
+	// OnEOF is called (once per a file "version") in case the file
+	// has been fully read and it has not grown for EOFMarkerIdleSecs.
+	// The lastPosition is the position of the last line of the file.
+	OnEOF(writer *LogDataWriter, lastPosition servicelog.LogRange)
+
 	// OnCheckStop marks the end of the single file check
 	OnCheckStop(writer *LogDataWriter)
 	OnQuit()
 }
 
-func initReaders(processors []FileTailProcessor, worklog *Worklog) ([]*FileTailReader, error) {
+// ProcessorFactory creates a processor for a file found by
+// a repeated expansion of configured glob patterns. In case the file
+// should not be processed (e.g. due to an app type filter), nil is returned.
+type ProcessorFactory func(fc FileConf) FileTailProcessor
+
+func initReaders(
+	processors []FileTailProcessor,
+	worklog *Worklog,
+	backoffConf *ParseErrorBackoffConf,
+	pollInterval time.Duration,
+) ([]*FileTailReader, error) {
 	readers := make([]*FileTailReader, len(processors))
 	for i, processor := range processors {
 		wlItem := worklog.GetData(processor.FilePath())
@@ -199,13 +542,140 @@ func initReaders(processors []FileTailProcessor, worklog *Worklog) ([]*FileTailR
 		if err != nil {
 			return readers, err
 		}
+		rdr.stormDetector = newParseStormDetector(backoffConf)
+		rdr.pollSched = newPollScheduler(processor.FilePath(), pollInterval)
 		readers[i] = rdr
 	}
 	return readers, nil
 }
 
-// Run starts the process of (multiple) log watching
-func Run(conf *Conf, processors []FileTailProcessor, finishEvent chan<- bool) {
+// runCheck reads new content of all the files and waits for all the
+// respective records to be written (or ignored). The worklog is updated
+// based on the received confirmations. Reads of individual files may be
+// delayed (see pollScheduler). Once the context is cancelled,
+// the readers stop reading new lines but the records already passed
+// to the processors are still written.
+func runCheck(ctx context.Context, readers []*FileTailReader, worklog *Worklog) {
+	var wg sync.WaitGroup
+	wg.Add(len(readers))
+	for _, reader := range readers {
+		go func(rdr *FileTailReader) {
+			if !rdr.pollSched.wait(ctx) {
+				wg.Done()
+				return
+			}
+			actionChan, writer := rdr.Processor().OnCheckStart()
+			go func() {
+				for action := range actionChan {
+					switch action := action.(type) {
+					case save.ConfirmMsg:
+						if action.Error != nil {
+							log.Error().Err(action.Error).Msg("Failed to write data to one of target databases")
+						}
+						worklog.UpdateFileInfo(action.FilePath, action.Position)
+					case save.IgnoredItemMsg:
+						worklog.UpdateFileInfo(action.FilePath, action.Position)
+					}
+				}
+				wg.Done()
+			}()
+			prevPos := worklog.GetData(rdr.processor.FilePath())
+			if currInode, _, err := fsop.GetFileProps(rdr.processor.FilePath()); err == nil &&
+				prevPos.Inode > 0 && currInode != prevPos.Inode {
+				// make sure confirmations from the original file
+				// cannot move the position of the new one
+				worklog.RegisterRotation(rdr.processor.FilePath(), currInode)
+			}
+			if err := rdr.ApplyNewContent(ctx, rdr.Processor(), writer, prevPos); err != nil {
+				log.Error().Err(err).Str("logFile", rdr.FilePath()).Msg("failed to read new content")
+			}
+			rdr.Processor().OnCheckStop(writer)
+		}(reader)
+	}
+	wg.Wait()
+}
+
+// rescanGlobs expands glob patterns of configured files again and returns
+// updated readers - i.e. with new readers for newly found files and without
+// readers of files no longer matching (e.g. removed ones). The function must
+// not be called while a check is running.
+func rescanGlobs(
+	conf *Conf,
+	readers []*FileTailReader,
+	worklog *Worklog,
+	newProcessor ProcessorFactory,
+) []*FileTailReader {
+	files, err := conf.FullFiles()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to expand configured files")
+		return readers
+	}
+	matching := make(map[string]bool)
+	for _, fc := range files {
+		matching[filepath.Clean(fc.Path)] = true
+	}
+	ans := make([]*FileTailReader, 0, len(readers))
+	for _, rdr := range readers {
+		if !matching[rdr.FilePath()] {
+			log.Warn().Str("logFile", rdr.FilePath()).Msg("file no longer found, stopping watching")
+			rdr.Processor().OnQuit()
+			rdr.close()
+			continue
+		}
+		ans = append(ans, rdr)
+	}
+	watched := make(map[string]bool)
+	for _, rdr := range ans {
+		watched[rdr.FilePath()] = true
+	}
+	for _, fc := range files {
+		if watched[filepath.Clean(fc.Path)] {
+			continue
+		}
+		processor := newProcessor(fc)
+		if processor == nil {
+			continue
+		}
+		newReaders, err := initReaders(
+			[]FileTailProcessor{processor}, worklog, conf.ParseErrorBackoff, conf.pollStaggerInterval())
+		if err != nil {
+			log.Error().Err(err).Str("logFile", fc.Path).Msg("failed to start watching a new file")
+			continue
+		}
+		log.Info().Str("logFile", fc.Path).Msg("found a new file matching configured pattern")
+		ans = append(ans, newReaders...)
+		watched[processor.FilePath()] = true
+	}
+	return ans
+}
+
+// waitForCheck waits for a running check (if any) to finish. In case
+// the check does not finish within the specified period, false is returned.
+func waitForCheck(checkDone <-chan struct{}, timeout time.Duration) bool {
+	if checkDone == nil {
+		return true
+	}
+	log.Info().Dur("timeout", timeout).Msg("waiting for pending records to be written")
+	select {
+	case <-checkDone:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Run starts the process of (multiple) log watching. The worklog
+// is expected to be created via NewWorklog (Run initializes it).
+// In case some of the files are configured using glob patterns and
+// newProcessor is provided, the patterns are periodically expanded
+// and newly found files are watched too.
+func Run(
+	conf *Conf,
+	processors []FileTailProcessor,
+	worklog *Worklog,
+	newProcessor ProcessorFactory,
+	finishEvent chan<- bool,
+) {
 	tickerInterval := time.Duration(conf.IntervalSecs)
 	if tickerInterval == 0 {
 		log.Warn().Msgf("intervalSecs for tail mode not set, using default %ds", defaultTickerIntervalSecs)
@@ -215,53 +685,81 @@ func Run(conf *Conf, processors []FileTailProcessor, finishEvent chan<- bool) {
 		log.Info().Msgf("configured to check for file changes every %d second(s)", tickerInterval)
 	}
 	ticker := time.NewTicker(tickerInterval * time.Second)
+	var rescanChan <-chan time.Time
+	if newProcessor != nil && conf.HasGlobs() {
+		rescanTicker := time.NewTicker(conf.GlobRescanInterval())
+		defer rescanTicker.Stop()
+		rescanChan = rescanTicker.C
+	}
+	// rescanPending is set in case a rescan should be performed
+	// once the running check finishes
+	var rescanPending bool
 	quitChan := make(chan bool, 10)
 	syscallChan := make(chan os.Signal, 10)
 	signal.Notify(syscallChan, os.Interrupt)
 	signal.Notify(syscallChan, syscall.SIGTERM)
-	worklog := NewWorklog(conf.WorklogPath)
 	var readers []*FileTailReader
+	if conf.CompactWorklog {
+		worklog.EnableCompaction(conf.worklogRetention())
+	}
 	err := worklog.Init()
 	if err != nil {
 		log.Error().Err(err).Msg("")
 		quitChan <- true
 
 	} else {
-		readers, err = initReaders(processors, worklog)
+		// note: we use all the configured files here (and not just the ones
+		// with processors) as the processors may be filtered (e.g. by app type)
+		fullFiles, err := conf.FullFiles()
+		if err != nil {
+			fullFiles = conf.Files
+		}
+		watchedFiles := make([]string, len(fullFiles))
+		for i, fc := range fullFiles {
+			watchedFiles[i] = filepath.Clean(fc.Path)
+		}
+		if _, err := worklog.Prune(watchedFiles); err != nil {
+			log.Error().Err(err).Msg("failed to prune worklog")
+		}
+		readers, err = initReaders(
+			processors, worklog, conf.ParseErrorBackoff, conf.pollStaggerInterval())
 		if err != nil {
 			log.Error().Err(err).Msg("")
 			quitChan <- true
 		}
 	}
 
+	ctx, stopReading := context.WithCancel(context.Background())
+	defer stopReading()
+	// checkDone is non-nil only while a check is running
+	var checkDone chan struct{}
+
 	for {
 		select {
 		case <-ticker.C:
-			var wg sync.WaitGroup
-			wg.Add(len(readers))
-			for _, reader := range readers {
-				go func(rdr *FileTailReader) {
-					actionChan, writer := rdr.Processor().OnCheckStart()
-					go func() {
-						for action := range actionChan {
-							switch action := action.(type) {
-							case save.ConfirmMsg:
-								if action.Error != nil {
-									log.Error().Err(action.Error).Msg("Failed to write data to one of target databases")
-								}
-								worklog.UpdateFileInfo(action.FilePath, action.Position)
-							case save.IgnoredItemMsg:
-								worklog.UpdateFileInfo(action.FilePath, action.Position)
-							}
-						}
-						wg.Done()
-					}()
-					prevPos := worklog.GetData(rdr.processor.FilePath())
-					rdr.ApplyNewContent(rdr.Processor(), writer, prevPos)
-					rdr.Processor().OnCheckStop(writer)
-				}(reader)
+			if checkDone != nil {
+				log.Warn().Msg("previous file check still running, skipping the current one")
+				continue
+			}
+			checkDone = make(chan struct{})
+			go func(done chan struct{}) {
+				runCheck(ctx, readers, worklog)
+				close(done)
+			}(checkDone)
+
+		case <-checkDone:
+			checkDone = nil
+			if rescanPending {
+				readers = rescanGlobs(conf, readers, worklog, newProcessor)
+				rescanPending = false
+			}
+
+		case <-rescanChan:
+			if checkDone != nil {
+				rescanPending = true
+				continue
 			}
-			wg.Wait()
+			readers = rescanGlobs(conf, readers, worklog, newProcessor)
 
 		case quit := <-quitChan:
 			if quit {
@@ -275,6 +773,12 @@ func Run(conf *Conf, processors []FileTailProcessor, finishEvent chan<- bool) {
 		case <-syscallChan:
 			log.Warn().Msg("Caught signal, exiting...")
 			ticker.Stop()
+			stopReading()
+			if !waitForCheck(checkDone, conf.ShutdownTimeout()) {
+				log.Warn().
+					Dur("timeout", conf.ShutdownTimeout()).
+					Msg("shutdown timeout exceeded, positions of unwritten records won't be stored")
+			}
 			for _, reader := range readers {
 				reader.Processor().OnQuit()
 			}
@@ -0,0 +1,229 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"klogproc/load"
+	"klogproc/load/appconf"
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowSinkProcessor simulates a storage with a noticeable write latency.
+// Each record is confirmed only after it has been "flushed".
+type slowSinkProcessor struct {
+	lineRecordingProcessor
+	cancelAt int64
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	flushed  []servicelog.LogRange
+}
+
+func (p *slowSinkProcessor) OnCheckStart() (LineProcConfirmChan, *LogDataWriter) {
+	confirm := make(LineProcConfirmChan)
+	writer := &LogDataWriter{Elastic: make(chan *servicelog.BoundOutputRecord, 10)}
+	go func() {
+		for rec := range writer.Elastic {
+			time.Sleep(10 * time.Millisecond)
+			rec.FilePos.Written = true
+			p.mu.Lock()
+			p.flushed = append(p.flushed, rec.FilePos)
+			p.mu.Unlock()
+			confirm <- save.ConfirmMsg{FilePath: rec.FilePath, Position: rec.FilePos}
+		}
+		close(confirm)
+	}()
+	return confirm, writer
+}
+
+func (p *slowSinkProcessor) OnEntry(writer *LogDataWriter, item string, logPosition servicelog.LogRange) {
+	p.lineRecordingProcessor.OnEntry(writer, item, logPosition)
+	writer.Elastic <- &servicelog.BoundOutputRecord{FilePath: p.path, FilePos: logPosition}
+	if logPosition.Line == p.cancelAt {
+		p.cancel()
+	}
+}
+
+func (p *slowSinkProcessor) OnCheckStop(writer *LogDataWriter) {
+	close(writer.Elastic)
+}
+
+func TestRunCheckCancelledMidCheck(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	assert.NoError(t, os.WriteFile(logPath, []byte("a\nb\nc\nd\ne\nf\n"), 0644))
+	wlPath := filepath.Join(dir, "worklog.json")
+	wl := NewWorklog(wlPath)
+	assert.NoError(t, wl.Init())
+	_, err := wl.ResetFile(logPath)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	proc := &slowSinkProcessor{
+		lineRecordingProcessor: lineRecordingProcessor{path: logPath},
+		cancelAt:               3,
+		cancel:                 cancel,
+	}
+	rdr, err := NewReader(proc, wl.GetData(logPath))
+	assert.NoError(t, err)
+	runCheck(ctx, []*FileTailReader{rdr}, wl)
+	wl.Close()
+
+	// all the lines read before the cancellation must be written...
+	assert.Equal(t, []string{"a", "b", "c"}, proc.items)
+	assert.Len(t, proc.flushed, 3)
+
+	// ... and the stored position must not go beyond the written ones
+	wl2 := NewWorklog(wlPath)
+	assert.NoError(t, wl2.Init())
+	defer wl2.Close()
+	stored := wl2.GetData(logPath)
+	assert.Equal(t, proc.flushed[len(proc.flushed)-1], stored)
+	assert.Equal(t, int64(6), stored.SeekEnd)
+}
+
+func TestWaitForCheckTimeout(t *testing.T) {
+	done := make(chan struct{})
+	assert.False(t, waitForCheck(done, 10*time.Millisecond))
+	close(done)
+	assert.True(t, waitForCheck(done, time.Second))
+	assert.True(t, waitForCheck(nil, time.Second))
+}
+
+func TestConfShutdownTimeout(t *testing.T) {
+	conf := Conf{}
+	assert.Equal(t, defaultShutdownTimeoutSecs*time.Second, conf.ShutdownTimeout())
+	conf.ShutdownTimeoutSecs = 5
+	assert.Equal(t, 5*time.Second, conf.ShutdownTimeout())
+}
+
+func TestFullFilesExpandsGlobs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "c.txt"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte{}, 0644))
+	}
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "d.log"), 0755))
+	buff := &load.BufferConf{
+		ID: "kontext", HistoryLookupItems: 10, AnalysisIntervalSecs: 30,
+		BotDetection: &load.BotDetectionConf{},
+	}
+	conf := Conf{
+		Files: []FileConf{
+			{Path: filepath.Join(dir, "b.log"), ProcessingConf: appconf.ProcessingConf{AppType: "syd"}},
+			{Path: filepath.Join(dir, "*.log"), ProcessingConf: appconf.ProcessingConf{
+				AppType: "kontext", Version: "0.18", Buffer: &load.BufferConf{ID: "kontext"}}},
+			{Path: filepath.Join(dir, "c.txt"), ProcessingConf: appconf.ProcessingConf{AppType: "kontext", Buffer: buff}},
+		},
+	}
+	assert.True(t, conf.HasGlobs())
+	files, err := conf.FullFiles()
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	assert.Equal(t, filepath.Join(dir, "b.log"), files[0].Path)
+	assert.Equal(t, "syd", files[0].AppType)
+	assert.Equal(t, filepath.Join(dir, "a.log"), files[1].Path)
+	assert.Equal(t, "kontext", files[1].AppType)
+	assert.Equal(t, "0.18", files[1].Version)
+	assert.Equal(t, buff, files[1].Buffer)
+	assert.Equal(t, filepath.Join(dir, "c.txt"), files[2].Path)
+}
+
+func TestFullFilesRecordIDSourceCollision(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, sub, "access.log"), []byte{}, 0644))
+	}
+	procConf := appconf.ProcessingConf{AppType: "ske"}
+	conf := Conf{
+		Files: []FileConf{
+			{Path: filepath.Join(dir, "a", "access.log"), ProcessingConf: procConf},
+			{Path: filepath.Join(dir, "b", "access.log"), ProcessingConf: procConf},
+		},
+	}
+	_, err := conf.FullFiles()
+	assert.NoError(t, err)
+
+	conf.Files[0].RecordIDStrategy = servicelog.RecordIDStrategyFileOffset
+	conf.Files[1].RecordIDStrategy = servicelog.RecordIDStrategyFileOffset
+	_, err = conf.FullFiles()
+	assert.Error(t, err)
+
+	conf.Files[1].RecordIDSourceKey = "b"
+	_, err = conf.FullFiles()
+	assert.NoError(t, err)
+}
+
+func TestFileConfValidateGlob(t *testing.T) {
+	fc := FileConf{Path: "/non/existing/*.log"}
+	assert.NoError(t, fc.Validate())
+	fc.Path = "/non/existing/[.log"
+	assert.Error(t, fc.Validate())
+	fc.Path = "/non/existing/app.log"
+	assert.Error(t, fc.Validate())
+}
+
+func TestFileConfValidateTransformWorkers(t *testing.T) {
+	fc := FileConf{Path: "/non/existing/*.log", TransformWorkers: 4}
+	assert.NoError(t, fc.Validate())
+	fc.TransformWorkers = -1
+	assert.Error(t, fc.Validate())
+}
+
+func TestRescanGlobs(t *testing.T) {
+	dir := t.TempDir()
+	logA := filepath.Join(dir, "a.log")
+	assert.NoError(t, os.WriteFile(logA, []byte("a\n"), 0644))
+	wl := NewWorklog(filepath.Join(dir, "worklog.json"))
+	assert.NoError(t, wl.Init())
+	defer wl.Close()
+	conf := &Conf{Files: []FileConf{{Path: filepath.Join(dir, "*.log"), ProcessingConf: appconf.ProcessingConf{AppType: "test"}}}}
+	var created []string
+	factory := func(fc FileConf) FileTailProcessor {
+		created = append(created, fc.Path)
+		if strings.HasSuffix(fc.Path, "ignored.log") {
+			return nil
+		}
+		return &lineRecordingProcessor{path: fc.Path}
+	}
+	readers := rescanGlobs(conf, nil, wl, factory)
+	assert.Len(t, readers, 1)
+	assert.Equal(t, logA, readers[0].FilePath())
+
+	logB := filepath.Join(dir, "b.log")
+	assert.NoError(t, os.WriteFile(logB, []byte("b\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.log"), []byte("x\n"), 0644))
+	readers = rescanGlobs(conf, readers, wl, factory)
+	assert.Len(t, readers, 2)
+	assert.Equal(t, logB, readers[1].FilePath())
+	assert.Equal(t, []string{logA, logB, filepath.Join(dir, "ignored.log")}, created)
+
+	assert.NoError(t, os.Remove(logA))
+	readers = rescanGlobs(conf, readers, wl, factory)
+	assert.Len(t, readers, 1)
+	assert.Equal(t, logB, readers[0].FilePath())
+}
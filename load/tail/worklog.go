@@ -18,9 +18,15 @@ package tail
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"klogproc/fsop"
 	"klogproc/servicelog"
@@ -30,9 +36,23 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// maxRetiredInodes specifies how many replaced inodes
+	// are remembered for each file
+	maxRetiredInodes = 8
+
+	// worklogCompactionInterval specifies how often (at most)
+	// the worklog is checked for records of no longer existing files
+	worklogCompactionInterval = time.Minute
+)
+
 type updateRequest struct {
 	FilePath string
 	Value    servicelog.LogRange
+
+	// Rotation marks a transition record switching the file
+	// to a new inode (Value.Inode) with a zero position
+	Rotation bool
 }
 
 // WorklogRecord provides log reading position info for all configured apps
@@ -44,11 +64,51 @@ type WorklogRecord = map[string]servicelog.LogRange
 // even if they arrive out of order - which is rather a typical
 // situation (e.g. ignored lines are confirmed sooner that the ones
 // send to Elastic/Influx).
+//
+// During a log rotation, confirmations of records from the original
+// (rotated) file may arrive after the ones from the new file. To prevent
+// such stale confirmations from switching the worklog back to the old
+// inode, positions of rotated files are tracked separately (in memory only).
+// Once a file switches to a new inode (either via an explicit transition
+// record - see RegisterRotation - or by receiving a confirmation with
+// a new inode), the position is reset to zero and any later updates
+// tagged with the replaced inode are considered stale.
 type Worklog struct {
 	filePath    string
 	fr          *os.File
 	rec         *collections.ConcurrentMap[string, servicelog.LogRange]
+	rotated     *collections.ConcurrentMap[string, servicelog.LogRange]
 	updRequests chan updateRequest
+
+	// retiredInodes contains replaced inodes of individual files.
+	// It is accessed only by the update processing goroutine.
+	retiredInodes map[string][]int64
+
+	// updMutex guards sending of update requests against closing
+	// the worklog (confirmations may arrive even after Close in case
+	// writing of data takes longer than the shutdown timeout)
+	updMutex sync.RWMutex
+	closed   bool
+
+	// updDone is closed once all the update requests are processed
+	updDone chan struct{}
+
+	// compaction is nil unless enabled via EnableCompaction
+	compaction *worklogCompaction
+
+	// watched contains paths of watched files as provided to Prune().
+	// Records of these files are never removed by compaction.
+	watched []string
+}
+
+// worklogCompaction holds a state of pruning of records
+// of no longer existing files. It is accessed only via save().
+type worklogCompaction struct {
+	retention time.Duration
+	lastRun   time.Time
+
+	// missingSince contains times files have been first found missing
+	missingSince map[string]time.Time
 }
 
 // Init initializes the worklog. It must be called before any other
@@ -75,20 +135,31 @@ func (w *Worklog) Init() error {
 		}
 	}
 	w.updRequests = make(chan updateRequest)
+	w.updDone = make(chan struct{})
 	go func() {
+		defer close(w.updDone)
 		for req := range w.updRequests {
+			if req.Rotation {
+				w.applyRotation(req.FilePath, req.Value.Inode)
+				continue
+			}
 			curr := w.rec.Get(req.FilePath)
+			if w.isRetiredInode(req) || w.isFromRotatedFile(req, curr) {
+				w.updateRotated(req)
+				continue
+			}
 			if curr.Inode != req.Value.Inode {
-				log.Warn().Msgf("inode for %s has changed from %d to %d", req.FilePath, curr.Inode, req.Value.Inode)
+				// no transition record received (yet)
+				w.applyRotation(req.FilePath, req.Value.Inode)
+				curr = w.rec.Get(req.FilePath)
 			}
 			// rules for worklog update:
-			// 1) if inodes differ then write the new record
+			// 1) inode changes are handled by a transition to zero position (see applyRotation)
 			// 2) non-written incoming item always overwrites a written one (to make sure we try again from its position)
 			// 3) non-written incoming rewrites the current written no matter how old it is
 			// 4) written incoming item can fix current non-written if its older or of the same age
 			// 5) if both are written then only more recent (higher seek) can overwrite the current one
-			if curr.Inode != req.Value.Inode ||
-				!curr.Written && curr.SeekStart >= req.Value.SeekStart ||
+			if !curr.Written && curr.SeekStart >= req.Value.SeekStart ||
 				curr.Written && req.Value.SeekEnd >= curr.SeekEnd ||
 				!req.Value.Written && (curr.Written || req.Value.SeekEnd < curr.SeekEnd) {
 				w.rec.Set(req.FilePath, req.Value)
@@ -102,20 +173,113 @@ func (w *Worklog) Init() error {
 	return nil
 }
 
-// Close cleans up worklog for safe exit
+// Close cleans up worklog for safe exit. All the update requests
+// received so far are processed and the final state is saved before
+// the worklog file is closed. Any later updates are ignored.
 func (w *Worklog) Close() {
-	if w.fr != nil {
-		w.fr.Close()
+	w.updMutex.Lock()
+	if w.closed {
+		w.updMutex.Unlock()
+		return
 	}
+	w.closed = true
 	if w.updRequests != nil {
 		close(w.updRequests)
 	}
+	w.updMutex.Unlock()
+	if w.updDone != nil {
+		<-w.updDone
+	}
+	if w.fr != nil {
+		if err := w.save(); err != nil {
+			log.Error().Err(err).Msg("failed to save worklog before closing")
+		}
+		w.fr.Close()
+	}
+}
+
+// EnableCompaction enables pruning of records of files which no longer
+// exist. A record is removed once its file is continuously missing for
+// at least the retention period (so files temporarily absent during
+// rotation are kept). Records of watched files (see Prune) are always
+// kept as their position is needed once the file appears again.
+// The pruning is performed as part of saving the worklog (at most
+// once per worklogCompactionInterval).
+//
+// The method should be called before Init().
+func (w *Worklog) EnableCompaction(retention time.Duration) {
+	w.compaction = &worklogCompaction{
+		retention:    retention,
+		missingSince: make(map[string]time.Time),
+	}
+}
+
+// compact removes records of files missing for at least the retention
+// period and returns number of removed records.
+func (w *Worklog) compact(now time.Time) int {
+	w.compaction.lastRun = now
+	removed := w.removeRecords(func(filePath string) bool {
+		if w.isWatched(filePath) {
+			delete(w.compaction.missingSince, filePath)
+			return false
+		}
+		_, _, err := fsop.GetFileProps(filePath)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			delete(w.compaction.missingSince, filePath)
+			return false
+		}
+		since, ok := w.compaction.missingSince[filePath]
+		if !ok {
+			w.compaction.missingSince[filePath] = now
+			return false
+		}
+		if now.Sub(since) >= w.compaction.retention {
+			delete(w.compaction.missingSince, filePath)
+			return true
+		}
+		return false
+	})
+	if removed > 0 {
+		log.Info().Int("numPruned", removed).Msg("worklog compacted - removed records of no longer existing files")
+	}
+	return removed
+}
+
+// removeRecords removes all the information about files matching
+// the provided predicate and returns number of removed records.
+func (w *Worklog) removeRecords(isStale func(filePath string) bool) int {
+	var removed int
+	for _, filePath := range w.rec.Keys() {
+		if isStale(filePath) {
+			w.rec.Delete(filePath)
+			w.rotated.Delete(filePath)
+			delete(w.retiredInodes, filePath)
+			log.Info().Str("file", filePath).Msg("removing stale worklog record")
+			removed++
+		}
+	}
+	return removed
+}
+
+// isWatched tests whether the file is among the watched ones
+func (w *Worklog) isWatched(filePath string) bool {
+	for _, k := range w.watched {
+		if filePath == k {
+			return true
+		}
+	}
+	return false
 }
 
 // save stores worklog's state to a configured file.
 // It is called automatically after each log update
 // request is processed.
 func (w *Worklog) save() error {
+	if w.compaction != nil {
+		if now := time.Now(); now.Sub(w.compaction.lastRun) >= worklogCompactionInterval {
+			w.compact(now)
+		}
+	}
 	err := w.fr.Truncate(0)
 	if err != nil {
 		return err
@@ -139,15 +303,112 @@ func (w *Worklog) save() error {
 	return nil
 }
 
+// applyRotation switches the file to a new inode with a zero position
+// and marks the original inode as retired so any later updates tagged
+// with it cannot affect the file's position. In case the file already
+// uses the inode, nothing is changed.
+func (w *Worklog) applyRotation(filePath string, inode int64) {
+	curr := w.rec.Get(filePath)
+	if curr.Inode == inode {
+		return
+	}
+	if curr.Inode > 0 {
+		log.Warn().Msgf("inode for %s has changed from %d to %d", filePath, curr.Inode, inode)
+		w.rotated.Set(filePath, curr)
+		retired := append(w.retiredInodes[filePath], curr.Inode)
+		if len(retired) > maxRetiredInodes {
+			retired = retired[len(retired)-maxRetiredInodes:]
+		}
+		w.retiredInodes[filePath] = retired
+	}
+	w.rec.Set(filePath, servicelog.LogRange{Inode: inode, Written: true})
+	if err := w.save(); err != nil {
+		log.Error().Err(err).Str("file", filePath).Msg("failed to save worklog after inode change")
+	}
+}
+
+// isRetiredInode tests whether the request refers to an inode
+// which has been already replaced by a newer one
+func (w *Worklog) isRetiredInode(req updateRequest) bool {
+	for _, inode := range w.retiredInodes[req.FilePath] {
+		if inode == req.Value.Inode {
+			return true
+		}
+	}
+	return false
+}
+
+// isFromRotatedFile tests whether the request refers to a file which has
+// been already replaced by a new one (i.e. the worklog already contains
+// the inode of the current file and the request comes with a different one).
+func (w *Worklog) isFromRotatedFile(req updateRequest, curr servicelog.LogRange) bool {
+	if curr.Inode <= 0 || curr.Inode == req.Value.Inode {
+		return false
+	}
+	actualInode, _, err := fsop.GetFileProps(req.FilePath)
+	return err == nil && actualInode == curr.Inode
+}
+
+// updateRotated stores a position of a rotated file. Only more
+// recent positions are accepted.
+func (w *Worklog) updateRotated(req updateRequest) {
+	curr, ok := w.rotated.GetWithTest(req.FilePath)
+	if !ok || curr.Inode != req.Value.Inode || req.Value.SeekEnd >= curr.SeekEnd {
+		w.rotated.Set(req.FilePath, req.Value)
+	}
+	log.Debug().
+		Str("file", req.FilePath).
+		Int64("inode", req.Value.Inode).
+		Msg("worklog: received a confirmation for a rotated file")
+}
+
+// GetRotatedData returns the last known reading position of a rotated
+// (i.e. replaced by a new file) version of the provided file. The information
+// is available only in case a rotation has been detected since klogproc start.
+func (w *Worklog) GetRotatedData(filePath string) (servicelog.LogRange, bool) {
+	return w.rotated.GetWithTest(filePath)
+}
+
 // UpdateFileInfo adds individual app reading position info. Please
 // note that this does not save the worklog.
 func (w *Worklog) UpdateFileInfo(filePath string, logPosition servicelog.LogRange) {
+	w.updMutex.RLock()
+	defer w.updMutex.RUnlock()
+	if w.closed {
+		log.Warn().
+			Str("file", filePath).
+			Int64("seekEnd", logPosition.SeekEnd).
+			Msg("worklog already closed, ignoring position update")
+		return
+	}
 	w.updRequests <- updateRequest{
 		FilePath: filePath,
 		Value:    logPosition,
 	}
 }
 
+// RegisterRotation sends a transition record switching the file
+// to a new inode with a zero position. Any update requests tagged
+// with the original inode received later are considered stale
+// (i.e. they do not affect the position of the file). In case
+// the file already uses the inode, nothing is changed.
+func (w *Worklog) RegisterRotation(filePath string, inode int64) {
+	w.updMutex.RLock()
+	defer w.updMutex.RUnlock()
+	if w.closed {
+		log.Warn().
+			Str("file", filePath).
+			Int64("inode", inode).
+			Msg("worklog already closed, ignoring inode change")
+		return
+	}
+	w.updRequests <- updateRequest{
+		FilePath: filePath,
+		Value:    servicelog.LogRange{Inode: inode, Written: true},
+		Rotation: true,
+	}
+}
+
 // ResetFile sets a zero seek and line for a new or an existing file.
 // Returns an inode of a respective file and a possible error
 func (w *Worklog) ResetFile(filePath string) (int64, error) {
@@ -155,18 +416,53 @@ func (w *Worklog) ResetFile(filePath string) (int64, error) {
 	if err != nil {
 		return -1, err
 	}
-	w.updRequests <- updateRequest{
-		FilePath: filePath,
-		Value: servicelog.LogRange{
-			Inode:     inode,
-			SeekStart: 0,
-			SeekEnd:   0,
-			Written:   true, // otherwise update request won't be accepted
-		},
-	}
+	w.RegisterRotation(filePath, inode)
 	return inode, nil
 }
 
+// isRotatedVariant tests whether filePath looks like a rotated
+// version of the watchedPath (e.g. `app.log.1`, `app.log.gz`, `app.log.2.gz`)
+func isRotatedVariant(filePath, watchedPath string) bool {
+	if !strings.HasPrefix(filePath, watchedPath+".") {
+		return false
+	}
+	suffix := strings.TrimSuffix(strings.TrimPrefix(filePath, watchedPath+"."), ".gz")
+	if suffix == "gz" || suffix == "" {
+		return true
+	}
+	_, err := strconv.Atoi(suffix)
+	return err == nil
+}
+
+// Prune removes records of files which are not among the
+// provided ones. Rotated variants of the kept files (e.g.
+// `.1`, `.gz` suffixes) are preserved as they may be still
+// consumed. The provided files are also remembered as watched
+// so compaction (if enabled) never removes their records.
+// The worklog is saved in case anything changes.
+// Returns number of removed records.
+//
+// The method should be called right after Init() before any
+// update requests are sent.
+func (w *Worklog) Prune(keep []string) (int, error) {
+	w.watched = keep
+	removed := w.removeRecords(func(filePath string) bool {
+		if w.isWatched(filePath) {
+			return false
+		}
+		for _, k := range keep {
+			if isRotatedVariant(filePath, k) {
+				return false
+			}
+		}
+		return true
+	})
+	if removed > 0 {
+		return removed, w.save()
+	}
+	return 0, nil
+}
+
 // GetData retrieves reading info for a provided app
 func (w *Worklog) GetData(filePath string) servicelog.LogRange {
 	v, ok := w.rec.GetWithTest(filePath)
@@ -182,5 +478,8 @@ func NewWorklog(path string) *Worklog {
 	return &Worklog{
 		filePath: path,
 		rec:      collections.NewConcurrentMap[string, servicelog.LogRange](),
+		rotated:  collections.NewConcurrentMap[string, servicelog.LogRange](),
+
+		retiredInodes: make(map[string][]int64),
 	}
 }
@@ -0,0 +1,208 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"klogproc/fsop"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorklogPrune(t *testing.T) {
+	wlPath := filepath.Join(t.TempDir(), "worklog.json")
+	data := `{
+		"/var/log/kontext.log": {"inode": 1, "seekStart": 0, "seekEnd": 10, "written": true},
+		"/var/log/kontext.log.1": {"inode": 2, "seekStart": 0, "seekEnd": 10, "written": true},
+		"/var/log/kontext.log.2.gz": {"inode": 3, "seekStart": 0, "seekEnd": 10, "written": true},
+		"/var/log/kontext.log.old": {"inode": 4, "seekStart": 0, "seekEnd": 10, "written": true},
+		"/var/log/syd.log": {"inode": 5, "seekStart": 0, "seekEnd": 10, "written": true},
+		"/var/log/treq.log": {"inode": 6, "seekStart": 0, "seekEnd": 10, "written": true}
+	}`
+	assert.NoError(t, os.WriteFile(wlPath, []byte(data), 0644))
+	wl := NewWorklog(wlPath)
+	assert.NoError(t, wl.Init())
+	defer wl.Close()
+
+	removed, err := wl.Prune([]string{"/var/log/kontext.log", "/var/log/treq.log"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.ElementsMatch(
+		t,
+		[]string{
+			"/var/log/kontext.log",
+			"/var/log/kontext.log.1",
+			"/var/log/kontext.log.2.gz",
+			"/var/log/treq.log",
+		},
+		wl.rec.Keys(),
+	)
+
+	// make sure the pruned state is persisted
+	wl2 := NewWorklog(wlPath)
+	assert.NoError(t, wl2.Init())
+	defer wl2.Close()
+	assert.Equal(t, int64(-1), wl2.GetData("/var/log/syd.log").Inode)
+	assert.Equal(t, int64(6), wl2.GetData("/var/log/treq.log").Inode)
+}
+
+func TestWorklogIgnoresLateRotatedConfirmations(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	assert.NoError(t, os.WriteFile(logPath, []byte("a\nb\n"), 0644))
+	oldInode, _, err := fsop.GetFileProps(logPath)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Rename(logPath, logPath+".1"))
+	assert.NoError(t, os.WriteFile(logPath, []byte("c\n"), 0644))
+	newInode, _, err := fsop.GetFileProps(logPath)
+	assert.NoError(t, err)
+
+	wl := NewWorklog(filepath.Join(dir, "worklog.json"))
+	assert.NoError(t, wl.Init())
+	defer wl.Close()
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: oldInode, SeekStart: 0, SeekEnd: 2, Written: true})
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: newInode, SeekStart: 0, SeekEnd: 2, Written: true})
+	// a late confirmation from the rotated file
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: oldInode, SeekStart: 2, SeekEnd: 4, Written: true})
+
+	assert.Eventually(
+		t,
+		func() bool {
+			rot, ok := wl.GetRotatedData(logPath)
+			return ok && rot.SeekEnd == 4
+		},
+		time.Second,
+		10*time.Millisecond,
+	)
+	assert.Equal(t, newInode, wl.GetData(logPath).Inode)
+	assert.Equal(t, int64(2), wl.GetData(logPath).SeekEnd)
+}
+
+func TestWorklogIgnoresUpdatesAfterClose(t *testing.T) {
+	wlPath := filepath.Join(t.TempDir(), "worklog.json")
+	wl := NewWorklog(wlPath)
+	assert.NoError(t, wl.Init())
+	wl.UpdateFileInfo("/var/log/app.log", servicelog.LogRange{Inode: 1, SeekEnd: 10, Written: true})
+	wl.Close()
+	assert.NotPanics(t, func() {
+		wl.UpdateFileInfo("/var/log/app.log", servicelog.LogRange{Inode: 1, SeekEnd: 20, Written: true})
+		wl.Close()
+	})
+
+	wl2 := NewWorklog(wlPath)
+	assert.NoError(t, wl2.Init())
+	defer wl2.Close()
+	assert.Equal(t, int64(10), wl2.GetData("/var/log/app.log").SeekEnd)
+}
+
+func TestWorklogInterleavedInodeUpdates(t *testing.T) {
+	wlPath := filepath.Join(t.TempDir(), "worklog.json")
+	logPath := "/var/log/nonexistent/app.log"
+	wl := NewWorklog(wlPath)
+	assert.NoError(t, wl.Init())
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 10, SeekStart: 0, SeekEnd: 100, Written: true})
+	wl.RegisterRotation(logPath, 20)
+	// stale in-flight updates of the original file interleaved with the new ones
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 10, SeekStart: 100, SeekEnd: 200, Written: true})
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 20, SeekStart: 0, SeekEnd: 50, Written: true})
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 10, SeekStart: 200, SeekEnd: 300, Written: false})
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 20, SeekStart: 50, SeekEnd: 80, Written: true})
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 10, SeekStart: 300, SeekEnd: 400, Written: true})
+	wl.Close()
+
+	wl2 := NewWorklog(wlPath)
+	assert.NoError(t, wl2.Init())
+	defer wl2.Close()
+	pos := wl2.GetData(logPath)
+	assert.Equal(t, int64(20), pos.Inode)
+	assert.Equal(t, int64(80), pos.SeekEnd)
+	assert.True(t, pos.Written)
+}
+
+func TestWorklogImplicitInodeTransition(t *testing.T) {
+	wlPath := filepath.Join(t.TempDir(), "worklog.json")
+	logPath := "/var/log/nonexistent/app.log"
+	wl := NewWorklog(wlPath)
+	assert.NoError(t, wl.Init())
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 10, SeekStart: 0, SeekEnd: 100, Written: true})
+	// no transition record, the first update of the new inode switches the file
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 20, SeekStart: 0, SeekEnd: 30, Written: true})
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 10, SeekStart: 100, SeekEnd: 200, Written: true})
+	wl.UpdateFileInfo(logPath, servicelog.LogRange{Inode: 20, SeekStart: 30, SeekEnd: 60, Written: true})
+	// a repeated transition to the current inode must not reset the position
+	wl.RegisterRotation(logPath, 20)
+	wl.Close()
+
+	wl2 := NewWorklog(wlPath)
+	assert.NoError(t, wl2.Init())
+	defer wl2.Close()
+	pos := wl2.GetData(logPath)
+	assert.Equal(t, int64(20), pos.Inode)
+	assert.Equal(t, int64(60), pos.SeekEnd)
+	rot, ok := wl.GetRotatedData(logPath)
+	assert.True(t, ok)
+	assert.Equal(t, int64(200), rot.SeekEnd)
+}
+
+func TestWorklogCompaction(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "app.log")
+	missing := filepath.Join(dir, "app.log.1")
+	watchedMissing := filepath.Join(dir, "other.log")
+	assert.NoError(t, os.WriteFile(existing, []byte("foo\n"), 0644))
+	wlPath := filepath.Join(dir, "worklog.json")
+	data := fmt.Sprintf(
+		`{%q: {"inode": 1, "seekEnd": 4, "written": true}, %q: {"inode": 2, "seekEnd": 10, "written": true}, `+
+			`%q: {"inode": 3, "seekEnd": 20, "written": true}}`,
+		existing, missing, watchedMissing)
+	assert.NoError(t, os.WriteFile(wlPath, []byte(data), 0644))
+	wl := NewWorklog(wlPath)
+	wl.EnableCompaction(time.Hour)
+	assert.NoError(t, wl.Init())
+	removed, err := wl.Prune([]string{existing, watchedMissing})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	now := time.Now()
+	assert.Equal(t, 0, wl.compact(now))
+	assert.Equal(t, 0, wl.compact(now.Add(30*time.Minute)))
+
+	// a temporarily missing file is not removed once it appears again
+	assert.NoError(t, os.WriteFile(missing, []byte{}, 0644))
+	assert.Equal(t, 0, wl.compact(now.Add(40*time.Minute)))
+	assert.NoError(t, os.Remove(missing))
+	assert.Equal(t, 0, wl.compact(now.Add(50*time.Minute)))
+	assert.Equal(t, 0, wl.compact(now.Add(100*time.Minute)))
+
+	assert.Equal(t, 1, wl.compact(now.Add(111*time.Minute)))
+	// a watched file is kept no matter how long it is missing
+	assert.ElementsMatch(t, []string{existing, watchedMissing}, wl.rec.Keys())
+	wl.Close()
+
+	wl2 := NewWorklog(wlPath)
+	assert.NoError(t, wl2.Init())
+	defer wl2.Close()
+	assert.Equal(t, int64(-1), wl2.GetData(missing).Inode)
+	assert.Equal(t, int64(1), wl2.GetData(existing).Inode)
+	assert.Equal(t, int64(3), wl2.GetData(watchedMissing).Inode)
+}
@@ -21,6 +21,7 @@ import (
 	"klogproc/load"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -29,6 +30,12 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// recordsPerClient is a max. number of records stored
+	// for a single clustering ID
+	recordsPerClient = 1000
+)
+
 type Storable interface {
 	GetTime() time.Time
 	ClusteringClientID() string
@@ -73,6 +80,17 @@ type PrevRecords[T Storable, U SerializableState] struct {
 	data     map[string]*collections.CircularList[T]
 	dataLock sync.RWMutex
 
+	// numInMemory is a total number of records in `data`
+	numInMemory int
+
+	// spill contains the oldest records moved out of memory
+	// once conf.MaxRecordsInMemory is exceeded
+	spill *spillSegment[T]
+
+	// spillFailed disables further spilling after an unrecoverable
+	// spill segment error
+	spillFailed bool
+
 	lastChecks     map[string]time.Time
 	lastChecksLock sync.RWMutex
 
@@ -92,9 +110,91 @@ func (st *PrevRecords[T, U]) AddRecord(rec T) {
 		cid := rec.ClusteringClientID()
 		_, ok := st.data[cid]
 		if !ok {
-			st.data[cid] = collections.NewCircularList[T](1000)
+			st.data[cid] = collections.NewCircularList[T](recordsPerClient)
+		}
+		if st.data[cid].Len() < recordsPerClient {
+			st.numInMemory++
 		}
 		st.data[cid].Append(rec)
+		if st.spill != nil {
+			// keep the total number of records per client within the limit
+			excess := st.spill.len(cid) + st.data[cid].Len() - recordsPerClient
+			if excess > 0 {
+				st.spill.removeFirst(cid, excess)
+			}
+		}
+		if st.conf.MaxRecordsInMemory > 0 && st.numInMemory > st.conf.MaxRecordsInMemory {
+			st.spillOldestRecords()
+		}
+	}
+}
+
+// spillOldestRecords moves the oldest in-memory records to the spill
+// segment so that only 3/4 of the configured max. number of records
+// remain in memory. The method expects the data lock to be acquired.
+func (st *PrevRecords[T, U]) spillOldestRecords() {
+	if st.spillFailed {
+		return
+	}
+	if st.spill == nil {
+		var err error
+		st.spill, err = newSpillSegment[T](
+			filepath.Join(st.storageDirPath, st.mkSpillFileName()))
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create log buffer spill segment, keeping all records in memory")
+			st.spillFailed = true
+			return
+		}
+	}
+	numToSpill := st.numInMemory - st.conf.MaxRecordsInMemory*3/4
+	times := make([]time.Time, 0, st.numInMemory)
+	for _, v := range st.data {
+		v.ForEach(func(i int, item T) bool {
+			times = append(times, item.GetTime())
+			return true
+		})
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	cutoff := times[numToSpill-1]
+	var numSpilled int
+	for cid, v := range st.data {
+		v.ShiftUntil(func(item T) bool {
+			if st.spillFailed || numSpilled >= numToSpill || item.GetTime().After(cutoff) {
+				return false
+			}
+			if err := st.spill.add(cid, item); err != nil {
+				log.Error().Err(err).Msg("failed to spill log buffer records, keeping remaining records in memory")
+				st.spillFailed = true
+				return false
+			}
+			numSpilled++
+			return true
+		})
+		if st.spillFailed || numSpilled >= numToSpill {
+			break
+		}
+	}
+	st.numInMemory -= numSpilled
+}
+
+// maintainSpill releases disk space occupied by removed spilled
+// records. An empty spill segment is closed (a new one is created once
+// needed). The method expects the data lock to be acquired.
+func (st *PrevRecords[T, U]) maintainSpill() {
+	if st.spill == nil {
+		return
+	}
+	if st.spill.isEmpty() {
+		if err := st.spill.close(); err != nil {
+			log.Error().Err(err).Msg("failed to close log buffer spill segment")
+		}
+		st.spill = nil
+		return
+	}
+	if err := st.spill.maintain(); err != nil {
+		log.Error().Err(err).Msg("failed to maintain log buffer spill segment")
 	}
 }
 
@@ -116,12 +216,20 @@ func (st *PrevRecords[T, U]) GetLastCheck(clusteringID string) time.Time {
 func (st *PrevRecords[T, U]) RemoveAnalyzedRecords(clusteringID string, dt time.Time) {
 	st.dataLock.Lock()
 	defer st.dataLock.Unlock()
+	if st.spill != nil {
+		st.spill.removeBefore(clusteringID, dt)
+		st.maintainSpill()
+	}
 	v, ok := st.data[clusteringID]
 	if !ok {
 		return
 	}
 	v.ShiftUntil(func(item T) bool {
-		return item.GetTime().Before(dt)
+		ans := item.GetTime().Before(dt)
+		if ans {
+			st.numInMemory--
+		}
+		return ans
 	})
 }
 
@@ -130,22 +238,33 @@ func (st *PrevRecords[T, U]) RemoveAnalyzedRecords(clusteringID string, dt time.
 func (st *PrevRecords[T, U]) NumOfRecords(clusteringID string) int {
 	st.dataLock.RLock()
 	defer st.dataLock.RUnlock()
+	var ans int
+	if st.spill != nil {
+		ans = st.spill.len(clusteringID)
+	}
 	v, ok := st.data[clusteringID]
 	if !ok {
-		return 0
+		return ans
 	}
-	return v.Len()
+	return ans + v.Len()
 }
 
 func (st *PrevRecords[T, U]) ClearOldRecords(maxAge time.Time) int {
 	st.dataLock.Lock()
 	defer st.dataLock.Unlock()
 	var totalRm int
+	if st.spill != nil {
+		for cid := range st.spill.refs {
+			totalRm += st.spill.removeBefore(cid, maxAge)
+		}
+		st.maintainSpill()
+	}
 	for _, records := range st.data {
 		records.ShiftUntil(func(item T) bool {
 			ans := maxAge.After(item.GetTime())
 			if ans {
 				totalRm++
+				st.numInMemory--
 			}
 			return ans
 		})
@@ -160,6 +279,15 @@ func (st *PrevRecords[T, U]) TotalNumOfRecordsSince(dt time.Time) int {
 	st.dataLock.RLock()
 	defer st.dataLock.RUnlock()
 	var ans int
+	if st.spill != nil {
+		for _, refs := range st.spill.refs {
+			for _, ref := range refs {
+				if !ref.time.Before(dt) {
+					ans++
+				}
+			}
+		}
+	}
 	for _, v := range st.data {
 		v.ForEach(func(i int, item T) bool {
 			if item.GetTime().After(dt) || item.GetTime().Equal(dt) {
@@ -171,11 +299,28 @@ func (st *PrevRecords[T, U]) TotalNumOfRecordsSince(dt time.Time) int {
 	return ans
 }
 
+// forEachSpilled calls `fn` for all the spilled records with
+// the provided `clusteringID`. The method expects the data lock
+// to be acquired.
+func (st *PrevRecords[T, U]) forEachSpilled(clusteringID string, fn func(item T)) {
+	if st.spill == nil {
+		return
+	}
+	if err := st.spill.forEach(clusteringID, fn); err != nil {
+		log.Error().
+			Err(err).
+			Str("clusteringId", clusteringID).
+			Msg("failed to read spilled log buffer records")
+	}
+}
+
 // ForEach iterates over stored records with the provided `clusteringID`
 // and calls the provided `fn` with each item as an argument.
+// Records spilled to disk are read first as they are the oldest ones.
 func (st *PrevRecords[T, U]) ForEach(clusteringID string, fn func(item T)) {
 	st.dataLock.RLock()
 	defer st.dataLock.RUnlock()
+	st.forEachSpilled(clusteringID, fn)
 	v, ok := st.data[clusteringID]
 	if !ok {
 		return
@@ -196,7 +341,15 @@ func (st *PrevRecords[T, U]) ForEach(clusteringID string, fn func(item T)) {
 func (st *PrevRecords[T, U]) TotalForEach(fn func(item T)) {
 	st.dataLock.RLock()
 	defer st.dataLock.RUnlock()
-	for _, v := range st.data {
+	if st.spill != nil {
+		for cid := range st.spill.refs {
+			if _, ok := st.data[cid]; !ok {
+				st.forEachSpilled(cid, fn)
+			}
+		}
+	}
+	for cid, v := range st.data {
+		st.forEachSpilled(cid, fn)
 		v.ForEach(func(i int, item T) bool {
 			fn(item)
 			return true
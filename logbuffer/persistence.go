@@ -34,6 +34,12 @@ func (st *PrevRecords[T, U]) mkStorageFileName() string {
 	return fmt.Sprintf("%x.json", h.Sum32())
 }
 
+func (st *PrevRecords[T, U]) mkSpillFileName() string {
+	h := fnv.New32a()
+	h.Write([]byte(st.logFilePath))
+	return fmt.Sprintf("%x.spill", h.Sum32())
+}
+
 func (st *PrevRecords[T, U]) SetStateData(stateData U) {
 	st.stateData = stateData
 	st.stateWriting <- stateData
@@ -0,0 +1,268 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logbuffer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+const (
+	// spillCompactionMinBytes is a minimum size of a spill segment
+	// file for it to be considered for compaction
+	spillCompactionMinBytes = 64 * 1024 * 1024
+)
+
+// Spillable is an optional interface of records with an internal state
+// not preserved by their JSON encoding (e.g. unexported fields). Such
+// records must be able to encode and decode the state themselves
+// so they can be moved to a spill segment and loaded back.
+type Spillable interface {
+	MarshalSpill() ([]byte, error)
+	UnmarshalSpill(data []byte) error
+}
+
+// spillRef refers to a record stored in a spill segment
+type spillRef struct {
+	offset  int64
+	size    int
+	typeIdx int
+	time    time.Time
+}
+
+// spillSegment is an on-disk storage of records moved out of memory.
+// Records are JSON-encoded (see Spillable) and their concrete types
+// are remembered so they can be decoded back to the original types.
+// The segment file is unlinked right after its creation so it does
+// not survive the process.
+type spillSegment[T Storable] struct {
+	file      *os.File
+	size      int64
+	liveBytes int64
+	refs      map[string][]spillRef
+	numRefs   int
+	types     []reflect.Type
+	typeIdx   map[reflect.Type]int
+
+	// compactionMinBytes is a minimum size of the segment
+	// for it to be considered for compaction
+	compactionMinBytes int64
+}
+
+func (seg *spillSegment[T]) getTypeIdx(typ reflect.Type) int {
+	idx, ok := seg.typeIdx[typ]
+	if !ok {
+		idx = len(seg.types)
+		seg.types = append(seg.types, typ)
+		seg.typeIdx[typ] = idx
+	}
+	return idx
+}
+
+// add writes the record to the end of the segment
+func (seg *spillSegment[T]) add(clusteringID string, rec T) error {
+	var data []byte
+	var err error
+	if sRec, ok := any(rec).(Spillable); ok {
+		data, err = sRec.MarshalSpill()
+
+	} else {
+		data, err = json.Marshal(rec)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode record to spill: %w", err)
+	}
+	if _, err := seg.file.WriteAt(data, seg.size); err != nil {
+		return fmt.Errorf("failed to write spilled record: %w", err)
+	}
+	seg.refs[clusteringID] = append(seg.refs[clusteringID], spillRef{
+		offset:  seg.size,
+		size:    len(data),
+		typeIdx: seg.getTypeIdx(reflect.TypeOf(rec)),
+		time:    rec.GetTime(),
+	})
+	seg.size += int64(len(data))
+	seg.liveBytes += int64(len(data))
+	seg.numRefs++
+	return nil
+}
+
+// read loads a record referred by ref
+func (seg *spillSegment[T]) read(ref spillRef) (T, error) {
+	var ans T
+	data := make([]byte, ref.size)
+	if _, err := seg.file.ReadAt(data, ref.offset); err != nil {
+		return ans, fmt.Errorf("failed to read spilled record: %w", err)
+	}
+	typ := seg.types[ref.typeIdx]
+	var target reflect.Value
+	if typ.Kind() == reflect.Pointer {
+		target = reflect.New(typ.Elem())
+
+	} else {
+		target = reflect.New(typ)
+	}
+	var err error
+	if sRec, ok := target.Interface().(Spillable); ok {
+		err = sRec.UnmarshalSpill(data)
+
+	} else {
+		err = json.Unmarshal(data, target.Interface())
+	}
+	if err != nil {
+		return ans, fmt.Errorf("failed to decode spilled record: %w", err)
+	}
+	if typ.Kind() != reflect.Pointer {
+		target = target.Elem()
+	}
+	ans, ok := target.Interface().(T)
+	if !ok {
+		return ans, fmt.Errorf("invalid type of spilled record: %s", typ)
+	}
+	return ans, nil
+}
+
+// len returns number of spilled records with the clusteringID
+func (seg *spillSegment[T]) len(clusteringID string) int {
+	return len(seg.refs[clusteringID])
+}
+
+// removeFirst removes the oldest n records with the clusteringID
+func (seg *spillSegment[T]) removeFirst(clusteringID string, n int) {
+	refs := seg.refs[clusteringID]
+	if n > len(refs) {
+		n = len(refs)
+	}
+	for _, ref := range refs[:n] {
+		seg.liveBytes -= int64(ref.size)
+	}
+	seg.numRefs -= n
+	if n == len(refs) {
+		delete(seg.refs, clusteringID)
+
+	} else {
+		seg.refs[clusteringID] = refs[n:]
+	}
+}
+
+// removeBefore removes records with the clusteringID older than dt
+// and returns the number of removed records
+func (seg *spillSegment[T]) removeBefore(clusteringID string, dt time.Time) int {
+	var n int
+	for _, ref := range seg.refs[clusteringID] {
+		if !ref.time.Before(dt) {
+			break
+		}
+		n++
+	}
+	seg.removeFirst(clusteringID, n)
+	return n
+}
+
+// forEach calls fn for all the records with the clusteringID
+// (from the oldest ones). It stops on the first error.
+func (seg *spillSegment[T]) forEach(clusteringID string, fn func(item T)) error {
+	for _, ref := range seg.refs[clusteringID] {
+		item, err := seg.read(ref)
+		if err != nil {
+			return err
+		}
+		fn(item)
+	}
+	return nil
+}
+
+// maintain releases the space occupied by removed records. A large
+// segment with mostly removed records is rewritten to a new file. The file
+// and the record references are replaced only once all the live records
+// are copied so a failed compaction leaves the segment intact.
+// An empty segment is expected to be closed by its owner.
+func (seg *spillSegment[T]) maintain() error {
+	if seg.size < seg.compactionMinBytes || seg.liveBytes*4 > seg.size {
+		return nil
+	}
+	newFile, err := createSpillFile(seg.file.Name())
+	if err != nil {
+		return err
+	}
+	newRefs := make(map[string][]spillRef, len(seg.refs))
+	var newSize int64
+	for cid, refs := range seg.refs {
+		cRefs := make([]spillRef, len(refs))
+		for i, ref := range refs {
+			data := make([]byte, ref.size)
+			if _, err := seg.file.ReadAt(data, ref.offset); err != nil {
+				newFile.Close()
+				return fmt.Errorf("failed to compact spill segment: %w", err)
+			}
+			if _, err := newFile.WriteAt(data, newSize); err != nil {
+				newFile.Close()
+				return fmt.Errorf("failed to compact spill segment: %w", err)
+			}
+			cRefs[i] = ref
+			cRefs[i].offset = newSize
+			newSize += int64(ref.size)
+		}
+		newRefs[cid] = cRefs
+	}
+	seg.file.Close()
+	seg.file = newFile
+	seg.refs = newRefs
+	seg.size = newSize
+	seg.liveBytes = newSize
+	return nil
+}
+
+// isEmpty tells whether the segment contains no live records
+func (seg *spillSegment[T]) isEmpty() bool {
+	return seg.numRefs == 0
+}
+
+// close releases the segment file
+func (seg *spillSegment[T]) close() error {
+	return seg.file.Close()
+}
+
+// createSpillFile creates a new unlinked file with a name derived
+// from the provided path
+func createSpillFile(path string) (*os.File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill segment: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to unlink spill segment: %w", err)
+	}
+	return f, nil
+}
+
+func newSpillSegment[T Storable](path string) (*spillSegment[T], error) {
+	f, err := createSpillFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &spillSegment[T]{
+		file:               f,
+		refs:               make(map[string][]spillRef),
+		typeIdx:            make(map[reflect.Type]int),
+		compactionMinBytes: spillCompactionMinBytes,
+	}, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logbuffer
+
+import (
+	"fmt"
+	"klogproc/load"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type spillTestRecord struct {
+	Time   time.Time `json:"time"`
+	Client string    `json:"client"`
+	Value  int       `json:"value"`
+}
+
+func (r *spillTestRecord) GetTime() time.Time {
+	return r.Time
+}
+
+func (r *spillTestRecord) ClusteringClientID() string {
+	return r.Client
+}
+
+type spillTestState struct{}
+
+func (s *spillTestState) ToJSON() ([]byte, error) {
+	return []byte("{}"), nil
+}
+
+func (s *spillTestState) AfterLoadNormalize(conf *load.BufferConf, dt time.Time) {}
+
+func (s *spillTestState) Report() map[string]any {
+	return map[string]any{}
+}
+
+func newSpillTestStorage(t *testing.T, maxInMemory int) *PrevRecords[*spillTestRecord, *spillTestState] {
+	return NewStorage[*spillTestRecord, *spillTestState](
+		&load.BufferConf{HistoryLookupItems: 100, MaxRecordsInMemory: maxInMemory},
+		false,
+		t.TempDir(),
+		"/var/log/test.log",
+		func() *spillTestState { return &spillTestState{} },
+	)
+}
+
+func TestSpillKeepsAllRecords(t *testing.T) {
+	st := newSpillTestStorage(t, 20)
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		st.AddRecord(&spillTestRecord{
+			Time:   t0.Add(time.Duration(i) * time.Second),
+			Client: fmt.Sprintf("client-%d", i%3),
+			Value:  i,
+		})
+	}
+	assert.LessOrEqual(t, st.numInMemory, 20)
+	assert.NotNil(t, st.spill)
+	assert.Equal(t, 34, st.NumOfRecords("client-0"))
+	assert.Equal(t, 33, st.NumOfRecords("client-1"))
+	assert.Equal(t, 100, st.TotalNumOfRecordsSince(t0))
+
+	values := make([]int, 0, 34)
+	st.ForEach("client-0", func(item *spillTestRecord) {
+		values = append(values, item.Value)
+	})
+	assert.Len(t, values, 34)
+	for i, v := range values {
+		assert.Equal(t, i*3, v)
+	}
+
+	var total int
+	st.TotalForEach(func(item *spillTestRecord) {
+		total++
+	})
+	assert.Equal(t, 100, total)
+}
+
+func TestSpillRemoveRecords(t *testing.T) {
+	st := newSpillTestStorage(t, 8)
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 40; i++ {
+		st.AddRecord(&spillTestRecord{
+			Time:   t0.Add(time.Duration(i) * time.Second),
+			Client: fmt.Sprintf("client-%d", i%2),
+			Value:  i,
+		})
+	}
+	st.RemoveAnalyzedRecords("client-0", t0.Add(10*time.Second))
+	assert.Equal(t, 15, st.NumOfRecords("client-0"))
+	assert.Equal(t, 20, st.NumOfRecords("client-1"))
+
+	numRm := st.ClearOldRecords(t0.Add(30 * time.Second))
+	assert.Equal(t, 25, numRm)
+	assert.Equal(t, 5, st.NumOfRecords("client-0"))
+	assert.Equal(t, 5, st.NumOfRecords("client-1"))
+
+	numRm = st.ClearOldRecords(t0.Add(time.Hour))
+	assert.Equal(t, 10, numRm)
+	assert.Equal(t, 0, st.numInMemory)
+	// an empty spill segment is closed
+	assert.Nil(t, st.spill)
+}
+
+func newCompactionTestSegment(t *testing.T) *spillSegment[*spillTestRecord] {
+	seg, err := newSpillSegment[*spillTestRecord](filepath.Join(t.TempDir(), "test.spill"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { seg.close() })
+	seg.compactionMinBytes = 1
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 40; i++ {
+		err := seg.add(
+			fmt.Sprintf("client-%d", i%2),
+			&spillTestRecord{Time: t0.Add(time.Duration(i) * time.Second), Value: i},
+		)
+		assert.NoError(t, err)
+	}
+	seg.removeBefore("client-0", t0.Add(36*time.Second))
+	seg.removeBefore("client-1", t0.Add(36*time.Second))
+	return seg
+}
+
+func TestSpillCompaction(t *testing.T) {
+	seg := newCompactionTestSegment(t)
+	origSize := seg.size
+	assert.NoError(t, seg.maintain())
+	assert.Less(t, seg.size, origSize)
+	assert.Equal(t, seg.liveBytes, seg.size)
+	values := make([]int, 0, 2)
+	assert.NoError(t, seg.forEach("client-1", func(item *spillTestRecord) {
+		values = append(values, item.Value)
+	}))
+	assert.Equal(t, []int{37, 39}, values)
+}
+
+func TestSpillFailedCompactionKeepsRefs(t *testing.T) {
+	seg := newCompactionTestSegment(t)
+	origFile := seg.file
+	origRefs := make(map[string][]spillRef)
+	for cid, refs := range seg.refs {
+		origRefs[cid] = append([]spillRef{}, refs...)
+	}
+	// make reading of the last live record fail so the compaction
+	// fails only after some records have been copied
+	var lastOffset int64
+	for _, refs := range seg.refs {
+		for _, ref := range refs {
+			if ref.offset > lastOffset {
+				lastOffset = ref.offset
+			}
+		}
+	}
+	assert.NoError(t, seg.file.Truncate(lastOffset))
+	assert.Error(t, seg.maintain())
+	assert.Equal(t, origFile, seg.file)
+	assert.Equal(t, origRefs, seg.refs)
+}
+
+func TestSpillWithoutLimit(t *testing.T) {
+	st := newSpillTestStorage(t, 0)
+	t0 := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 50; i++ {
+		st.AddRecord(&spillTestRecord{Time: t0.Add(time.Duration(i) * time.Second), Client: "a"})
+	}
+	assert.Nil(t, st.spill)
+	assert.Equal(t, 50, st.numInMemory)
+	assert.Equal(t, 50, st.NumOfRecords("a"))
+}
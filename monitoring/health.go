@@ -0,0 +1,122 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+
+	// FileHealthOK means the file is processed regularly
+	FileHealthOK = "ok"
+
+	// FileHealthNotUpdated means the file has been fully processed
+	// but it has not been written to within the inactivity limit
+	FileHealthNotUpdated = "notUpdated"
+
+	// FileHealthBehind means the reader falls behind the file's
+	// actual end (see Conf.MaxLagBytes, Conf.MaxLagSecs)
+	FileHealthBehind = "behind"
+)
+
+// FileHealth describes whether a tailed file is
+// processed regularly
+type FileHealth struct {
+	FilePath string `json:"filePath"`
+	AppType  string `json:"appType"`
+
+	// LastActivity is the time the last line of the file
+	// has been processed (or the time the processing started
+	// in case there were no lines yet)
+	LastActivity time.Time `json:"lastActivity"`
+
+	InactivityLimitSecs int `json:"inactivityLimitSecs"`
+
+	// Stale is true if no line has been processed
+	// within the inactivity limit
+	Stale bool `json:"stale"`
+
+	// FileSize is the current size of the file
+	FileSize int64 `json:"fileSize"`
+
+	// SeekPosition is the last position confirmed
+	// in the worklog
+	SeekPosition int64 `json:"seekPosition"`
+
+	// BytesBehind is the amount of data not processed yet
+	BytesBehind int64 `json:"bytesBehind"`
+
+	// Behind is true if the reader falls behind the file's end
+	// more than the configured thresholds allow
+	Behind bool `json:"behind"`
+
+	// Status summarizes the state (FileHealthOK, FileHealthNotUpdated,
+	// FileHealthBehind)
+	Status string `json:"status"`
+
+	// StartupGrace is true if the file is within the startup
+	// grace period (i.e. it cannot be reported as stale or behind)
+	StartupGrace bool `json:"startupGrace"`
+}
+
+// IsHealthy tests whether the file is neither stale nor behind
+func (fh FileHealth) IsHealthy() bool {
+	return !fh.Stale && !fh.Behind
+}
+
+// HealthResponse is a response of the `/health` endpoint
+type HealthResponse struct {
+	Healthy bool         `json:"healthy"`
+	Files   []FileHealth `json:"files"`
+}
+
+// FileHealthProvider provides current health of all
+// the tailed files
+type FileHealthProvider func() []FileHealth
+
+// RegisterHealth adds a `/health` endpoint reporting activity of each tailed
+// file. It responds with 200 in case all the files are healthy and with 503
+// in case any of them is stale so it can be used e.g. as a load balancer probe.
+func (s *Server) RegisterHealth(provider FileHealthProvider) {
+	s.mux.HandleFunc("/health", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		ans := HealthResponse{Healthy: true, Files: provider()}
+		for _, fh := range ans.Files {
+			if !fh.IsHealthy() {
+				ans.Healthy = false
+				break
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if ans.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(ans); err != nil {
+			log.Error().Err(err).Msg("failed to write health status")
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthEndpoint(t *testing.T) {
+	srv := NewServer(&Conf{ListenAddress: "localhost:0", InactivityLimitSecs: 60})
+	files := []FileHealth{
+		{FilePath: "/var/log/app.log", AppType: "kontext", LastActivity: time.Now(), InactivityLimitSecs: 60},
+		{FilePath: "/var/log/syd.log", AppType: "syd", LastActivity: time.Now(), InactivityLimitSecs: 60},
+	}
+	srv.RegisterHealth(func() []FileHealth { return files })
+
+	resp := httptest.NewRecorder()
+	srv.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var data HealthResponse
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &data))
+	assert.True(t, data.Healthy)
+	assert.Equal(t, 2, len(data.Files))
+
+	files[1].Stale = true
+	resp = httptest.NewRecorder()
+	srv.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &data))
+	assert.False(t, data.Healthy)
+	assert.True(t, data.Files[1].Stale)
+
+	resp = httptest.NewRecorder()
+	srv.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/health", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
+
+func TestHealthEndpointBehind(t *testing.T) {
+	srv := NewServer(&Conf{ListenAddress: "localhost:0", MaxLagBytes: 1000})
+	srv.RegisterHealth(func() []FileHealth {
+		return []FileHealth{
+			{
+				FilePath:     "/var/log/app.log",
+				FileSize:     5000,
+				SeekPosition: 1000,
+				BytesBehind:  4000,
+				Behind:       true,
+				Status:       FileHealthBehind,
+			},
+		}
+	})
+	resp := httptest.NewRecorder()
+	srv.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	var data HealthResponse
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &data))
+	assert.False(t, data.Healthy)
+	assert.Equal(t, FileHealthBehind, data.Files[0].Status)
+	assert.Equal(t, int64(4000), data.Files[0].BytesBehind)
+}
+
+func TestConfHasHealthCheck(t *testing.T) {
+	assert.False(t, (&Conf{ListenAddress: "localhost:0"}).HasHealthCheck())
+	assert.True(t, (&Conf{ListenAddress: "localhost:0", MaxLagSecs: 30}).HasHealthCheck())
+	assert.False(t, (&Conf{MaxLagBytes: 100}).HasHealthCheck())
+	assert.Error(t, (&Conf{MaxLagBytes: -1}).Validate())
+	assert.Error(t, (&Conf{StartupGraceSecs: -1}).Validate())
+}
+
+func TestConfIsInStartupGrace(t *testing.T) {
+	startedAt := time.Now()
+	conf := &Conf{StartupGraceSecs: 60}
+	assert.True(t, conf.IsInStartupGrace(startedAt, 10, startedAt.Add(65*time.Second)))
+	assert.False(t, conf.IsInStartupGrace(startedAt, 10, startedAt.Add(70*time.Second)))
+	assert.False(t, (&Conf{}).IsInStartupGrace(startedAt, 10, startedAt))
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	metricsNamespace = "klogproc"
+)
+
+var (
+	// RecordsParsed counts log lines successfully parsed into input records
+	RecordsParsed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "records_parsed_total",
+			Help:      "Number of successfully parsed log records",
+		},
+		[]string{"appType", "file"},
+	)
+
+	// RecordsIgnored counts records which were not sent to any
+	// output (non-processable records, excluded IPs etc.)
+	RecordsIgnored = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "records_ignored_total",
+			Help:      "Number of ignored log records",
+		},
+		[]string{"appType", "file"},
+	)
+
+	// ParseErrors counts log lines we were unable to parse
+	ParseErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "parse_errors_total",
+			Help:      "Number of log lines which failed to parse",
+		},
+		[]string{"appType", "file"},
+	)
+
+	// TransformErrors counts parsed records we were unable
+	// to transform into output records
+	TransformErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "transform_errors_total",
+			Help:      "Number of records which failed to transform",
+		},
+		[]string{"appType", "file"},
+	)
+
+	// ProcessingLag shows a difference between wall clock and
+	// the time of the most recent processed record (as measured
+	// at the end of each file check)
+	ProcessingLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "processing_lag_seconds",
+			Help:      "Difference between now and the time of the last processed record",
+		},
+		[]string{"appType", "file"},
+	)
+)
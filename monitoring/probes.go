@@ -0,0 +1,57 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ReadinessProvider tells whether the process is ready to do its work
+// (e.g. all the tailed files have been checked at least once)
+type ReadinessProvider func() bool
+
+func writeProbeResult(w http.ResponseWriter, req *http.Request, ok bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	status, msg := http.StatusOK, "ok"
+	if !ok {
+		status, msg = http.StatusServiceUnavailable, "unavailable"
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(msg + "\n")); err != nil {
+		log.Error().Err(err).Msg("failed to write probe result")
+	}
+}
+
+// RegisterProbes adds `/healthz` (liveness) and `/readyz` (readiness)
+// endpoints suitable for orchestrators like Kubernetes. The process is
+// considered alive until ctx is cancelled and ready once it is alive and
+// the provider reports readiness.
+func (s *Server) RegisterProbes(ctx context.Context, ready ReadinessProvider) {
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		writeProbeResult(w, req, ctx.Err() == nil)
+	})
+	s.mux.HandleFunc("/readyz", func(w http.ResponseWriter, req *http.Request) {
+		writeProbeResult(w, req, ctx.Err() == nil && ready())
+	})
+}
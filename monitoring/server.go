@@ -0,0 +1,135 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	shutdownTimeoutSecs = 5
+)
+
+// Conf configures an optional embedded HTTP server
+// providing klogproc's runtime metrics
+type Conf struct {
+
+	// ListenAddress specifies an address (e.g. `localhost:8089`)
+	// the server listens on. If empty, the server is not started.
+	ListenAddress string `json:"listenAddress"`
+
+	// InactivityLimitSecs enables the `/health` endpoint (tail mode only).
+	// A file is reported as stale in case none of its lines has been
+	// processed within the limit. Individual files may override the
+	// value via their `inactivityLimitSecs`.
+	InactivityLimitSecs int `json:"inactivityLimitSecs"`
+
+	// MaxLagBytes enables reporting a tailed file as behind (via the
+	// `/health` endpoint) in case the difference between the file size
+	// and the worklog position exceeds the value
+	MaxLagBytes int64 `json:"maxLagBytes"`
+
+	// MaxLagSecs enables reporting a tailed file as behind (via the
+	// `/health` endpoint) in case there is unprocessed data in the file
+	// and no line has been processed for the specified time
+	MaxLagSecs int `json:"maxLagSecs"`
+
+	// StartupGraceSecs specifies how long after a file's processing
+	// has started the `/health` endpoint reports it as healthy even if
+	// it looks stale or behind (e.g. due to data accumulated during
+	// a restart). The grace period is extended by the check interval
+	// and it ends early once the file goes through its first check.
+	StartupGraceSecs int `json:"startupGraceSecs"`
+}
+
+// HasHealthCheck tests whether the `/health` endpoint should be provided
+func (conf *Conf) HasHealthCheck() bool {
+	return conf.IsConfigured() &&
+		(conf.InactivityLimitSecs > 0 || conf.MaxLagBytes > 0 || conf.MaxLagSecs > 0)
+}
+
+// Validate tests the configured values
+func (conf *Conf) Validate() error {
+	if conf.InactivityLimitSecs < 0 || conf.MaxLagBytes < 0 || conf.MaxLagSecs < 0 ||
+		conf.StartupGraceSecs < 0 {
+		return errors.New("monitoring limits must be non-negative numbers")
+	}
+	return nil
+}
+
+// IsInStartupGrace tests whether a file with processing started at startedAt
+// and checked each checkIntervalSecs is still within the startup grace period
+func (conf *Conf) IsInStartupGrace(startedAt time.Time, checkIntervalSecs int, now time.Time) bool {
+	if conf.StartupGraceSecs <= 0 {
+		return false
+	}
+	grace := time.Duration(conf.StartupGraceSecs+checkIntervalSecs) * time.Second
+	return now.Sub(startedAt) < grace
+}
+
+// IsConfigured tests whether the server should be started
+func (conf *Conf) IsConfigured() bool {
+	return conf != nil && conf.ListenAddress != ""
+}
+
+// Server is an HTTP server exposing klogproc's
+// runtime metrics in the Prometheus format
+type Server struct {
+	srv *http.Server
+	mux *http.ServeMux
+}
+
+// Start runs the server in a separate goroutine
+func (s *Server) Start() {
+	go func() {
+		log.Info().Str("address", s.srv.Addr).Msg("starting monitoring HTTP server")
+		err := s.srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("monitoring HTTP server failed")
+		}
+	}()
+}
+
+// Stop gracefully shuts down the server
+func (s *Server) Stop() {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), time.Duration(shutdownTimeoutSecs)*time.Second)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to shut down monitoring HTTP server")
+	}
+}
+
+// NewServer creates a new monitoring server. To actually
+// run it, Start() must be called.
+func NewServer(conf *Conf) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &Server{
+		srv: &http.Server{
+			Addr:    conf.ListenAddress,
+			Handler: mux,
+		},
+		mux: mux,
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FileStatus describes a current state of a tailed file
+type FileStatus struct {
+	FilePath string `json:"filePath"`
+	AppType  string `json:"appType"`
+	Inode    int64  `json:"inode"`
+
+	// SeekPosition is the last position confirmed
+	// in the worklog
+	SeekPosition int64 `json:"seekPosition"`
+
+	// Line is the line number related to SeekPosition
+	// (zero if unknown)
+	Line int64 `json:"line"`
+
+	// LastRecordTime is the time of the most recent
+	// processed record (nil if there was none yet)
+	LastRecordTime *time.Time `json:"lastRecordTime"`
+
+	RecordsProcessed int64 `json:"recordsProcessed"`
+	Errors           int64 `json:"errors"`
+}
+
+// FileStatusProvider provides current statuses of all
+// the tailed files
+type FileStatusProvider func() []FileStatus
+
+// RegisterFileStatus adds a read-only `/tail/files` endpoint
+// listing the status of each tailed file
+func (s *Server) RegisterFileStatus(provider FileStatusProvider) {
+	s.mux.HandleFunc("/tail/files", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(provider()); err != nil {
+			log.Error().Err(err).Msg("failed to write tail files status")
+		}
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStatusEndpoint(t *testing.T) {
+	srv := NewServer(&Conf{ListenAddress: "localhost:0"})
+	lastTime := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	srv.RegisterFileStatus(func() []FileStatus {
+		return []FileStatus{
+			{FilePath: "/var/log/app.log", AppType: "kontext", SeekPosition: 1024, LastRecordTime: &lastTime, RecordsProcessed: 10},
+			{FilePath: "/var/log/syd.log", AppType: "syd"},
+		}
+	})
+
+	resp := httptest.NewRecorder()
+	srv.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/tail/files", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var data []FileStatus
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &data))
+	assert.Equal(t, 2, len(data))
+	assert.Equal(t, int64(1024), data[0].SeekPosition)
+	assert.True(t, lastTime.Equal(*data[0].LastRecordTime))
+	assert.Nil(t, data[1].LastRecordTime)
+
+	resp = httptest.NewRecorder()
+	srv.mux.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/tail/files", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifications
+
+import "github.com/rs/zerolog/log"
+
+// fanOutNotifier sends each notification via all the wrapped notifiers
+// (e.g. e-mail and a webhook). A failure of one notifier does not prevent
+// sending via the other ones.
+type fanOutNotifier struct {
+	notifiers []Notifier
+}
+
+// SendNotification sends the notification via all the notifiers.
+// In case some of them fail, the first error is returned.
+func (fn *fanOutNotifier) SendNotification(
+	tag, subject string, metadata map[string]any, paragraphs ...string,
+) error {
+	var ans error
+	for _, n := range fn.notifiers {
+		if err := n.SendNotification(tag, subject, metadata, paragraphs...); err != nil {
+			log.Error().Err(err).Str("subject", subject).Msg("failed to send notification")
+			if ans == nil {
+				ans = err
+			}
+		}
+	}
+	return ans
+}
@@ -32,21 +32,47 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// NewNotifier is a factory function for e-mail/Conomi notification.
-// Because of a difference between both configurations, the Klogproc
-// config type contains two sections - here represented by `conf` and
-// `conf2`. Both configs are mutually exclusive and in case both
-// are provided, the function returns and error.
+// NewNotifier is a factory function for e-mail/Conomi/webhook notification.
+// Because of a difference between the configurations, the Klogproc
+// config type contains three sections - here represented by `conf` (e-mail),
+// `conf2` (Conomi) and `conf3` (webhook). The e-mail and Conomi configs are
+// mutually exclusive and in case both are provided, the function returns
+// an error. A webhook can be configured along with any of them - in such
+// case, notifications are sent via both channels.
 //
 // Missing sender is replaced by a default value.
 func NewNotifier(
 	conf *mail.NotificationConf,
 	conf2 *client.ConomiClientConf,
+	conf3 *WebhookConf,
 	loc *time.Location,
 ) (Notifier, error) {
 	if conf != nil && conf2 != nil {
 		return nil, errors.New("either Conomi or e-mail notifier can be configured")
 	}
+	if conf3 == nil {
+		return newMailOrConomiNotifier(conf, conf2, loc)
+	}
+	log.Info().Msgf("creating webhook notifier with URL %s", conf3.URL)
+	webhook, err := newWebhookNotifier(conf3)
+	if err != nil {
+		return nil, err
+	}
+	if conf == nil && conf2 == nil {
+		return webhook, nil
+	}
+	other, err := newMailOrConomiNotifier(conf, conf2, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &fanOutNotifier{notifiers: []Notifier{other, webhook}}, nil
+}
+
+func newMailOrConomiNotifier(
+	conf *mail.NotificationConf,
+	conf2 *client.ConomiClientConf,
+	loc *time.Location,
+) (Notifier, error) {
 	if conf2 != nil {
 		cclient := client.NewConomiClient(*conf2)
 		return &conomiNotifier{conf: conf2, client: cclient}, nil
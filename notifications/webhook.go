@@ -0,0 +1,149 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultWebhookTimeoutSecs = 10
+)
+
+// WebhookConf configures a notifier sending notifications
+// as JSON documents via HTTP POST (e.g. to a Slack incoming webhook)
+type WebhookConf struct {
+	URL string `json:"url"`
+
+	// BearerToken is optional. If set, the `Authorization: Bearer ...`
+	// header is sent along with each request
+	BearerToken string `json:"bearerToken"`
+
+	// Template is an optional Go text/template used to produce the request
+	// body. Available values are .Tag, .Subject, .Metadata, .Paragraphs
+	// and .Text (paragraphs joined by empty lines). The `json` function
+	// can be used to properly encode values (e.g. `{"text": {{json .Text}}}`).
+	// If empty, a JSON document with all the values is sent.
+	Template string `json:"template"`
+
+	TimeoutSecs int `json:"timeoutSecs"`
+}
+
+func (conf *WebhookConf) Validate() error {
+	if conf.URL == "" {
+		return fmt.Errorf("missing webhook URL")
+	}
+	if conf.TimeoutSecs < 0 {
+		return fmt.Errorf("invalid webhook timeout %d", conf.TimeoutSecs)
+	}
+	if conf.TimeoutSecs == 0 {
+		log.Warn().Msgf("webhook timeoutSecs not set - using default %d", defaultWebhookTimeoutSecs)
+		conf.TimeoutSecs = defaultWebhookTimeoutSecs
+	}
+	return nil
+}
+
+type webhookPayload struct {
+	Tag        string         `json:"tag"`
+	Subject    string         `json:"subject"`
+	Metadata   map[string]any `json:"metadata"`
+	Paragraphs []string       `json:"paragraphs"`
+	Text       string         `json:"text"`
+}
+
+type webhookNotifier struct {
+	conf   *WebhookConf
+	tpl    *template.Template
+	client *http.Client
+}
+
+func (wn *webhookNotifier) encodePayload(payload webhookPayload) ([]byte, error) {
+	if wn.tpl == nil {
+		return json.Marshal(payload)
+	}
+	var buff bytes.Buffer
+	if err := wn.tpl.Execute(&buff, payload); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func (wn *webhookNotifier) SendNotification(tag, subject string, metadata map[string]any, paragraphs ...string) error {
+	body, err := wn.encodePayload(webhookPayload{
+		Tag:        tag,
+		Subject:    subject,
+		Metadata:   metadata,
+		Paragraphs: paragraphs,
+		Text:       strings.Join(paragraphs, "\n\n"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare webhook notification: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, wn.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to prepare webhook notification: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wn.conf.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+wn.conf.BearerToken)
+	}
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Error().
+			Str("url", wn.conf.URL).
+			Int("status", resp.StatusCode).
+			Str("subject", subject).
+			Msg("webhook notification not accepted")
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func newWebhookNotifier(conf *WebhookConf) (*webhookNotifier, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	ans := &webhookNotifier{
+		conf:   conf,
+		client: &http.Client{Timeout: time.Duration(conf.TimeoutSecs) * time.Second},
+	}
+	if conf.Template != "" {
+		tpl, err := template.New("webhook").Funcs(template.FuncMap{
+			"json": func(v any) (string, error) {
+				ans, err := json.Marshal(v)
+				return string(ans), err
+			},
+		}).Parse(conf.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+		}
+		ans.tpl = tpl
+	}
+	return ans, nil
+}
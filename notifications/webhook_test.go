@@ -0,0 +1,101 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/czcorpus/conomi/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookNotifierSendsTemplatedBody(t *testing.T) {
+	var body []byte
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	notifier, err := newWebhookNotifier(&WebhookConf{
+		URL:         srv.URL,
+		BearerToken: "abc",
+		Template:    `{"text": {{json .Subject}}, "file": {{json (index .Metadata "filePath")}}}`,
+	})
+	assert.NoError(t, err)
+	err = notifier.SendNotification(
+		"kontext", "alarm \"x\"", map[string]any{"filePath": "/var/log/app.log"}, "par1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer abc", auth)
+	var decoded map[string]string
+	assert.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, "alarm \"x\"", decoded["text"])
+	assert.Equal(t, "/var/log/app.log", decoded["file"])
+}
+
+func TestWebhookNotifierNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	notifier, err := newWebhookNotifier(&WebhookConf{URL: srv.URL})
+	assert.NoError(t, err)
+	err = notifier.SendNotification("kontext", "alarm", map[string]any{})
+	assert.Error(t, err)
+}
+
+type failingNotifier struct {
+	numCalls int
+}
+
+func (fn *failingNotifier) SendNotification(tag, subject string, metadata map[string]any, paragraphs ...string) error {
+	fn.numCalls++
+	return errors.New("failed to send")
+}
+
+func TestFanOutNotifierSendsViaAll(t *testing.T) {
+	var numRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		numRequests++
+	}))
+	defer srv.Close()
+
+	webhook, err := newWebhookNotifier(&WebhookConf{URL: srv.URL})
+	assert.NoError(t, err)
+	failing := &failingNotifier{}
+	notifier := &fanOutNotifier{notifiers: []Notifier{failing, webhook}}
+	err = notifier.SendNotification("kontext", "alarm", map[string]any{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, failing.numCalls)
+	assert.Equal(t, 1, numRequests)
+}
+
+func TestNewNotifierWebhookWithConomi(t *testing.T) {
+	notifier, err := NewNotifier(
+		nil, &client.ConomiClientConf{}, &WebhookConf{URL: "http://localhost:8080"}, time.UTC)
+	assert.NoError(t, err)
+	_, ok := notifier.(*fanOutNotifier)
+	assert.True(t, ok)
+}
@@ -17,38 +17,68 @@
 package main
 
 import (
+	"context"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
+	"klogproc/analysis"
 	"klogproc/config"
 	"klogproc/fsop"
+	"klogproc/geodb"
+	"klogproc/load"
 	"klogproc/load/batch"
+	"klogproc/logbuffer"
+	"klogproc/notifications"
+	"klogproc/save/elastic"
+	"klogproc/save/influx"
 	"klogproc/servicelog"
+	"klogproc/trfactory"
 	"klogproc/users"
-
-	"github.com/oschwald/geoip2-golang"
 )
 
-func applyLocation(rec servicelog.InputRecord, db *geoip2.Reader, outRec servicelog.OutputRecord) {
-	ip := rec.GetClientIP()
-	if len(ip) > 0 {
-		city, err := db.City(ip)
-		if err != nil {
-			log.Error().Err(err).Msgf("Failed to fetch GeoIP data for IP %s.", ip.String())
+// newUserAgentMatcher creates a matcher for bots and monitoring
+// tools based on buffer's bot detection configuration. The returned
+// value may be nil which means no user agent filtering.
+func newUserAgentMatcher(buffConf *load.BufferConf) *servicelog.UserAgentMatcher {
+	if buffConf == nil {
+		return nil
+	}
+	return servicelog.NewUserAgentMatcher(buffConf.BotDetection)
+}
 
-		} else {
-			outRec.SetLocation(city.Country.Names["en"], float32(city.Location.Latitude),
-				float32(city.Location.Longitude), city.Location.TimeZone)
-		}
+// newBotCandidateExporter creates an exporter of IP addresses detected
+// by bot detection in case a respective index is configured. In dry-run
+// modes and when reindexing, nothing is exported (nil is returned).
+func newBotCandidateExporter(
+	conf *config.Main,
+	options *ProcessOptions,
+) analysis.BotCandidateExporter {
+	if options.dryRun || options.dryRunDiff || options.reindex || !conf.ElasticSearch.ExportsBotCandidates() {
+		return nil
 	}
+	log.Info().
+		Str("index", conf.ElasticSearch.BotCandidatesIndex).
+		Msg("detected bot candidates will be exported to ElasticSearch")
+	return elastic.NewBotCandidateWriter(&conf.ElasticSearch)
 }
 
 type ProcessOptions struct {
 	worklogReset  bool
 	dryRun        bool
+	dryRunDiff    bool
+	diffJSON      bool
 	analysisOnly  bool
+	onlyAppType   string
+	maxDuration   time.Duration
+	statsJSON     bool
+	strictSchema  bool
 	datetimeRange batch.DatetimeRange
+	// reindex means batch processing of a datetime range
+	// without using the worklog
+	reindex bool
 }
 
 // CNKLogProcessor imports parsed log records represented
@@ -56,38 +86,100 @@ type ProcessOptions struct {
 type CNKLogProcessor struct {
 	appType        string
 	appVersion     string
+	instanceID     string
+	instanceIDInID bool
 	anonymousUsers []int
-	geoIPDb        *geoip2.Reader
+	anonymizeIP    bool
+	geoIPDb        trfactory.GeoIPLookup
 	chunkSize      int
-	numNonLoggable int
+	ignored        servicelog.IgnoredCounter
 	skipAnalysis   bool
 	logTransformer servicelog.LogItemTransformer
 	logBuffer      servicelog.ServiceLogBuffer
+	uaMatcher      *servicelog.UserAgentMatcher
+	actionFilter   *servicelog.ActionFilter
+	sampleRate     servicelog.SampleRate
+	// preprocMutex serializes preprocessing (which may involve
+	// stateful analysis) in case multiple files are processed
+	// concurrently (see batch.Conf.Concurrency)
+	preprocMutex sync.Mutex
 }
 
-func (clp *CNKLogProcessor) recordIsLoggable(logRec servicelog.InputRecord) bool {
-	return logRec.IsProcessable()
+// newInspectionLogProcessor creates a CNKLogProcessor for actions which
+// only inspect configured log files (`stats`, `count`). It applies the same
+// filters as the `batch` action but it neither looks up GeoIP data nor uses
+// a persisted analysis state.
+func newInspectionLogProcessor(conf *config.Main) (*CNKLogProcessor, error) {
+	nullNotifier, _ := notifications.NewNotifier(nil, nil, nil, conf.TimezoneLocation())
+	lt, err := trfactory.GetLogTransformer(
+		conf.LogFiles.ProcessingConf,
+		trfactory.TransformerDeps{UserMap: users.EmptyUserMap(), Notifier: nullNotifier},
+	)
+	if err != nil {
+		return nil, err
+	}
+	actionFilter, err := servicelog.NewActionFilter(
+		conf.LogFiles.AppType, conf.IgnoreActions[conf.LogFiles.AppType])
+	if err != nil {
+		return nil, err
+	}
+	return &CNKLogProcessor{
+		appType:        conf.LogFiles.AppType,
+		appVersion:     conf.LogFiles.Version,
+		logTransformer: lt,
+		logBuffer: logbuffer.NewDummyStorage[servicelog.InputRecord, logbuffer.SerializableState](
+			func() logbuffer.SerializableState {
+				return &analysis.SimpleAnalysisState{}
+			},
+		),
+		uaMatcher:    newUserAgentMatcher(conf.LogFiles.Buffer),
+		actionFilter: actionFilter,
+		sampleRate:   conf.LogFiles.SampleRate,
+	}, nil
 }
 
 // ProcItem transforms input log record into an output format.
 // In case an unsupported record is encountered, nil is returned.
 func (clp *CNKLogProcessor) ProcItem(logRec servicelog.InputRecord, tzShiftMin int) []servicelog.OutputRecord {
-	if clp.recordIsLoggable(logRec) {
-		ans := make([]servicelog.OutputRecord, 0, 2)
-		for _, precord := range clp.logTransformer.Preprocess(logRec, clp.logBuffer) {
-			clp.logBuffer.AddRecord(precord)
-			rec, err := clp.logTransformer.Transform(precord, clp.appType, tzShiftMin, clp.anonymousUsers)
-			ans = append(ans, rec)
-			if err != nil {
-				log.Error().Err(err).Msgf("Failed to transform item %s", precord)
-				return []servicelog.OutputRecord{}
+	if !logRec.IsProcessable() {
+		clp.ignored.Inc(servicelog.IgnoredReasonNotProcessable)
+		return []servicelog.OutputRecord{}
+	}
+	if !clp.uaMatcher.AgentIsLoggable(logRec.GetUserAgent()) {
+		clp.ignored.Inc(servicelog.IgnoredReasonBot)
+		return []servicelog.OutputRecord{}
+	}
+	ans := make([]servicelog.OutputRecord, 0, 2)
+	clp.preprocMutex.Lock()
+	prepInp := clp.logTransformer.Preprocess(logRec, clp.logBuffer)
+	for _, precord := range prepInp {
+		clp.logBuffer.AddRecord(precord)
+	}
+	clp.preprocMutex.Unlock()
+	if len(prepInp) == 0 {
+		clp.ignored.Inc(servicelog.IgnoredReasonExcluded)
+	}
+	for _, precord := range prepInp {
+		recs, err := clp.logTransformer.Transform(precord, clp.appType, tzShiftMin, clp.anonymousUsers)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to transform item %s", precord)
+			clp.ignored.Inc(servicelog.IgnoredReasonTransformError)
+			return []servicelog.OutputRecord{}
+		}
+		for _, rec := range recs {
+			if clp.actionFilter.Ignores(rec) {
+				clp.ignored.Inc(servicelog.IgnoredReasonActionFiltered)
+				continue
 			}
-			applyLocation(precord, clp.geoIPDb, rec)
+			if !clp.sampleRate.Keeps(rec) {
+				clp.ignored.Inc(servicelog.IgnoredReasonSampledOut)
+				continue
+			}
+			ans = append(ans, rec)
+			trfactory.ApplyLocation(precord, clp.geoIPDb, rec, clp.anonymizeIP)
 		}
-		return ans
 	}
-	clp.numNonLoggable++
-	return []servicelog.OutputRecord{}
+	return ans
 }
 
 // GetAppType returns a string idenfier unique for a concrete application we
@@ -96,11 +188,44 @@ func (clp *CNKLogProcessor) GetAppType() string {
 	return clp.appType
 }
 
+// GetInstanceID returns an optional identifier of the klogproc instance
+func (clp *CNKLogProcessor) GetInstanceID() string {
+	return clp.instanceID
+}
+
+// InstanceIDInRecID tells whether the instance ID should
+// be incorporated into record IDs
+func (clp *CNKLogProcessor) InstanceIDInRecID() bool {
+	return clp.instanceIDInID
+}
+
 // GetAppVersion returns an application version (major and minor version info, e.g. 0.15, 1.7)
 func (clp *CNKLogProcessor) GetAppVersion() string {
 	return clp.appVersion
 }
 
+// checkOutputsAvailability tests whether the configured ElasticSearch
+// and InfluxDB servers are reachable. This allows failing fast before
+// any reading begins (and before the worklog is possibly updated based
+// on ignored records). In the dry-run mode, nothing is tested, in the
+// dry-run-diff mode, only ElasticSearch (which is read) is tested.
+func checkOutputsAvailability(conf *config.Main, options *ProcessOptions) error {
+	if options.dryRun || options.analysisOnly {
+		return nil
+	}
+	if conf.ElasticSearch.IsConfigured() {
+		if err := elastic.NewClient(&conf.ElasticSearch).Ping(); err != nil {
+			return err
+		}
+	}
+	if conf.InfluxDB.IsConfigured() && !options.dryRunDiff {
+		if err := influx.Ping(&conf.InfluxDB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ProcessLogs runs through all the logs found in configuration and matching
 // some basic properties (it is a query, preferably from a human user etc.).
 // The "producer" part of the processing runs in a separate goroutine while
@@ -112,7 +237,7 @@ func (clp *CNKLogProcessor) GetAppVersion() string {
 // last loaded value). In case both locations are configured, Redis has
 // precedence.
 func processLogs(conf *config.Main, action string, options *ProcessOptions) {
-	geoDb, err := geoip2.Open(conf.GeoIPDbPath)
+	geoDb, err := geodb.Open(conf.GeoIPDbPath)
 	if err != nil {
 		log.Fatal().Msgf("%s", err)
 	}
@@ -125,6 +250,15 @@ func processLogs(conf *config.Main, action string, options *ProcessOptions) {
 		}
 	}
 	defer geoDb.Close()
+	if err := checkOutputsAvailability(conf, options); err != nil {
+		log.Fatal().Err(err).Msg("preflight check of outputs failed")
+	}
+
+	if (action == config.ActionTail || action == config.ActionHTTP) && conf.GeoIPReloadInterval() > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go geoDb.WatchChanges(ctx, conf.GeoIPReloadInterval())
+	}
 
 	finishEvent := make(chan bool)
 
@@ -133,8 +267,15 @@ func processLogs(conf *config.Main, action string, options *ProcessOptions) {
 		case config.ActionBatch:
 			runBatchAction(conf, options, geoDb, userMap, finishEvent)
 
+		case config.ActionReindex:
+			options.reindex = true
+			runBatchAction(conf, options, geoDb, userMap, finishEvent)
+
 		case config.ActionTail:
 			runTailAction(conf, options, geoDb, userMap, finishEvent)
+
+		case config.ActionHTTP:
+			runHTTPAction(conf, options, geoDb, userMap, finishEvent)
 		}
 	}()
 	<-finishEvent
@@ -0,0 +1,99 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+
+	"klogproc/config"
+	"klogproc/fsop"
+	"klogproc/save/elastic"
+	"klogproc/trfactory"
+	"klogproc/users"
+
+	"github.com/oschwald/geoip2-golang"
+	"github.com/rs/zerolog/log"
+)
+
+// runReplayAction re-creates stored ElasticSearch records of the `logFiles`
+// app type out of their raw input (see batch.Conf.StoreRawInput) using
+// the current transformer and writes them back.
+func runReplayAction(conf *config.Main, options *ProcessOptions) {
+	geoDb, err := geoip2.Open(conf.GeoIPDbPath)
+	if err != nil {
+		log.Fatal().Msgf("%s", err)
+	}
+	defer geoDb.Close()
+	userMap := users.EmptyUserMap()
+	confPath := filepath.Join(conf.CustomConfDir, "usermap.json")
+	if fsop.IsFile(confPath) {
+		userMap, err = users.LoadUserMap(confPath)
+		if err != nil {
+			log.Fatal().Msgf("%s", err)
+		}
+	}
+	lf := conf.LogFiles
+	opts := []trfactory.Option{
+		trfactory.WithGeoIPDB(geoDb),
+		trfactory.WithAnonymousUsers(conf.AnonymousUsers),
+		trfactory.WithUserMap(userMap),
+		trfactory.WithExcludeIPList(lf.ExcludeIPList),
+		trfactory.WithConversionActions(lf.ConversionActions),
+		trfactory.WithTZShiftConf(lf.TZShift),
+		trfactory.WithTraceIDField(lf.TraceIDField),
+		trfactory.WithParsingMode(lf.ParsingMode),
+		trfactory.WithAccessLogFields(lf.AccessLogFields),
+		trfactory.WithClientIPConf(lf.ClientIP),
+		trfactory.WithScriptPath(lf.ScriptPath),
+		trfactory.WithPathTemplates(lf.PathTemplates),
+		trfactory.WithFilterScript(lf.FilterScriptPath),
+		trfactory.WithProcTimeThreshold(lf.ProcTimeThreshold),
+		trfactory.WithExcludeStatus(lf.ExcludeStatus),
+		trfactory.WithQueryAggregates(lf.QueryAggregates),
+	}
+	if conf.AnonymizeIP {
+		opts = append(opts, trfactory.WithAnonymizedIP())
+	}
+	replayer, err := trfactory.NewPipeline(lf.AppType, lf.Version, opts...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize record processing")
+	}
+	client := elastic.NewClient6(&conf.ElasticSearch, lf.AppType)
+	stats, err := client.ReplayRecords(
+		lf.AppType, options.datetimeRange.From, options.datetimeRange.To, replayer,
+		conf.ElasticSearch.FloatPrecision, conf.ElasticSearch.ScrollTTL,
+		conf.ElasticSearch.PushChunkSize, options.dryRun)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Int("numWritten", stats.NumWritten).
+			Int("numSkipped", stats.NumSkipped).
+			Msg("Failed to replay records")
+	}
+	if options.dryRun {
+		log.Info().
+			Int("numWritten", stats.NumWritten).
+			Int("numSkipped", stats.NumSkipped).
+			Msg("Replay finished (dry run - nothing written)")
+
+	} else {
+		log.Info().
+			Int("numWritten", stats.NumWritten).
+			Int("numSkipped", stats.NumSkipped).
+			Msg("Replay finished")
+	}
+}
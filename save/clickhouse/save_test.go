@@ -0,0 +1,112 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"bufio"
+	"encoding/json"
+	"klogproc/servicelog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	action   string
+	procTime float64
+}
+
+func (r *testRecord) ToJSON() ([]byte, error) { return []byte{}, nil }
+func (r *testRecord) GetID() string           { return "1" }
+func (r *testRecord) GetType() string         { return "test" }
+func (r *testRecord) GetTime() time.Time      { return time.Unix(1700000000, 0) }
+func (r *testRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+func (r *testRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{"action": r.action}, map[string]any{"procTime": r.procTime}
+}
+
+func TestRunWriteConsumerBatches(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	var rows []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, r.URL.Query().Get("query"))
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var row map[string]any
+			assert.NoError(t, json.Unmarshal(sc.Bytes(), &row))
+			rows = append(rows, row)
+		}
+	}))
+	defer srv.Close()
+
+	conf := &ConnectionConf{DSN: srv.URL + "/?database=logs", Table: "kontext", PushChunkSize: 2}
+	assert.NoError(t, conf.Validate())
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := RunWriteConsumer(conf, incoming)
+	go func() {
+		for i := 0; i < 3; i++ {
+			incoming <- &servicelog.BoundOutputRecord{
+				FilePath: "/var/log/app.log",
+				Rec:      &testRecord{action: "view", procTime: 0.5},
+				FilePos:  servicelog.LogRange{SeekStart: int64(i * 10), SeekEnd: int64(i*10 + 10)},
+			}
+		}
+		close(incoming)
+	}()
+	var confirms []servicelog.LogRange
+	for msg := range confirmChan {
+		assert.NoError(t, msg.Error)
+		confirms = append(confirms, msg.Position)
+	}
+	assert.Equal(t, 2, len(confirms))
+	assert.Equal(t, int64(0), confirms[0].SeekStart)
+	assert.Equal(t, int64(20), confirms[0].SeekEnd)
+	assert.True(t, confirms[0].Written)
+	assert.Equal(t, int64(20), confirms[1].SeekStart)
+	assert.Equal(t, int64(30), confirms[1].SeekEnd)
+	assert.Equal(t, []string{"INSERT INTO kontext FORMAT JSONEachRow", "INSERT INTO kontext FORMAT JSONEachRow"}, queries)
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, "view", rows[0]["action"])
+	assert.Equal(t, 0.5, rows[0]["procTime"])
+	assert.Equal(t, float64(1700000000), rows[0][TimeColumn])
+}
+
+func TestRunWriteConsumerInsertFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. DB::Exception: Table logs.kontext doesn't exist", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	conf := &ConnectionConf{DSN: srv.URL, Table: "kontext", PushChunkSize: 10, ReqTimeoutSecs: 1}
+	incoming := make(chan *servicelog.BoundOutputRecord, 1)
+	incoming <- &servicelog.BoundOutputRecord{
+		Rec:     &testRecord{action: "view"},
+		FilePos: servicelog.LogRange{SeekStart: 0, SeekEnd: 10},
+	}
+	close(incoming)
+	msg := <-RunWriteConsumer(conf, incoming)
+	assert.Error(t, msg.Error)
+	assert.False(t, msg.Position.Written)
+}
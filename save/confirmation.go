@@ -19,6 +19,7 @@ package save
 import (
 	"fmt"
 	"klogproc/servicelog"
+	"time"
 )
 
 type ConfirmMsg struct {
@@ -36,14 +37,60 @@ func (cm ConfirmMsg) String() string {
 type IgnoredItemMsg struct {
 	FilePath string
 	Position servicelog.LogRange
+	Reason   servicelog.IgnoredReason
 }
 
 func (iim IgnoredItemMsg) String() string {
-	return fmt.Sprintf("IgnoredItemMsg{FilePath: %v, Position: %v}", iim.FilePath, iim.Position)
+	return fmt.Sprintf(
+		"IgnoredItemMsg{FilePath: %v, Position: %v, Reason: %v}", iim.FilePath, iim.Position, iim.Reason)
 }
 
-func NewIgnoredItemMsg(filePath string, position servicelog.LogRange) IgnoredItemMsg {
+func NewIgnoredItemMsg(
+	filePath string,
+	position servicelog.LogRange,
+	reason servicelog.IgnoredReason,
+) IgnoredItemMsg {
 	newPos := position
 	newPos.Written = true
-	return IgnoredItemMsg{FilePath: filePath, Position: newPos}
+	return IgnoredItemMsg{FilePath: filePath, Position: newPos, Reason: reason}
+}
+
+// --------------------
+
+// DeadLetterMsg describes a line which could not be parsed or transformed
+// (as opposed to IgnoredItemMsg describing intentionally skipped lines).
+// Such items can be written to a dead-letter file or index for auditing.
+type DeadLetterMsg struct {
+	AppType  string              `json:"appType"`
+	FilePath string              `json:"filePath"`
+	Position servicelog.LogRange `json:"position"`
+	RawInput string              `json:"rawInput"`
+	Error    string              `json:"error"`
+	Time     time.Time           `json:"datetime"`
+
+	// Reason is either IgnoredReasonParseError or IgnoredReasonTransformError
+	Reason servicelog.IgnoredReason `json:"reason"`
+}
+
+func (dlm DeadLetterMsg) String() string {
+	return fmt.Sprintf(
+		"DeadLetterMsg{FilePath: %v, Position: %v, Error: %v}", dlm.FilePath, dlm.Position, dlm.Error)
+}
+
+func NewDeadLetterMsg(
+	appType, filePath string,
+	position servicelog.LogRange,
+	rawInput string,
+	err error,
+	reason servicelog.IgnoredReason,
+) DeadLetterMsg {
+	return DeadLetterMsg{
+		AppType:  appType,
+		FilePath: filePath,
+		Position: position,
+		RawInput: rawInput,
+		Error:    err.Error(),
+		Time:     time.Now(),
+		Reason:   reason,
+	}
 }
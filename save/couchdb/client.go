@@ -0,0 +1,315 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package couchdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"klogproc/servicelog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultReqTimeoutSecs = 10
+
+	errConflict = "conflict"
+)
+
+// CouchConf specifies a configuration required to store
+// records to a CouchDB database. The URL is the base URL of
+// a CouchDB server (e.g. http://localhost:5984).
+type CouchConf struct {
+	URL            string `json:"url"`
+	Database       string `json:"database"`
+	PushChunkSize  int    `json:"pushChunkSize"`
+	ReqTimeoutSecs int    `json:"reqTimeoutSecs"`
+
+	// Username and Password enable HTTP basic authentication
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// IsConfigured tests whether the configuration is considered
+// to be enabled (i.e. no error checking just enabled/disabled)
+func (conf *CouchConf) IsConfigured() bool {
+	return conf.URL != ""
+}
+
+// Validate tests whether the configuration is filled in
+// correctly. Please note that if the function returns nil
+// then IsConfigured() must return 'true'.
+func (conf *CouchConf) Validate() error {
+	if conf.URL == "" {
+		return fmt.Errorf("missing 'url' information for CouchDB")
+	}
+	if _, err := url.Parse(conf.URL); err != nil {
+		return fmt.Errorf("invalid 'url' for CouchDB: %w", err)
+	}
+	if conf.Database == "" {
+		return fmt.Errorf("missing 'database' information for CouchDB")
+	}
+	if conf.PushChunkSize <= 0 {
+		return fmt.Errorf("couchDb.pushChunkSize must be a positive number")
+	}
+	if conf.Password != "" && conf.Username == "" {
+		return fmt.Errorf("couchDb.password specified without couchDb.username")
+	}
+	if conf.ReqTimeoutSecs == 0 {
+		conf.ReqTimeoutSecs = defaultReqTimeoutSecs
+		log.Warn().Msgf("value couchDb.reqTimeoutSecs not specified, using default %d", defaultReqTimeoutSecs)
+	}
+	return nil
+}
+
+// ------
+
+// document is a JSON object representing a record
+// along with CouchDB's special properties (_id, _rev)
+type document map[string]json.RawMessage
+
+func (doc document) id() string {
+	var ans string
+	json.Unmarshal(doc["_id"], &ans)
+	return ans
+}
+
+func (doc document) setString(key, value string) {
+	doc[key], _ = json.Marshal(value)
+}
+
+type bulkDocsRequest struct {
+	Docs []document `json:"docs"`
+}
+
+type bulkDocsResult struct {
+	ID     string `json:"id"`
+	Rev    string `json:"rev"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+type allDocsRequest struct {
+	Keys []string `json:"keys"`
+}
+
+type allDocsResponse struct {
+	Rows []struct {
+		Key   string `json:"key"`
+		Error string `json:"error"`
+		Value struct {
+			Rev     string `json:"rev"`
+			Deleted bool   `json:"deleted"`
+		} `json:"value"`
+	} `json:"rows"`
+}
+
+// RecordWriter collects records and inserts them to CouchDB
+// in batches using the `_bulk_docs` API. Records' IDs (GetID())
+// are used as document IDs so repeated inserts of the same records
+// update the existing documents instead of creating duplicates.
+// Similarly to other writers, Finish() must be always called
+// to write possible stale records.
+type RecordWriter struct {
+	client        *http.Client
+	dbURL         string
+	username      string
+	password      string
+	pushChunkSize int
+	docs          []document
+}
+
+// AddRecord adds a record and if internal batch is full then
+// it also inserts the batch to CouchDB. The first returned value
+// says whether a write has been performed.
+func (c *RecordWriter) AddRecord(rec servicelog.OutputRecord) (bool, error) {
+	data, err := rec.ToJSON()
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize record for CouchDB: %w", err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false, fmt.Errorf("failed to serialize record for CouchDB: %w", err)
+	}
+	doc.setString("_id", rec.GetID())
+	delete(doc, "_rev")
+	c.docs = append(c.docs, doc)
+	if len(c.docs) >= c.pushChunkSize {
+		return true, c.writeCurrBatch()
+	}
+	return false, nil
+}
+
+// Finish ensures that the current operation is fully
+// processed and all the data are inserted to CouchDB.
+// The first returned value says whether a write has
+// been performed.
+func (c *RecordWriter) Finish() (bool, error) {
+	if len(c.docs) == 0 {
+		return false, nil
+	}
+	return true, c.writeCurrBatch()
+}
+
+// writeCurrBatch inserts current batch of documents. Documents
+// rejected due to an update conflict (i.e. they already exist)
+// are inserted once more with their current revisions.
+func (c *RecordWriter) writeCurrBatch() error {
+	defer func() {
+		c.docs = c.docs[:0]
+	}()
+	results, err := c.bulkDocs(c.docs)
+	if err != nil {
+		return err
+	}
+	conflicting, err := c.evalResults(c.docs, results, true)
+	if err != nil || len(conflicting) == 0 {
+		return err
+	}
+	log.Debug().Msgf("retrying %d conflicting CouchDB documents", len(conflicting))
+	if err := c.setCurrentRevs(conflicting); err != nil {
+		return err
+	}
+	results, err = c.bulkDocs(conflicting)
+	if err != nil {
+		return err
+	}
+	_, err = c.evalResults(conflicting, results, false)
+	return err
+}
+
+// evalResults checks results of the `_bulk_docs` request and returns
+// documents rejected due to an update conflict (in case allowConflicts
+// is true, otherwise the conflicts are reported as errors)
+func (c *RecordWriter) evalResults(
+	docs []document,
+	results []bulkDocsResult,
+	allowConflicts bool,
+) ([]document, error) {
+	byID := make(map[string]document, len(docs))
+	for _, doc := range docs {
+		byID[doc.id()] = doc
+	}
+	var conflicting []document
+	var failed []string
+	for _, res := range results {
+		if res.Error == "" {
+			continue
+		}
+		if res.Error == errConflict && allowConflicts {
+			if doc, ok := byID[res.ID]; ok {
+				conflicting = append(conflicting, doc)
+				continue
+			}
+		}
+		failed = append(failed, fmt.Sprintf("%s: %s (%s)", res.ID, res.Error, res.Reason))
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf(
+			"failed to insert %d document(s) to CouchDB: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return conflicting, nil
+}
+
+func (c *RecordWriter) setCurrentRevs(docs []document) error {
+	keys := make([]string, len(docs))
+	for i, doc := range docs {
+		keys[i] = doc.id()
+	}
+	var resp allDocsResponse
+	if err := c.post("/_all_docs", allDocsRequest{Keys: keys}, &resp); err != nil {
+		return fmt.Errorf("failed to fetch current CouchDB revisions: %w", err)
+	}
+	revs := make(map[string]string, len(resp.Rows))
+	for _, row := range resp.Rows {
+		if row.Error == "" && !row.Value.Deleted {
+			revs[row.Key] = row.Value.Rev
+		}
+	}
+	for _, doc := range docs {
+		if rev, ok := revs[doc.id()]; ok {
+			doc.setString("_rev", rev)
+		}
+	}
+	return nil
+}
+
+func (c *RecordWriter) bulkDocs(docs []document) ([]bulkDocsResult, error) {
+	var results []bulkDocsResult
+	if err := c.post("/_bulk_docs", bulkDocsRequest{Docs: docs}, &results); err != nil {
+		return nil, fmt.Errorf("failed to insert data to CouchDB: %w", err)
+	}
+	return results, nil
+}
+
+func (c *RecordWriter) post(path string, payload any, result any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		context.Background(), http.MethodPost, c.dbURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf(
+			"status: %d, response: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// NewRecordWriter is a factory function for RecordWriter
+func NewRecordWriter(conf *CouchConf) (*RecordWriter, error) {
+	dbURL, err := url.JoinPath(conf.URL, url.PathEscape(conf.Database))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CouchDB url: %w", err)
+	}
+	return &RecordWriter{
+		client:        &http.Client{Timeout: time.Duration(conf.ReqTimeoutSecs) * time.Second},
+		dbURL:         dbURL,
+		username:      conf.Username,
+		password:      conf.Password,
+		pushChunkSize: conf.PushChunkSize,
+		docs:          make([]document, 0, conf.PushChunkSize),
+	}, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package couchdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"klogproc/servicelog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	id     string
+	action string
+}
+
+func (r *testRecord) ToJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"action": r.action})
+}
+func (r *testRecord) GetID() string      { return r.id }
+func (r *testRecord) GetType() string    { return "test" }
+func (r *testRecord) GetTime() time.Time { return time.Unix(1700000000, 0) }
+func (r *testRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+func (r *testRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{}, map[string]any{}
+}
+
+// fakeCouch is a minimal in-memory implementation of CouchDB's
+// `_bulk_docs` and `_all_docs` (with keys) endpoints
+type fakeCouch struct {
+	mu        sync.Mutex
+	docs      map[string]map[string]any
+	revs      map[string]int
+	bulkCalls int
+}
+
+func (fc *fakeCouch) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		switch r.URL.Path {
+		case "/logs/_bulk_docs":
+			fc.bulkCalls++
+			var req struct {
+				Docs []map[string]any `json:"docs"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			ans := make([]bulkDocsResult, 0, len(req.Docs))
+			for _, doc := range req.Docs {
+				id := doc["_id"].(string)
+				currRev := fc.revs[id]
+				if currRev > 0 && doc["_rev"] != fmt.Sprintf("%d-x", currRev) {
+					ans = append(ans, bulkDocsResult{ID: id, Error: errConflict, Reason: "Document update conflict."})
+					continue
+				}
+				fc.revs[id] = currRev + 1
+				fc.docs[id] = doc
+				ans = append(ans, bulkDocsResult{ID: id, OK: true, Rev: fmt.Sprintf("%d-x", currRev+1)})
+			}
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(ans)
+		case "/logs/_all_docs":
+			var req allDocsRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			rows := make([]map[string]any, 0, len(req.Keys))
+			for _, k := range req.Keys {
+				if rev, ok := fc.revs[k]; ok {
+					rows = append(rows, map[string]any{
+						"id": k, "key": k, "value": map[string]any{"rev": fmt.Sprintf("%d-x", rev)}})
+
+				} else {
+					rows = append(rows, map[string]any{"key": k, "error": "not_found"})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"rows": rows})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func runRecords(conf *CouchConf, recs []*testRecord) []confirmation {
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := RunWriteConsumer(conf, incoming)
+	go func() {
+		for i, rec := range recs {
+			incoming <- &servicelog.BoundOutputRecord{
+				FilePath: "/var/log/app.log",
+				Rec:      rec,
+				FilePos:  servicelog.LogRange{SeekStart: int64(i * 10), SeekEnd: int64(i*10 + 10)},
+			}
+		}
+		close(incoming)
+	}()
+	var ans []confirmation
+	for msg := range confirmChan {
+		ans = append(ans, confirmation{pos: msg.Position, err: msg.Error})
+	}
+	return ans
+}
+
+type confirmation struct {
+	pos servicelog.LogRange
+	err error
+}
+
+func TestRunWriteConsumerUpserts(t *testing.T) {
+	fc := &fakeCouch{docs: make(map[string]map[string]any), revs: make(map[string]int)}
+	srv := httptest.NewServer(fc.handler(t))
+	defer srv.Close()
+
+	conf := &CouchConf{URL: srv.URL, Database: "logs", PushChunkSize: 2}
+	assert.NoError(t, conf.Validate())
+	confirms := runRecords(conf, []*testRecord{{"a", "view"}, {"b", "query"}, {"c", "view"}})
+	assert.Equal(t, 2, len(confirms))
+	assert.NoError(t, confirms[0].err)
+	assert.True(t, confirms[0].pos.Written)
+	assert.Equal(t, int64(0), confirms[0].pos.SeekStart)
+	assert.Equal(t, int64(20), confirms[0].pos.SeekEnd)
+	assert.Equal(t, int64(20), confirms[1].pos.SeekStart)
+	assert.Equal(t, "query", fc.docs["b"]["action"])
+	assert.Equal(t, 2, fc.bulkCalls)
+
+	// repeated insert (e.g. a re-read of a file) updates existing documents
+	confirms = runRecords(conf, []*testRecord{{"a", "view2"}, {"d", "query"}})
+	assert.Equal(t, 1, len(confirms))
+	assert.NoError(t, confirms[0].err)
+	assert.True(t, confirms[0].pos.Written)
+	assert.Equal(t, "view2", fc.docs["a"]["action"])
+	assert.Equal(t, 2, fc.revs["a"])
+	assert.Equal(t, 1, fc.revs["d"])
+	assert.Equal(t, 4, fc.bulkCalls)
+}
+
+func TestRunWriteConsumerFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	conf := &CouchConf{URL: srv.URL, Database: "logs", PushChunkSize: 10, ReqTimeoutSecs: 1}
+	confirms := runRecords(conf, []*testRecord{{"a", "view"}})
+	assert.Equal(t, 1, len(confirms))
+	assert.ErrorContains(t, confirms[0].err, "status: 401")
+	assert.False(t, confirms[0].pos.Written)
+}
+
+func TestValidate(t *testing.T) {
+	conf := &CouchConf{URL: "http://localhost:5984", PushChunkSize: 10}
+	assert.Error(t, conf.Validate())
+	conf.Database = "logs"
+	assert.NoError(t, conf.Validate())
+	assert.Equal(t, defaultReqTimeoutSecs, conf.ReqTimeoutSecs)
+	conf.Password = "secret"
+	assert.Error(t, conf.Validate())
+}
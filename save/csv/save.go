@@ -0,0 +1,300 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	enccsv "encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"klogproc/fsop"
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+
+	// IDColumn is the name of the column containing record ID
+	IDColumn = "id"
+
+	// TimeColumn is the name of the column containing record time
+	// (in RFC3339 format)
+	TimeColumn = "time"
+
+	defaultDiscoveryRecords = 100
+)
+
+// Conf configures writing of output records to a CSV file.
+// Columns are derived from tags and values provided
+// by OutputRecord.ToInfluxDB() (plus IDColumn and TimeColumn).
+type Conf struct {
+	Path string `json:"path"`
+
+	// Columns optionally specifies the columns (in the order they are
+	// written) following the ID and time columns. If empty, the columns
+	// are discovered from the first DiscoveryRecords records (union of
+	// all the found keys in alphabetical order).
+	Columns []string `json:"columns"`
+
+	// DiscoveryRecords specifies how many records are inspected to
+	// determine the columns (if Columns is not set). Zero means
+	// a default value.
+	DiscoveryRecords int `json:"discoveryRecords"`
+}
+
+// IsConfigured tests whether the configuration is considered
+// to be enabled (i.e. no error checking just enabled/disabled)
+func (conf *Conf) IsConfigured() bool {
+	return conf != nil && conf.Path != ""
+}
+
+// Validate tests whether the configuration is filled in
+// correctly. Please note that if the function returns nil
+// then IsConfigured() must return 'true'.
+func (conf *Conf) Validate() error {
+	if conf.Path == "" {
+		return fmt.Errorf("missing csv.path")
+	}
+	if !fsop.IsDir(filepath.Dir(conf.Path)) {
+		return fmt.Errorf("directory of csv.path %s does not exist", conf.Path)
+	}
+	if conf.DiscoveryRecords < 0 {
+		return fmt.Errorf("csv.discoveryRecords must be a non-negative number")
+	}
+	return nil
+}
+
+func formatValue(v any) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tv
+	case float32:
+		return strconv.FormatFloat(float64(tv), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	default:
+		return fmt.Sprint(tv)
+	}
+}
+
+// ------
+
+type writeResult struct {
+	rec *servicelog.BoundOutputRecord
+	err error
+}
+
+// recordWriter writes records as CSV rows. In case columns are not
+// known in advance, first records are kept in memory until the columns
+// are discovered. finish() must be always called to write possible
+// pending records.
+type recordWriter struct {
+	out              *enccsv.Writer
+	columns          []string
+	discoveryRecords int
+	pending          []*servicelog.BoundOutputRecord
+	ignoredKeys      map[string]bool
+}
+
+func (w *recordWriter) discoverColumns() {
+	keys := make(map[string]bool)
+	for _, rec := range w.pending {
+		tags, values := rec.Rec.ToInfluxDB()
+		for k := range tags {
+			keys[k] = true
+		}
+		for k := range values {
+			keys[k] = true
+		}
+	}
+	delete(keys, IDColumn)
+	delete(keys, TimeColumn)
+	w.columns = make([]string, 0, len(keys))
+	for k := range keys {
+		w.columns = append(w.columns, k)
+	}
+	sort.Strings(w.columns)
+}
+
+func (w *recordWriter) writeHeader() error {
+	return w.out.Write(append([]string{IDColumn, TimeColumn}, w.columns...))
+}
+
+func (w *recordWriter) writeRecord(rec *servicelog.BoundOutputRecord) error {
+	tags, values := rec.Rec.ToInfluxDB()
+	row := make([]string, len(w.columns)+2)
+	row[0] = rec.GetID()
+	row[1] = rec.Rec.GetTime().Format(time.RFC3339)
+	colIdx := make(map[string]int, len(w.columns))
+	for i, col := range w.columns {
+		colIdx[col] = i + 2
+	}
+	set := func(k, v string) {
+		if i, ok := colIdx[k]; ok {
+			row[i] = v
+
+		} else if !w.ignoredKeys[k] {
+			w.ignoredKeys[k] = true
+			log.Warn().Str("key", k).Msg("CSV output: value of an unknown column ignored")
+		}
+	}
+	for k, v := range values {
+		set(k, formatValue(v))
+	}
+	for k, v := range tags {
+		set(k, v)
+	}
+	return w.out.Write(row)
+}
+
+func (w *recordWriter) writePending() []writeResult {
+	ans := make([]writeResult, len(w.pending))
+	if err := w.writeHeader(); err != nil {
+		for i, rec := range w.pending {
+			ans[i] = writeResult{rec: rec, err: err}
+		}
+		w.pending = nil
+		return ans
+	}
+	for i, rec := range w.pending {
+		ans[i] = writeResult{rec: rec, err: w.writeRecord(rec)}
+	}
+	w.pending = nil
+	return ans
+}
+
+// addRecord adds a record to the output. Because of a possible
+// column discovery, the record may not be written immediately.
+// The returned value contains records written by the call
+// (along with possible errors).
+func (w *recordWriter) addRecord(
+	rec *servicelog.BoundOutputRecord,
+) []writeResult {
+	if w.columns != nil {
+		return []writeResult{{rec: rec, err: w.writeRecord(rec)}}
+	}
+	w.pending = append(w.pending, rec)
+	if len(w.pending) < w.discoveryRecords {
+		return []writeResult{}
+	}
+	w.discoverColumns()
+	return w.writePending()
+}
+
+// finish writes all the pending records and flushes
+// the output.
+func (w *recordWriter) finish() ([]writeResult, error) {
+	ans := []writeResult{}
+	if w.columns == nil {
+		w.discoverColumns()
+		ans = w.writePending()
+	}
+	w.out.Flush()
+	return ans, w.out.Error()
+}
+
+// newRecordWriter is a factory function for recordWriter
+func newRecordWriter(conf *Conf, out io.Writer) *recordWriter {
+	ans := &recordWriter{
+		out:              enccsv.NewWriter(out),
+		discoveryRecords: conf.DiscoveryRecords,
+		ignoredKeys:      make(map[string]bool),
+	}
+	if ans.discoveryRecords == 0 {
+		ans.discoveryRecords = defaultDiscoveryRecords
+	}
+	if len(conf.Columns) > 0 {
+		ans.columns = conf.Columns
+		if err := ans.writeHeader(); err != nil {
+			log.Error().Err(err).Msg("failed to write CSV header")
+		}
+	}
+	return ans
+}
+
+// ------
+
+func confirm(
+	confirmChan chan<- save.ConfirmMsg,
+	written []writeResult,
+) {
+	for _, item := range written {
+		rec, err := item.rec, item.err
+		if err != nil {
+			log.Error().Err(err).Str("file", rec.FilePath).Msg("failed to write record to CSV")
+		}
+		pos := rec.FilePos
+		pos.Written = err == nil
+		confirmChan <- save.ConfirmMsg{
+			FilePath: rec.FilePath,
+			Position: pos,
+			Error:    err,
+		}
+	}
+}
+
+func runWriteConsumer(
+	conf *Conf,
+	out io.Writer,
+	incomingData <-chan *servicelog.BoundOutputRecord,
+	confirmChan chan<- save.ConfirmMsg,
+) {
+	writer := newRecordWriter(conf, out)
+	for rec := range incomingData {
+		confirm(confirmChan, writer.addRecord(rec))
+	}
+	written, err := writer.finish()
+	confirm(confirmChan, written)
+	if err != nil {
+		log.Error().Err(err).Str("path", conf.Path).Msg("failed to write CSV output file")
+	}
+}
+
+// RunWriteConsumer reads from incomingData channel and writes the records
+// to a configured CSV file (the file is truncated first). In case the output
+// is not configured, the incoming data are just consumed.
+func RunWriteConsumer(conf *Conf, incomingData <-chan *servicelog.BoundOutputRecord) <-chan save.ConfirmMsg {
+	confirmChan := make(chan save.ConfirmMsg)
+	go func() {
+		defer close(confirmChan)
+		if !conf.IsConfigured() {
+			for range incomingData {
+			}
+			return
+		}
+		f, err := os.Create(conf.Path)
+		if err != nil {
+			log.Error().Err(err).Str("path", conf.Path).Msg("failed to create CSV output file")
+			for rec := range incomingData {
+				confirm(confirmChan, []writeResult{{rec: rec, err: err}})
+			}
+			return
+		}
+		defer f.Close()
+		runWriteConsumer(conf, f, incomingData, confirmChan)
+	}()
+	return confirmChan
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	id     string
+	tags   map[string]string
+	values map[string]any
+}
+
+func (r *testRecord) ToJSON() ([]byte, error) { return []byte{}, nil }
+func (r *testRecord) GetID() string           { return r.id }
+func (r *testRecord) GetType() string         { return "test" }
+func (r *testRecord) GetTime() time.Time {
+	return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+}
+func (r *testRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+func (r *testRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return r.tags, r.values
+}
+
+func runTest(conf *Conf, recs ...*testRecord) (string, []save.ConfirmMsg) {
+	var buff bytes.Buffer
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := make(chan save.ConfirmMsg)
+	go func() {
+		runWriteConsumer(conf, &buff, incoming, confirmChan)
+		close(confirmChan)
+	}()
+	go func() {
+		for i, rec := range recs {
+			incoming <- &servicelog.BoundOutputRecord{
+				Rec:      rec,
+				FilePath: "app.log",
+				FilePos:  servicelog.LogRange{SeekStart: int64(i * 10), SeekEnd: int64(i*10 + 10)},
+			}
+		}
+		close(incoming)
+	}()
+	var confirms []save.ConfirmMsg
+	for c := range confirmChan {
+		confirms = append(confirms, c)
+	}
+	return buff.String(), confirms
+}
+
+func TestDiscoveredColumnsUnion(t *testing.T) {
+	out, confirms := runTest(
+		&Conf{Path: "out.csv"},
+		&testRecord{
+			id:     "a",
+			tags:   map[string]string{"user": "joe"},
+			values: map[string]any{"procTime": 0.25},
+		},
+		&testRecord{
+			id:     "b",
+			tags:   map[string]string{"corpus": "syn2020"},
+			values: map[string]any{"procTime": 1.5, "numHits": 10},
+		},
+	)
+	assert.Equal(
+		t,
+		"id,time,corpus,numHits,procTime,user\n"+
+			"a,2024-03-01T10:00:00Z,,,0.25,joe\n"+
+			"b,2024-03-01T10:00:00Z,syn2020,10,1.5,\n",
+		out,
+	)
+	assert.Len(t, confirms, 2)
+	assert.True(t, confirms[0].Position.Written)
+	assert.Equal(t, int64(10), confirms[1].Position.SeekStart)
+}
+
+func TestDiscoveryLimitDropsLaterKeys(t *testing.T) {
+	out, confirms := runTest(
+		&Conf{Path: "out.csv", DiscoveryRecords: 1},
+		&testRecord{id: "a", tags: map[string]string{"user": "joe"}},
+		&testRecord{id: "b", tags: map[string]string{"user": "ann", "corpus": "syn2020"}},
+	)
+	assert.Equal(
+		t,
+		"id,time,user\n"+
+			"a,2024-03-01T10:00:00Z,joe\n"+
+			"b,2024-03-01T10:00:00Z,ann\n",
+		out,
+	)
+	assert.Len(t, confirms, 2)
+}
+
+func TestConfiguredColumns(t *testing.T) {
+	out, _ := runTest(
+		&Conf{Path: "out.csv", Columns: []string{"user", "procTime"}},
+		&testRecord{
+			id:     "a",
+			tags:   map[string]string{"user": "joe", "corpus": "syn2020"},
+			values: map[string]any{"procTime": 0.25},
+		},
+	)
+	assert.Equal(t, "id,time,user,procTime\na,2024-03-01T10:00:00Z,joe,0.25\n", out)
+}
+
+func TestNoRecords(t *testing.T) {
+	out, confirms := runTest(&Conf{Path: "out.csv"})
+	assert.Equal(t, "id,time\n", out)
+	assert.Len(t, confirms, 0)
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"klogproc/fsop"
+	"klogproc/save"
+	"klogproc/save/elastic"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// fileMutex makes sure lines written by concurrent
+	// consumers (e.g. multiple tailed files) do not interleave
+	fileMutex sync.Mutex
+)
+
+// Conf configures writing of lines which could not be parsed
+// or transformed (see save.DeadLetterMsg). Items can be written
+// to a file (as JSON lines) and/or to an ElasticSearch index
+// (using the `elasticSearch` connection).
+type Conf struct {
+	FilePath     string `json:"filePath"`
+	ElasticIndex string `json:"elasticIndex"`
+}
+
+func (conf *Conf) IsConfigured() bool {
+	return conf != nil && (conf.FilePath != "" || conf.ElasticIndex != "")
+}
+
+func (conf *Conf) Validate(esConf *elastic.ConnectionConf) error {
+	if conf.FilePath != "" && !fsop.IsDir(filepath.Dir(conf.FilePath)) {
+		return fmt.Errorf(
+			"failed to validate deadLetter: directory of %s does not exist", conf.FilePath)
+	}
+	if conf.ElasticIndex != "" && !esConf.IsConfigured() {
+		return errors.New(
+			"failed to validate deadLetter: elasticIndex requires ElasticSearch to be configured")
+	}
+	return nil
+}
+
+// writer writes individual dead-letter items to configured outputs.
+// The file is opened lazily with the first item.
+type writer struct {
+	conf     *Conf
+	esWriter *elastic.DeadLetterWriter
+	file     *os.File
+}
+
+func (w *writer) writeToFile(item save.DeadLetterMsg) error {
+	if w.file == nil {
+		var err error
+		w.file, err = os.OpenFile(w.conf.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open dead-letter file: %w", err)
+		}
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead-letter item: %w", err)
+	}
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+	_, err = w.file.Write(append(data, '\n'))
+	return err
+}
+
+func (w *writer) write(item save.DeadLetterMsg) {
+	if w.conf.FilePath != "" {
+		if err := w.writeToFile(item); err != nil {
+			log.Error().Err(err).Str("file", item.FilePath).Msg("failed to write dead-letter item to file")
+		}
+	}
+	if w.esWriter != nil {
+		if err := w.esWriter.WriteDeadLetters([]save.DeadLetterMsg{item}); err != nil {
+			log.Error().Err(err).Str("file", item.FilePath).Msg("failed to write dead-letter item to ElasticSearch")
+		}
+	}
+}
+
+func (w *writer) close() {
+	if w.file != nil {
+		w.file.Close()
+	}
+}
+
+// RunWriteConsumer reads from the incoming channel and writes each item
+// to the configured outputs. Each item is then confirmed as handled
+// (even if the writing fails) so the worklog advances and the failed
+// line is not read again and again. In case the dead-letter output is not
+// configured, the incoming items are just confirmed.
+func RunWriteConsumer(
+	conf *Conf,
+	esConf *elastic.ConnectionConf,
+	incoming <-chan save.DeadLetterMsg,
+) <-chan save.IgnoredItemMsg {
+	confirmChan := make(chan save.IgnoredItemMsg)
+	go func() {
+		defer close(confirmChan)
+		var w *writer
+		if conf.IsConfigured() {
+			w = &writer{conf: conf}
+			if conf.ElasticIndex != "" {
+				w.esWriter = elastic.NewDeadLetterWriter(esConf, conf.ElasticIndex)
+			}
+			defer w.close()
+		}
+		for item := range incoming {
+			if w != nil {
+				w.write(item)
+			}
+			confirmChan <- save.NewIgnoredItemMsg(item.FilePath, item.Position, item.Reason)
+		}
+	}()
+	return confirmChan
+}
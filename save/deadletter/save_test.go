@@ -0,0 +1,83 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"klogproc/save"
+	"klogproc/save/elastic"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWriteConsumerWritesFile(t *testing.T) {
+	conf := &Conf{FilePath: filepath.Join(t.TempDir(), "deadletter.jsonl")}
+	incoming := make(chan save.DeadLetterMsg, 2)
+	pos := servicelog.LogRange{Inode: 7, SeekStart: 10, SeekEnd: 20, Line: 3}
+	incoming <- save.NewDeadLetterMsg("kontext", "/var/log/app.log", pos, "{broken", errors.New("unexpected EOF"),
+		servicelog.IgnoredReasonParseError)
+	close(incoming)
+	var confirmed []save.IgnoredItemMsg
+	for msg := range RunWriteConsumer(conf, &elastic.ConnectionConf{}, incoming) {
+		confirmed = append(confirmed, msg)
+	}
+	assert.Len(t, confirmed, 1)
+	assert.True(t, confirmed[0].Position.Written)
+	assert.Equal(t, int64(20), confirmed[0].Position.SeekEnd)
+	assert.Equal(t, servicelog.IgnoredReasonParseError, confirmed[0].Reason)
+
+	data, err := os.ReadFile(conf.FilePath)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 1)
+	var item save.DeadLetterMsg
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &item))
+	assert.Equal(t, "{broken", item.RawInput)
+	assert.Equal(t, "unexpected EOF", item.Error)
+	assert.Equal(t, "/var/log/app.log", item.FilePath)
+	assert.Equal(t, int64(3), item.Position.Line)
+	assert.Equal(t, servicelog.IgnoredReasonParseError, item.Reason)
+}
+
+func TestRunWriteConsumerNotConfigured(t *testing.T) {
+	incoming := make(chan save.DeadLetterMsg, 1)
+	incoming <- save.NewDeadLetterMsg(
+		"kontext", "/var/log/app.log", servicelog.LogRange{SeekEnd: 5}, "x", errors.New("err"),
+		servicelog.IgnoredReasonTransformError)
+	close(incoming)
+	var numConfirmed int
+	for range RunWriteConsumer(nil, &elastic.ConnectionConf{}, incoming) {
+		numConfirmed++
+	}
+	assert.Equal(t, 1, numConfirmed)
+}
+
+func TestConfValidate(t *testing.T) {
+	conf := &Conf{ElasticIndex: "klogproc_deadletter"}
+	assert.Error(t, conf.Validate(&elastic.ConnectionConf{}))
+	conf = &Conf{FilePath: "/nonexistent/dir/deadletter.jsonl"}
+	assert.Error(t, conf.Validate(&elastic.ConnectionConf{}))
+	conf = &Conf{FilePath: filepath.Join(t.TempDir(), "deadletter.jsonl")}
+	assert.NoError(t, conf.Validate(&elastic.ConnectionConf{}))
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"klogproc/analysis"
+)
+
+const (
+	botCandidateDocType = "botCandidate"
+)
+
+// botCandidateID derives a document ID from the candidate's identity
+// so repeated exports (e.g. batch reruns) do not create duplicates
+func botCandidateID(cand analysis.BotCandidate) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf(
+		"%s#%s#%s#%s",
+		cand.AppType, cand.IP, cand.Reason, cand.IntervalEnd.UTC().Format(time.RFC3339))))
+	return hex.EncodeToString(sum[:])
+}
+
+// BotCandidateWriter exports detected bot candidates to a dedicated
+// index (see ConnectionConf.BotCandidatesIndex)
+type BotCandidateWriter struct {
+	conf *ConnectionConf
+}
+
+func (w *BotCandidateWriter) encodeBulk(candidates []analysis.BotCandidate) ([]byte, error) {
+	data := make([][]byte, 0, len(candidates)*2+1)
+	for _, cand := range candidates {
+		meta, err := (&ESCNKRecordMeta{
+			Index: CNKRecordMeta{
+				Index: w.conf.BotCandidatesIndex,
+				ID:    botCandidateID(cand),
+				Type:  w.conf.bulkDocType(botCandidateDocType),
+			},
+		}).ToJSON()
+		if err != nil {
+			return nil, err
+		}
+		doc, err := json.Marshal(cand)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, meta, doc)
+	}
+	data = append(data, []byte("\n"))
+	return bytes.Join(data, []byte("\n")), nil
+}
+
+// ExportBotCandidates writes the candidates using a single bulk request
+func (w *BotCandidateWriter) ExportBotCandidates(candidates []analysis.BotCandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	q, err := w.encodeBulk(candidates)
+	if err != nil {
+		return fmt.Errorf("failed to encode bot candidates: %w", err)
+	}
+	if _, err := NewClient(w.conf).Do("POST", "/_bulk", q); err != nil {
+		return fmt.Errorf("failed to write bot candidates: %w", err)
+	}
+	return nil
+}
+
+// NewBotCandidateWriter creates a new BotCandidateWriter instance
+func NewBotCandidateWriter(conf *ConnectionConf) *BotCandidateWriter {
+	return &BotCandidateWriter{conf: conf}
+}
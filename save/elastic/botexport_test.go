@@ -0,0 +1,82 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"klogproc/analysis"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotCandidateWriter(t *testing.T) {
+	var body, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		path = r.URL.Path
+		w.Write([]byte(`{"took": 1, "errors": false, "items": []}`))
+	}))
+	defer srv.Close()
+	conf := &ConnectionConf{
+		Server:             srv.URL,
+		Index:              "logs",
+		MajorVersion:       6,
+		ReqTimeoutSecs:     5,
+		BotCandidatesIndex: "bot_candidates",
+	}
+	assert.True(t, conf.ExportsBotCandidates())
+	end := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	cand := analysis.BotCandidate{
+		AppType:       "kontext",
+		IP:            "192.168.1.10",
+		Reason:        analysis.BotReasonIPOutlier,
+		NumRequests:   1200,
+		Threshold:     300,
+		IntervalStart: end.Add(-time.Hour),
+		IntervalEnd:   end,
+	}
+	err := NewBotCandidateWriter(conf).ExportBotCandidates([]analysis.BotCandidate{cand})
+	assert.NoError(t, err)
+	assert.Equal(t, "/_bulk", path)
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(
+		t,
+		`{"index":{"_index":"bot_candidates","_id":"`+botCandidateID(cand)+`","_type":"_doc"}}`,
+		lines[0],
+	)
+	assert.Contains(t, lines[1], `"ip":"192.168.1.10"`)
+	assert.Contains(t, lines[1], `"reason":"ipOutlier"`)
+	assert.Contains(t, lines[1], `"intervalEnd":"2024-03-01T10:00:00Z"`)
+}
+
+func TestBotCandidateIDIsDeterministic(t *testing.T) {
+	end := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	c1 := analysis.BotCandidate{AppType: "kontext", IP: "10.0.0.1", Reason: "ipOutlier", IntervalEnd: end}
+	c2 := c1
+	c2.NumRequests = 10
+	assert.Equal(t, botCandidateID(c1), botCandidateID(c2))
+	c2.IntervalEnd = end.Add(time.Minute)
+	assert.NotEqual(t, botCandidateID(c1), botCandidateID(c2))
+}
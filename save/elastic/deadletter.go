@@ -0,0 +1,97 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"klogproc/save"
+)
+
+const (
+	deadLetterDocType = "deadLetter"
+)
+
+// deadLetterID derives a document ID from the item's position
+// so repeated processing of the same line does not create duplicates
+func deadLetterID(item save.DeadLetterMsg) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf(
+		"%s#%d#%d", item.FilePath, item.Position.Inode, item.Position.SeekStart)))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeadLetterWriter writes lines which could not be parsed
+// or transformed to a dedicated index
+type DeadLetterWriter struct {
+	conf  *ConnectionConf
+	index string
+}
+
+func (w *DeadLetterWriter) encodeBulk(items []save.DeadLetterMsg) ([]byte, error) {
+	data := make([][]byte, 0, len(items)*2+1)
+	for _, item := range items {
+		meta, err := (&ESCNKRecordMeta{
+			Index: CNKRecordMeta{
+				Index: w.index,
+				ID:    deadLetterID(item),
+				Type:  w.conf.bulkDocType(deadLetterDocType),
+			},
+		}).ToJSON()
+		if err != nil {
+			return nil, err
+		}
+		doc, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, meta, doc)
+	}
+	data = append(data, []byte("\n"))
+	return bytes.Join(data, []byte("\n")), nil
+}
+
+// WriteDeadLetters writes the items using a single bulk request
+func (w *DeadLetterWriter) WriteDeadLetters(items []save.DeadLetterMsg) error {
+	if len(items) == 0 {
+		return nil
+	}
+	q, err := w.encodeBulk(items)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead-letter items: %w", err)
+	}
+	resp, err := NewClient(w.conf).Do("POST", "/_bulk", q)
+	if err != nil {
+		return fmt.Errorf("failed to write dead-letter items: %w", err)
+	}
+	var bwResp BulkWriteResp
+	if err := json.Unmarshal(resp, &bwResp); err != nil {
+		return fmt.Errorf("failed to decode dead-letter bulk response: %w", err)
+	}
+	if bwResp.Errors {
+		return fmt.Errorf("failed to write dead-letter items: %s", bwResp.FirstError())
+	}
+	return nil
+}
+
+// NewDeadLetterWriter creates a new DeadLetterWriter instance
+func NewDeadLetterWriter(conf *ConnectionConf, index string) *DeadLetterWriter {
+	return &DeadLetterWriter{conf: conf, index: index}
+}
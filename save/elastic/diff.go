@@ -0,0 +1,208 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DiffStats summarizes results of comparing processed records
+// with documents already stored in ElasticSearch
+type DiffStats struct {
+	New       int
+	Changed   int
+	Identical int
+	Failed    int
+}
+
+func (ds DiffStats) String() string {
+	return fmt.Sprintf(
+		"new: %d, changed: %d, identical: %d, failed: %d",
+		ds.New, ds.Changed, ds.Identical, ds.Failed)
+}
+
+// FieldDiff describes a single changed field of a document.
+// Nested fields are represented using the dot notation.
+type FieldDiff struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+func (fd FieldDiff) String() string {
+	return fmt.Sprintf("%s: %v -> %v", fd.Field, fd.Old, fd.New)
+}
+
+// diffDocuments compares two JSON-decoded documents and returns
+// a list of changed fields sorted by their names
+func diffDocuments(prefix string, stored, current map[string]any) []FieldDiff {
+	keys := make(map[string]bool)
+	for k := range stored {
+		keys[k] = true
+	}
+	for k := range current {
+		keys[k] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+
+	ans := make([]FieldDiff, 0, 5)
+	for _, k := range sortedKeys {
+		oldVal, newVal := stored[k], current[k]
+		oldMap, ok1 := oldVal.(map[string]any)
+		newMap, ok2 := newVal.(map[string]any)
+		if ok1 && ok2 {
+			ans = append(ans, diffDocuments(prefix+k+".", oldMap, newMap)...)
+
+		} else if !reflect.DeepEqual(oldVal, newVal) {
+			ans = append(ans, FieldDiff{Field: prefix + k, Old: oldVal, New: newVal})
+		}
+	}
+	return ans
+}
+
+// diffReport is a JSON representation of a record
+// comparison result
+type diffReport struct {
+	ID      string      `json:"id"`
+	Status  string      `json:"status"`
+	Changes []FieldDiff `json:"changes,omitempty"`
+}
+
+// writeDiff prints a result of a record comparison. A nil diff
+// means there is no stored document with the ID.
+func writeDiff(out io.Writer, recID string, diff []FieldDiff, jsonOutput bool) error {
+	status := "changed"
+	if diff == nil {
+		status = "new"
+	}
+	if jsonOutput {
+		data, err := json.Marshal(diffReport{ID: recID, Status: status, Changes: diff})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	}
+	if _, err := fmt.Fprintf(out, "[%s] %s\n", status, recID); err != nil {
+		return err
+	}
+	for _, fd := range diff {
+		if _, err := fmt.Fprintf(out, "\t%s\n", fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunDiffConsumer reads incoming records and compares them with documents
+// stored in ElasticSearch (matched by their IDs). Found differences are
+// printed to stdout (as plain text or as JSON lines if jsonOutput is set)
+// and counted in the provided stats. Nothing is written to the database.
+// The stats can be safely read once the returned channel is closed.
+func RunDiffConsumer(
+	appType string,
+	conf *ConnectionConf,
+	incomingData <-chan *servicelog.BoundOutputRecord,
+	stats *DiffStats,
+	jsonOutput bool,
+) <-chan save.ConfirmMsg {
+	confirmChan := make(chan save.ConfirmMsg)
+	go func() {
+		var esclient *ESClient
+		if conf.usesLegacyDocTypes() {
+			esclient = NewClient(conf)
+
+		} else {
+			esclient = NewClient6(conf, appType)
+		}
+		for rec := range incomingData {
+			recType := es6DocType
+			if conf.usesLegacyDocTypes() {
+				recType = rec.GetType()
+			}
+			err := compareWithStored(esclient, appType, recType, conf, rec, stats, jsonOutput)
+			if err != nil {
+				log.Error().Err(err).Msgf("failed to compare item %s", rec.GetID())
+				stats.Failed++
+			}
+			pos := rec.FilePos
+			pos.Written = true
+			confirmChan <- save.ConfirmMsg{
+				FilePath: rec.FilePath,
+				Position: pos,
+			}
+		}
+		close(confirmChan)
+	}()
+	return confirmChan
+}
+
+func compareWithStored(
+	esclient *ESClient,
+	appType string,
+	recType string,
+	conf *ConnectionConf,
+	rec *servicelog.BoundOutputRecord,
+	stats *DiffStats,
+	jsonOutput bool,
+) error {
+	jsonData, err := rec.ToJSON()
+	if err == nil && conf.FloatPrecision.IsActive() {
+		jsonData, err = conf.FloatPrecision.Apply(jsonData)
+	}
+	if err != nil {
+		return err
+	}
+	recID, err := resolveRecordID(conf.EmptyIDPolicy, rec.GetID(), jsonData)
+	if err != nil {
+		return err
+	}
+	var current map[string]any
+	if err := json.Unmarshal(jsonData, &current); err != nil {
+		return err
+	}
+	stored, err := esclient.GetIndexDocument(
+		conf.RecordIndex(appType, rec.GetTime()), recType, recID)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		stats.New++
+		return writeDiff(os.Stdout, recID, nil, jsonOutput)
+	}
+	diff := diffDocuments("", stored, current)
+	if len(diff) == 0 {
+		stats.Identical++
+		return nil
+	}
+	stats.Changed++
+	return writeDiff(os.Stdout, recID, diff, jsonOutput)
+}
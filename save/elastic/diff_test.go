@@ -0,0 +1,75 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	stored := map[string]any{
+		"action":   "query_submit",
+		"procTime": 0.5,
+		"geoip":    map[string]any{"country_name": "Czechia", "ip": "1.2.3.4"},
+		"isQuery":  true,
+	}
+	current := map[string]any{
+		"action":   "query_submit",
+		"procTime": 0.7,
+		"geoip":    map[string]any{"country_name": "Czechia", "ip": "1.2.3.5"},
+		"traceId":  "abc",
+	}
+	ans := diffDocuments("", stored, current)
+	assert.Equal(
+		t,
+		[]FieldDiff{
+			{Field: "geoip.ip", Old: "1.2.3.4", New: "1.2.3.5"},
+			{Field: "isQuery", Old: true, New: nil},
+			{Field: "procTime", Old: 0.5, New: 0.7},
+			{Field: "traceId", Old: nil, New: "abc"},
+		},
+		ans,
+	)
+}
+
+func TestDiffDocumentsIdentical(t *testing.T) {
+	doc := map[string]any{"action": "view", "args": map[string]any{"q": []any{"a"}}}
+	assert.Empty(t, diffDocuments("", doc, doc))
+}
+
+func TestWriteDiffJSON(t *testing.T) {
+	var buff bytes.Buffer
+	assert.NoError(t, writeDiff(&buff, "rec1", nil, true))
+	assert.NoError(
+		t, writeDiff(&buff, "rec2", []FieldDiff{{Field: "procTime", Old: 0.5, New: 0.7}}, true))
+	assert.Equal(
+		t,
+		"{\"id\":\"rec1\",\"status\":\"new\"}\n"+
+			"{\"id\":\"rec2\",\"status\":\"changed\",\"changes\":[{\"field\":\"procTime\",\"old\":0.5,\"new\":0.7}]}\n",
+		buff.String(),
+	)
+}
+
+func TestWriteDiffText(t *testing.T) {
+	var buff bytes.Buffer
+	assert.NoError(
+		t, writeDiff(&buff, "rec2", []FieldDiff{{Field: "procTime", Old: 0.5, New: 0.7}}, false))
+	assert.Equal(t, "[changed] rec2\n\tprocTime: 0.5 -> 0.7\n", buff.String())
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -41,6 +42,77 @@ type ConnectionConf struct {
 	ScrollTTL      string `json:"scrollTtl"`
 	ReqTimeoutSecs int    `json:"reqTimeoutSecs"`
 	MajorVersion   int    `json:"majorVersion"`
+
+	// FloatPrecision optionally specifies rounding of some
+	// numeric fields (the default is to keep full precision)
+	FloatPrecision *FloatPrecision `json:"floatPrecision"`
+
+	// Flavor specifies a search engine klogproc writes to. Supported values
+	// are `elasticsearch` (default) and `opensearch`. The differences
+	// in the OpenSearch mode are:
+	//   - the `_type` field is omitted in bulk insert metadata (OpenSearch 2
+	//     rejects it),
+	//   - `majorVersion` is ignored and the "ES 6+" layout is always used
+	//     (i.e. one index per app type named `<index>_<appType>`),
+	//   - no Elastic specific product checks are expected (klogproc's client
+	//     does not validate the `X-Elastic-Product` response header in any
+	//     mode so OpenSearch responses are accepted as they are).
+	// The mode follows the OpenSearch 2.x API documentation; it has not
+	// been tested against a running OpenSearch instance.
+	Flavor string `json:"flavor"`
+
+	// EmptyIDPolicy specifies how to handle records with an empty ID
+	// (which would otherwise get a random ID from ElasticSearch and thus
+	// duplicate on reruns). Supported values are `drop` (default) - the
+	// record is not stored and an error is logged, and `hash` - an ID
+	// is derived from the record's content.
+	EmptyIDPolicy string `json:"emptyIdPolicy"`
+
+	// FlushIntervalSecs optionally specifies a max. time a partially
+	// filled chunk waits for new records before it is written anyway
+	// (zero means that only full chunks are written until the end
+	// of input)
+	FlushIntervalSecs int `json:"flushIntervalSecs"`
+
+	// BotCandidatesIndex optionally specifies an index where IP addresses
+	// detected by bot detection (see load.BotDetectionConf) are written.
+	// If empty, the candidates are only reported via notifications.
+	BotCandidatesIndex string `json:"botCandidatesIndex"`
+
+	// IndexSettings optionally specifies (per app type, `*` for all
+	// the other app types) settings of time-partitioned indices created
+	// by klogproc. The settings are applied only when an index is created,
+	// existing indices are not affected.
+	IndexSettings map[string]*IndexSettings `json:"indexSettings"`
+}
+
+// ExportsBotCandidates tests whether detected bot candidates
+// should be written to a dedicated index
+func (conf *ConnectionConf) ExportsBotCandidates() bool {
+	return conf.IsConfigured() && conf.BotCandidatesIndex != ""
+}
+
+// IsOpenSearch tests whether the configured server is OpenSearch
+func (conf *ConnectionConf) IsOpenSearch() bool {
+	return conf.Flavor == FlavorOpenSearch
+}
+
+// usesLegacyDocTypes tests whether records are stored in a single
+// index with custom (per app type) document types (ES < 6)
+func (conf *ConnectionConf) usesLegacyDocTypes() bool {
+	return !conf.IsOpenSearch() && conf.MajorVersion < 6
+}
+
+// bulkDocType returns a value for the `_type` field of bulk insert
+// metadata. An empty value means the field is omitted.
+func (conf *ConnectionConf) bulkDocType(recType string) string {
+	if conf.IsOpenSearch() {
+		return ""
+	}
+	if conf.MajorVersion < 6 {
+		return recType
+	}
+	return es6DocType
 }
 
 // IsConfigured tests whether the configuration is considered
@@ -56,6 +128,9 @@ func (conf *ConnectionConf) Validate() error {
 	if conf.Index == "" {
 		return fmt.Errorf("ERROR: index/indexPrefix not set for ElasticSearch")
 	}
+	if err := validateIndexName(conf.Index); err != nil {
+		return fmt.Errorf("ERROR: elasticSearch.index: %w", err)
+	}
 	if conf.ScrollTTL == "" {
 		return fmt.Errorf("ERROR: elasticScrollTtl must be a valid ElasticSearch scroll arg value (e.g. '2m', '30s')")
 	}
@@ -66,6 +141,35 @@ func (conf *ConnectionConf) Validate() error {
 		conf.ReqTimeoutSecs = defaultReqTimeoutSecs
 		log.Warn().Msgf("missing elasticSearch.reqTimeoutSecs, using default %d", defaultReqTimeoutSecs)
 	}
+	if conf.FloatPrecision != nil {
+		if err := conf.FloatPrecision.Validate(); err != nil {
+			return fmt.Errorf("ERROR: %w", err)
+		}
+	}
+	switch conf.Flavor {
+	case "":
+		conf.Flavor = FlavorElasticsearch
+	case FlavorElasticsearch, FlavorOpenSearch:
+	default:
+		return fmt.Errorf("ERROR: unsupported elasticSearch.flavor: %s", conf.Flavor)
+	}
+	if err := validateEmptyIDPolicy(conf.EmptyIDPolicy); err != nil {
+		return fmt.Errorf("ERROR: elasticSearch: %w", err)
+	}
+	if conf.FlushIntervalSecs < 0 {
+		return fmt.Errorf("ERROR: elasticSearch.flushIntervalSecs must be a non-negative number")
+	}
+	for appType, settings := range conf.IndexSettings {
+		if settings == nil {
+			return fmt.Errorf("ERROR: elasticSearch.indexSettings.%s is empty", appType)
+		}
+		if err := settings.Validate(); err != nil {
+			return fmt.Errorf("ERROR: elasticSearch.indexSettings.%s: %w", appType, err)
+		}
+	}
+	if len(conf.IndexSettings) > 0 && !hasDatePlaceholders(conf.Index) {
+		log.Warn().Msg("elasticSearch.indexSettings apply only to time-partitioned indices, ignoring")
+	}
 	return nil
 }
 
@@ -139,11 +243,13 @@ type ESClient struct {
 	reqTimeoutSecs int
 }
 
-// NewClient returns an instance of ESClient
+// NewClient returns an instance of ESClient. In case the configured
+// index name contains placeholders, they are replaced with wildcards
+// (i.e. the client searches all the matching indices).
 func NewClient(conf *ConnectionConf) *ESClient {
 	return &ESClient{
 		server:         conf.Server,
-		index:          conf.Index,
+		index:          interpolateIndexName(conf.Index, "*", time.Time{}, false),
 		reqTimeoutSecs: conf.ReqTimeoutSecs,
 	}
 }
@@ -152,7 +258,17 @@ func NewClient(conf *ConnectionConf) *ESClient {
 func NewClient6(conf *ConnectionConf, appType string) *ESClient {
 	return &ESClient{
 		server:         conf.Server,
-		index:          fmt.Sprintf("%s_%s", conf.Index, appType),
+		index:          conf.IndexPattern(appType),
+		reqTimeoutSecs: conf.ReqTimeoutSecs,
+	}
+}
+
+// NewIndexClient returns an instance of ESClient for
+// an explicitly specified index
+func NewIndexClient(conf *ConnectionConf, index string) *ESClient {
+	return &ESClient{
+		server:         conf.Server,
+		index:          index,
 		reqTimeoutSecs: conf.ReqTimeoutSecs,
 	}
 }
@@ -199,6 +315,65 @@ func (c *ESClient) Do(method string, path string, query []byte) ([]byte, error)
 	return respBody, nil
 }
 
+// Ping tests whether the server is reachable and responds
+// with a success status code.
+func (c *ESClient) Ping() error {
+	client := http.Client{Timeout: time.Second * time.Duration(c.reqTimeoutSecs)}
+	resp, err := client.Get(c.server + "/")
+	if err != nil {
+		return fmt.Errorf("ElasticSearch server %s is not reachable: %w", c.server, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newESClientError(
+			fmt.Sprintf("ElasticSearch server %s responded with code %d", c.server, resp.StatusCode),
+			respBody, []byte{})
+	}
+	return nil
+}
+
+// GetDocument fetches a stored document (its `_source` part) by its ID.
+// In case the document does not exist, nil is returned with no error.
+func (c *ESClient) GetDocument(docType, id string) (map[string]any, error) {
+	return c.GetIndexDocument(c.index, docType, id)
+}
+
+// GetIndexDocument fetches a stored document (its `_source` part) from
+// an explicitly specified index. In case the document does not exist,
+// nil is returned with no error.
+func (c *ESClient) GetIndexDocument(index, docType, id string) (map[string]any, error) {
+	client := http.Client{Timeout: time.Second * time.Duration(c.reqTimeoutSecs)}
+	path := fmt.Sprintf("/%s/%s/%s", index, docType, url.PathEscape(id))
+	resp, err := client.Get(c.server + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return nil, newESClientError(
+			fmt.Sprintf("Request %s failed with code %d", path, resp.StatusCode), respBody, []byte{})
+	}
+	var doc struct {
+		Found  bool           `json:"found"`
+		Source map[string]any `json:"_source"`
+	}
+	if err := json.Unmarshal(respBody, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode ES document: %w", err)
+	}
+	if !doc.Found {
+		return nil, nil
+	}
+	return doc.Source, nil
+}
+
 // search is a low level search function
 func (c *ESClient) search(query []byte, scroll string) (Result, error) {
 	path := "/" + c.index + "/_search"
@@ -0,0 +1,44 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version": {"number": "6.8.0"}}`))
+	}))
+	defer srv.Close()
+	assert.NoError(t, NewClient(&ConnectionConf{Server: srv.URL, ReqTimeoutSecs: 1}).Ping())
+}
+
+func TestPingFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"type": "security_exception", "reason": "missing authentication"}}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	assert.ErrorContains(t, NewClient(&ConnectionConf{Server: srv.URL, ReqTimeoutSecs: 1}).Ping(), "code 401")
+
+	srv.Close()
+	assert.ErrorContains(t, NewClient(&ConnectionConf{Server: srv.URL, ReqTimeoutSecs: 1}).Ping(), "not reachable")
+}
@@ -101,7 +101,7 @@ func (sq *srchQuery) ToJSONQuery() ([]byte, error) {
 type CNKRecordMeta struct {
 	Index string `json:"_index"`
 	ID    string `json:"_id"`
-	Type  string `json:"_type"`
+	Type  string `json:"_type,omitempty"`
 }
 
 // ESCNKRecordMeta is just a wrapper for CNKRecordMeta
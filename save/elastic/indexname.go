@@ -0,0 +1,107 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// appTypePlaceholder can be used in an index name to specify
+	// where the app type is placed (for ES 6+, the default is to
+	// append `_<appType>` to the index name)
+	appTypePlaceholder = "{appType}"
+)
+
+var (
+	indexPlaceholderRegexp = regexp.MustCompile(`\{([^}]*)\}`)
+	datePlaceholderRegexp  = regexp.MustCompile(`^(YYYY|MM|DD)([.\-_]?(YYYY|MM|DD))*$`)
+	datePlaceholderLayout  = strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02")
+)
+
+// validateIndexName tests whether all the placeholders in an index
+// name are supported
+func validateIndexName(index string) error {
+	for _, m := range indexPlaceholderRegexp.FindAllStringSubmatch(index, -1) {
+		if "{"+m[1]+"}" != appTypePlaceholder && !datePlaceholderRegexp.MatchString(m[1]) {
+			return fmt.Errorf("unsupported placeholder %s in index name %s", m[0], index)
+		}
+	}
+	return nil
+}
+
+// hasDatePlaceholders tests whether the index name
+// is time-partitioned
+func hasDatePlaceholders(index string) bool {
+	for _, m := range indexPlaceholderRegexp.FindAllStringSubmatch(index, -1) {
+		if datePlaceholderRegexp.MatchString(m[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// interpolateIndexName replaces app type and date placeholders
+// with actual values. If replaceDates is false, date placeholders are
+// replaced with a wildcard so the name can be used in searches.
+func interpolateIndexName(index, appType string, t time.Time, replaceDates bool) string {
+	index = strings.ReplaceAll(index, appTypePlaceholder, appType)
+	return indexPlaceholderRegexp.ReplaceAllStringFunc(index, func(m string) string {
+		if !replaceDates {
+			return "*"
+		}
+		return t.Format(datePlaceholderLayout.Replace(m[1 : len(m)-1]))
+	})
+}
+
+// hitIndex returns an index a bulk operation on a search hit
+// should target. For index names with placeholders, the actual
+// index of the hit is used.
+func hitIndex(index string, hit ResultHit) string {
+	if hit.Index != "" && indexPlaceholderRegexp.MatchString(index) {
+		return hit.Index
+	}
+	return index
+}
+
+// indexTemplate returns the configured index name with the app type
+// applied the way the configured ElasticSearch version expects
+// (ES 6+ uses the `<index>_<appType>` layout unless the name contains
+// the `{appType}` placeholder).
+func (conf *ConnectionConf) indexTemplate(appType string) string {
+	if conf.usesLegacyDocTypes() || strings.Contains(conf.Index, appTypePlaceholder) {
+		return conf.Index
+	}
+	return fmt.Sprintf("%s_%s", conf.Index, appType)
+}
+
+// RecordIndex returns the name of an index a record of the app type
+// created at the time t belongs to. In case the configured index
+// name contains date placeholders, they are filled in using
+// the provided time (e.g. `logs-{YYYY.MM.DD}` => `logs-2024.01.15`).
+func (conf *ConnectionConf) RecordIndex(appType string, t time.Time) string {
+	return interpolateIndexName(conf.indexTemplate(appType), appType, t, true)
+}
+
+// IndexPattern returns an index name usable for searching records
+// of the app type. Date placeholders are replaced with a wildcard.
+func (conf *ConnectionConf) IndexPattern(appType string) string {
+	return interpolateIndexName(conf.indexTemplate(appType), appType, time.Time{}, false)
+}
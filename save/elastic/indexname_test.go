@@ -0,0 +1,91 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type timedTestRecord struct {
+	testRecord
+	t time.Time
+}
+
+func (r *timedTestRecord) GetTime() time.Time { return r.t }
+
+func TestRecordIndex(t *testing.T) {
+	tm := time.Date(2024, 1, 15, 23, 59, 0, 0, time.UTC)
+	conf := &ConnectionConf{Index: "logs", MajorVersion: 6}
+	assert.Equal(t, "logs_kontext", conf.RecordIndex("kontext", tm))
+	assert.Equal(t, "logs_kontext", conf.IndexPattern("kontext"))
+
+	conf.Index = "logs-{YYYY.MM.DD}"
+	assert.Equal(t, "logs-2024.01.15_kontext", conf.RecordIndex("kontext", tm))
+	assert.Equal(t, "logs-*_kontext", conf.IndexPattern("kontext"))
+
+	conf.Index = "{appType}-{YYYY-MM}"
+	assert.Equal(t, "kontext-2024-01", conf.RecordIndex("kontext", tm))
+	assert.Equal(t, "kontext-*", conf.IndexPattern("kontext"))
+
+	conf.MajorVersion = 5
+	conf.Index = "logs-{YYYY}"
+	assert.Equal(t, "logs-2024", conf.RecordIndex("kontext", tm))
+}
+
+func TestValidateIndexName(t *testing.T) {
+	assert.NoError(t, validateIndexName("logs"))
+	assert.NoError(t, validateIndexName("logs-{YYYY.MM.DD}"))
+	assert.NoError(t, validateIndexName("{appType}-{YYYY_MM}"))
+	assert.Error(t, validateIndexName("logs-{YY.MM}"))
+	assert.Error(t, validateIndexName("logs-{foo}"))
+	assert.Error(t, validateIndexName("logs-{}"))
+}
+
+func TestRunWriteConsumerTemplatedIndex(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"took": 1, "errors": false, "items": []}`))
+	}))
+	defer srv.Close()
+	conf := &ConnectionConf{
+		Server: srv.URL, Index: "{appType}-{YYYY.MM.DD}", PushChunkSize: 10,
+		MajorVersion: 6, ReqTimeoutSecs: 5,
+	}
+	incoming := make(chan *servicelog.BoundOutputRecord, 2)
+	incoming <- &servicelog.BoundOutputRecord{Rec: &timedTestRecord{
+		testRecord: testRecord{id: "1"}, t: time.Date(2024, 1, 15, 23, 59, 59, 0, time.UTC)}}
+	incoming <- &servicelog.BoundOutputRecord{Rec: &timedTestRecord{
+		testRecord: testRecord{id: "2"}, t: time.Date(2024, 1, 16, 0, 0, 1, 0, time.UTC)}}
+	close(incoming)
+	for range RunWriteConsumer(context.Background(), "kontext", conf, incoming) {
+	}
+	lines := strings.Split(body, "\n")
+	assert.Contains(t, lines[0], `"_index":"kontext-2024.01.15"`)
+	assert.Contains(t, lines[2], `"_index":"kontext-2024.01.16"`)
+}
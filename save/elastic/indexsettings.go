@@ -0,0 +1,154 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultIndexSettingsKey is a key of IndexSettings applied
+	// to app types without their own settings
+	defaultIndexSettingsKey = "*"
+)
+
+// IndexSettings specifies settings of a newly created
+// time-partitioned index. Unset values are left to the server
+// (or to a possible index template).
+type IndexSettings struct {
+	NumberOfReplicas *int   `json:"numberOfReplicas"`
+	RefreshInterval  string `json:"refreshInterval"`
+}
+
+// Validate tests the configured values
+func (s *IndexSettings) Validate() error {
+	if s.NumberOfReplicas != nil && *s.NumberOfReplicas < 0 {
+		return errors.New("numberOfReplicas must be a non-negative number")
+	}
+	return nil
+}
+
+func (s *IndexSettings) toJSON() ([]byte, error) {
+	settings := make(map[string]any)
+	if s.NumberOfReplicas != nil {
+		settings["number_of_replicas"] = *s.NumberOfReplicas
+	}
+	if s.RefreshInterval != "" {
+		settings["refresh_interval"] = s.RefreshInterval
+	}
+	return json.Marshal(map[string]any{"settings": map[string]any{"index": settings}})
+}
+
+// indexSettings returns settings for new indices of the app type
+// (or nil in case nothing is configured or the index is not
+// time-partitioned)
+func (conf *ConnectionConf) indexSettings(appType string) *IndexSettings {
+	if !hasDatePlaceholders(conf.Index) {
+		return nil
+	}
+	if s, ok := conf.IndexSettings[appType]; ok {
+		return s
+	}
+	return conf.IndexSettings[defaultIndexSettingsKey]
+}
+
+// IndexExists tests whether the index exists
+func (c *ESClient) IndexExists(index string) (bool, error) {
+	client := http.Client{Timeout: time.Second * time.Duration(c.reqTimeoutSecs)}
+	req, err := http.NewRequest(http.MethodHead, c.server+"/"+index, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to test index %s existence, code %d", index, resp.StatusCode)
+}
+
+// CreateIndex creates an index with the provided settings
+func (c *ESClient) CreateIndex(index string, settings *IndexSettings) error {
+	body, err := settings.toJSON()
+	if err != nil {
+		return err
+	}
+	_, err = c.Do(http.MethodPut, "/"+index, body)
+	return err
+}
+
+// indexInitializer creates new time-partitioned indices with
+// configured settings before records are written to them
+// (otherwise, the server would create them automatically with
+// default settings). Once an index is known, it is not tested again.
+type indexInitializer struct {
+	client   *ESClient
+	settings *IndexSettings
+	known    map[string]bool
+}
+
+// ensure creates the index in case it does not exist yet. Errors
+// are only logged as the server can still create the index on write.
+func (ii *indexInitializer) ensure(index string) {
+	if ii == nil || ii.known[index] {
+		return
+	}
+	exists, err := ii.client.IndexExists(index)
+	if err != nil {
+		log.Error().Err(err).Str("index", index).Msg("failed to initialize index")
+		return
+	}
+	if !exists {
+		if err := ii.client.CreateIndex(index, ii.settings); err != nil {
+			// the index may have been created in the meantime (e.g. by another node)
+			if exists, err2 := ii.client.IndexExists(index); err2 != nil || !exists {
+				log.Error().Err(err).Str("index", index).Msg("failed to create index")
+				return
+			}
+
+		} else {
+			log.Info().Str("index", index).Msg("created new index with configured settings")
+		}
+	}
+	ii.known[index] = true
+}
+
+// newIndexInitializer returns nil in case no index
+// settings apply to the app type
+func newIndexInitializer(conf *ConnectionConf, appType string) *indexInitializer {
+	settings := conf.indexSettings(appType)
+	if settings == nil {
+		return nil
+	}
+	return &indexInitializer{
+		client:   NewClient(conf),
+		settings: settings,
+		known:    make(map[string]bool),
+	}
+}
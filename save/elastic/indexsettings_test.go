@@ -0,0 +1,105 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexSettingsToJSON(t *testing.T) {
+	replicas := 0
+	data, err := (&IndexSettings{NumberOfReplicas: &replicas, RefreshInterval: "30s"}).toJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"settings": {"index": {"number_of_replicas": 0, "refresh_interval": "30s"}}}`, string(data))
+}
+
+func TestIndexSettingsSelection(t *testing.T) {
+	kontextSettings := &IndexSettings{RefreshInterval: "60s"}
+	defaultSettings := &IndexSettings{RefreshInterval: "5s"}
+	conf := &ConnectionConf{
+		Index: "{appType}-{YYYY.MM}",
+		IndexSettings: map[string]*IndexSettings{
+			"kontext": kontextSettings,
+			"*":       defaultSettings,
+		},
+	}
+	assert.Equal(t, kontextSettings, conf.indexSettings("kontext"))
+	assert.Equal(t, defaultSettings, conf.indexSettings("syd"))
+	conf.Index = "logs"
+	assert.Nil(t, conf.indexSettings("kontext"))
+}
+
+func TestRunWriteConsumerCreatesIndex(t *testing.T) {
+	var mutex sync.Mutex
+	var requests []string
+	var createBody string
+	existing := map[string]bool{"/kontext-2024.01.15": true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodHead:
+			if !existing[r.URL.Path] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			createBody = string(data)
+			existing[r.URL.Path] = true
+			w.Write([]byte(`{"acknowledged": true}`))
+		default:
+			w.Write([]byte(`{"took": 1, "errors": false, "items": []}`))
+		}
+	}))
+	defer srv.Close()
+	replicas := 0
+	conf := &ConnectionConf{
+		Server: srv.URL, Index: "{appType}-{YYYY.MM.DD}", PushChunkSize: 10,
+		MajorVersion: 6, ReqTimeoutSecs: 5,
+		IndexSettings: map[string]*IndexSettings{
+			"*": {NumberOfReplicas: &replicas, RefreshInterval: "-1"},
+		},
+	}
+	incoming := make(chan *servicelog.BoundOutputRecord, 3)
+	incoming <- &servicelog.BoundOutputRecord{Rec: &timedTestRecord{
+		testRecord: testRecord{id: "1"}, t: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)}}
+	incoming <- &servicelog.BoundOutputRecord{Rec: &timedTestRecord{
+		testRecord: testRecord{id: "2"}, t: time.Date(2024, 1, 16, 10, 0, 0, 0, time.UTC)}}
+	incoming <- &servicelog.BoundOutputRecord{Rec: &timedTestRecord{
+		testRecord: testRecord{id: "3"}, t: time.Date(2024, 1, 16, 11, 0, 0, 0, time.UTC)}}
+	close(incoming)
+	for range RunWriteConsumer(context.Background(), "kontext", conf, incoming) {
+	}
+	assert.Equal(t, []string{
+		"HEAD /kontext-2024.01.15",
+		"HEAD /kontext-2024.01.16",
+		"PUT /kontext-2024.01.16",
+		"POST /_bulk",
+	}, requests)
+	assert.JSONEq(t, `{"settings": {"index": {"number_of_replicas": 0, "refresh_interval": "-1"}}}`, createBody)
+}
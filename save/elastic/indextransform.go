@@ -0,0 +1,262 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"klogproc/fsop"
+	"klogproc/scripting"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultIndexTransformChunkSize = 500
+)
+
+// IndexTransformConf configures copying of stored records from
+// a source index to a target index with an applied transformation.
+// The transformation consists of (in this order) removing keys,
+// setting values and running an optional Lua script.
+type IndexTransformConf struct {
+	SourceIndex string `json:"sourceIndex"`
+	TargetIndex string `json:"targetIndex"`
+
+	// Filters optionally specifies which records should be processed.
+	// Each filter is processed separately (i.e. a record matching more
+	// filters is written repeatedly - with the same ID). If empty, all
+	// the records are processed.
+	Filters []DocFilter `json:"filters"`
+
+	// RemoveKeys specifies keys to be removed from each record
+	RemoveKeys []string `json:"removeKeys"`
+
+	// Set specifies values to be set in each record
+	Set DocUpdRecord `json:"set"`
+
+	// ScriptPath specifies an optional Lua script defining a `transform(input)`
+	// function called for each record. The script can set record properties
+	// via `set_output_property(name, value)` (a nil value removes the property).
+	ScriptPath string `json:"scriptPath"`
+
+	// SearchChunkSize specifies how many items at once should
+	// klogproc search, load and write. If zero, a default value is used.
+	SearchChunkSize int `json:"searchChunkSize"`
+}
+
+// IsConfigured tests whether the transformation is configured
+func (conf *IndexTransformConf) IsConfigured() bool {
+	return conf != nil && (conf.SourceIndex != "" || conf.TargetIndex != "")
+}
+
+// ChunkSize returns the configured search chunk size or a default value
+func (conf *IndexTransformConf) ChunkSize() int {
+	if conf.SearchChunkSize == 0 {
+		return defaultIndexTransformChunkSize
+	}
+	return conf.SearchChunkSize
+}
+
+func (conf *IndexTransformConf) Validate() error {
+	if conf.SourceIndex == "" || conf.TargetIndex == "" {
+		return errors.New("indexTransform: both sourceIndex and targetIndex must be set")
+	}
+	if conf.SourceIndex == conf.TargetIndex {
+		return errors.New("indexTransform: sourceIndex and targetIndex must differ")
+	}
+	if conf.SearchChunkSize < 0 {
+		return errors.New("indexTransform: searchChunkSize must be a non-negative number")
+	}
+	if conf.ScriptPath != "" && !fsop.IsFile(conf.ScriptPath) {
+		return fmt.Errorf("indexTransform: script %s not found", conf.ScriptPath)
+	}
+	return nil
+}
+
+// DocTransformer transforms a stored record (in place)
+// before it is written to a target index.
+type DocTransformer interface {
+	TransformDoc(doc map[string]any) error
+}
+
+// ConfiguredDocTransformer is a DocTransformer based
+// on IndexTransformConf
+type ConfiguredDocTransformer struct {
+	removeKeys   []string
+	set          DocUpdRecord
+	scriptEngine *scripting.Engine
+}
+
+func (t *ConfiguredDocTransformer) TransformDoc(doc map[string]any) error {
+	for _, k := range t.removeKeys {
+		delete(doc, k)
+	}
+	for k, v := range t.set {
+		doc[k] = v
+	}
+	if t.scriptEngine != nil {
+		return t.scriptEngine.Transform(doc, func(name string, value any) error {
+			if value == nil {
+				delete(doc, name)
+
+			} else {
+				doc[name] = value
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// Close releases resources of the transformer
+func (t *ConfiguredDocTransformer) Close() {
+	if t.scriptEngine != nil {
+		t.scriptEngine.Close()
+	}
+}
+
+// NewDocTransformer creates a transformer based on the configuration.
+// In case a script is configured, the transformer should be closed
+// once it is no longer needed.
+func NewDocTransformer(conf *IndexTransformConf) (*ConfiguredDocTransformer, error) {
+	ans := &ConfiguredDocTransformer{
+		removeKeys: conf.RemoveKeys,
+		set:        conf.Set,
+	}
+	if conf.ScriptPath != "" {
+		var err error
+		ans.scriptEngine, err = scripting.NewEngine(conf.ScriptPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ans, nil
+}
+
+// ----
+
+type docBulkIndexMetaObj struct {
+	Index docBulkMetaRecord `json:"index"`
+}
+
+func createMatchAllSrchQuery(chunkSize int) ([]byte, error) {
+	if chunkSize < 1 {
+		return []byte{}, fmt.Errorf("cannot load results of size < 1 (found %d)", chunkSize)
+	}
+	return json.Marshal(map[string]any{
+		"query": map[string]any{"match_all": map[string]any{}},
+		"size":  chunkSize,
+	})
+}
+
+func (c *ESClient) bulkIndexTransformedScroll(
+	index string,
+	hits Hits,
+	transformer DocTransformer,
+	dryRun bool,
+) (int, error) {
+	jsonLines := make([][]byte, 0, len(hits.Hits)*2+1) // one for final 'new line'
+	for _, item := range hits.Hits {
+		doc, ok := item.Source.(map[string]any)
+		if !ok {
+			log.Error().Str("id", item.ID).Msg("skipping record with invalid source data")
+			continue
+		}
+		if err := transformer.TransformDoc(doc); err != nil {
+			return 0, fmt.Errorf("failed to transform record %s: %w", item.ID, err)
+		}
+		jsonMeta, err := json.Marshal(
+			docBulkIndexMetaObj{Index: docBulkMetaRecord{Index: index, Type: item.Type, ID: item.ID}})
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate bulk index JSON (meta): %w", err)
+		}
+		jsonData, err := json.Marshal(doc)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode record %s: %w", item.ID, err)
+		}
+		if dryRun {
+			log.Info().Str("id", item.ID).RawJSON("record", jsonData).Msg("transformed record")
+		}
+		jsonLines = append(jsonLines, jsonMeta, jsonData)
+	}
+	numDocs := len(jsonLines) / 2
+	if dryRun || numDocs == 0 {
+		return numDocs, nil
+	}
+	jsonLines = append(jsonLines, []byte{})
+	if _, err := c.Do("POST", "/_bulk", bytes.Join(jsonLines, []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return numDocs, nil
+}
+
+// TransformIndex scrolls through the records of the source index (the
+// one the client was created for) matching the filter (nil = all records),
+// transforms them and bulk-writes them to the target index. Record IDs are
+// kept so repeated runs overwrite the previously written records.
+// In the dry-run mode, the transformed records are only logged.
+func (c *ESClient) TransformIndex(
+	targetIndex string,
+	filter *DocFilter,
+	transformer DocTransformer,
+	scrollTTL string,
+	srchChunkSize int,
+	dryRun bool,
+) (int, error) {
+	var query []byte
+	var err error
+	if filter != nil {
+		if filter.Disabled {
+			return 0, nil
+		}
+		query, err = CreateClientSrchQuery(*filter, srchChunkSize)
+
+	} else {
+		query, err = createMatchAllSrchQuery(srchChunkSize)
+	}
+	if err != nil {
+		return 0, err
+	}
+	items, err := c.search(query, scrollTTL)
+	if err != nil {
+		return 0, err
+	}
+	totalWritten := 0
+	for len(items.Hits.Hits) > 0 {
+		if filter != nil && filter.WithProbability > 0 {
+			items.Hits = items.Hits.Sampled(filter.WithProbability)
+		}
+		ans, err := c.bulkIndexTransformedScroll(targetIndex, items.Hits, transformer, dryRun)
+		totalWritten += ans
+		if err != nil {
+			return totalWritten, err
+		}
+		log.Debug().Int("numWritten", totalWritten).Msg("transformed index chunk written")
+		if items.ScrollID == "" {
+			break
+		}
+		items, err = c.FetchScroll(items.ScrollID, scrollTTL)
+		if err != nil {
+			return totalWritten, err
+		}
+	}
+	return totalWritten, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformIndex(t *testing.T) {
+	var bulkBody string
+	var searchPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/src/_search":
+			searchPath = r.URL.String()
+			w.Write([]byte(`{"_scroll_id": "s1", "hits": {"total": 2, "hits": [
+				{"_index": "src", "_type": "_doc", "_id": "a", "_source": {"action": "query", "old": 1}},
+				{"_index": "src", "_type": "_doc", "_id": "b", "_source": {"action": "view", "old": 2}}
+			]}}`))
+		case "/_search/scroll":
+			w.Write([]byte(`{"_scroll_id": "s1", "hits": {"total": 2, "hits": []}}`))
+		case "/_bulk":
+			body, _ := io.ReadAll(r.Body)
+			bulkBody = string(body)
+			w.Write([]byte(`{"errors": false, "items": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	scriptPath := filepath.Join(t.TempDir(), "transform.lua")
+	assert.NoError(t, os.WriteFile(scriptPath, []byte(`
+function transform(input)
+	if input.action == "query" then
+		set_output_property("isQuery", true)
+	end
+end
+`), 0644))
+	conf := &IndexTransformConf{
+		SourceIndex: "src",
+		TargetIndex: "dst",
+		RemoveKeys:  []string{"old"},
+		Set:         DocUpdRecord{"version": "2"},
+		ScriptPath:  scriptPath,
+	}
+	assert.NoError(t, conf.Validate())
+	transformer, err := NewDocTransformer(conf)
+	assert.NoError(t, err)
+	defer transformer.Close()
+
+	client := NewIndexClient(&ConnectionConf{Server: srv.URL, ReqTimeoutSecs: 1}, conf.SourceIndex)
+	numWritten, err := client.TransformIndex(conf.TargetIndex, nil, transformer, "1m", conf.ChunkSize(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, numWritten)
+	assert.Equal(t, "/src/_search?scroll=1m", searchPath)
+	lines := strings.Split(bulkBody, "\n")
+	assert.Len(t, lines, 5)
+	assert.JSONEq(t, `{"index": {"_index": "dst", "_type": "_doc", "_id": "a"}}`, lines[0])
+	assert.JSONEq(t, `{"action": "query", "version": "2", "isQuery": true}`, lines[1])
+	assert.JSONEq(t, `{"index": {"_index": "dst", "_type": "_doc", "_id": "b"}}`, lines[2])
+	assert.JSONEq(t, `{"action": "view", "version": "2"}`, lines[3])
+
+	// dry run does not write anything
+	bulkBody = ""
+	numWritten, err = client.TransformIndex(conf.TargetIndex, nil, transformer, "1m", conf.ChunkSize(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, numWritten)
+	assert.Empty(t, bulkBody)
+}
+
+func TestIndexTransformConfValidate(t *testing.T) {
+	conf := IndexTransformConf{SourceIndex: "src"}
+	assert.Error(t, conf.Validate())
+	conf.TargetIndex = "src"
+	assert.Error(t, conf.Validate())
+	conf.TargetIndex = "dst"
+	assert.NoError(t, conf.Validate())
+	conf.ScriptPath = "/non/existing/script.lua"
+	assert.Error(t, conf.Validate())
+}
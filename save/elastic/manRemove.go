@@ -52,7 +52,7 @@ func (c *ESClient) bulkRemoveRecordScroll(index string, hits Hits) (int, error)
 	jsonLines := make([][]byte, len(hits.Hits)+1) // one for final 'new line'
 	stopIdx := 0
 	for _, item := range hits.Hits {
-		jsonMeta, err := createDocBulkRemoveMetaRecord(index, item.Type, item.ID)
+		jsonMeta, err := createDocBulkRemoveMetaRecord(hitIndex(index, item), item.Type, item.ID)
 		if err != nil {
 			log.Panic().Msgf("Failed to generate bulk remove JSON (meta): %v", err)
 		}
@@ -76,8 +76,10 @@ type docBulkUpdateMetaObj struct {
 type docBulkMetaRecord struct {
 	// "/"+c.index+"/"+item.Type+"/"+item.ID+"/_update", updQuery)
 	Index string `json:"_index"`
-	Type  string `json:"_type"`
-	ID    string `json:"_id"`
+	// Type is omitted in case search hits come without it (OpenSearch 2,
+	// which also rejects `_type` in bulk requests)
+	Type string `json:"_type,omitempty"`
+	ID   string `json:"_id"`
 }
 
 // UpdResponse describes ElasticSearch response
@@ -95,7 +97,7 @@ func (c *ESClient) bulkUpdateUpdRecordScroll(index string, hits Hits, rawOp []by
 	jsonLines := make([][]byte, len(hits.Hits)*2+1) // one for final 'new line'
 	stopIdx := 0
 	for _, item := range hits.Hits {
-		jsonMeta, err := createDocBulkUpdateMetaRecord(index, item.Type, item.ID)
+		jsonMeta, err := createDocBulkUpdateMetaRecord(hitIndex(index, item), item.Type, item.ID)
 		if err != nil {
 			log.Panic().Msgf("Failed to generate bulk update JSON (meta): %v", err)
 		}
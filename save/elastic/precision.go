@@ -0,0 +1,94 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// FloatPrecision specifies number of decimal places numeric
+// values are rounded to before they are stored. Nil values
+// mean no rounding (i.e. full precision).
+type FloatPrecision struct {
+
+	// ProcTime applies to the top-level `procTime` field
+	ProcTime *int `json:"procTime"`
+
+	// Coordinates applies to `geoip` latitude, longitude and location
+	Coordinates *int `json:"coordinates"`
+}
+
+func (fp *FloatPrecision) Validate() error {
+	if fp.ProcTime != nil && *fp.ProcTime < 0 {
+		return fmt.Errorf("invalid floatPrecision.procTime value %d", *fp.ProcTime)
+	}
+	if fp.Coordinates != nil && *fp.Coordinates < 0 {
+		return fmt.Errorf("invalid floatPrecision.coordinates value %d", *fp.Coordinates)
+	}
+	return nil
+}
+
+// IsActive tests whether any rounding should be applied
+func (fp *FloatPrecision) IsActive() bool {
+	return fp != nil && (fp.ProcTime != nil || fp.Coordinates != nil)
+}
+
+func roundNumber(v any, decimals int) any {
+	num, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	fv, err := num.Float64()
+	if err != nil {
+		return v
+	}
+	p := math.Pow10(decimals)
+	return math.Round(fv*p) / p
+}
+
+// Apply rounds configured fields of a JSON-encoded record
+func (fp *FloatPrecision) Apply(jsonData []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonData))
+	dec.UseNumber() // to keep other numeric values intact
+	var rec map[string]any
+	if err := dec.Decode(&rec); err != nil {
+		return nil, err
+	}
+	if fp.ProcTime != nil {
+		if v, ok := rec["procTime"]; ok {
+			rec["procTime"] = roundNumber(v, *fp.ProcTime)
+		}
+	}
+	if fp.Coordinates != nil {
+		if geo, ok := rec["geoip"].(map[string]any); ok {
+			for _, k := range []string{"latitude", "longitude"} {
+				if v, ok := geo[k]; ok {
+					geo[k] = roundNumber(v, *fp.Coordinates)
+				}
+			}
+			if loc, ok := geo["location"].([]any); ok {
+				for i, v := range loc {
+					loc[i] = roundNumber(v, *fp.Coordinates)
+				}
+			}
+		}
+	}
+	return json.Marshal(rec)
+}
@@ -0,0 +1,46 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloatPrecisionApply(t *testing.T) {
+	two := 2
+	one := 1
+	fp := &FloatPrecision{ProcTime: &two, Coordinates: &one}
+	ans, err := fp.Apply([]byte(`{"procTime": 0.71234, "numLines": 12345678901234567,` +
+		` "geoip": {"latitude": 50.0833, "longitude": 14.4167, "location": [14.4167, 50.0833]}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"procTime": 0.71, "numLines": 12345678901234567,`+
+			` "geoip": {"latitude": 50.1, "longitude": 14.4, "location": [14.4, 50.1]}}`,
+		string(ans),
+	)
+}
+
+func TestFloatPrecisionPartial(t *testing.T) {
+	zero := 0
+	fp := &FloatPrecision{ProcTime: &zero}
+	ans, err := fp.Apply([]byte(`{"procTime": 1.6, "geoip": {"latitude": 50.0833}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"procTime": 2, "geoip": {"latitude": 50.0833}}`, string(ans))
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	// EmptyIDPolicyDrop means that records with empty ID are not
+	// written (an error is logged instead)
+	EmptyIDPolicyDrop = "drop"
+
+	// EmptyIDPolicyHash means that records with empty ID get
+	// an ID derived from their content
+	EmptyIDPolicyHash = "hash"
+)
+
+var (
+	// ErrEmptyRecordID is returned for records with empty ID
+	// in case the EmptyIDPolicyDrop is used
+	ErrEmptyRecordID = errors.New("record has an empty ID")
+)
+
+func validateEmptyIDPolicy(policy string) error {
+	switch policy {
+	case "", EmptyIDPolicyDrop, EmptyIDPolicyHash:
+		return nil
+	}
+	return fmt.Errorf("unsupported emptyIdPolicy: %s", policy)
+}
+
+// resolveRecordID returns an ID to be used when storing a record.
+// Without an ID, ElasticSearch would generate a random one which
+// would break idempotency of (re)imports. So in case the ID is empty,
+// we either refuse the record (ErrEmptyRecordID) or create a hash
+// of the record's serialized document - based on the policy.
+func resolveRecordID(policy string, id string, jsonData []byte) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	if policy == EmptyIDPolicyHash {
+		sum := sha1.Sum(jsonData)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return "", ErrEmptyRecordID
+}
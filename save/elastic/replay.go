@@ -0,0 +1,203 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"klogproc/servicelog"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RecordReplayer creates output records out of an original log line
+// (typically using an updated transformer)
+type RecordReplayer interface {
+	ProcessLineAll(line string) ([]servicelog.OutputRecord, error)
+}
+
+// ReplayStats summarizes a replay run
+type ReplayStats struct {
+
+	// NumWritten is the number of re-created records written back
+	NumWritten int
+
+	// NumSkipped is the number of stored records which could not be
+	// re-created (e.g. the line no longer produces a matching record)
+	NumSkipped int
+}
+
+func createReplaySrchQuery(appType string, from, to *time.Time, chunkSize int) ([]byte, error) {
+	if chunkSize < 1 {
+		return []byte{}, fmt.Errorf("cannot load results of size < 1 (found %d)", chunkSize)
+	}
+	must := []any{
+		map[string]any{"exists": map[string]any{"field": servicelog.RawInputField}},
+		appTypeMatchObj{appTypeExpr{AppType: appType}},
+	}
+	if from != nil || to != nil {
+		rng := make(map[string]any)
+		if from != nil {
+			rng["gte"] = from.Format(time.RFC3339)
+		}
+		if to != nil {
+			rng["lte"] = to.Format(time.RFC3339)
+		}
+		must = append(must, map[string]any{"range": map[string]any{"datetime": rng}})
+	}
+	return json.Marshal(map[string]any{
+		"query": map[string]any{"bool": map[string]any{"must": must}},
+		"size":  chunkSize,
+	})
+}
+
+// selectReplayedRecord finds a record matching the stored one among records
+// created out of its raw input. If the IDs do not match (e.g. for IDs based
+// on file offsets which cannot be re-created without the original file),
+// a single created record (or a single created record of the stored record's
+// type - e.g. a KonText record along with its aggregate record) is considered
+// to be the one. Otherwise, nil is returned and the stored record is skipped.
+func selectReplayedRecord(
+	hit ResultHit,
+	recType string,
+	recs []servicelog.OutputRecord,
+) servicelog.OutputRecord {
+	for _, rec := range recs {
+		if rec.GetID() == hit.ID {
+			return rec
+		}
+	}
+	if len(recs) == 1 {
+		return recs[0]
+	}
+	var ans servicelog.OutputRecord
+	for _, rec := range recs {
+		if rec.GetType() != recType {
+			continue
+		}
+		if ans != nil {
+			return nil
+		}
+		ans = rec
+	}
+	return ans
+}
+
+func (c *ESClient) bulkReplayScroll(
+	hits Hits,
+	replayer RecordReplayer,
+	precision *FloatPrecision,
+	dryRun bool,
+) (ReplayStats, error) {
+	var stats ReplayStats
+	jsonLines := make([][]byte, 0, len(hits.Hits)*2+1) // one for final 'new line'
+	for _, item := range hits.Hits {
+		doc, ok := item.Source.(map[string]any)
+		if !ok {
+			log.Error().Str("id", item.ID).Msg("skipping record with invalid source data")
+			stats.NumSkipped++
+			continue
+		}
+		rawInput, _ := doc[servicelog.RawInputField].(string)
+		recs, err := replayer.ProcessLineAll(rawInput)
+		if err != nil {
+			log.Warn().Err(err).Str("id", item.ID).Msg("failed to process raw input, skipping record")
+			stats.NumSkipped++
+			continue
+		}
+		recType, _ := doc["type"].(string)
+		rec := selectReplayedRecord(item, recType, recs)
+		if rec == nil {
+			log.Warn().Str("id", item.ID).Msg("no matching record created out of raw input, skipping")
+			stats.NumSkipped++
+			continue
+		}
+		instanceID, _ := doc["instanceId"].(string)
+		bRec := &servicelog.BoundOutputRecord{Rec: rec, RawInput: rawInput, InstanceID: instanceID}
+		jsonData, err := bRec.ToJSON()
+		if err == nil && precision.IsActive() {
+			jsonData, err = precision.Apply(jsonData)
+		}
+		if err != nil {
+			return stats, fmt.Errorf("failed to encode record %s: %w", item.ID, err)
+		}
+		jsonMeta, err := json.Marshal(
+			docBulkIndexMetaObj{Index: docBulkMetaRecord{Index: item.Index, Type: item.Type, ID: item.ID}})
+		if err != nil {
+			return stats, fmt.Errorf("failed to generate bulk index JSON (meta): %w", err)
+		}
+		if dryRun {
+			log.Info().Str("id", item.ID).RawJSON("record", jsonData).Msg("replayed record")
+		}
+		jsonLines = append(jsonLines, jsonMeta, jsonData)
+	}
+	stats.NumWritten = len(jsonLines) / 2
+	if dryRun || stats.NumWritten == 0 {
+		return stats, nil
+	}
+	jsonLines = append(jsonLines, []byte{})
+	if _, err := c.Do("POST", "/_bulk", bytes.Join(jsonLines, []byte("\n"))); err != nil {
+		stats.NumWritten = 0
+		return stats, err
+	}
+	return stats, nil
+}
+
+// ReplayRecords scrolls through the records of the app type with a stored
+// raw input (see servicelog.RawInputField) created within the range
+// [from, to] (nil = unlimited), creates them again out of the raw input
+// and writes them back under their original IDs.
+// In the dry-run mode, the re-created records are only logged.
+func (c *ESClient) ReplayRecords(
+	appType string,
+	from, to *time.Time,
+	replayer RecordReplayer,
+	precision *FloatPrecision,
+	scrollTTL string,
+	srchChunkSize int,
+	dryRun bool,
+) (ReplayStats, error) {
+	var total ReplayStats
+	query, err := createReplaySrchQuery(appType, from, to, srchChunkSize)
+	if err != nil {
+		return total, err
+	}
+	items, err := c.search(query, scrollTTL)
+	if err != nil {
+		return total, err
+	}
+	for len(items.Hits.Hits) > 0 {
+		stats, err := c.bulkReplayScroll(items.Hits, replayer, precision, dryRun)
+		total.NumWritten += stats.NumWritten
+		total.NumSkipped += stats.NumSkipped
+		if err != nil {
+			return total, err
+		}
+		log.Debug().Int("numWritten", total.NumWritten).Msg("replayed records chunk written")
+		if items.ScrollID == "" {
+			break
+		}
+		items, err = c.FetchScroll(items.ScrollID, scrollTTL)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
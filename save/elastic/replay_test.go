@@ -0,0 +1,105 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testReplayer struct{}
+
+func (r *testReplayer) ProcessLineAll(line string) ([]servicelog.OutputRecord, error) {
+	if line == "broken" {
+		return nil, errors.New("parse error")
+	}
+	return []servicelog.OutputRecord{&testRecord{id: line}}, nil
+}
+
+type typedTestRecord struct {
+	testRecord
+	recType string
+}
+
+func (r *typedTestRecord) GetType() string { return r.recType }
+
+func TestSelectReplayedRecord(t *testing.T) {
+	raw := &typedTestRecord{testRecord: testRecord{id: "x1"}, recType: "kontext"}
+	agg := &typedTestRecord{testRecord: testRecord{id: "x2"}, recType: "kontext_aggregate"}
+	recs := []servicelog.OutputRecord{raw, agg}
+
+	assert.Equal(t, agg, selectReplayedRecord(ResultHit{ID: "x2"}, "kontext", recs))
+	// offset-based IDs cannot be matched, the type decides
+	assert.Equal(t, raw, selectReplayedRecord(ResultHit{ID: "off1"}, "kontext", recs))
+	assert.Equal(t, agg, selectReplayedRecord(ResultHit{ID: "off1"}, "kontext_aggregate", recs))
+	// ambiguous
+	raw2 := &typedTestRecord{testRecord: testRecord{id: "x3"}, recType: "kontext"}
+	assert.Nil(t, selectReplayedRecord(
+		ResultHit{ID: "off1"}, "kontext", []servicelog.OutputRecord{raw, raw2}))
+	assert.Nil(t, selectReplayedRecord(ResultHit{ID: "off1"}, "syd", recs))
+}
+
+func TestCreateReplaySrchQuery(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	data, err := createReplaySrchQuery("kontext", &from, nil, 100)
+	assert.NoError(t, err)
+	assert.JSONEq(
+		t,
+		`{"query": {"bool": {"must": [
+			{"exists": {"field": "rawInput"}},
+			{"match": {"type": "kontext"}},
+			{"range": {"datetime": {"gte": "2024-03-01T00:00:00Z"}}}
+		]}}, "size": 100}`,
+		string(data),
+	)
+}
+
+func TestReplayRecords(t *testing.T) {
+	var bulkBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			data, _ := io.ReadAll(r.Body)
+			bulkBody = string(data)
+			w.Write([]byte(`{"took": 1, "errors": false, "items": []}`))
+			return
+		}
+		resp, _ := json.Marshal(Result{Hits: Hits{Hits: []ResultHit{
+			{Index: "logs-2024.03", ID: "a", Source: map[string]any{"rawInput": "a", "instanceId": "n1"}},
+			{Index: "logs-2024.03", ID: "b", Source: map[string]any{"rawInput": "broken"}},
+		}}})
+		w.Write(resp)
+	}))
+	defer srv.Close()
+	client := NewIndexClient(&ConnectionConf{Server: srv.URL, ReqTimeoutSecs: 5}, "logs-*")
+	stats, err := client.ReplayRecords("kontext", nil, nil, &testReplayer{}, nil, "", 10, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ReplayStats{NumWritten: 1, NumSkipped: 1}, stats)
+	lines := strings.Split(strings.TrimSpace(bulkBody), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"index": {"_index": "logs-2024.03", "_id": "a"}}`, lines[0])
+	assert.JSONEq(t, `{"instanceId": "n1", "rawInput": "a", "id": "a"}`, lines[1])
+}
@@ -18,7 +18,9 @@ package elastic
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"time"
 
 	"klogproc/save"
 	"klogproc/servicelog"
@@ -28,6 +30,12 @@ import (
 
 const (
 	es6DocType = "_doc"
+
+	// FlavorElasticsearch represents ElasticSearch server (default)
+	FlavorElasticsearch = "elasticsearch"
+
+	// FlavorOpenSearch represents OpenSearch server
+	FlavorOpenSearch = "opensearch"
 )
 
 // ESImportFailHandler represents an object able to handle (valid)
@@ -41,7 +49,7 @@ type ESImportFailHandler interface {
 
 func BulkWriteRequest(data [][]byte, appType string, esconf *ConnectionConf) error {
 	var esclient *ESClient
-	if esconf.MajorVersion < 6 {
+	if esconf.usesLegacyDocTypes() {
 		esclient = NewClient(esconf)
 
 	} else {
@@ -61,7 +69,15 @@ func BulkWriteRequest(data [][]byte, appType string, esconf *ConnectionConf) err
 // RunWriteConsumer reads incoming records from incomingData channel and writes them
 // chunk by chunk. Once the channel is closed, the rest of items in buffer is writtten
 // and the consumer finishes.
-func RunWriteConsumer(appType string, conf *ConnectionConf, incomingData <-chan *servicelog.BoundOutputRecord) <-chan save.ConfirmMsg {
+// In case conf.FlushIntervalSecs is set, a partial chunk is also written once no new
+// record arrives within the interval. The idle flushing stops once the ctx is cancelled
+// (the consumer itself still runs until incomingData is closed).
+func RunWriteConsumer(
+	ctx context.Context,
+	appType string,
+	conf *ConnectionConf,
+	incomingData <-chan *servicelog.BoundOutputRecord,
+) <-chan save.ConfirmMsg {
 	// Elasticsearch bulk writes
 	confirmChan := make(chan save.ConfirmMsg)
 	go func() {
@@ -69,61 +85,100 @@ func RunWriteConsumer(appType string, conf *ConnectionConf, incomingData <-chan
 			i := 0
 			data := make([][]byte, conf.PushChunkSize*2+1)
 			var chunkPosition *servicelog.LogRange
-			var esErr error
-			var rec *servicelog.BoundOutputRecord
-			for rec = range incomingData {
-				if chunkPosition == nil {
-					chunkPosition = &rec.FilePos
-				}
-				chunkPosition.SeekEnd = rec.FilePos.SeekEnd
-				jsonData, err := rec.ToJSON()
-				recType := es6DocType
-				index := fmt.Sprintf("%s_%s", conf.Index, appType)
-				if conf.MajorVersion < 6 {
-					recType = rec.GetType()
-					index = conf.Index
-				}
-				jsonMeta := CNKRecordMeta{
-					ID:    rec.GetID(),
-					Type:  recType,
-					Index: index,
-				}
-				jsonMetaES, err2 := (&ESCNKRecordMeta{Index: jsonMeta}).ToJSON()
-
-				if err != nil {
-					log.Error().Err(err).Msgf("Failed to encode item %s", rec.GetID())
+			var chunkFilePath string
+			indexInit := newIndexInitializer(conf, appType)
 
-				} else if err2 != nil {
-					log.Error().Err(err2).Msgf("Failed to encode a 'meta' record for item %s", rec.GetID())
-
-				} else {
-					data[i] = jsonMetaES
-					data[i+1] = jsonData
-					i += 2
-				}
-				if i == conf.PushChunkSize*2 {
-					data[i] = []byte("\n")
-					esErr = BulkWriteRequest(data[:i+1], appType, conf)
-					chunkPosition.Written = esErr == nil
-					confirmMsg := save.ConfirmMsg{
-						FilePath: rec.FilePath,
-						Position: *chunkPosition,
-						Error:    esErr,
-					}
-					confirmChan <- confirmMsg
-					i = 0
-				}
-			}
-			if i > 0 {
+			flush := func() {
 				data[i] = []byte("\n")
-				esErr = BulkWriteRequest(data[:i+1], appType, conf)
+				esErr := BulkWriteRequest(data[:i+1], appType, conf)
 				chunkPosition.Written = esErr == nil
 				confirmMsg := save.ConfirmMsg{
-					FilePath: rec.FilePath,
+					FilePath: chunkFilePath,
 					Position: *chunkPosition,
 					Error:    esErr,
 				}
 				confirmChan <- confirmMsg
+				chunkPosition = nil
+				i = 0
+			}
+
+			var idleTimeout <-chan time.Time
+			var idleTimer *time.Timer
+			if conf.FlushIntervalSecs > 0 {
+				idleTimer = time.NewTimer(time.Duration(conf.FlushIntervalSecs) * time.Second)
+				defer idleTimer.Stop()
+				idleTimeout = idleTimer.C
+			}
+			ctxDone := ctx.Done()
+
+		loop:
+			for {
+				select {
+				case <-ctxDone:
+					ctxDone = nil
+					idleTimeout = nil
+				case <-idleTimeout:
+					if i > 0 {
+						log.Debug().Msgf("flushing %d ElasticSearch items due to inactivity", i/2)
+						flush()
+					}
+					idleTimer.Reset(time.Duration(conf.FlushIntervalSecs) * time.Second)
+				case rec, ok := <-incomingData:
+					if !ok {
+						break loop
+					}
+					if idleTimeout != nil {
+						if !idleTimer.Stop() {
+							<-idleTimer.C
+						}
+						idleTimer.Reset(time.Duration(conf.FlushIntervalSecs) * time.Second)
+					}
+					if chunkPosition == nil {
+						pos := rec.FilePos
+						chunkPosition = &pos
+					}
+					chunkPosition.SeekEnd = rec.FilePos.SeekEnd
+					chunkFilePath = rec.FilePath
+					jsonData, err := rec.ToJSON()
+					if err == nil && conf.FloatPrecision.IsActive() {
+						jsonData, err = conf.FloatPrecision.Apply(jsonData)
+					}
+					recID, idErr := resolveRecordID(conf.EmptyIDPolicy, rec.GetID(), jsonData)
+					recType := conf.bulkDocType(rec.GetType())
+					jsonMeta := CNKRecordMeta{
+						ID:    recID,
+						Type:  recType,
+						Index: conf.RecordIndex(appType, rec.GetTime()),
+					}
+					indexInit.ensure(jsonMeta.Index)
+					jsonMetaES, err2 := (&ESCNKRecordMeta{Index: jsonMeta}).ToJSON()
+
+					if err != nil {
+						log.Error().Err(err).Msgf("Failed to encode item %s", rec.GetID())
+
+					} else if idErr != nil {
+						log.Error().
+							Err(idErr).
+							Str("file", rec.FilePath).
+							Int64("line", rec.FilePos.Line).
+							Str("appType", appType).
+							Msg("record with empty ID dropped (see elasticSearch.emptyIdPolicy)")
+
+					} else if err2 != nil {
+						log.Error().Err(err2).Msgf("Failed to encode a 'meta' record for item %s", rec.GetID())
+
+					} else {
+						data[i] = jsonMetaES
+						data[i+1] = jsonData
+						i += 2
+					}
+					if i == conf.PushChunkSize*2 {
+						flush()
+					}
+				}
+			}
+			if i > 0 {
+				flush()
 			}
 			close(confirmChan)
 
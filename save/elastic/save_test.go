@@ -0,0 +1,186 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elastic
+
+import (
+	"context"
+	"io"
+	"klogproc/servicelog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	id string
+}
+
+func (r *testRecord) ToJSON() ([]byte, error) { return []byte(`{"id":"` + r.id + `"}`), nil }
+func (r *testRecord) GetID() string           { return r.id }
+func (r *testRecord) GetType() string         { return "test" }
+func (r *testRecord) GetTime() time.Time      { return time.Time{} }
+func (r *testRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+func (r *testRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{}, map[string]any{}
+}
+
+func newBulkServer(numBulkReqs *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			atomic.AddInt32(numBulkReqs, 1)
+		}
+		w.Write([]byte(`{"took": 1, "errors": false, "items": []}`))
+	}))
+}
+
+func TestRunWriteConsumerIdleFlush(t *testing.T) {
+	var numBulkReqs int32
+	srv := newBulkServer(&numBulkReqs)
+	defer srv.Close()
+	conf := &ConnectionConf{
+		Server: srv.URL, Index: "logs", PushChunkSize: 100, MajorVersion: 6,
+		ReqTimeoutSecs: 5, FlushIntervalSecs: 1,
+	}
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := RunWriteConsumer(context.Background(), "test", conf, incoming)
+	incoming <- &servicelog.BoundOutputRecord{
+		Rec: &testRecord{id: "1"}, FilePos: servicelog.LogRange{SeekStart: 0, SeekEnd: 10}}
+	incoming <- &servicelog.BoundOutputRecord{
+		Rec: &testRecord{id: "2"}, FilePos: servicelog.LogRange{SeekStart: 10, SeekEnd: 20}}
+
+	select {
+	case msg := <-confirmChan:
+		assert.NoError(t, msg.Error)
+		assert.True(t, msg.Position.Written)
+		assert.Equal(t, int64(0), msg.Position.SeekStart)
+		assert.Equal(t, int64(20), msg.Position.SeekEnd)
+	case <-time.After(5 * time.Second):
+		t.Fatal("partial chunk not flushed on idle timeout")
+	}
+	close(incoming)
+	_, ok := <-confirmChan
+	assert.False(t, ok)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&numBulkReqs))
+}
+
+func TestRunWriteConsumerIdleFlushCancelled(t *testing.T) {
+	var numBulkReqs int32
+	srv := newBulkServer(&numBulkReqs)
+	defer srv.Close()
+	conf := &ConnectionConf{
+		Server: srv.URL, Index: "logs", PushChunkSize: 100, MajorVersion: 6,
+		ReqTimeoutSecs: 5, FlushIntervalSecs: 1,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := RunWriteConsumer(ctx, "test", conf, incoming)
+	incoming <- &servicelog.BoundOutputRecord{
+		Rec: &testRecord{id: "1"}, FilePos: servicelog.LogRange{SeekStart: 0, SeekEnd: 10}}
+	time.Sleep(1500 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&numBulkReqs))
+	close(incoming)
+	msg := <-confirmChan
+	assert.True(t, msg.Position.Written)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&numBulkReqs))
+}
+
+func writeAndCaptureBulk(t *testing.T, conf *ConnectionConf) (string, string) {
+	var body, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		path = r.URL.Path
+		w.Write([]byte(`{"took": 1, "errors": false, "items": []}`))
+	}))
+	defer srv.Close()
+	conf.Server = srv.URL
+	conf.Index = "logs"
+	conf.PushChunkSize = 10
+	conf.ReqTimeoutSecs = 5
+	incoming := make(chan *servicelog.BoundOutputRecord, 1)
+	incoming <- &servicelog.BoundOutputRecord{Rec: &testRecord{id: "1"}}
+	close(incoming)
+	for range RunWriteConsumer(context.Background(), "kontext", conf, incoming) {
+	}
+	return path, body
+}
+
+func TestBulkMetaFlavors(t *testing.T) {
+	path, body := writeAndCaptureBulk(t, &ConnectionConf{MajorVersion: 6})
+	assert.Equal(t, "/_bulk", path)
+	assert.Contains(t, body, `{"index":{"_index":"logs_kontext","_id":"1","_type":"_doc"}}`)
+
+	_, body = writeAndCaptureBulk(t, &ConnectionConf{MajorVersion: 5})
+	assert.Contains(t, body, `{"index":{"_index":"logs","_id":"1","_type":"test"}}`)
+
+	_, body = writeAndCaptureBulk(t, &ConnectionConf{MajorVersion: 5, Flavor: FlavorOpenSearch})
+	assert.Contains(t, body, `{"index":{"_index":"logs_kontext","_id":"1"}}`)
+	assert.NotContains(t, body, "_type")
+}
+
+func TestManualBulkMetaWithoutType(t *testing.T) {
+	data, err := createDocBulkUpdateMetaRecord("logs_kontext", "", "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"update":{"_index":"logs_kontext","_id":"abc"}}`, string(data))
+
+	data, err = createDocBulkRemoveMetaRecord("logs", "kontext", "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"delete":{"_index":"logs","_type":"kontext","_id":"abc"}}`, string(data))
+}
+
+func TestFlavorValidation(t *testing.T) {
+	conf := &ConnectionConf{Index: "logs", ScrollTTL: "3m", PushChunkSize: 10, ReqTimeoutSecs: 5}
+	assert.NoError(t, conf.Validate())
+	assert.Equal(t, FlavorElasticsearch, conf.Flavor)
+	conf.Flavor = "solr"
+	assert.Error(t, conf.Validate())
+}
+
+func TestResolveRecordID(t *testing.T) {
+	id, err := resolveRecordID(EmptyIDPolicyDrop, "abc", []byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", id)
+
+	_, err = resolveRecordID("", "", []byte(`{"a":1}`))
+	assert.ErrorIs(t, err, ErrEmptyRecordID)
+
+	id1, err := resolveRecordID(EmptyIDPolicyHash, "", []byte(`{"a":1}`))
+	assert.NoError(t, err)
+	id2, _ := resolveRecordID(EmptyIDPolicyHash, "", []byte(`{"a":1}`))
+	id3, _ := resolveRecordID(EmptyIDPolicyHash, "", []byte(`{"a":2}`))
+	assert.Len(t, id1, 40)
+	assert.Equal(t, id1, id2)
+	assert.NotEqual(t, id1, id3)
+}
+
+func TestEmptyIDPolicyValidation(t *testing.T) {
+	conf := &ConnectionConf{
+		Index: "logs", ScrollTTL: "3m", PushChunkSize: 10, ReqTimeoutSecs: 5,
+		EmptyIDPolicy: "random",
+	}
+	assert.Error(t, conf.Validate())
+	conf.EmptyIDPolicy = EmptyIDPolicyHash
+	assert.NoError(t, conf.Validate())
+}
@@ -19,6 +19,9 @@ package influx
 import (
 	"fmt"
 	"klogproc/servicelog"
+	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -38,6 +41,27 @@ type ConnectionConf struct {
 	Measurement     string `json:"measurement"`
 	RetentionPolicy string `json:"retentionPolicy"`
 	ReqTimeoutSecs  int    `json:"reqTimeoutSecs"`
+
+	// Filter optionally limits records written to InfluxDB
+	// (other outputs are not affected)
+	Filter *RecordFilter `json:"filter"`
+
+	// Mapping optionally overrides (per app type) which record
+	// properties are written as tags and which ones as fields
+	Mapping map[string]PropertyMapping `json:"mapping"`
+
+	// Version specifies the InfluxDB API version (1 - default, or 2).
+	// With version 2, Org, Bucket and Token are used instead of
+	// Database and RetentionPolicy.
+	Version int    `json:"version"`
+	Org     string `json:"org"`
+	Bucket  string `json:"bucket"`
+	Token   string `json:"token"`
+}
+
+// IsV2 tests whether InfluxDB 2.x API should be used
+func (conf *ConnectionConf) IsV2() bool {
+	return conf.Version == 2
 }
 
 // IsConfigured tests whether the configuration is considered
@@ -54,14 +78,34 @@ func (conf *ConnectionConf) Validate() error {
 	if conf.Server == "" {
 		err = fmt.Errorf("missing 'server' information for InfluxDB")
 	}
-	if conf.Database == "" {
-		err = fmt.Errorf("missing 'database' information for InfluxDB")
-	}
 	if conf.Measurement == "" {
 		err = fmt.Errorf("missing 'measurement' information for InfluxDB")
 	}
-	if conf.RetentionPolicy == "" {
-		err = fmt.Errorf("missing 'retentionPolicy' information for InfluxDB")
+	switch conf.Version {
+	case 0, 1:
+		if conf.Database == "" {
+			err = fmt.Errorf("missing 'database' information for InfluxDB")
+		}
+		if conf.RetentionPolicy == "" {
+			err = fmt.Errorf("missing 'retentionPolicy' information for InfluxDB")
+		}
+	case 2:
+		if conf.Org == "" {
+			err = fmt.Errorf("missing 'org' information for InfluxDB 2")
+		}
+		if conf.Bucket == "" {
+			err = fmt.Errorf("missing 'bucket' information for InfluxDB 2")
+		}
+		if conf.Token == "" {
+			err = fmt.Errorf("missing 'token' information for InfluxDB 2")
+		}
+	default:
+		err = fmt.Errorf("unsupported InfluxDB version %d", conf.Version)
+	}
+	for appType, mapping := range conf.Mapping {
+		if mErr := mapping.Validate(); mErr != nil {
+			err = fmt.Errorf("invalid InfluxDB mapping for %s: %w", appType, mErr)
+		}
 	}
 	if conf.ReqTimeoutSecs == 0 {
 		conf.ReqTimeoutSecs = defaultReqTimeoutSecs
@@ -70,6 +114,25 @@ func (conf *ConnectionConf) Validate() error {
 	return err
 }
 
+// Ping tests whether the server is reachable using the `/ping`
+// endpoint (available in both InfluxDB 1.x and 2.x).
+func Ping(conf *ConnectionConf) error {
+	pingURL, err := url.JoinPath(conf.Server, "/ping")
+	if err != nil {
+		return fmt.Errorf("invalid InfluxDB server URL: %w", err)
+	}
+	httpClient := http.Client{Timeout: time.Duration(conf.ReqTimeoutSecs) * time.Second}
+	resp, err := httpClient.Get(pingURL)
+	if err != nil {
+		return fmt.Errorf("InfluxDB server %s is not reachable: %w", conf.Server, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("InfluxDB server %s responded with code %d", conf.Server, resp.StatusCode)
+	}
+	return nil
+}
+
 // ------
 
 func newBatchPoints(database string, retentionPolicy string) (client.BatchPoints, error) {
@@ -98,6 +161,7 @@ type RecordWriter struct {
 	measurement     string
 	pushChunkSize   int
 	bp              client.BatchPoints
+	mapper          *pointMapper
 }
 
 // AddRecord adds a record and if internal batch is full then
@@ -105,7 +169,7 @@ type RecordWriter struct {
 // measurement. Please note that without calling Finish() at
 // the end of an operation, stale records may remain.
 func (c *RecordWriter) AddRecord(rec servicelog.OutputRecord) (bool, error) {
-	tags, values := rec.ToInfluxDB()
+	tags, values := c.mapper.influxData(rec)
 	point, err := client.NewPoint(c.measurement, tags, values, rec.GetTime())
 	if err != nil {
 		log.Error().Msgf("Failed to add record to influxdb: %s", err)
@@ -136,6 +200,21 @@ func (c *RecordWriter) writeCurrBatch() error {
 	return nil
 }
 
+// recordWriter is implemented by writers for different
+// InfluxDB API versions
+type recordWriter interface {
+	AddRecord(rec servicelog.OutputRecord) (bool, error)
+	Finish() error
+}
+
+// newRecordWriter creates a writer for the configured API version
+func newRecordWriter(conf *ConnectionConf) (recordWriter, error) {
+	if conf.IsV2() {
+		return NewRecordWriterV2(conf)
+	}
+	return NewRecordWriter(conf)
+}
+
 // NewRecordWriter is a factory function for RecordWriter
 func NewRecordWriter(conf *ConnectionConf) (*RecordWriter, error) {
 	conn, err := client.NewHTTPClient(client.HTTPConfig{Addr: conf.Server})
@@ -156,5 +235,6 @@ func NewRecordWriter(conf *ConnectionConf) (*RecordWriter, error) {
 		measurement:     conf.Measurement,
 		bp:              bp,
 		pushChunkSize:   conf.PushChunkSize,
+		mapper:          newPointMapper(conf.Mapping),
 	}, nil
 }
@@ -0,0 +1,113 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"klogproc/servicelog"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// RecordWriterV2 writes records to InfluxDB 2.x using its
+// `/api/v2/write` endpoint (line protocol, token authentication).
+// Just like RecordWriter, it is stateful and Finish() must be called
+// to write the remaining records.
+type RecordWriterV2 struct {
+	httpClient    *http.Client
+	writeURL      string
+	token         string
+	measurement   string
+	pushChunkSize int
+	lines         []string
+	mapper        *pointMapper
+}
+
+// AddRecord adds a record and if the internal batch is full then
+// it also writes the batch to the configured bucket.
+func (c *RecordWriterV2) AddRecord(rec servicelog.OutputRecord) (bool, error) {
+	tags, values := c.mapper.influxData(rec)
+	point, err := client.NewPoint(c.measurement, tags, values, rec.GetTime())
+	if err != nil {
+		log.Error().Msgf("Failed to add record to influxdb: %s", err)
+		return false, nil
+	}
+	c.lines = append(c.lines, point.PrecisionString("s"))
+	if len(c.lines) == c.pushChunkSize {
+		return true, c.writeCurrBatch()
+	}
+	return false, nil
+}
+
+// Finish ensures that all the added records are written to InfluxDB.
+func (c *RecordWriterV2) Finish() error {
+	return c.writeCurrBatch()
+}
+
+func (c *RecordWriterV2) writeCurrBatch() error {
+	if len(c.lines) == 0 {
+		return nil
+	}
+	body := strings.Join(c.lines, "\n")
+	c.lines = c.lines[:0]
+	req, err := http.NewRequest(http.MethodPost, c.writeURL, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+c.token)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf(
+			"InfluxDB write failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// NewRecordWriterV2 is a factory function for RecordWriterV2
+func NewRecordWriterV2(conf *ConnectionConf) (*RecordWriterV2, error) {
+	args := url.Values{}
+	args.Set("org", conf.Org)
+	args.Set("bucket", conf.Bucket)
+	args.Set("precision", "s")
+	writeURL, err := url.JoinPath(conf.Server, "/api/v2/write")
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB server URL: %w", err)
+	}
+	return &RecordWriterV2{
+		httpClient:    &http.Client{Timeout: time.Duration(conf.ReqTimeoutSecs) * time.Second},
+		writeURL:      writeURL + "?" + args.Encode(),
+		token:         conf.Token,
+		measurement:   conf.Measurement,
+		pushChunkSize: conf.PushChunkSize,
+		lines:         make([]string, 0, conf.PushChunkSize),
+		mapper:        newPointMapper(conf.Mapping),
+	}, nil
+}
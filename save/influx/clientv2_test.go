@@ -0,0 +1,100 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordWriterV2(t *testing.T) {
+	var bodies []string
+	var authHeaders []string
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(data))
+		authHeaders = append(authHeaders, req.Header.Get("Authorization"))
+		query = req.URL.Path + "?" + req.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	conf := &ConnectionConf{
+		Server:         srv.URL,
+		Measurement:    "kontext",
+		PushChunkSize:  2,
+		ReqTimeoutSecs: 5,
+		Version:        2,
+		Org:            "cnc",
+		Bucket:         "logs",
+		Token:          "secret",
+	}
+	assert.NoError(t, conf.Validate())
+	writer, err := newRecordWriter(conf)
+	assert.NoError(t, err)
+	written, err := writer.AddRecord(&testRecord{action: "view"})
+	assert.False(t, written)
+	assert.NoError(t, err)
+	written, err = writer.AddRecord(&testRecord{action: "query_submit"})
+	assert.True(t, written)
+	assert.NoError(t, err)
+	_, err = writer.AddRecord(&testRecord{action: "wordlist"})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Finish())
+
+	assert.Equal(t, "/api/v2/write?bucket=logs&org=cnc&precision=s", query)
+	assert.Equal(t, []string{"Token secret", "Token secret"}, authHeaders)
+	assert.Len(t, bodies, 2)
+	assert.Equal(
+		t,
+		"kontext,action=view error=\"\"\n"+
+			"kontext,action=query_submit error=\"\"",
+		bodies[0],
+	)
+}
+
+func TestRecordWriterV2Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"code":"unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	writer, err := NewRecordWriterV2(&ConnectionConf{
+		Server: srv.URL, Measurement: "kontext", PushChunkSize: 1, ReqTimeoutSecs: 5, Version: 2})
+	assert.NoError(t, err)
+	_, err = writer.AddRecord(&testRecord{action: "view"})
+	assert.ErrorContains(t, err, "status 401")
+}
+
+func TestValidateV2RequiresToken(t *testing.T) {
+	conf := &ConnectionConf{
+		Server:         "http://localhost:8086",
+		Measurement:    "kontext",
+		ReqTimeoutSecs: 5,
+		Version:        2,
+		Org:            "cnc",
+		Bucket:         "logs",
+	}
+	assert.Error(t, conf.Validate())
+	conf.Version = 3
+	conf.Token = "secret"
+	assert.Error(t, conf.Validate())
+}
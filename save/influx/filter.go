@@ -0,0 +1,76 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influx
+
+import (
+	"fmt"
+
+	"klogproc/servicelog"
+
+	"github.com/czcorpus/cnc-gokit/collections"
+)
+
+// RecordFilter specifies which records are written to InfluxDB.
+// Conditions are evaluated against tags and values of the
+// respective InfluxDB point (tags have precedence in case
+// of a name collision). Values are compared as strings.
+//
+// A record is accepted if for each field in Include its value is
+// among the listed ones and for none of fields in Exclude its
+// value is among the listed ones.
+//
+// Example (successful queries only):
+// {"include": {"action": ["query_submit"], "error": [""]}}
+type RecordFilter struct {
+	Include map[string][]string `json:"include"`
+	Exclude map[string][]string `json:"exclude"`
+}
+
+func (rf *RecordFilter) fieldValue(
+	field string,
+	tags map[string]string,
+	values map[string]any,
+) (string, bool) {
+	if v, ok := tags[field]; ok {
+		return v, true
+	}
+	if v, ok := values[field]; ok {
+		return fmt.Sprintf("%v", v), true
+	}
+	return "", false
+}
+
+// Accepts tests whether the record should be written
+func (rf *RecordFilter) Accepts(rec servicelog.OutputRecord) bool {
+	if rf == nil {
+		return true
+	}
+	tags, values := rec.ToInfluxDB()
+	for field, allowed := range rf.Include {
+		v, ok := rf.fieldValue(field, tags, values)
+		if !ok || !collections.SliceContains(allowed, v) {
+			return false
+		}
+	}
+	for field, denied := range rf.Exclude {
+		v, ok := rf.fieldValue(field, tags, values)
+		if ok && collections.SliceContains(denied, v) {
+			return false
+		}
+	}
+	return true
+}
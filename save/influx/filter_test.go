@@ -0,0 +1,60 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	action string
+	err    string
+}
+
+func (r *testRecord) ToJSON() ([]byte, error) { return []byte{}, nil }
+func (r *testRecord) GetID() string           { return "1" }
+func (r *testRecord) GetType() string         { return "test" }
+func (r *testRecord) GetTime() time.Time      { return time.Time{} }
+func (r *testRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+
+func (r *testRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{"action": r.action}, map[string]any{"error": r.err}
+}
+
+func TestRecordFilterAccepts(t *testing.T) {
+	filter := &RecordFilter{
+		Include: map[string][]string{"action": {"query_submit", "view"}, "error": {""}},
+	}
+	assert.True(t, filter.Accepts(&testRecord{action: "view"}))
+	assert.False(t, filter.Accepts(&testRecord{action: "view", err: "NotFound"}))
+	assert.False(t, filter.Accepts(&testRecord{action: "login"}))
+}
+
+func TestRecordFilterExclude(t *testing.T) {
+	filter := &RecordFilter{Exclude: map[string][]string{"action": {"login"}}}
+	assert.True(t, filter.Accepts(&testRecord{action: "view"}))
+	assert.False(t, filter.Accepts(&testRecord{action: "login"}))
+}
+
+func TestNilRecordFilter(t *testing.T) {
+	var filter *RecordFilter
+	assert.True(t, filter.Accepts(&testRecord{action: "login"}))
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"klogproc/servicelog"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PropertyMapping declares which properties of an output record
+// should be written as InfluxDB tags and which ones as fields.
+// Properties not mentioned here keep the role given by the record's
+// built-in ToInfluxDB(). Besides the built-in tags and fields, any
+// top-level scalar property of the record's JSON form can be used.
+type PropertyMapping struct {
+	Tags   []string `json:"tags"`
+	Fields []string `json:"fields"`
+}
+
+// Validate tests the mapping for empty and conflicting declarations.
+// Whether the properties actually exist can be tested only once
+// a respective record is available (see PropertyMapping.checkRecord).
+func (pm *PropertyMapping) Validate() error {
+	used := make(map[string]string)
+	for _, v := range pm.Tags {
+		if v == "" {
+			return fmt.Errorf("empty tag name")
+		}
+		if _, ok := used[v]; ok {
+			return fmt.Errorf("duplicate tag %s", v)
+		}
+		used[v] = "tag"
+	}
+	for _, v := range pm.Fields {
+		if v == "" {
+			return fmt.Errorf("empty field name")
+		}
+		if role, ok := used[v]; ok {
+			return fmt.Errorf("property %s declared as both field and %s", v, role)
+		}
+		used[v] = "field"
+	}
+	if len(used) == 0 {
+		return fmt.Errorf("no tags or fields declared")
+	}
+	return nil
+}
+
+func (pm *PropertyMapping) checkRecord(props map[string]any) error {
+	for _, v := range pm.Tags {
+		if _, ok := props[v]; !ok {
+			return fmt.Errorf("unknown property %s", v)
+		}
+	}
+	for _, v := range pm.Fields {
+		if _, ok := props[v]; !ok {
+			return fmt.Errorf("unknown property %s", v)
+		}
+	}
+	return nil
+}
+
+// recordProperties collects all the properties available for mapping.
+// The built-in tags and fields take precedence over the JSON properties.
+func recordProperties(
+	rec servicelog.OutputRecord,
+	tags map[string]string,
+	values map[string]any,
+) map[string]any {
+	ans := make(map[string]any)
+	if data, err := rec.ToJSON(); err == nil {
+		var obj map[string]any
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&obj); err == nil {
+			for k, v := range obj {
+				switch tv := v.(type) {
+				case string, bool:
+					ans[k] = tv
+				case json.Number:
+					if iv, err := tv.Int64(); err == nil {
+						ans[k] = iv
+
+					} else if fv, err := tv.Float64(); err == nil {
+						ans[k] = fv
+					}
+				}
+			}
+		}
+	}
+	for k, v := range values {
+		ans[k] = v
+	}
+	for k, v := range tags {
+		ans[k] = v
+	}
+	return ans
+}
+
+// pointMapper applies configured property mappings to records
+// before they are converted to InfluxDB points. For app types without
+// a mapping (or with a mapping which does not match actual records),
+// the record's ToInfluxDB() is used as is.
+type pointMapper struct {
+	mapping map[string]PropertyMapping
+
+	// checked contains app types whose mapping has already been
+	// tested against an actual record (value = mapping is usable)
+	checked map[string]bool
+}
+
+func (pm *pointMapper) isUsable(appType string, props map[string]any) bool {
+	usable, ok := pm.checked[appType]
+	if ok {
+		return usable
+	}
+	mapping := pm.mapping[appType]
+	if err := mapping.checkRecord(props); err != nil {
+		log.Error().
+			Err(err).
+			Str("appType", appType).
+			Msg("invalid InfluxDB property mapping, using built-in tags and fields")
+		pm.checked[appType] = false
+		return false
+	}
+	pm.checked[appType] = true
+	return true
+}
+
+// influxData returns tags and fields of the InfluxDB point
+// for the record
+func (pm *pointMapper) influxData(rec servicelog.OutputRecord) (map[string]string, map[string]any) {
+	tags, values := rec.ToInfluxDB()
+	if pm == nil {
+		return tags, values
+	}
+	mapping, ok := pm.mapping[rec.GetType()]
+	if !ok {
+		return tags, values
+	}
+	props := recordProperties(rec, tags, values)
+	if !pm.isUsable(rec.GetType(), props) {
+		return tags, values
+	}
+	for _, v := range mapping.Tags {
+		delete(values, v)
+		tags[v] = fmt.Sprintf("%v", props[v])
+	}
+	for _, v := range mapping.Fields {
+		delete(tags, v)
+		values[v] = props[v]
+	}
+	return tags, values
+}
+
+func newPointMapper(mapping map[string]PropertyMapping) *pointMapper {
+	if len(mapping) == 0 {
+		return nil
+	}
+	return &pointMapper{
+		mapping: mapping,
+		checked: make(map[string]bool),
+	}
+}
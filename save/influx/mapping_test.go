@@ -0,0 +1,84 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influx
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mappedRecord struct {
+	Type   string `json:"type"`
+	Corpus string `json:"corpus"`
+	User   string `json:"user"`
+	Size   int    `json:"size"`
+}
+
+func (r *mappedRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+
+func (r *mappedRecord) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (r *mappedRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{"corpus": r.Corpus}, map[string]any{"size": r.Size}
+}
+
+func (r *mappedRecord) GetID() string      { return "1" }
+func (r *mappedRecord) GetType() string    { return r.Type }
+func (r *mappedRecord) GetTime() time.Time { return time.Unix(0, 0) }
+
+func TestPropertyMappingValidate(t *testing.T) {
+	assert.NoError(t, (&PropertyMapping{Tags: []string{"user"}, Fields: []string{"corpus"}}).Validate())
+	assert.Error(t, (&PropertyMapping{}).Validate())
+	assert.Error(t, (&PropertyMapping{Tags: []string{""}}).Validate())
+	assert.Error(t, (&PropertyMapping{Tags: []string{"corpus"}, Fields: []string{"corpus"}}).Validate())
+}
+
+func TestPointMapperOverridesBuiltIn(t *testing.T) {
+	mapper := newPointMapper(map[string]PropertyMapping{
+		"wag": {Tags: []string{"user"}, Fields: []string{"corpus"}},
+	})
+	tags, values := mapper.influxData(&mappedRecord{Type: "wag", Corpus: "syn2020", User: "joe", Size: 7})
+	assert.Equal(t, map[string]string{"user": "joe"}, tags)
+	assert.Equal(t, map[string]any{"corpus": "syn2020", "size": 7}, values)
+
+	tags, values = mapper.influxData(&mappedRecord{Type: "kontext", Corpus: "syn2020", User: "joe", Size: 7})
+	assert.Equal(t, map[string]string{"corpus": "syn2020"}, tags)
+	assert.Equal(t, map[string]any{"size": 7}, values)
+}
+
+func TestPointMapperUnknownPropertyFallsBack(t *testing.T) {
+	mapper := newPointMapper(map[string]PropertyMapping{
+		"wag": {Tags: []string{"missing"}},
+	})
+	tags, values := mapper.influxData(&mappedRecord{Type: "wag", Corpus: "syn2020", Size: 7})
+	assert.Equal(t, map[string]string{"corpus": "syn2020"}, tags)
+	assert.Equal(t, map[string]any{"size": 7}, values)
+	assert.False(t, mapper.checked["wag"])
+}
+
+func TestPointMapperNil(t *testing.T) {
+	var mapper *pointMapper
+	tags, _ := mapper.influxData(&mappedRecord{Type: "wag", Corpus: "syn2020"})
+	assert.Equal(t, map[string]string{"corpus": "syn2020"}, tags)
+	assert.Nil(t, newPointMapper(nil))
+}
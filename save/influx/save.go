@@ -32,12 +32,15 @@ func RunWriteConsumer(conf *ConnectionConf, incomingData <-chan *servicelog.Boun
 	go func() {
 		if conf.IsConfigured() {
 			var err error
-			client, err := NewRecordWriter(conf)
+			client, err := newRecordWriter(conf)
 			if err != nil {
 				log.Error().Err(err).Msg("")
 			}
 			for rec := range incomingData {
-				write, err := client.AddRecord(rec.Rec)
+				if !conf.Filter.Accepts(rec) {
+					continue
+				}
+				write, err := client.AddRecord(rec)
 				if write {
 					confirmMsg := save.ConfirmMsg{
 						Position: rec.FilePos,
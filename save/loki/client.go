@@ -0,0 +1,251 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"klogproc/servicelog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultReqTimeoutSecs = 10
+
+	pushPath = "/loki/api/v1/push"
+)
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// ConnectionConf specifies a configuration required to push data
+// to Grafana Loki. The URL is the base URL of a Loki instance
+// (e.g. http://localhost:3100), the push API path is added automatically.
+type ConnectionConf struct {
+	URL            string `json:"url"`
+	PushChunkSize  int    `json:"pushChunkSize"`
+	ReqTimeoutSecs int    `json:"reqTimeoutSecs"`
+
+	// Username and Password enable HTTP basic authentication
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// TenantID is sent as the X-Scope-OrgID header
+	// (needed in case Loki runs in the multi-tenant mode)
+	TenantID string `json:"tenantId"`
+}
+
+// IsConfigured tests whether the configuration is considered
+// to be enabled (i.e. no error checking just enabled/disabled)
+func (conf *ConnectionConf) IsConfigured() bool {
+	return conf.URL != ""
+}
+
+// Validate tests whether the configuration is filled in
+// correctly. Please note that if the function returns nil
+// then IsConfigured() must return 'true'.
+func (conf *ConnectionConf) Validate() error {
+	if conf.URL == "" {
+		return fmt.Errorf("missing 'url' information for Loki")
+	}
+	if _, err := url.Parse(conf.URL); err != nil {
+		return fmt.Errorf("invalid 'url' for Loki: %w", err)
+	}
+	if conf.PushChunkSize <= 0 {
+		return fmt.Errorf("loki.pushChunkSize must be a positive number")
+	}
+	if conf.Password != "" && conf.Username == "" {
+		return fmt.Errorf("loki.password specified without loki.username")
+	}
+	if conf.ReqTimeoutSecs == 0 {
+		conf.ReqTimeoutSecs = defaultReqTimeoutSecs
+		log.Warn().Msgf("value loki.reqTimeoutSecs not specified, using default %d", defaultReqTimeoutSecs)
+	}
+	return nil
+}
+
+// ------
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type pushRequest struct {
+	Streams []*stream `json:"streams"`
+}
+
+// sanitizeLabelName makes sure the name matches Loki's
+// label name format ([a-zA-Z_][a-zA-Z0-9_]*)
+func sanitizeLabelName(name string) string {
+	name = invalidLabelChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func recordLabels(rec servicelog.OutputRecord) map[string]string {
+	tags, _ := rec.ToInfluxDB()
+	labels := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			labels[sanitizeLabelName(k)] = v
+		}
+	}
+	return labels
+}
+
+// labelsKey creates a unique and stable identifier of a label set
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var ans strings.Builder
+	for _, k := range keys {
+		ans.WriteString(k)
+		ans.WriteString("=")
+		ans.WriteString(strconv.Quote(labels[k]))
+		ans.WriteString(",")
+	}
+	return ans.String()
+}
+
+// RecordWriter collects records and pushes them to Loki
+// in batches. Stream labels are derived from tags provided
+// by OutputRecord.ToInfluxDB(), the log line is the JSON
+// representation of the record (OutputRecord.ToJSON()).
+// Similarly to other writers, Finish() must be always
+// called to write possible stale records.
+type RecordWriter struct {
+	client        *http.Client
+	pushURL       string
+	username      string
+	password      string
+	tenantID      string
+	pushChunkSize int
+	numEntries    int
+	streams       map[string]*stream
+	streamOrder   []string
+}
+
+// AddRecord adds a record and if internal batch is full then
+// it also pushes the batch to Loki. The first returned value
+// says whether a write has been performed.
+func (c *RecordWriter) AddRecord(rec servicelog.OutputRecord) (bool, error) {
+	line, err := rec.ToJSON()
+	if err != nil {
+		return false, fmt.Errorf("failed to serialize record for Loki: %w", err)
+	}
+	labels := recordLabels(rec)
+	key := labelsKey(labels)
+	s, ok := c.streams[key]
+	if !ok {
+		s = &stream{Stream: labels}
+		c.streams[key] = s
+		c.streamOrder = append(c.streamOrder, key)
+	}
+	s.Values = append(
+		s.Values,
+		[2]string{strconv.FormatInt(rec.GetTime().UnixNano(), 10), string(line)},
+	)
+	c.numEntries++
+	if c.numEntries >= c.pushChunkSize {
+		return true, c.writeCurrBatch()
+	}
+	return false, nil
+}
+
+// Finish ensures that the current operation is fully
+// processed and all the data are pushed to Loki.
+// The first returned value says whether a write has
+// been performed.
+func (c *RecordWriter) Finish() (bool, error) {
+	if c.numEntries == 0 {
+		return false, nil
+	}
+	return true, c.writeCurrBatch()
+}
+
+func (c *RecordWriter) writeCurrBatch() error {
+	defer func() {
+		c.streams = make(map[string]*stream)
+		c.streamOrder = c.streamOrder[:0]
+		c.numEntries = 0
+	}()
+	payload := pushRequest{Streams: make([]*stream, 0, len(c.streamOrder))}
+	for _, key := range c.streamOrder {
+		payload.Streams = append(payload.Streams, c.streams[key])
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode Loki push request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		context.Background(), http.MethodPost, c.pushURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Loki request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if c.tenantID != "" {
+		req.Header.Set("X-Scope-OrgID", c.tenantID)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push data to Loki: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf(
+			"failed to push data to Loki, status: %d, response: %s",
+			resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// NewRecordWriter is a factory function for RecordWriter
+func NewRecordWriter(conf *ConnectionConf) (*RecordWriter, error) {
+	pushURL, err := url.JoinPath(conf.URL, pushPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Loki url: %w", err)
+	}
+	return &RecordWriter{
+		client:        &http.Client{Timeout: time.Duration(conf.ReqTimeoutSecs) * time.Second},
+		pushURL:       pushURL,
+		username:      conf.Username,
+		password:      conf.Password,
+		tenantID:      conf.TenantID,
+		pushChunkSize: conf.PushChunkSize,
+		streams:       make(map[string]*stream),
+	}, nil
+}
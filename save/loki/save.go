@@ -0,0 +1,80 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loki
+
+import (
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RunWriteConsumer reads from incomingData channel and pushes the data
+// to a configured Loki instance. The data are pushed in batches of
+// conf.PushChunkSize records (and once more when the incomingData channel
+// is closed). Each batch is confirmed by a single message covering
+// the whole batch so the worklog advances only after a successful push.
+func RunWriteConsumer(conf *ConnectionConf, incomingData <-chan *servicelog.BoundOutputRecord) <-chan save.ConfirmMsg {
+	confirmChan := make(chan save.ConfirmMsg)
+	go func() {
+		defer close(confirmChan)
+		if !conf.IsConfigured() {
+			for range incomingData {
+			}
+			return
+		}
+		client, err := NewRecordWriter(conf)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to initialize Loki writer")
+			for rec := range incomingData {
+				confirmChan <- save.ConfirmMsg{FilePath: rec.FilePath, Position: rec.FilePos, Error: err}
+			}
+			return
+		}
+		var chunkPosition *servicelog.LogRange
+		var filePath string
+		confirm := func(err error) {
+			chunkPosition.Written = err == nil
+			confirmChan <- save.ConfirmMsg{
+				FilePath: filePath,
+				Position: *chunkPosition,
+				Error:    err,
+			}
+			chunkPosition = nil
+		}
+		for rec := range incomingData {
+			if chunkPosition == nil {
+				pos := rec.FilePos
+				chunkPosition = &pos
+				filePath = rec.FilePath
+			}
+			chunkPosition.SeekEnd = rec.FilePos.SeekEnd
+			written, err := client.AddRecord(rec)
+			if written {
+				confirm(err)
+
+			} else if err != nil {
+				log.Error().Err(err).Str("recId", rec.GetID()).Msg("failed to add record to Loki batch")
+			}
+		}
+		written, err := client.Finish()
+		if written {
+			confirm(err)
+		}
+	}()
+	return confirmChan
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loki
+
+import (
+	"encoding/json"
+	"klogproc/servicelog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	action string
+	app    string
+}
+
+func (r *testRecord) ToJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"action": r.action})
+}
+func (r *testRecord) GetID() string      { return "1" }
+func (r *testRecord) GetType() string    { return "test" }
+func (r *testRecord) GetTime() time.Time { return time.Unix(1700000000, 0) }
+func (r *testRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+func (r *testRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{"action": r.action, "app-type": r.app}, map[string]any{"procTime": 0.5}
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	assert.Equal(t, "app_type", sanitizeLabelName("app-type"))
+	assert.Equal(t, "_1st", sanitizeLabelName("1st"))
+	assert.Equal(t, "action", sanitizeLabelName("action"))
+}
+
+func TestRunWriteConsumerBatches(t *testing.T) {
+	var mu sync.Mutex
+	var requests []pushRequest
+	var tenants []string
+	var users []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, pushPath, r.URL.Path)
+		var req pushRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		requests = append(requests, req)
+		tenants = append(tenants, r.Header.Get("X-Scope-OrgID"))
+		user, _, _ := r.BasicAuth()
+		users = append(users, user)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	conf := &ConnectionConf{
+		URL: srv.URL, PushChunkSize: 2, Username: "klogproc", Password: "secret", TenantID: "cnc"}
+	assert.NoError(t, conf.Validate())
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := RunWriteConsumer(conf, incoming)
+	go func() {
+		actions := []string{"view", "query_submit", "view"}
+		for i, action := range actions {
+			incoming <- &servicelog.BoundOutputRecord{
+				FilePath: "/var/log/app.log",
+				Rec:      &testRecord{action: action, app: "kontext"},
+				FilePos:  servicelog.LogRange{SeekStart: int64(i * 10), SeekEnd: int64(i*10 + 10)},
+			}
+		}
+		close(incoming)
+	}()
+	var confirms []servicelog.LogRange
+	for msg := range confirmChan {
+		assert.NoError(t, msg.Error)
+		confirms = append(confirms, msg.Position)
+	}
+	assert.Equal(t, 2, len(confirms))
+	assert.Equal(t, int64(0), confirms[0].SeekStart)
+	assert.Equal(t, int64(20), confirms[0].SeekEnd)
+	assert.True(t, confirms[0].Written)
+	assert.Equal(t, int64(20), confirms[1].SeekStart)
+	assert.Equal(t, int64(30), confirms[1].SeekEnd)
+
+	assert.Equal(t, []string{"cnc", "cnc"}, tenants)
+	assert.Equal(t, []string{"klogproc", "klogproc"}, users)
+	assert.Equal(t, 2, len(requests))
+	assert.Equal(t, 2, len(requests[0].Streams))
+	assert.Equal(
+		t,
+		map[string]string{"action": "view", "app_type": "kontext"},
+		requests[0].Streams[0].Stream,
+	)
+	assert.Equal(
+		t,
+		[][2]string{{"1700000000000000000", `{"action":"view"}`}},
+		requests[0].Streams[0].Values,
+	)
+	assert.Equal(t, "query_submit", requests[0].Streams[1].Stream["action"])
+	assert.Equal(t, 1, len(requests[1].Streams))
+}
+
+func TestRunWriteConsumerPushFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "entry out of order", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	conf := &ConnectionConf{URL: srv.URL, PushChunkSize: 10, ReqTimeoutSecs: 1}
+	incoming := make(chan *servicelog.BoundOutputRecord, 1)
+	incoming <- &servicelog.BoundOutputRecord{
+		Rec:     &testRecord{action: "view"},
+		FilePos: servicelog.LogRange{SeekStart: 0, SeekEnd: 10},
+	}
+	close(incoming)
+	msg := <-RunWriteConsumer(conf, incoming)
+	assert.ErrorContains(t, msg.Error, "status: 400")
+	assert.False(t, msg.Position.Written)
+}
+
+func TestValidatePasswordWithoutUsername(t *testing.T) {
+	conf := &ConnectionConf{URL: "http://localhost:3100", PushChunkSize: 10, Password: "secret"}
+	assert.Error(t, conf.Validate())
+}
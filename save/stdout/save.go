@@ -0,0 +1,89 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stdout
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// outputMutex makes sure lines written by concurrent
+	// consumers (e.g. multiple tailed files) do not interleave
+	outputMutex sync.Mutex
+)
+
+// Conf configures writing of output records to the standard
+// output as NDJSON (one JSON document per line). This can be
+// used along with other outputs - e.g. to pipe the records
+// to another tool.
+type Conf struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (conf *Conf) IsConfigured() bool {
+	return conf != nil && conf.Enabled
+}
+
+// RunWriteConsumer reads from incomingData channel and writes each
+// record as a single line of JSON to the standard output. Each record
+// is confirmed individually. In case the output is not configured,
+// the incoming data are just consumed.
+func RunWriteConsumer(conf *Conf, incomingData <-chan *servicelog.BoundOutputRecord) <-chan save.ConfirmMsg {
+	return runWriteConsumer(conf, os.Stdout, incomingData)
+}
+
+func runWriteConsumer(
+	conf *Conf,
+	out io.Writer,
+	incomingData <-chan *servicelog.BoundOutputRecord,
+) <-chan save.ConfirmMsg {
+	confirmChan := make(chan save.ConfirmMsg)
+	go func() {
+		defer close(confirmChan)
+		if !conf.IsConfigured() {
+			for range incomingData {
+			}
+			return
+		}
+		for rec := range incomingData {
+			data, err := rec.ToJSON()
+			if err == nil {
+				outputMutex.Lock()
+				_, err = out.Write(append(data, '\n'))
+				outputMutex.Unlock()
+			}
+			if err != nil {
+				log.Error().Err(err).Str("file", rec.FilePath).Msg("failed to write record to stdout")
+			}
+			pos := rec.FilePos
+			pos.Written = err == nil
+			confirmChan <- save.ConfirmMsg{
+				FilePath: rec.FilePath,
+				Position: pos,
+				Error:    err,
+			}
+		}
+	}()
+	return confirmChan
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stdout
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"klogproc/save"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	id string
+}
+
+func (r *testRecord) ToJSON() ([]byte, error) { return []byte(`{"id":"` + r.id + `"}`), nil }
+func (r *testRecord) GetID() string           { return r.id }
+func (r *testRecord) GetType() string         { return "test" }
+func (r *testRecord) GetTime() time.Time      { return time.Time{} }
+func (r *testRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+func (r *testRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{}, map[string]any{}
+}
+
+func TestRunWriteConsumerNDJSON(t *testing.T) {
+	var buff bytes.Buffer
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := runWriteConsumer(&Conf{Enabled: true}, &buff, incoming)
+	go func() {
+		incoming <- &servicelog.BoundOutputRecord{
+			Rec: &testRecord{id: "1"}, FilePath: "app.log", FilePos: servicelog.LogRange{SeekEnd: 10}}
+		incoming <- &servicelog.BoundOutputRecord{
+			Rec: &testRecord{id: "2"}, FilePath: "app.log", FilePos: servicelog.LogRange{SeekStart: 10, SeekEnd: 20}}
+		close(incoming)
+	}()
+	var confirms []save.ConfirmMsg
+	for c := range confirmChan {
+		confirms = append(confirms, c)
+	}
+	assert.Equal(t, "{\"id\":\"1\"}\n{\"id\":\"2\"}\n", buff.String())
+	assert.Len(t, confirms, 2)
+	assert.True(t, confirms[1].Position.Written)
+	assert.Equal(t, int64(20), confirms[1].Position.SeekEnd)
+}
+
+func TestRunWriteConsumerDisabled(t *testing.T) {
+	var buff bytes.Buffer
+	incoming := make(chan *servicelog.BoundOutputRecord)
+	confirmChan := runWriteConsumer(&Conf{}, &buff, incoming)
+	go func() {
+		incoming <- &servicelog.BoundOutputRecord{Rec: &testRecord{id: "1"}}
+		close(incoming)
+	}()
+	for range confirmChan {
+		t.Error("no confirmation expected")
+	}
+	assert.Empty(t, buff.String())
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// GoToLua converts JSON-like Go values (strings, numbers, bools,
+// slices and string-keyed maps) to Lua values. Unsupported
+// types are converted to strings.
+func GoToLua(L *lua.LState, v any) lua.LValue {
+	switch tv := v.(type) {
+	case nil:
+		return lua.LNil
+	case string:
+		return lua.LString(tv)
+	case bool:
+		return lua.LBool(tv)
+	case int:
+		return lua.LNumber(tv)
+	case int64:
+		return lua.LNumber(tv)
+	case float32:
+		return lua.LNumber(tv)
+	case float64:
+		return lua.LNumber(tv)
+	case []string:
+		tbl := L.NewTable()
+		for _, item := range tv {
+			tbl.Append(lua.LString(item))
+		}
+		return tbl
+	case []any:
+		tbl := L.NewTable()
+		for _, item := range tv {
+			tbl.Append(GoToLua(L, item))
+		}
+		return tbl
+	case map[string]any:
+		tbl := L.NewTable()
+		for k, item := range tv {
+			tbl.RawSetString(k, GoToLua(L, item))
+		}
+		return tbl
+	default:
+		return lua.LString(fmt.Sprint(tv))
+	}
+}
+
+// LuaToGo converts a Lua value to a JSON-like Go value.
+// Tables with a sequence part are converted to slices, other
+// tables to maps with string keys.
+func LuaToGo(v lua.LValue) any {
+	switch tv := v.(type) {
+	case lua.LString:
+		return string(tv)
+	case lua.LNumber:
+		return float64(tv)
+	case lua.LBool:
+		return bool(tv)
+	case *lua.LTable:
+		if n := tv.MaxN(); n > 0 {
+			ans := make([]any, 0, n)
+			for i := 1; i <= n; i++ {
+				ans = append(ans, LuaToGo(tv.RawGetInt(i)))
+			}
+			return ans
+		}
+		ans := make(map[string]any)
+		tv.ForEach(func(k, item lua.LValue) {
+			ans[k.String()] = LuaToGo(item)
+		})
+		return ans
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+
+	// TransformFnName is the name of a Lua function called
+	// for each transformed record. It receives a table
+	// with input record data.
+	TransformFnName = "transform"
+
+	// SetOutputPropertyFnName is the name of a Lua function
+	// the script can use to set a property of the output record
+	// (the function is available only within the `transform` call).
+	SetOutputPropertyFnName = "set_output_property"
+)
+
+var (
+	// ErrScriptingNotSupported is returned by transformers
+	// with no support for scripting
+	ErrScriptingNotSupported = errors.New("scripting not supported by the transformer")
+)
+
+// PropertySetter is a function a transformer provides to allow
+// a script to set a property of an output record
+type PropertySetter func(name string, value any) error
+
+// Engine runs a user-defined Lua script for transformed records.
+// A single Lua state is used so the engine serializes the calls.
+type Engine struct {
+	mu          sync.Mutex
+	lstate      *lua.LState
+	transformFn *lua.LFunction
+	setProp     PropertySetter
+}
+
+func (e *Engine) luaSetOutputProperty(L *lua.LState) int {
+	name := L.CheckString(1)
+	if e.setProp == nil {
+		L.RaiseError("%s called outside of %s", SetOutputPropertyFnName, TransformFnName)
+		return 0
+	}
+	if err := e.setProp(name, LuaToGo(L.Get(2))); err != nil {
+		L.RaiseError("failed to set output property %s: %s", name, err)
+	}
+	return 0
+}
+
+// Transform calls the `transform` Lua function with the provided
+// input data. The setter is used to apply values passed by the script
+// via `set_output_property(name, value)`.
+func (e *Engine) Transform(input map[string]any, setter PropertySetter) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.setProp = setter
+	defer func() { e.setProp = nil }()
+	err := e.lstate.CallByParam(
+		lua.P{
+			Fn:      e.transformFn,
+			NRet:    0,
+			Protect: true,
+		},
+		GoToLua(e.lstate, input),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to run Lua %s: %w", TransformFnName, err)
+	}
+	return nil
+}
+
+// Close releases the Lua state
+func (e *Engine) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lstate.Close()
+}
+
+// NewEngine loads a Lua script from the specified path and checks
+// that the script defines the required `transform` function.
+func NewEngine(scriptPath string) (*Engine, error) {
+	ans := &Engine{lstate: lua.NewState()}
+	ans.lstate.SetGlobal(SetOutputPropertyFnName, ans.lstate.NewFunction(ans.luaSetOutputProperty))
+	if err := ans.lstate.DoFile(scriptPath); err != nil {
+		ans.lstate.Close()
+		return nil, fmt.Errorf("failed to load Lua script %s: %w", scriptPath, err)
+	}
+	fn, ok := ans.lstate.GetGlobal(TransformFnName).(*lua.LFunction)
+	if !ok {
+		ans.lstate.Close()
+		return nil, fmt.Errorf(
+			"failed to load Lua script %s: function %s not defined", scriptPath, TransformFnName)
+	}
+	ans.transformFn = fn
+	return ans, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeScript(t *testing.T, src string) string {
+	path := filepath.Join(t.TempDir(), "script.lua")
+	assert.NoError(t, os.WriteFile(path, []byte(src), 0644))
+	return path
+}
+
+func TestNewEngineRequiresTransform(t *testing.T) {
+	_, err := NewEngine(writeScript(t, `function foo() end`))
+	assert.Error(t, err)
+}
+
+func TestNewEngineSyntaxError(t *testing.T) {
+	_, err := NewEngine(writeScript(t, `function transform(`))
+	assert.Error(t, err)
+}
+
+func TestTransformSetsProperties(t *testing.T) {
+	engine, err := NewEngine(writeScript(t, `
+function transform(input)
+  set_output_property("n", input.num * 2)
+  set_output_property("first", input.items[1])
+  set_output_property("nested", {a = input.args.x})
+end
+`))
+	assert.NoError(t, err)
+	defer engine.Close()
+	props := make(map[string]any)
+	err = engine.Transform(
+		map[string]any{
+			"num":   21,
+			"items": []any{"foo", "bar"},
+			"args":  map[string]any{"x": true},
+		},
+		func(name string, value any) error {
+			props[name] = value
+			return nil
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, props["n"])
+	assert.Equal(t, "foo", props["first"])
+	assert.Equal(t, map[string]any{"a": true}, props["nested"])
+}
+
+func TestTransformRuntimeError(t *testing.T) {
+	engine, err := NewEngine(writeScript(t, `
+function transform(input)
+  error("boom")
+end
+`))
+	assert.NoError(t, err)
+	defer engine.Close()
+	err = engine.Transform(map[string]any{}, func(name string, value any) error { return nil })
+	assert.Error(t, err)
+}
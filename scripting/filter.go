@@ -0,0 +1,133 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"fmt"
+	"sync"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+
+	// FilterFnName is the name of a Lua function deciding
+	// whether a record should be processed. It receives a table
+	// with input record data and it must return a boolean.
+	FilterFnName = "filter"
+
+	// ShouldRecordFnName is the name of a Lua function deciding
+	// whether a record should be processed. Unlike `filter`, it receives
+	// an input record object (see Input) and it is available only for
+	// transformers supporting scripting. It must return a boolean.
+	ShouldRecordFnName = "should_record"
+)
+
+// Filter runs user-defined Lua predicates for input records.
+// The script must define `filter(record)` and/or `should_record(input)`.
+// A single Lua state is used so the filter serializes the calls.
+type Filter struct {
+	mu             sync.Mutex
+	lstate         *lua.LState
+	filterFn       *lua.LFunction
+	shouldRecordFn *lua.LFunction
+}
+
+func (f *Filter) callPredicate(fn *lua.LFunction, fnName string, arg lua.LValue) (bool, error) {
+	err := f.lstate.CallByParam(
+		lua.P{
+			Fn:      fn,
+			NRet:    1,
+			Protect: true,
+		},
+		arg,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to run Lua %s: %w", fnName, err)
+	}
+	ret := f.lstate.Get(-1)
+	f.lstate.Pop(1)
+	ans, ok := ret.(lua.LBool)
+	if !ok {
+		return false, fmt.Errorf(
+			"failed to run Lua %s: expected a boolean result, got %s", fnName, ret.Type())
+	}
+	return bool(ans), nil
+}
+
+// HasFilter tells whether the script defines the `filter` function
+func (f *Filter) HasFilter() bool {
+	return f.filterFn != nil
+}
+
+// HasShouldRecord tells whether the script defines
+// the `should_record` function
+func (f *Filter) HasShouldRecord() bool {
+	return f.shouldRecordFn != nil
+}
+
+// Accepts calls the `filter` Lua function with the provided
+// record data and returns its result. In case the function
+// is not defined, all the records are accepted.
+func (f *Filter) Accepts(record map[string]any) (bool, error) {
+	if f.filterFn == nil {
+		return true, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.callPredicate(f.filterFn, FilterFnName, GoToLua(f.lstate, record))
+}
+
+// ShouldRecord calls the `should_record` Lua function with the provided
+// input record and returns its result. In case the function is not defined,
+// all the records are accepted.
+func (f *Filter) ShouldRecord(input Input) (bool, error) {
+	if f.shouldRecordFn == nil {
+		return true, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.callPredicate(f.shouldRecordFn, ShouldRecordFnName, newLuaInput(f.lstate, input))
+}
+
+// Close releases the Lua state
+func (f *Filter) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lstate.Close()
+}
+
+// NewFilter loads a Lua script from the specified path and checks
+// that the script defines at least one of the `filter`
+// and `should_record` functions.
+func NewFilter(scriptPath string) (*Filter, error) {
+	ans := &Filter{lstate: lua.NewState()}
+	registerInputType(ans.lstate)
+	if err := ans.lstate.DoFile(scriptPath); err != nil {
+		ans.lstate.Close()
+		return nil, fmt.Errorf("failed to load Lua script %s: %w", scriptPath, err)
+	}
+	ans.filterFn, _ = ans.lstate.GetGlobal(FilterFnName).(*lua.LFunction)
+	ans.shouldRecordFn, _ = ans.lstate.GetGlobal(ShouldRecordFnName).(*lua.LFunction)
+	if ans.filterFn == nil && ans.shouldRecordFn == nil {
+		ans.lstate.Close()
+		return nil, fmt.Errorf(
+			"failed to load Lua script %s: neither %s nor %s defined",
+			scriptPath, FilterFnName, ShouldRecordFnName)
+	}
+	return ans, nil
+}
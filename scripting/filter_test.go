@@ -0,0 +1,101 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFilterRequiresFilter(t *testing.T) {
+	_, err := NewFilter(writeScript(t, `function transform(input) end`))
+	assert.Error(t, err)
+}
+
+func TestFilterAccepts(t *testing.T) {
+	filter, err := NewFilter(writeScript(t, `
+function filter(record)
+  return record.UserID ~= "1234" and record.corpus ~= "internal"
+end
+`))
+	assert.NoError(t, err)
+	defer filter.Close()
+	ok, err := filter.Accepts(map[string]any{"UserID": "1", "corpus": "syn2020"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = filter.Accepts(map[string]any{"UserID": "1234"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = filter.Accepts(map[string]any{"corpus": "internal"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestFilterInvalidResult(t *testing.T) {
+	filter, err := NewFilter(writeScript(t, `
+function filter(record)
+  return record.UserID
+end
+`))
+	assert.NoError(t, err)
+	defer filter.Close()
+	_, err = filter.Accepts(map[string]any{"UserID": "1"})
+	assert.Error(t, err)
+	_, err = filter.Accepts(map[string]any{})
+	assert.Error(t, err)
+}
+
+type testInput struct {
+	action string
+	args   map[string]any
+}
+
+func (r *testInput) GetTime() time.Time      { return time.Date(2024, 2, 11, 11, 2, 31, 0, time.UTC) }
+func (r *testInput) GetClientIP() net.IP     { return net.ParseIP("192.168.1.10") }
+func (r *testInput) GetAction() string       { return r.action }
+func (r *testInput) GetArgs() map[string]any { return r.args }
+
+func TestFilterShouldRecord(t *testing.T) {
+	filter, err := NewFilter(writeScript(t, `
+function should_record(input)
+  if string.sub(input:ip(), 1, 8) == "192.168." and input:action() == "query_submit" then
+    return false
+  end
+  return input:args().corpname ~= "internal" and input:time() == "2024-02-11T11:02:31Z"
+end
+`))
+	assert.NoError(t, err)
+	defer filter.Close()
+	assert.False(t, filter.HasFilter())
+	assert.True(t, filter.HasShouldRecord())
+	ok, err := filter.ShouldRecord(&testInput{action: "view", args: map[string]any{"corpname": "syn2020"}})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = filter.ShouldRecord(&testInput{action: "query_submit"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = filter.ShouldRecord(&testInput{action: "view", args: map[string]any{"corpname": "internal"}})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	// with no `filter` function, all the records are accepted by it
+	ok, err = filter.Accepts(map[string]any{})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scripting
+
+import (
+	"net"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	inputTypeName = "klogproc.input"
+)
+
+// Input is a read-only view of an input record provided
+// to the Lua `should_record(input)` function. In Lua, the record
+// is accessible via methods `input:time()` (RFC3339 string),
+// `input:ip()` (string or nil), `input:action()` (string)
+// and `input:args()` (table).
+type Input interface {
+	GetTime() time.Time
+	GetClientIP() net.IP
+	GetAction() string
+	GetArgs() map[string]any
+}
+
+func checkInput(L *lua.LState) Input {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(Input); ok {
+		return v
+	}
+	L.ArgError(1, "input record expected")
+	return nil
+}
+
+var inputMethods = map[string]lua.LGFunction{
+	"time": func(L *lua.LState) int {
+		L.Push(lua.LString(checkInput(L).GetTime().Format(time.RFC3339)))
+		return 1
+	},
+	"ip": func(L *lua.LState) int {
+		ip := checkInput(L).GetClientIP()
+		if ip == nil {
+			L.Push(lua.LNil)
+
+		} else {
+			L.Push(lua.LString(ip.String()))
+		}
+		return 1
+	},
+	"action": func(L *lua.LState) int {
+		L.Push(lua.LString(checkInput(L).GetAction()))
+		return 1
+	},
+	"args": func(L *lua.LState) int {
+		L.Push(GoToLua(L, checkInput(L).GetArgs()))
+		return 1
+	},
+}
+
+func registerInputType(L *lua.LState) {
+	mt := L.NewTypeMetatable(inputTypeName)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), inputMethods))
+}
+
+func newLuaInput(L *lua.LState, input Input) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = input
+	L.SetMetatable(ud, L.GetTypeMetatable(inputTypeName))
+	return ud
+}
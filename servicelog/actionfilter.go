@@ -0,0 +1,118 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	actionFilterSummaryInterval = time.Minute
+)
+
+// ActionRecord is an optional interface of OutputRecord types
+// providing a logged action
+type ActionRecord interface {
+	GetAction() string
+}
+
+// ActionFilter matches records with actions which should not be
+// stored (e.g. health checks, autocomplete). Patterns are either exact
+// action names or regular expressions enclosed in slashes (e.g. `/^ajax_/`).
+// A nil filter matches nothing.
+type ActionFilter struct {
+	appType string
+	exact   map[string]bool
+	regexps []*regexp.Regexp
+
+	mutex       sync.Mutex
+	numIgnored  int
+	lastSummary time.Time
+}
+
+// Ignores tests whether the record's action matches the filter.
+// Records without action information are never ignored. From time
+// to time, a debug summary of ignored records is logged.
+func (af *ActionFilter) Ignores(rec OutputRecord) bool {
+	if af == nil {
+		return false
+	}
+	tRec, ok := rec.(ActionRecord)
+	if !ok || !af.Matches(tRec.GetAction()) {
+		return false
+	}
+	af.mutex.Lock()
+	defer af.mutex.Unlock()
+	af.numIgnored++
+	if time.Since(af.lastSummary) >= actionFilterSummaryInterval {
+		log.Debug().
+			Str("appType", af.appType).
+			Int("numIgnored", af.numIgnored).
+			Msg("records ignored due to ignoreActions")
+		af.numIgnored = 0
+		af.lastSummary = time.Now()
+	}
+	return true
+}
+
+// Matches tests whether an action matches any of the patterns
+func (af *ActionFilter) Matches(action string) bool {
+	if af == nil {
+		return false
+	}
+	if af.exact[action] {
+		return true
+	}
+	for _, rx := range af.regexps {
+		if rx.MatchString(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewActionFilter creates a filter for the specified app type and
+// patterns. In case there are no patterns, nil is returned.
+func NewActionFilter(appType string, patterns []string) (*ActionFilter, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	ans := &ActionFilter{
+		appType:     appType,
+		exact:       make(map[string]bool),
+		lastSummary: time.Now(),
+	}
+	for _, p := range patterns {
+		if len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			rx, err := regexp.Compile(p[1 : len(p)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid ignored action pattern %s: %w", p, err)
+			}
+			ans.regexps = append(ans.regexps, rx)
+
+		} else {
+			ans.exact[p] = true
+		}
+	}
+	return ans, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type actionTestRecord struct {
+	OutputRecord
+	action string
+}
+
+func (r *actionTestRecord) GetAction() string {
+	return r.action
+}
+
+func TestActionFilterMatches(t *testing.T) {
+	af, err := NewActionFilter("kontext", []string{"healthcheck", "/^ajax_/"})
+	assert.NoError(t, err)
+	assert.True(t, af.Matches("healthcheck"))
+	assert.True(t, af.Matches("ajax_autocomplete"))
+	assert.False(t, af.Matches("query_submit"))
+	assert.False(t, af.Matches("healthcheck2"))
+}
+
+func TestActionFilterIgnores(t *testing.T) {
+	af, err := NewActionFilter("kontext", []string{"/complete$/"})
+	assert.NoError(t, err)
+	assert.True(t, af.Ignores(&actionTestRecord{action: "autocomplete"}))
+	assert.False(t, af.Ignores(&actionTestRecord{action: "query"}))
+	assert.False(t, af.Ignores(&TrivialOutputRecord{}))
+	assert.Equal(t, 1, af.numIgnored)
+}
+
+func TestActionFilterEmpty(t *testing.T) {
+	af, err := NewActionFilter("kontext", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, af)
+	assert.False(t, af.Ignores(&actionTestRecord{action: "query"}))
+}
+
+func TestActionFilterInvalidRegexp(t *testing.T) {
+	_, err := NewActionFilter("kontext", []string{"/[a-/"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import "net"
+
+var (
+	ipv4AnonymMask = net.CIDRMask(24, 32)
+	ipv6AnonymMask = net.CIDRMask(48, 128)
+)
+
+// IPAnonymizer describes an output record able to replace
+// its stored client IP address(es) with an anonymized variant
+// (see AnonymizeIP).
+type IPAnonymizer interface {
+	AnonymizeIP()
+}
+
+// AnonymizeIP masks the host part of an IP address - for IPv4,
+// the last octet is zeroed, for IPv6, the last 80 bits are zeroed.
+// Values which are not valid IP addresses are returned as empty
+// strings to make sure no identifying information leaks.
+func AnonymizeIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(ipv4AnonymMask).String()
+	}
+	return parsed.Mask(ipv6AnonymMask).String()
+}
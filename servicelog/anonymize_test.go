@@ -0,0 +1,39 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymizeIPv4(t *testing.T) {
+	assert.Equal(t, "192.168.1.0", AnonymizeIP("192.168.1.10"))
+	assert.Equal(t, "10.0.0.0", AnonymizeIP("10.0.0.255"))
+}
+
+func TestAnonymizeIPv6(t *testing.T) {
+	assert.Equal(t, "2001:db8:85a3::", AnonymizeIP("2001:db8:85a3:8d3:1319:8a2e:370:7348"))
+	assert.Equal(t, "::", AnonymizeIP("::1"))
+}
+
+func TestAnonymizeIPInvalid(t *testing.T) {
+	assert.Equal(t, "", AnonymizeIP(""))
+	assert.Equal(t, "", AnonymizeIP("<nil>"))
+	assert.Equal(t, "", AnonymizeIP("foo"))
+}
@@ -69,6 +69,11 @@ func (rec *InputRecord) GetUserAgent() string {
 	return rec.UserAgent
 }
 
+// GetProcTime returns the logged processing time
+func (rec *InputRecord) GetProcTime() float64 {
+	return rec.ProcTime
+}
+
 // IsProcessable declares whether the log record matches
 // the type we are interested in (i.e. "access log").
 // For APIGuard we also accept older records without 'accessLog==true'
@@ -76,3 +76,9 @@ func (cnkr *OutputRecord) SetLocation(countryName string, latitude float32, long
 	cnkr.GeoIP.Location[1] = cnkr.GeoIP.Latitude
 	cnkr.GeoIP.Timezone = timezone
 }
+
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (cnkr *OutputRecord) AnonymizeIP() {
+	cnkr.IPAddress = servicelog.AnonymizeIP(cnkr.IPAddress)
+	cnkr.GeoIP.IP = servicelog.AnonymizeIP(cnkr.GeoIP.IP)
+}
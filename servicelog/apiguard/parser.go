@@ -17,20 +17,20 @@
 package apiguard
 
 import (
-	"encoding/json"
 	"fmt"
 	"klogproc/servicelog"
 )
 
 // LineParser is a parser for reading APIGuard application logs
 type LineParser struct {
+	mode servicelog.ParsingMode
 }
 
 // ParseLine parses a query log line - i.e. it expects
 // that the line contains user interaction log
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	var record InputRecord
-	err := json.Unmarshal([]byte(s), &record)
+	err := servicelog.UnmarshalRecord([]byte(s), &record, lp.mode)
 	if err != nil {
 		return nil, servicelog.NewStreamedLineParsingError(s, fmt.Sprintf("failed to parse line: %s", err))
 	}
@@ -38,6 +38,6 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 }
 
 // NewLineParser is a factory for LineParser
-func NewLineParser() *LineParser {
-	return &LineParser{}
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
 }
@@ -0,0 +1,115 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// ClientIPHopFirst selects the first (leftmost) entry
+	// of a comma-separated list of addresses
+	ClientIPHopFirst = "first"
+
+	// ClientIPHopLast selects the last (rightmost) entry
+	// of a comma-separated list of addresses
+	ClientIPHopLast = "last"
+
+	// DefaultClientIPHeader is a header used when ClientIPConf
+	// does not specify one
+	DefaultClientIPHeader = "HTTP_X_FORWARDED_FOR"
+)
+
+// ClientIPConf specifies which request header is trusted to contain
+// a client IP address. This is useful e.g. behind load balancers
+// appending the address of the previous hop to a forwarded-for chain
+// (where the real client is the last entry).
+type ClientIPConf struct {
+
+	// Header is a name of the trusted header as logged by the application
+	// (e.g. `HTTP_X_FORWARDED_FOR`, `HTTP_REMOTE_ADDR`, `REMOTE_ADDR`).
+	// The default is `HTTP_X_FORWARDED_FOR`.
+	Header string `json:"header"`
+
+	// Hop specifies which entry of a comma-separated list of addresses
+	// is used (`first` - default, `last`)
+	Hop string `json:"hop"`
+}
+
+// Validate tests whether the configuration is valid. A nil
+// configuration is valid (the default precedence is used).
+func (conf *ClientIPConf) Validate() error {
+	if conf == nil {
+		return nil
+	}
+	if conf.Hop != "" && conf.Hop != ClientIPHopFirst && conf.Hop != ClientIPHopLast {
+		return fmt.Errorf("invalid clientIp.hop value: %s", conf.Hop)
+	}
+	return nil
+}
+
+// HeaderName returns the trusted header (applying the default value)
+func (conf *ClientIPConf) HeaderName() string {
+	if conf.Header == "" {
+		return DefaultClientIPHeader
+	}
+	return conf.Header
+}
+
+// ClientIPConfReceiver is an optional interface of InputRecord
+// types able to determine a client IP based on ClientIPConf.
+type ClientIPConfReceiver interface {
+	SetClientIPConf(conf *ClientIPConf)
+}
+
+// ParseIPList parses a single IP address or a comma-separated list
+// of addresses (e.g. an X-Forwarded-For chain) and returns the entry
+// specified by `hop` (see ClientIPHop* constants). In case the entry
+// is not a valid address, nil is returned.
+func ParseIPList(v string, hop string) net.IP {
+	if hop == ClientIPHopLast {
+		if i := strings.LastIndexByte(v, ','); i >= 0 {
+			v = v[i+1:]
+		}
+
+	} else if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	return net.ParseIP(strings.TrimSpace(v))
+}
+
+// ResolveClientIP determines a client IP address. If `conf` is set,
+// the configured header (obtained via `getHeader`) is tried first.
+// Then the `defaults` values are tried in the provided order (taking
+// the first entry of a possible list). Empty or invalid values fall
+// through to the next source. If no valid address is found, nil
+// is returned.
+func ResolveClientIP(conf *ClientIPConf, getHeader func(name string) string, defaults ...string) net.IP {
+	if conf != nil {
+		if ip := ParseIPList(getHeader(conf.HeaderName()), conf.Hop); ip != nil {
+			return ip
+		}
+	}
+	for _, v := range defaults {
+		if ip := ParseIPList(v, ClientIPHopFirst); ip != nil {
+			return ip
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIPList(t *testing.T) {
+	assert.Equal(t, net.ParseIP("10.0.0.1"), ParseIPList("10.0.0.1", ClientIPHopFirst))
+	assert.Equal(t, net.ParseIP("10.0.0.1"), ParseIPList("10.0.0.1", ClientIPHopLast))
+	assert.Equal(t, net.ParseIP("10.0.0.1"), ParseIPList("10.0.0.1, 192.168.1.1", ""))
+	assert.Equal(t, net.ParseIP("192.168.1.1"), ParseIPList("10.0.0.1, 192.168.1.1", ClientIPHopLast))
+	assert.Nil(t, ParseIPList("10.0.0.1, unknown", ClientIPHopLast))
+	assert.Nil(t, ParseIPList("", ClientIPHopFirst))
+}
+
+func TestResolveClientIPDefaults(t *testing.T) {
+	getHeader := func(name string) string { return "" }
+	assert.Equal(t, net.ParseIP("10.0.0.2"), ResolveClientIP(nil, getHeader, "", "invalid", "10.0.0.2"))
+	assert.Nil(t, ResolveClientIP(nil, getHeader, "", "invalid"))
+}
+
+func TestResolveClientIPConfigured(t *testing.T) {
+	headers := map[string]string{
+		"HTTP_X_FORWARDED_FOR": "1.2.3.4, 10.0.0.1, 172.16.0.5",
+		"REMOTE_ADDR":          "10.0.0.9",
+	}
+	getHeader := func(name string) string { return headers[name] }
+	conf := &ClientIPConf{Hop: ClientIPHopLast}
+	assert.Equal(t, net.ParseIP("172.16.0.5"), ResolveClientIP(conf, getHeader, headers["REMOTE_ADDR"]))
+	conf = &ClientIPConf{Header: "HTTP_X_REAL_IP"}
+	assert.Equal(t, net.ParseIP("10.0.0.9"), ResolveClientIP(conf, getHeader, headers["REMOTE_ADDR"]))
+}
+
+func TestClientIPConfValidate(t *testing.T) {
+	var conf *ClientIPConf
+	assert.NoError(t, conf.Validate())
+	assert.NoError(t, (&ClientIPConf{Hop: ClientIPHopLast}).Validate())
+	assert.Error(t, (&ClientIPConf{Hop: "middle"}).Validate())
+}
@@ -17,8 +17,11 @@
 package servicelog
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"klogproc/logbuffer"
 	"net"
@@ -91,6 +94,10 @@ const (
 
 	// AppTypeMquerySRU defines a universal storage identifier for Mquery-SRU
 	AppTypeMquerySRU = "mquery-sru"
+
+	// AppTypeNginxJSON defines a universal storage identifier for
+	// generic nginx JSON access logs
+	AppTypeNginxJSON = "nginx-json"
 )
 
 type ServiceLogBuffer logbuffer.AbstractRecentRecords[InputRecord, logbuffer.SerializableState]
@@ -112,6 +119,10 @@ func (m LineParsingError) Error() string {
 	return fmt.Sprintf("%s: LineParsingError at line %d", m.Message, m.LineNumber)
 }
 
+// ErrEmptyLine is returned by line parsers for lines which
+// contain no record and should be silently skipped
+var ErrEmptyLine = errors.New("empty line")
+
 // NewLineParsingError is a constructor for LineParsingError
 func NewLineParsingError(lineNumber int64, message string) LineParsingError {
 	return LineParsingError{LineNumber: lineNumber, Message: message}
@@ -187,6 +198,19 @@ type GeoDataRecord struct {
 	Timezone      string     `json:"timezone"`
 }
 
+// FramingMetadata contains information extracted from an envelope
+// (e.g. a syslog header) the actual log record was wrapped in
+type FramingMetadata struct {
+	Timestamp time.Time
+}
+
+// FramingMetadataReceiver is an optional interface of InputRecord
+// types able to use FramingMetadata as a fallback in case
+// the record itself lacks some information.
+type FramingMetadataReceiver interface {
+	SetFramingMetadata(meta FramingMetadata)
+}
+
 // OutputRecord describes a common behavior for records ready to
 // be stored to the storage with a defined type. Implementation
 // details are up to concrete implementations but these functions are
@@ -220,30 +244,135 @@ type LogRange struct {
 	Inode     int64 `json:"inode"`
 	SeekStart int64 `json:"seekStart"`
 	SeekEnd   int64 `json:"seekEnd"`
-	Written   bool  `json:"written"`
+
+	// Line is a (1-based) number of the line starting at SeekStart.
+	// Zero means the value is unknown (e.g. older worklogs).
+	Line    int64 `json:"line"`
+	Written bool  `json:"written"`
 }
 
 func (p LogRange) String() string {
-	return fmt.Sprintf("LogRange{Inode: %d, Seek: %d-%d, Written: %t}",
-		p.Inode, p.SeekStart, p.SeekEnd, p.Written)
+	return fmt.Sprintf("LogRange{Inode: %d, Seek: %d-%d, Line: %d, Written: %t}",
+		p.Inode, p.SeekStart, p.SeekEnd, p.Line, p.Written)
 }
 
+// RawInputField is a name of the field containing an original
+// log line (in case storing of raw input is enabled)
+const RawInputField = "rawInput"
+
 type BoundOutputRecord struct {
 	Rec      OutputRecord
 	FilePos  LogRange
 	FilePath string
+
+	// InstanceID is an optional identifier of a klogproc
+	// instance (node) which is stamped onto the stored data
+	InstanceID string
+
+	// InstanceIDInRecID specifies whether InstanceID should be
+	// incorporated into the record ID (see GetID())
+	InstanceIDInRecID bool
+
+	// IDStrategy specifies how GetID() creates the ID
+	// (by default, the ID of the wrapped record is used)
+	IDStrategy RecordIDStrategy
+
+	// OutputIdx is an index of the record among records created
+	// out of a single log line (used by RecordIDStrategyFileOffset)
+	OutputIdx int
+
+	// IDSourceKey optionally distinguishes source files of the same
+	// name (used by RecordIDStrategyFileOffset)
+	IDSourceKey string
+
+	// RawInput is an optional original log line the record
+	// has been created from
+	RawInput string
 }
 
+// ToJSON serializes the wrapped record. In case InstanceID is set,
+// it is added as the `instanceId` field. In case RawInput is set,
+// it is added as the `rawInput` field.
 func (r *BoundOutputRecord) ToJSON() ([]byte, error) {
-	return r.Rec.ToJSON()
+	data, err := r.Rec.ToJSON()
+	if err != nil {
+		return data, err
+	}
+	if r.RawInput != "" {
+		data, err = prependJSONField(data, RawInputField, r.RawInput)
+		if err != nil {
+			return data, err
+		}
+	}
+	if r.InstanceID == "" {
+		return data, nil
+	}
+	return prependJSONField(data, "instanceId", r.InstanceID)
+}
+
+// prependJSONField adds a field to a JSON-encoded object
+func prependJSONField(data []byte, key string, value any) ([]byte, error) {
+	if len(data) < 2 || data[0] != '{' {
+		return nil, fmt.Errorf("cannot add %s to a non-object record", key)
+	}
+	encKey, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	encValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	ans := make([]byte, 0, len(data)+len(encKey)+len(encValue)+2)
+	ans = append(ans, '{')
+	ans = append(ans, encKey...)
+	ans = append(ans, ':')
+	ans = append(ans, encValue...)
+	if len(bytes.TrimSpace(data[1:len(data)-1])) > 0 {
+		ans = append(ans, ',')
+	}
+	return append(ans, data[1:]...), nil
+}
+
+// ToInfluxDB creates tags and values of the wrapped record.
+// In case InstanceID is set, it is added as the `instanceId` tag.
+func (r *BoundOutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
+	tags, values = r.Rec.ToInfluxDB()
+	if r.InstanceID != "" {
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags["instanceId"] = r.InstanceID
+	}
+	return
+}
+
+func (r *BoundOutputRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+	r.Rec.SetLocation(countryName, latitude, longitude, timezone)
 }
 
 func (r *BoundOutputRecord) GetTime() time.Time {
 	return r.Rec.GetTime()
 }
 
+// GetID returns ID of the wrapped record or an ID based on the record's
+// file position (see IDStrategy). In case InstanceIDInRecID
+// is set (and InstanceID is not empty), the ID is derived from both
+// the ID and the instance ID so records from different
+// instances do not collide.
 func (r *BoundOutputRecord) GetID() string {
-	return r.Rec.GetID()
+	var id string
+	if r.IDStrategy == RecordIDStrategyFileOffset {
+		id = fileOffsetID(r.IDSourceKey, r.FilePath, r.FilePos.SeekStart, r.OutputIdx)
+
+	} else {
+		id = r.Rec.GetID()
+	}
+	if !r.InstanceIDInRecID || r.InstanceID == "" || id == "" {
+		return id
+	}
+	sum := sha1.Sum([]byte(r.InstanceID + "#" + id))
+	return hex.EncodeToString(sum[:])
 }
 
 func (r *BoundOutputRecord) GetType() string {
@@ -266,7 +395,10 @@ type LogItemTransformer interface {
 
 	Preprocess(rec InputRecord, prevRecs ServiceLogBuffer) []InputRecord
 
-	Transform(logRec InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (OutputRecord, error)
+	// Transform creates output records out of an input record. Typically,
+	// a single record is produced but some transformers may add more
+	// (e.g. aggregates). All the produced records share the input's position.
+	Transform(logRec InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]OutputRecord, error)
 }
 
 // AppErrorRegister describes a type which reacts to logged errors
@@ -286,6 +418,14 @@ type AppErrorRegister interface {
 	Reset()
 }
 
+// LineOutcomeRegister is an optional extension of AppErrorRegister
+// for registers reacting also to results of individual lines
+// processing (e.g. to evaluate a ratio of failed lines).
+type LineOutcomeRegister interface {
+	OnLineProcessed()
+	OnLineFailed()
+}
+
 // UserBelongsToList tests whether a provided user can be
 // found in a provided array of users.
 func UserBelongsToList[T int | string](userID T, anonymousUsers []int) bool {
@@ -342,3 +482,14 @@ func (elist ExcludeIPList) Excludes(rec InputRecord) bool {
 	}
 	return excludes
 }
+
+// ConversionActionList represents a list of actions which are
+// considered to be "conversions" (i.e. actions important from
+// the product analytics point of view - e.g. a successful export).
+type ConversionActionList []string
+
+// Contains tests whether a provided action is
+// a "conversion" action
+func (clist ConversionActionList) Contains(action string) bool {
+	return collections.SliceContains(clist, action)
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testOutputRecord struct {
+	Action string `json:"action"`
+}
+
+func (r *testOutputRecord) ToJSON() ([]byte, error) { return json.Marshal(r) }
+func (r *testOutputRecord) GetID() string           { return "1" }
+func (r *testOutputRecord) GetType() string         { return "test" }
+func (r *testOutputRecord) GetTime() time.Time      { return time.Time{} }
+func (r *testOutputRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+
+func (r *testOutputRecord) ToInfluxDB() (map[string]string, map[string]any) {
+	return map[string]string{"action": r.Action}, map[string]any{}
+}
+
+func TestBoundOutputRecordInstanceID(t *testing.T) {
+	rec := &BoundOutputRecord{Rec: &testOutputRecord{Action: "view"}, InstanceID: "node\"1"}
+	data, err := rec.ToJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"instanceId": "node\"1", "action": "view"}`, string(data))
+	tags, _ := rec.ToInfluxDB()
+	assert.Equal(t, map[string]string{"action": "view", "instanceId": "node\"1"}, tags)
+}
+
+func TestBoundOutputRecordRawInput(t *testing.T) {
+	rec := &BoundOutputRecord{Rec: &testOutputRecord{Action: "view"}, RawInput: "a \"raw\" line"}
+	data, err := rec.ToJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"rawInput": "a \"raw\" line", "action": "view"}`, string(data))
+}
+
+func TestBoundOutputRecordInstanceIDInRecID(t *testing.T) {
+	rec1 := &BoundOutputRecord{Rec: &testOutputRecord{}, InstanceID: "node1"}
+	assert.Equal(t, "1", rec1.GetID())
+	rec1.InstanceIDInRecID = true
+	rec2 := &BoundOutputRecord{Rec: &testOutputRecord{}, InstanceID: "node2", InstanceIDInRecID: true}
+	assert.Len(t, rec1.GetID(), 40)
+	assert.NotEqual(t, rec1.GetID(), rec2.GetID())
+	rec2.InstanceID = ""
+	assert.Equal(t, "1", rec2.GetID())
+}
+
+func TestBoundOutputRecordNoInstanceID(t *testing.T) {
+	rec := &BoundOutputRecord{Rec: &testOutputRecord{Action: "view"}}
+	data, err := rec.ToJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"action":"view"}`, string(data))
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EOFMarkerType is a record type of EOFMarker records
+const EOFMarkerType = "eofMarker"
+
+// EOFMarker is a synthetic output record signaling that a log file
+// has been fully read and it has not grown for a configured period.
+// It allows downstream consumers to recognize that the data of
+// a file (written once and then closed) are completely ingested.
+type EOFMarker struct {
+	ID       string `json:"-"`
+	Type     string `json:"type"`
+	AppType  string `json:"appType"`
+	FilePath string `json:"filePath"`
+	Inode    int64  `json:"inode"`
+	Size     int64  `json:"size"`
+	NumLines int64  `json:"numLines"`
+	Datetime string `json:"datetime"`
+	time     time.Time
+}
+
+// SetLocation is a no-op as the marker is not related
+// to any client
+func (m *EOFMarker) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
+
+func (m *EOFMarker) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func (m *EOFMarker) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
+	tags = map[string]string{
+		"type":    m.Type,
+		"appType": m.AppType,
+	}
+	values = map[string]interface{}{
+		"filePath": m.FilePath,
+		"size":     m.Size,
+		"numLines": m.NumLines,
+	}
+	return
+}
+
+func (m *EOFMarker) GetID() string {
+	return m.ID
+}
+
+// GetType returns EOFMarkerType so the marker can be distinguished
+// from the application's regular records
+func (m *EOFMarker) GetType() string {
+	return m.Type
+}
+
+func (m *EOFMarker) GetTime() time.Time {
+	return m.time
+}
+
+// NewEOFMarker creates a marker for a file of a specified app type
+// with pos being the position of the last record in the file.
+// The ID is derived from the file path, its inode and size so repeated
+// markers for an unchanged file have the same ID.
+func NewEOFMarker(appType, filePath string, pos LogRange, t time.Time) *EOFMarker {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s#%s#%d#%d", EOFMarkerType, filePath, pos.Inode, pos.SeekEnd)))
+	return &EOFMarker{
+		ID:       hex.EncodeToString(sum[:]),
+		Type:     EOFMarkerType,
+		AppType:  appType,
+		FilePath: filePath,
+		Inode:    pos.Inode,
+		Size:     pos.SeekEnd,
+		NumLines: pos.Line,
+		Datetime: t.Format(time.RFC3339),
+		time:     t,
+	}
+}
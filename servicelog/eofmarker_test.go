@@ -0,0 +1,46 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEOFMarker(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	pos := LogRange{Inode: 42, SeekStart: 100, SeekEnd: 120, Line: 7}
+	marker := NewEOFMarker("kontext", "/var/log/app.log", pos, now)
+	assert.Equal(t, EOFMarkerType, marker.GetType())
+	assert.Equal(t, now, marker.GetTime())
+	assert.Equal(t, marker.GetID(), NewEOFMarker("kontext", "/var/log/app.log", pos, now.Add(time.Hour)).GetID())
+	pos.SeekEnd = 140
+	assert.NotEqual(t, marker.GetID(), NewEOFMarker("kontext", "/var/log/app.log", pos, now).GetID())
+
+	data, err := marker.ToJSON()
+	assert.NoError(t, err)
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "eofMarker", decoded["type"])
+	assert.Equal(t, "kontext", decoded["appType"])
+	assert.Equal(t, float64(120), decoded["size"])
+	assert.Equal(t, float64(7), decoded["numLines"])
+	assert.Equal(t, "2024-03-01T10:00:00Z", decoded["datetime"])
+}
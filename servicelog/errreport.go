@@ -0,0 +1,111 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ParseErrorReporter aggregates line parsing errors of a single
+// file over a time window and logs just a summary for each window
+// (instead of logging each failed line). In case the window is
+// zero, each error is logged immediately.
+type ParseErrorReporter struct {
+	filePath    string
+	window      time.Duration
+	count       int
+	firstErr    error
+	firstLine   int64
+	lastErr     error
+	lastLine    int64
+	windowStart time.Time
+	now         func() time.Time
+	mutex       sync.Mutex
+}
+
+func (r *ParseErrorReporter) flush() {
+	if r.count == 0 {
+		return
+	}
+	evt := log.Warn().
+		Str("file", r.filePath).
+		Int("count", r.count).
+		Float64("windowSecs", r.window.Seconds()).
+		AnErr("firstError", r.firstErr).
+		Int64("firstLine", r.firstLine)
+	if r.count > 1 {
+		evt = evt.AnErr("lastError", r.lastErr).Int64("lastLine", r.lastLine)
+	}
+	evt.Msg("parsing errors encountered")
+	r.count = 0
+	r.firstErr = nil
+	r.lastErr = nil
+}
+
+// Report registers a parsing error of a line. In case the current
+// window has expired, a summary of the window is logged first.
+func (r *ParseErrorReporter) Report(err error, lineNum int64) {
+	if r.window == 0 {
+		log.Warn().Err(err).Int64("line", lineNum).Msgf("parsing error in file %s", r.filePath)
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := r.now()
+	if r.count > 0 && now.Sub(r.windowStart) >= r.window {
+		r.flush()
+	}
+	if r.count == 0 {
+		r.windowStart = now
+		r.firstErr = err
+		r.firstLine = lineNum
+	}
+	r.lastErr = err
+	r.lastLine = lineNum
+	r.count++
+}
+
+// FlushExpired logs a summary of the current window
+// in case the window has already expired.
+func (r *ParseErrorReporter) FlushExpired() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.count > 0 && r.now().Sub(r.windowStart) >= r.window {
+		r.flush()
+	}
+}
+
+// Flush logs a summary of the current window (if any errors
+// were registered) no matter whether the window has expired.
+func (r *ParseErrorReporter) Flush() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.flush()
+}
+
+// NewParseErrorReporter creates a new reporter for a file
+// with the provided aggregation window
+func NewParseErrorReporter(filePath string, windowSecs int) *ParseErrorReporter {
+	return &ParseErrorReporter{
+		filePath: filePath,
+		window:   time.Duration(windowSecs) * time.Second,
+		now:      time.Now,
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseErrorReporterWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	r := NewParseErrorReporter("/var/log/app.log", 60)
+	r.now = func() time.Time { return now }
+
+	r.Report(errors.New("err1"), 10)
+	now = now.Add(10 * time.Second)
+	r.Report(errors.New("err2"), 11)
+	r.Report(errors.New("err3"), 12)
+	assert.Equal(t, 3, r.count)
+	assert.Equal(t, int64(10), r.firstLine)
+	assert.Equal(t, int64(12), r.lastLine)
+
+	r.FlushExpired()
+	assert.Equal(t, 3, r.count)
+
+	now = now.Add(60 * time.Second)
+	r.Report(errors.New("err4"), 20)
+	assert.Equal(t, 1, r.count)
+	assert.Equal(t, int64(20), r.firstLine)
+
+	r.Flush()
+	assert.Equal(t, 0, r.count)
+}
+
+func TestParseErrorReporterNoWindow(t *testing.T) {
+	r := NewParseErrorReporter("/var/log/app.log", 0)
+	r.Report(errors.New("err1"), 10)
+	assert.Equal(t, 0, r.count)
+}
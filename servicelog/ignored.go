@@ -0,0 +1,81 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"sync"
+)
+
+// IgnoredReason describes why an input line has not produced
+// any output record
+type IgnoredReason string
+
+const (
+	IgnoredReasonEmptyLine      IgnoredReason = "emptyLine"
+	IgnoredReasonParseError     IgnoredReason = "parseError"
+	IgnoredReasonTransformError IgnoredReason = "transformError"
+	IgnoredReasonNotProcessable IgnoredReason = "notProcessable"
+	IgnoredReasonBot            IgnoredReason = "bot"
+	IgnoredReasonOutOfTimeRange IgnoredReason = "outOfTimeRange"
+	IgnoredReasonExcluded       IgnoredReason = "excluded"
+	IgnoredReasonActionFiltered IgnoredReason = "actionFiltered"
+	IgnoredReasonSampledOut     IgnoredReason = "sampledOut"
+)
+
+// IgnoredCounts contains numbers of ignored lines by reason
+type IgnoredCounts map[IgnoredReason]int64
+
+// Add adds all the counts from other
+func (ic IgnoredCounts) Add(other IgnoredCounts) {
+	for k, v := range other {
+		ic[k] += v
+	}
+}
+
+// Total returns a total number of ignored lines
+func (ic IgnoredCounts) Total() int64 {
+	var ans int64
+	for _, v := range ic {
+		ans += v
+	}
+	return ans
+}
+
+// IgnoredCounter is a concurrency-safe counter of ignored lines
+type IgnoredCounter struct {
+	counts IgnoredCounts
+	mutex  sync.Mutex
+}
+
+// Inc increments a count of the reason
+func (c *IgnoredCounter) Inc(reason IgnoredReason) {
+	c.mutex.Lock()
+	if c.counts == nil {
+		c.counts = make(IgnoredCounts)
+	}
+	c.counts[reason]++
+	c.mutex.Unlock()
+}
+
+// Counts returns a copy of the current counts
+func (c *IgnoredCounter) Counts() IgnoredCounts {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ans := make(IgnoredCounts)
+	ans.Add(c.counts)
+	return ans
+}
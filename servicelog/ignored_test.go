@@ -0,0 +1,37 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoredCounter(t *testing.T) {
+	var c IgnoredCounter
+	assert.Empty(t, c.Counts())
+	c.Inc(IgnoredReasonBot)
+	c.Inc(IgnoredReasonBot)
+	c.Inc(IgnoredReasonExcluded)
+	counts := c.Counts()
+	assert.Equal(t, IgnoredCounts{IgnoredReasonBot: 2, IgnoredReasonExcluded: 1}, counts)
+
+	counts.Add(IgnoredCounts{IgnoredReasonBot: 1, IgnoredReasonEmptyLine: 4})
+	assert.Equal(t, int64(8), counts.Total())
+	assert.Equal(t, int64(2), c.Counts()[IgnoredReasonBot])
+}
@@ -25,7 +25,8 @@ import (
 
 // Transformer converts a source log object into a destination one
 type Transformer struct {
-	ExcludeIPList servicelog.ExcludeIPList
+	ExcludeIPList     servicelog.ExcludeIPList
+	ConversionActions servicelog.ConversionActionList
 }
 
 // Transform creates a new OutputRecord out of an existing InputRecord
@@ -42,6 +43,7 @@ func (t *Transformer) Transform(logRecord *InputRecord, recType string, tzShiftM
 		IsAnonymous:    servicelog.UserBelongsToList(logRecord.UserID, anonymousUsers),
 		IsQuery:        isEntryQuery(logRecord.Action),
 		Limited:        fullCorpname.limited,
+		IsConversion:   t.ConversionActions.Contains(logRecord.Action),
 		ProcTime:       logRecord.ProcTime,
 		QueryType:      importQueryType(logRecord),
 		UserAgent:      logRecord.Request.HTTPUserAgent,
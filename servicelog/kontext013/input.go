@@ -65,6 +65,20 @@ type Request struct {
 	RemoteAddr       string `json:"REMOTE_ADDR"`
 }
 
+// headerValue returns a value of a request header specified
+// by its logged name (e.g. HTTP_X_FORWARDED_FOR)
+func (r *Request) headerValue(name string) string {
+	switch name {
+	case "HTTP_X_FORWARDED_FOR":
+		return r.HTTPForwardedFor
+	case "HTTP_REMOTE_ADDR":
+		return r.HTTPRemoteAddr
+	case "REMOTE_ADDR":
+		return r.RemoteAddr
+	}
+	return ""
+}
+
 // ------------------------------------------------------------
 
 // ErrorRecord specifies a thrown error along with
@@ -79,15 +93,16 @@ type ErrorRecord struct {
 
 // InputRecord represents a parsed KonText record
 type InputRecord struct {
-	UserID   int                    `json:"user_id"`
-	ProcTime float32                `json:"proc_time"`
-	Date     string                 `json:"date"`
-	Action   string                 `json:"action"`
-	Request  Request                `json:"request"`
-	Params   map[string]interface{} `json:"params"`
-	PID      int                    `json:"pid"`
-	Settings map[string]interface{} `json:"settings"`
-	Error    ErrorRecord            `json:"error"`
+	UserID       int                    `json:"user_id"`
+	ProcTime     float32                `json:"proc_time"`
+	Date         string                 `json:"date"`
+	Action       string                 `json:"action"`
+	Request      Request                `json:"request"`
+	Params       map[string]interface{} `json:"params"`
+	PID          int                    `json:"pid"`
+	Settings     map[string]interface{} `json:"settings"`
+	Error        ErrorRecord            `json:"error"`
+	clientIPConf *servicelog.ClientIPConf
 }
 
 // GetTime returns record's time as a Golang's Time
@@ -101,16 +116,19 @@ func (rec *InputRecord) GetTime() time.Time {
 // part of the record it was found
 // (e.g. REMOTE_ADDR vs. HTTP_REMOTE_ADDR vs. HTTP_FORWARDED_FOR)
 func (rec *InputRecord) GetClientIP() net.IP {
-	if rec.Request.HTTPForwardedFor != "" {
-		return net.ParseIP(rec.Request.HTTPForwardedFor)
-
-	} else if rec.Request.HTTPRemoteAddr != "" {
-		return net.ParseIP(rec.Request.HTTPRemoteAddr)
-
-	} else if rec.Request.RemoteAddr != "" {
-		return net.ParseIP(rec.Request.RemoteAddr)
+	ip := servicelog.ResolveClientIP(
+		rec.clientIPConf, rec.Request.headerValue,
+		rec.Request.HTTPForwardedFor, rec.Request.HTTPRemoteAddr, rec.Request.RemoteAddr)
+	if ip == nil {
+		return make([]byte, 0)
 	}
-	return make([]byte, 0)
+	return ip
+}
+
+// SetClientIPConf specifies how the client IP is determined
+// (see servicelog.ClientIPConfReceiver)
+func (rec *InputRecord) SetClientIPConf(conf *servicelog.ClientIPConf) {
+	rec.clientIPConf = conf
 }
 
 func (rec *InputRecord) ClusteringClientID() string {
@@ -129,6 +147,11 @@ func (rec *InputRecord) GetUserAgent() string {
 	return rec.Request.HTTPUserAgent
 }
 
+// GetProcTime returns the logged processing time
+func (rec *InputRecord) GetProcTime() float64 {
+	return float64(rec.ProcTime)
+}
+
 // IsProcessable returns true if there was no error in reading the record
 func (rec *InputRecord) IsProcessable() bool {
 	return true
@@ -181,3 +204,26 @@ func (rec *InputRecord) GetAlignedCorpora() []string {
 func (rec *InputRecord) IsSuspicious() bool {
 	return false
 }
+
+// spilledInputRecord extends the record with its internal state
+// so it survives being moved to a log buffer spill segment
+type spilledInputRecord struct {
+	*InputRecord
+	ClientIPConf *servicelog.ClientIPConf `json:"_clientIPConf"`
+}
+
+// MarshalSpill encodes the record including its internal state
+// (see logbuffer.Spillable)
+func (rec *InputRecord) MarshalSpill() ([]byte, error) {
+	return json.Marshal(spilledInputRecord{InputRecord: rec, ClientIPConf: rec.clientIPConf})
+}
+
+// UnmarshalSpill decodes the record encoded by MarshalSpill
+func (rec *InputRecord) UnmarshalSpill(data []byte) error {
+	tmp := spilledInputRecord{InputRecord: rec}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	rec.clientIPConf = tmp.ClientIPConf
+	return nil
+}
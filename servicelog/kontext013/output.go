@@ -83,6 +83,7 @@ type OutputRecord struct {
 	IPAddress      string                   `json:"ipAddress"`
 	IsAnonymous    bool                     `json:"isAnonymous"`
 	IsQuery        bool                     `json:"isQuery"`
+	IsConversion   bool                     `json:"isConversion"`
 	Limited        bool                     `json:"limited"`
 	ProcTime       float32                  `json:"procTime"`
 	QueryType      string                   `json:"queryType"`
@@ -97,6 +98,11 @@ func (cnkr *OutputRecord) ToJSON() ([]byte, error) {
 	return json.Marshal(cnkr)
 }
 
+// GetAction returns the logged action
+func (cnkr *OutputRecord) GetAction() string {
+	return cnkr.Action
+}
+
 func (cnkr *OutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
 	tags = make(map[string]string)
 	values = make(map[string]interface{})
@@ -133,6 +139,12 @@ func (cnkr *OutputRecord) SetLocation(countryName string, latitude float32, long
 	cnkr.GeoIP.Timezone = timezone
 }
 
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (cnkr *OutputRecord) AnonymizeIP() {
+	cnkr.IPAddress = servicelog.AnonymizeIP(cnkr.IPAddress)
+	cnkr.GeoIP.IP = servicelog.AnonymizeIP(cnkr.GeoIP.IP)
+}
+
 type fullCorpname struct {
 	Corpname string
 	limited  bool
@@ -35,7 +35,8 @@ func exportArgs(data map[string]interface{}) map[string]interface{} {
 
 // Transformer converts a source log object into a destination one
 type Transformer struct {
-	ExcludeIPList servicelog.ExcludeIPList
+	ExcludeIPList     servicelog.ExcludeIPList
+	ConversionActions servicelog.ConversionActionList
 }
 
 // Transform creates a new OutputRecord out of an existing InputRecord
@@ -51,6 +52,7 @@ func (t *Transformer) Transform(logRecord *InputRecord, recType string, tzShiftM
 		IPAddress:      logRecord.GetClientIP().String(),
 		IsAnonymous:    servicelog.UserBelongsToList(logRecord.UserID, anonymousUsers),
 		IsQuery:        isEntryQuery(logRecord.Action) && !logRecord.IsIndirectCall,
+		IsConversion:   t.ConversionActions.Contains(logRecord.Action),
 		ProcTime:       logRecord.ProcTime,
 		QueryType:      importQueryType(logRecord),
 		UserAgent:      logRecord.Request.HTTPUserAgent,
@@ -63,6 +63,7 @@ type OutputRecord struct {
 	IPAddress      string                   `json:"ipAddress"`
 	IsAnonymous    bool                     `json:"isAnonymous"`
 	IsQuery        bool                     `json:"isQuery"`
+	IsConversion   bool                     `json:"isConversion"`
 	ProcTime       float32                  `json:"procTime"`
 	QueryType      string                   `json:"queryType"`
 	UserAgent      string                   `json:"userAgent"`
@@ -77,6 +78,11 @@ func (cnkr *OutputRecord) ToJSON() ([]byte, error) {
 	return json.Marshal(cnkr)
 }
 
+// GetAction returns the logged action
+func (cnkr *OutputRecord) GetAction() string {
+	return cnkr.Action
+}
+
 func (cnkr *OutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
 	tags = make(map[string]string)
 	values = make(map[string]interface{})
@@ -113,6 +119,12 @@ func (cnkr *OutputRecord) SetLocation(countryName string, latitude float32, long
 	cnkr.GeoIP.Timezone = timezone
 }
 
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (cnkr *OutputRecord) AnonymizeIP() {
+	cnkr.IPAddress = servicelog.AnonymizeIP(cnkr.IPAddress)
+	cnkr.GeoIP.IP = servicelog.AnonymizeIP(cnkr.GeoIP.IP)
+}
+
 func createID(cnkr *OutputRecord) string {
 	str := cnkr.Action + cnkr.Corpus + cnkr.Datetime + cnkr.IPAddress +
 		cnkr.Type + cnkr.UserAgent + cnkr.UserID
@@ -0,0 +1,101 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kontext018
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// AggregateTypeSuffix is appended to the type of the source record
+	// to distinguish aggregate records (e.g. `kontext_aggregate`).
+	AggregateTypeSuffix = "_aggregate"
+
+	aggregateDayFormat = "2006-01-02"
+)
+
+// QueryAggregateRecord is a lightweight record derived from an OutputRecord
+// and keyed by corpus, action and day. Each instance represents a single
+// query (Count = 1) so downstream storages can sum them up.
+type QueryAggregateRecord struct {
+	ID       string `json:"-"`
+	Type     string `json:"type"`
+	Corpus   string `json:"corpus"`
+	Action   string `json:"action"`
+	Day      string `json:"day"`
+	Datetime string `json:"datetime"`
+	Count    int    `json:"count"`
+	datetime time.Time
+}
+
+// NewQueryAggregateRecord creates an aggregate record for the provided
+// output record. The ID is derived from the source record's ID so
+// the aggregate record is as idempotent as its source.
+func NewQueryAggregateRecord(rec *OutputRecord) *QueryAggregateRecord {
+	sum := sha1.Sum([]byte(rec.ID + AggregateTypeSuffix))
+	return &QueryAggregateRecord{
+		ID:       hex.EncodeToString(sum[:]),
+		Type:     rec.Type + AggregateTypeSuffix,
+		Corpus:   rec.Corpus,
+		Action:   rec.Action,
+		Day:      rec.datetime.Format(aggregateDayFormat),
+		Datetime: rec.Datetime,
+		Count:    1,
+		datetime: rec.datetime,
+	}
+}
+
+// ToJSON converts self to JSON string
+func (qar *QueryAggregateRecord) ToJSON() ([]byte, error) {
+	return json.Marshal(qar)
+}
+
+// GetAction returns the logged action
+func (qar *QueryAggregateRecord) GetAction() string {
+	return qar.Action
+}
+
+func (qar *QueryAggregateRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
+	tags = make(map[string]string)
+	values = make(map[string]interface{})
+	tags["corpname"] = qar.Corpus
+	tags["action"] = qar.Action
+	tags["day"] = qar.Day
+	values["count"] = qar.Count
+	return
+}
+
+func (qar *QueryAggregateRecord) GetID() string {
+	return qar.ID
+}
+
+func (qar *QueryAggregateRecord) GetType() string {
+	return qar.Type
+}
+
+// GetTime returns Go Time instance representing
+// date and time when the source record was created.
+func (qar *QueryAggregateRecord) GetTime() time.Time {
+	return qar.datetime
+}
+
+// SetLocation does nothing as aggregate records are not bound to a client
+func (qar *QueryAggregateRecord) SetLocation(countryName string, latitude float32, longitude float32, timezone string) {
+}
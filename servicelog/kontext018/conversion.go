@@ -17,12 +17,14 @@
 package kontext018
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
 	"klogproc/analysis"
 	"klogproc/load"
 	"klogproc/notifications"
+	"klogproc/scripting"
 	"klogproc/servicelog"
 )
 
@@ -38,8 +40,67 @@ func exportArgs(data map[string]interface{}) map[string]interface{} {
 
 // Transformer converts a source log object into a destination one
 type Transformer struct {
-	analyzer      *analysis.BotAnalyzer[*QueryInputRecord]
-	ExcludeIPList servicelog.ExcludeIPList
+	analyzer          *analysis.BotAnalyzer[*QueryInputRecord]
+	scriptEngine      *scripting.Engine
+	ExcludeIPList     servicelog.ExcludeIPList
+	ConversionActions servicelog.ConversionActionList
+}
+
+// SetOutputProperty sets an output record property by its JSON name.
+// Only some of the standard properties can be changed, other names are
+// stored as custom properties (OutputRecord.Custom).
+func (t *Transformer) SetOutputProperty(rec *OutputRecord, name string, value any) error {
+	switch name {
+	case "corpus", "queryType", "userId":
+		v, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("property %s must be a string, got %T", name, value)
+		}
+		switch name {
+		case "corpus":
+			rec.Corpus = v
+		case "queryType":
+			rec.QueryType = v
+		case "userId":
+			rec.UserID = v
+		}
+	case "isQuery", "isAnonymous", "isConversion":
+		v, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("property %s must be a boolean, got %T", name, value)
+		}
+		switch name {
+		case "isQuery":
+			rec.IsQuery = v
+		case "isAnonymous":
+			rec.IsAnonymous = v
+		case "isConversion":
+			rec.IsConversion = v
+		}
+	case "type", "action", "datetime", "ipAddress", "geoip", "error", "args", "traceId":
+		return fmt.Errorf("property %s is read-only", name)
+	default:
+		if rec.Custom == nil {
+			rec.Custom = make(map[string]any)
+		}
+		rec.Custom[name] = value
+	}
+	return nil
+}
+
+func (t *Transformer) runScript(logRecord *QueryInputRecord, rec *OutputRecord) error {
+	input := map[string]any{
+		"time":     logRecord.GetTime().Format(time.RFC3339),
+		"ip":       logRecord.GetClientIP().String(),
+		"action":   logRecord.Action,
+		"userId":   logRecord.UserID,
+		"procTime": logRecord.ProcTime,
+		"corpus":   rec.Corpus,
+		"args":     logRecord.Args,
+	}
+	return t.scriptEngine.Transform(input, func(name string, value any) error {
+		return t.SetOutputProperty(rec, name, value)
+	})
 }
 
 // Transform creates a new OutputRecord out of an existing InputRecord
@@ -55,14 +116,22 @@ func (t *Transformer) Transform(logRecord *QueryInputRecord, recType string, tzS
 		IPAddress:      logRecord.GetClientIP().String(),
 		IsAnonymous:    servicelog.UserBelongsToList(logRecord.UserID, anonymousUsers),
 		IsQuery:        isEntryQuery(logRecord.Action) && !logRecord.IsIndirectCall,
+		IsConversion:   t.ConversionActions.Contains(logRecord.Action),
 		ProcTime:       logRecord.ProcTime,
 		QueryType:      importQueryType(logRecord),
 		UserAgent:      logRecord.Request.HTTPUserAgent,
 		UserID:         strconv.Itoa(logRecord.UserID),
 		Error:          logRecord.Error,
 		Args:           exportArgs(logRecord.Args),
+		TraceID:        logRecord.GetTraceID(),
+		Level:          logRecord.Level,
 	}
 	r.ID = createID(r)
+	if t.scriptEngine != nil {
+		if err := t.runScript(logRecord, r); err != nil {
+			return nil, err
+		}
+	}
 	return r, nil
 }
 
@@ -83,11 +152,17 @@ func NewTransformer(
 	bufferConf *load.BufferConf,
 	realtimeClock bool,
 	emailNotifier notifications.Notifier,
+	botExporter analysis.BotCandidateExporter,
 	excludeIPList []string,
+	conversionActions []string,
+	scriptEngine *scripting.Engine,
 ) *Transformer {
-	analyzer := analysis.NewBotAnalyzer[*QueryInputRecord]("kontext", bufferConf, realtimeClock, emailNotifier)
+	analyzer := analysis.NewBotAnalyzer[*QueryInputRecord](
+		"kontext", bufferConf, realtimeClock, emailNotifier, botExporter)
 	return &Transformer{
-		analyzer:      analyzer,
-		ExcludeIPList: excludeIPList,
+		analyzer:          analyzer,
+		scriptEngine:      scriptEngine,
+		ExcludeIPList:     excludeIPList,
+		ConversionActions: conversionActions,
 	}
 }
@@ -0,0 +1,101 @@
+// Copyright 2023 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+// Copyright 2023 Martin Zimandl <martin.zimandl@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kontext018
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"klogproc/scripting"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformConversionAction(t *testing.T) {
+	tr := &Transformer{ConversionActions: []string{"/query_submit", "/wordlist/submit"}}
+	rec := &QueryInputRecord{
+		GeneralInputRecord: GeneralInputRecord{Date: "2023-10-11T09:01:02.123456+02:00"},
+		Action:             "/query_submit",
+		isProcessable:      true,
+	}
+	out, err := tr.Transform(rec, "kontext", 0, []int{})
+	assert.NoError(t, err)
+	assert.True(t, out.IsConversion)
+
+	rec.Action = "/view"
+	out, err = tr.Transform(rec, "kontext", 0, []int{})
+	assert.NoError(t, err)
+	assert.False(t, out.IsConversion)
+}
+
+func TestTransformWithScript(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "script.lua")
+	err := os.WriteFile(scriptPath, []byte(`
+function transform(input)
+  if string.sub(input.corpus, 1, 3) == "syn" then
+    set_output_property("corpusGroup", "syn")
+  end
+  set_output_property("queryType", input.args.qtype .. "-x")
+end
+`), 0644)
+	assert.NoError(t, err)
+	engine, err := scripting.NewEngine(scriptPath)
+	assert.NoError(t, err)
+	defer engine.Close()
+	tr := &Transformer{scriptEngine: engine}
+	rec := &QueryInputRecord{
+		GeneralInputRecord: GeneralInputRecord{Date: "2023-10-11T09:01:02.123456+02:00"},
+		Action:             "/query_submit",
+		Args:               map[string]interface{}{"corpname": "syn2020", "qtype": "simple"},
+		isProcessable:      true,
+	}
+	out, err := tr.Transform(rec, "kontext", 0, []int{})
+	assert.NoError(t, err)
+	assert.Equal(t, "syn", out.Custom["corpusGroup"])
+	assert.Equal(t, "simple-x", out.QueryType)
+}
+
+func TestSetOutputPropertyReadOnly(t *testing.T) {
+	tr := &Transformer{}
+	rec := &OutputRecord{}
+	assert.Error(t, tr.SetOutputProperty(rec, "action", "/foo"))
+	assert.Error(t, tr.SetOutputProperty(rec, "isQuery", "yes"))
+	assert.NoError(t, tr.SetOutputProperty(rec, "isQuery", true))
+	assert.True(t, rec.IsQuery)
+}
+
+func TestTransformLevel(t *testing.T) {
+	tr := &Transformer{}
+	rec := &QueryInputRecord{
+		GeneralInputRecord: GeneralInputRecord{Date: "2023-10-11T09:01:02.123456+02:00", Level: "WARNING"},
+		Action:             "/view",
+		isProcessable:      true,
+	}
+	out, err := tr.Transform(rec, "kontext", 0, []int{})
+	assert.NoError(t, err)
+	data, err := out.ToJSON()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"level":"WARNING"`)
+
+	rec.Level = ""
+	out, err = tr.Transform(rec, "kontext", 0, []int{})
+	assert.NoError(t, err)
+	data, err = out.ToJSON()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `"level"`)
+}
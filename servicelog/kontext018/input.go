@@ -17,10 +17,22 @@
 package kontext018
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"klogproc/servicelog"
 	"net"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// clusteringTimeBucket specifies a time granularity for grouping
+	// requests of the same client (see ClusteringClientID)
+	clusteringTimeBucket = time.Hour
 )
 
 func getSliceOfStrings(data interface{}, key string) ([]string, bool) {
@@ -66,6 +78,20 @@ type Request struct {
 	RemoteAddr       string `json:"REMOTE_ADDR"`
 }
 
+// headerValue returns a value of a request header specified
+// by its logged name (e.g. HTTP_X_FORWARDED_FOR)
+func (r *Request) headerValue(name string) string {
+	switch name {
+	case "HTTP_X_FORWARDED_FOR":
+		return r.HTTPForwardedFor
+	case "HTTP_REMOTE_ADDR":
+		return r.HTTPRemoteAddr
+	case "REMOTE_ADDR":
+		return r.RemoteAddr
+	}
+	return ""
+}
+
 // ErrorRecord specifies a thrown error along with
 // optional anchor for easier search within text file
 // log
@@ -85,12 +111,18 @@ type QueryInputRecord struct {
 	Args           map[string]interface{} `json:"args"`
 	Error          ErrorRecord            `json:"error"`
 	isProcessable  bool
+	traceID        string
+	framing        servicelog.FramingMetadata
+	clientIPConf   *servicelog.ClientIPConf
 }
 
 // GetTime returns record's time as a Golang's Time
 // instance. Please note that the value is truncated
 // to seconds.
 func (rec *QueryInputRecord) GetTime() time.Time {
+	if rec.isProcessable && rec.Date == "" {
+		return rec.framing.Timestamp
+	}
 	if rec.isProcessable {
 		if rec.Date[len(rec.Date)-1] == 'Z' {
 			return servicelog.ConvertDatetimeStringWithMillisNoTZ(rec.Date[:len(rec.Date)-1] + "000")
@@ -104,16 +136,15 @@ func (rec *QueryInputRecord) GetTime() time.Time {
 // part of the record it was found
 // (e.g. REMOTE_ADDR vs. HTTP_REMOTE_ADDR vs. HTTP_FORWARDED_FOR)
 func (rec *QueryInputRecord) GetClientIP() net.IP {
-	if rec.Request.HTTPForwardedFor != "" {
-		return net.ParseIP(rec.Request.HTTPForwardedFor)
-
-	} else if rec.Request.HTTPRemoteAddr != "" {
-		return net.ParseIP(rec.Request.HTTPRemoteAddr)
+	return servicelog.ResolveClientIP(
+		rec.clientIPConf, rec.Request.headerValue,
+		rec.Request.HTTPForwardedFor, rec.Request.HTTPRemoteAddr, rec.Request.RemoteAddr)
+}
 
-	} else if rec.Request.RemoteAddr != "" {
-		return net.ParseIP(rec.Request.RemoteAddr)
-	}
-	return nil
+// SetClientIPConf specifies how the client IP is determined
+// (see servicelog.ClientIPConfReceiver)
+func (rec *QueryInputRecord) SetClientIPConf(conf *servicelog.ClientIPConf) {
+	rec.clientIPConf = conf
 }
 
 func (rec *QueryInputRecord) ShouldBeAnalyzed() bool {
@@ -122,8 +153,30 @@ func (rec *QueryInputRecord) ShouldBeAnalyzed() bool {
 	// TODO the list of actions is incomplete
 }
 
+// ClusteringClientID derives a stable client identifier from the client IP,
+// the user agent and a coarse time bucket of the record. This means that
+// reprocessing the same log always yields the same clusters. In case
+// neither the IP nor the user agent are available, a random ID is used
+// so such records are not analyzed as a single client.
 func (rec *QueryInputRecord) ClusteringClientID() string {
-	return servicelog.GenerateRandomClusteringID()
+	var inp string
+	if len(rec.GetClientIP()) > 0 || rec.GetUserAgent() != "" {
+		inp = fmt.Sprintf(
+			"%s#%s#%d",
+			rec.GetClientIP(),
+			rec.GetUserAgent(),
+			rec.GetTime().Truncate(clusteringTimeBucket).Unix(),
+		)
+
+	} else {
+		log.Warn().
+			Str("recDatetime", rec.Date).
+			Str("action", rec.Action).
+			Msg("unable to get a proper clustering client ID - using uuid instead")
+		inp = uuid.New().String()
+	}
+	sum := sha1.Sum([]byte(inp))
+	return hex.EncodeToString(sum[:])
 }
 
 func (rec *QueryInputRecord) ClusterSize() int {
@@ -138,6 +191,34 @@ func (rec *QueryInputRecord) GetUserAgent() string {
 	return rec.Request.HTTPUserAgent
 }
 
+// GetAction returns the logged KonText action
+func (rec *QueryInputRecord) GetAction() string {
+	return rec.Action
+}
+
+// GetArgs returns the original request arguments
+func (rec *QueryInputRecord) GetArgs() map[string]any {
+	return rec.Args
+}
+
+// GetProcTime returns the logged processing time
+func (rec *QueryInputRecord) GetProcTime() float64 {
+	return float64(rec.ProcTime)
+}
+
+// SetFramingMetadata stores information from an envelope
+// the record was wrapped in (e.g. syslog). The values are
+// used as a fallback in case the record lacks them.
+func (rec *QueryInputRecord) SetFramingMetadata(meta servicelog.FramingMetadata) {
+	rec.framing = meta
+}
+
+// GetTraceID returns a trace/correlation ID of the request
+// (if configured and found in the source log)
+func (rec *QueryInputRecord) GetTraceID() string {
+	return rec.traceID
+}
+
 // IsProcessable returns true if there was no error in reading the record
 func (rec *QueryInputRecord) IsProcessable() bool {
 	return rec.isProcessable
@@ -195,3 +276,38 @@ func (rec *QueryInputRecord) GetAlignedCorpora() []string {
 func (rec *QueryInputRecord) IsSuspicious() bool {
 	return false
 }
+
+// spilledQueryInputRecord extends the record with its internal state
+// so it survives being moved to a log buffer spill segment
+type spilledQueryInputRecord struct {
+	*QueryInputRecord
+	IsProcessable bool                       `json:"_isProcessable"`
+	TraceID       string                     `json:"_traceID"`
+	Framing       servicelog.FramingMetadata `json:"_framing"`
+	ClientIPConf  *servicelog.ClientIPConf   `json:"_clientIPConf"`
+}
+
+// MarshalSpill encodes the record including its internal state
+// (see logbuffer.Spillable)
+func (rec *QueryInputRecord) MarshalSpill() ([]byte, error) {
+	return json.Marshal(spilledQueryInputRecord{
+		QueryInputRecord: rec,
+		IsProcessable:    rec.isProcessable,
+		TraceID:          rec.traceID,
+		Framing:          rec.framing,
+		ClientIPConf:     rec.clientIPConf,
+	})
+}
+
+// UnmarshalSpill decodes the record encoded by MarshalSpill
+func (rec *QueryInputRecord) UnmarshalSpill(data []byte) error {
+	tmp := spilledQueryInputRecord{QueryInputRecord: rec}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	rec.isProcessable = tmp.IsProcessable
+	rec.traceID = tmp.TraceID
+	rec.framing = tmp.Framing
+	rec.clientIPConf = tmp.ClientIPConf
+	return nil
+}
@@ -63,3 +63,12 @@ func TestImportCorpname(t *testing.T) {
 	c := importCorpname(r)
 	assert.Equal(t, "foobar7", c)
 }
+
+func TestOutputRecordAnonymizeIP(t *testing.T) {
+	rec := &OutputRecord{IPAddress: "192.168.1.10"}
+	rec.SetLocation("Czechia", 50.08, 14.42, "Europe/Prague")
+	rec.AnonymizeIP()
+	assert.Equal(t, "192.168.1.0", rec.IPAddress)
+	assert.Equal(t, "192.168.1.0", rec.GeoIP.IP)
+	assert.Equal(t, "Czechia", rec.GeoIP.CountryName)
+}
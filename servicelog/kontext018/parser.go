@@ -19,27 +19,148 @@ package kontext018
 import (
 	"encoding/json"
 	"klogproc/servicelog"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// findStringValue walks through nested JSON objects using
+// the provided path and returns a string value found there
+func findStringValue(data map[string]any, path []string) string {
+	var val any = data
+	for _, name := range path {
+		valmap, ok := val.(map[string]any)
+		if !ok {
+			return ""
+		}
+		val = valmap[name]
+	}
+	switch v := val.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+const (
+	// maxExceptionStackLines limits the number of continuation lines
+	// stored in an exception stack of a single record
+	maxExceptionStackLines = 200
+
+	truncatedStackMark = "[stack truncated]"
 )
 
+// isErrorRecord tests whether a record may be followed
+// by a multi-line exception dump
+func isErrorRecord(rec *QueryInputRecord) bool {
+	switch strings.ToUpper(rec.Level) {
+	case "ERROR", "CRITICAL", "FATAL":
+		return true
+	}
+	return rec.Exception.Type != ""
+}
+
 // LineParser is a parser for reading KonText application logs
 type LineParser struct {
+
+	// traceIDPath is a path of a (possibly nested) JSON
+	// field containing a trace/correlation ID of the request
+	traceIDPath []string
+
+	mode servicelog.ParsingMode
+
+	// lastError is the most recent error-level record. Non-JSON lines
+	// following such a record are considered to be a part of its
+	// exception stack (KonText writes Python tracebacks this way).
+	lastError *QueryInputRecord
+
+	stackTruncated bool
+}
+
+// appendStackLine adds a continuation line to the exception stack
+// of the last error record. The number of stored lines is limited
+// by maxExceptionStackLines.
+func (lp *LineParser) appendStackLine(s string) {
+	stack := lp.lastError.Exception.Stack
+	if len(stack) < maxExceptionStackLines {
+		lp.lastError.Exception.Stack = append(stack, s)
+
+	} else if !lp.stackTruncated {
+		lp.lastError.Exception.Stack = append(stack, truncatedStackMark)
+		lp.stackTruncated = true
+	}
+}
+
+// reportUnknownFields logs a warning for each top-level field
+// of the line not mapped to QueryInputRecord
+func (lp *LineParser) reportUnknownFields(s string, lineNum int64) {
+	fields, err := servicelog.UnknownFields([]byte(s), &QueryInputRecord{})
+	if err != nil {
+		log.Warn().Err(err).Int64("line", lineNum).Msg("failed to check record schema")
+		return
+	}
+	for _, field := range fields {
+		log.Warn().
+			Int64("line", lineNum).
+			Str("field", field).
+			Msg("unknown field in KonText record")
+	}
 }
 
 // ParseLine parses a query log line - i.e. it expects
 // that the line contains user interaction log
+//
+// Non-JSON lines following an error-level record are appended to the record's
+// exception stack and servicelog.ErrEmptyLine is returned for them. Please
+// note that the error record itself has been already returned at that time
+// (error records are not processable so this does not affect the output data).
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*QueryInputRecord, error) {
+	if lp.lastError != nil && !strings.HasPrefix(strings.TrimSpace(s), "{") {
+		lp.appendStackLine(s)
+		return nil, servicelog.ErrEmptyLine
+	}
+	lp.lastError = nil
+	lp.stackTruncated = false
 	var record QueryInputRecord
-	err := json.Unmarshal([]byte(s), &record)
+	err := servicelog.UnmarshalRecord([]byte(s), &record, lp.mode)
 	if err != nil {
 		return nil, servicelog.NewStreamedLineParsingError(s, "json Unmarshal error")
 	}
+	if lp.mode.ReportsUnknownFields() {
+		lp.reportUnknownFields(s, lineNum)
+	}
+	if isErrorRecord(&record) {
+		lp.lastError = &record
+	}
 	if record.Logger == "QUERY" {
 		record.isProcessable = true
+		if len(lp.traceIDPath) > 0 {
+			if lp.traceIDPath[0] == "args" {
+				record.traceID = findStringValue(record.Args, lp.traceIDPath[1:])
+
+			} else {
+				// the field is not mapped to QueryInputRecord so we have to
+				// decode the line once more
+				var rawRecord map[string]any
+				if err := json.Unmarshal([]byte(s), &rawRecord); err == nil {
+					record.traceID = findStringValue(rawRecord, lp.traceIDPath)
+				}
+			}
+		}
 	}
 	return &record, nil
 }
 
-// NewLineParser is a factory for LineParser
-func NewLineParser() *LineParser {
-	return &LineParser{}
+// NewLineParser is a factory for LineParser. The traceIDField
+// is optional and specifies a dot-separated path of a JSON field
+// containing a trace ID (e.g. `trace_id`, `args.trace_id`).
+func NewLineParser(traceIDField string, mode servicelog.ParsingMode) *LineParser {
+	ans := &LineParser{mode: mode}
+	if traceIDField != "" {
+		ans.traceIDPath = strings.Split(traceIDField, ".")
+	}
+	return ans
 }
@@ -0,0 +1,190 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kontext018
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceLine = `{"logger": "QUERY", "level": "INFO", "date": "2024-02-11T11:02:31.880",` +
+	` "action": "query_submit", "trace_id": "abc-1", "args": {"trace": {"id": "xyz-2"}}}`
+
+func TestParseTopLevelTraceID(t *testing.T) {
+	p := NewLineParser("trace_id", servicelog.ParsingModeLenient)
+	rec, err := p.ParseLine(testTraceLine, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc-1", rec.GetTraceID())
+}
+
+func TestParseArgsTraceID(t *testing.T) {
+	p := NewLineParser("args.trace.id", servicelog.ParsingModeLenient)
+	rec, err := p.ParseLine(testTraceLine, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "xyz-2", rec.GetTraceID())
+}
+
+func TestParseMissingTraceID(t *testing.T) {
+	p := NewLineParser("request_id", servicelog.ParsingModeLenient)
+	rec, err := p.ParseLine(testTraceLine, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "", rec.GetTraceID())
+	p = NewLineParser("", servicelog.ParsingModeLenient)
+	rec, err = p.ParseLine(testTraceLine, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "", rec.GetTraceID())
+}
+
+const testClusteringLine = `{"logger": "QUERY", "level": "INFO", "date": "2024-02-11T11:02:31.880",` +
+	` "action": "query_submit", "request": {"REMOTE_ADDR": "192.168.1.10", "HTTP_USER_AGENT": "Mozilla/5.0"}}`
+
+func TestClusteringClientIDIsDeterministic(t *testing.T) {
+	rec1, err := NewLineParser("", servicelog.ParsingModeLenient).ParseLine(testClusteringLine, 1)
+	assert.NoError(t, err)
+	rec2, err := NewLineParser("", servicelog.ParsingModeLenient).ParseLine(testClusteringLine, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, rec1.ClusteringClientID(), rec2.ClusteringClientID())
+
+	// a different request within the same time bucket
+	rec3, err := NewLineParser("", servicelog.ParsingModeLenient).ParseLine(
+		strings.Replace(testClusteringLine, "11:02:31", "11:45:00", 1), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, rec1.ClusteringClientID(), rec3.ClusteringClientID())
+
+	rec4, err := NewLineParser("", servicelog.ParsingModeLenient).ParseLine(
+		strings.Replace(testClusteringLine, "Mozilla/5.0", "curl/8.0", 1), 3)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rec1.ClusteringClientID(), rec4.ClusteringClientID())
+
+	// without IP and user agent, records must not share a clustering ID
+	anonLine := strings.Replace(
+		testClusteringLine,
+		`"request": {"REMOTE_ADDR": "192.168.1.10", "HTTP_USER_AGENT": "Mozilla/5.0"}`,
+		`"request": {}`,
+		1,
+	)
+	rec5, err := NewLineParser("", servicelog.ParsingModeLenient).ParseLine(anonLine, 4)
+	assert.NoError(t, err)
+	rec6, err := NewLineParser("", servicelog.ParsingModeLenient).ParseLine(anonLine, 5)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rec5.ClusteringClientID(), rec6.ClusteringClientID())
+}
+
+func TestParseMultilineTraceback(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "testdata", "kontext018", "traceback.log"))
+	assert.NoError(t, err)
+	defer f.Close()
+	p := NewLineParser("", servicelog.ParsingModeLenient)
+	var records []*QueryInputRecord
+	var numSkipped int
+	sc := bufio.NewScanner(f)
+	for i := int64(1); sc.Scan(); i++ {
+		rec, err := p.ParseLine(sc.Text(), i)
+		if err == servicelog.ErrEmptyLine {
+			numSkipped++
+			continue
+		}
+		assert.NoError(t, err)
+		records = append(records, rec)
+	}
+	assert.Len(t, records, 3)
+	assert.Equal(t, 8, numSkipped)
+	assert.Equal(t, "ConcNotFound", records[1].Exception.Type)
+	assert.Len(t, records[1].Exception.Stack, 8)
+	assert.Equal(t, "Traceback (most recent call last):", records[1].Exception.Stack[0])
+	assert.Equal(
+		t,
+		"conclib.errors.ConcNotFoundException: Concordance record not found",
+		records[1].Exception.Stack[7],
+	)
+	assert.True(t, records[2].IsProcessable())
+	assert.Empty(t, records[2].Exception.Stack)
+}
+
+func TestParseNonJSONWithoutErrorRecord(t *testing.T) {
+	p := NewLineParser("", servicelog.ParsingModeLenient)
+	_, err := p.ParseLine(testTraceLine, 1)
+	assert.NoError(t, err)
+	_, err = p.ParseLine("Traceback (most recent call last):", 2)
+	assert.Error(t, err)
+	assert.NotEqual(t, servicelog.ErrEmptyLine, err)
+}
+
+func TestParseTracebackIsBounded(t *testing.T) {
+	p := NewLineParser("", servicelog.ParsingModeLenient)
+	rec, err := p.ParseLine(`{"logger": "kontext", "level": "ERROR", "date": "2024-02-11T11:02:31.880"}`, 1)
+	assert.NoError(t, err)
+	for i := 0; i < maxExceptionStackLines*2; i++ {
+		_, err := p.ParseLine("  File \"foo.py\", line 1, in bar", int64(i+2))
+		assert.Equal(t, servicelog.ErrEmptyLine, err)
+	}
+	assert.Len(t, rec.Exception.Stack, maxExceptionStackLines+1)
+	assert.Equal(t, truncatedStackMark, rec.Exception.Stack[maxExceptionStackLines])
+}
+
+func TestParseStrictSchemaAcceptsUnknownFields(t *testing.T) {
+	p := NewLineParser("", servicelog.ParsingModeStrictSchema)
+	rec, err := p.ParseLine(testTraceLine, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "query_submit", rec.Action)
+	fields, err := servicelog.UnknownFields([]byte(testTraceLine), rec)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"trace_id"}, fields)
+}
+
+func TestSpillRoundTrip(t *testing.T) {
+	p := NewLineParser("trace_id", servicelog.ParsingModeLenient)
+	rec, err := p.ParseLine(testTraceLine, 1)
+	assert.NoError(t, err)
+	data, err := rec.MarshalSpill()
+	assert.NoError(t, err)
+	var rec2 QueryInputRecord
+	assert.NoError(t, rec2.UnmarshalSpill(data))
+	assert.True(t, rec2.IsProcessable())
+	assert.Equal(t, "abc-1", rec2.GetTraceID())
+	assert.Equal(t, rec.GetTime(), rec2.GetTime())
+	assert.Equal(t, rec.Action, rec2.Action)
+	assert.Equal(t, rec.Args, rec2.Args)
+}
+
+const testForwardedLine = `{"logger": "QUERY", "level": "INFO", "date": "2024-02-11T11:02:31.880",` +
+	` "action": "query_submit", "request": {"HTTP_X_FORWARDED_FOR": "1.2.3.4, 10.0.0.1, 172.16.0.5",` +
+	` "REMOTE_ADDR": "10.0.0.9"}}`
+
+func TestGetClientIPDefault(t *testing.T) {
+	p := NewLineParser("", servicelog.ParsingModeLenient)
+	rec, err := p.ParseLine(testForwardedLine, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", rec.GetClientIP().String())
+}
+
+func TestGetClientIPConfigured(t *testing.T) {
+	p := NewLineParser("", servicelog.ParsingModeLenient)
+	rec, err := p.ParseLine(testForwardedLine, 1)
+	assert.NoError(t, err)
+	rec.SetClientIPConf(&servicelog.ClientIPConf{Hop: servicelog.ClientIPHopLast})
+	assert.Equal(t, "172.16.0.5", rec.GetClientIP().String())
+	rec.SetClientIPConf(&servicelog.ClientIPConf{Header: "HTTP_REMOTE_ADDR"})
+	assert.Equal(t, "1.2.3.4", rec.GetClientIP().String())
+}
@@ -61,6 +61,12 @@ func (r *OutputRecord) SetLocation(countryName string, latitude float32, longitu
 	r.GeoIP.Timezone = timezone
 }
 
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (r *OutputRecord) AnonymizeIP() {
+	r.IPAddress = servicelog.AnonymizeIP(r.IPAddress)
+	r.GeoIP.IP = servicelog.AnonymizeIP(r.GeoIP.IP)
+}
+
 // ToJSON converts data to a JSON document (typically for ElasticSearch)
 func (r *OutputRecord) ToJSON() ([]byte, error) {
 	return json.Marshal(r)
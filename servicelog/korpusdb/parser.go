@@ -17,16 +17,23 @@
 package korpusdb
 
 import (
-	"encoding/json"
+	"klogproc/servicelog"
 )
 
-type LineParser struct{}
+type LineParser struct {
+	mode servicelog.ParsingMode
+}
 
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	var rec InputRecord
-	err := json.Unmarshal([]byte(s), &rec)
+	err := servicelog.UnmarshalRecord([]byte(s), &rec, lp.mode)
 	if err != nil {
 		return nil, err
 	}
 	return &rec, nil
 }
+
+// NewLineParser is a factory for LineParser
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
+}
@@ -17,17 +17,17 @@
 package kwords2
 
 import (
-	"encoding/json"
 	"fmt"
 	"klogproc/servicelog"
 )
 
 type LineParser struct {
+	mode servicelog.ParsingMode
 }
 
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	var record InputRecord
-	err := json.Unmarshal([]byte(s), &record)
+	err := servicelog.UnmarshalRecord([]byte(s), &record, lp.mode)
 	if err != nil {
 		return nil, servicelog.NewStreamedLineParsingError(
 			s, fmt.Sprintf("json Unmarshal error: %s", err))
@@ -35,6 +35,6 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	return &record, nil
 }
 
-func NewLineParser() *LineParser {
-	return &LineParser{}
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
 }
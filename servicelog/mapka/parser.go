@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"klogproc/load/accesslog"
+	"klogproc/servicelog"
 )
 
 func getAction(path string) (string, *RequestParams) {
@@ -54,7 +55,7 @@ func getAction(path string) (string, *RequestParams) {
 
 // LineParser is a parser for reading Mapka application logs
 type LineParser struct {
-	parser accesslog.LineParser
+	parser *accesslog.LineParser
 }
 
 // ParseLine parses a HTTP access log format line
@@ -79,7 +80,14 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 			RemoteAddr:     parsed.IPAddress, // TODO the same stuff as above?
 		},
 		Params:   params,
+		Status:   parsed.Status,
 		ProcTime: parsed.ProcTime,
 	}
 	return ans, nil
 }
+
+// NewLineParser is a factory for LineParser. The fields argument
+// is optional (see accesslog.FieldSpec).
+func NewLineParser(mode servicelog.ParsingMode, fields accesslog.FieldSpec) *LineParser {
+	return &LineParser{parser: accesslog.NewLineParser(mode, fields)}
+}
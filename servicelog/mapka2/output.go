@@ -51,6 +51,12 @@ func (r *OutputRecord) SetLocation(countryName string, latitude float32, longitu
 	r.GeoIP.Timezone = timezone
 }
 
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (r *OutputRecord) AnonymizeIP() {
+	r.IPAddress = servicelog.AnonymizeIP(r.IPAddress)
+	r.GeoIP.IP = servicelog.AnonymizeIP(r.GeoIP.IP)
+}
+
 // GetID returns an idempotent ID of the record.
 func (r *OutputRecord) GetID() string {
 	return r.ID
@@ -71,6 +77,11 @@ func (r *OutputRecord) ToJSON() ([]byte, error) {
 	return json.Marshal(r)
 }
 
+// GetAction returns the logged action
+func (r *OutputRecord) GetAction() string {
+	return r.Action
+}
+
 // ToInfluxDB creates tags and values to store in InfluxDB
 func (r *OutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
 	return make(map[string]string), make(map[string]interface{})
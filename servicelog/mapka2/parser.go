@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"klogproc/load/accesslog"
+	"klogproc/servicelog"
 )
 
 var (
@@ -45,7 +46,7 @@ func getAction(fullPath string) string {
 
 // LineParser is a parser for reading Mapka application logs
 type LineParser struct {
-	parser accesslog.LineParser
+	parser *accesslog.LineParser
 }
 
 // ParseLine parses a HTTP access log format line
@@ -69,7 +70,14 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 			HTTPRemoteAddr: parsed.IPAddress,
 			RemoteAddr:     parsed.IPAddress, // TODO the same stuff as above?
 		},
+		Status:   parsed.Status,
 		ProcTime: parsed.ProcTime,
 	}
 	return ans, nil
 }
+
+// NewLineParser is a factory for LineParser. The fields argument
+// is optional (see accesslog.FieldSpec).
+func NewLineParser(mode servicelog.ParsingMode, fields accesslog.FieldSpec) *LineParser {
+	return &LineParser{parser: accesslog.NewLineParser(mode, fields)}
+}
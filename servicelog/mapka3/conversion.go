@@ -60,9 +60,11 @@ func (t *Transformer) Transform(
 		UserAgent: logRecord.GetUserAgent(),
 		IsAnonymous: logRecord.Extra.UserID == "" ||
 			servicelog.UserBelongsToList(logRecord.Extra.UserID, anonymousUsers),
-		Action:      "interaction",
-		UserID:      logRecord.Extra.UserID,
-		ClusterSize: logRecord.clusterSize,
+		Action:              "interaction",
+		UserID:              logRecord.Extra.UserID,
+		ClusterSize:         logRecord.clusterSize,
+		SessionDurationSecs: logRecord.sessionDuration,
+		InteractionCount:    logRecord.interactionCount,
 	}
 	if r.ClusterSize > 0 {
 		r.IsQuery = true
@@ -82,7 +84,13 @@ func (t *Transformer) Preprocess(
 	if t.excludeIPList.Excludes(rec) {
 		return []servicelog.InputRecord{}
 	}
-	return t.analyzer.Preprocess(rec, prevRecs)
+	ans := t.analyzer.Preprocess(rec, prevRecs)
+	for _, item := range ans {
+		if tItem, ok := item.(*InputRecord); ok {
+			tItem.calcSessionMetrics()
+		}
+	}
+	return ans
 }
 
 // NewTransformer is a default constructor for the Transformer.
@@ -0,0 +1,75 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapka3
+
+import (
+	"testing"
+
+	"klogproc/clustering"
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRecord(datetime, url string) *InputRecord {
+	return &InputRecord{
+		Datetime: datetime,
+		Extra:    Extra{URL: url, IP: "192.168.1.1", SessionSelector: "abc"},
+	}
+}
+
+func TestSessionMetrics(t *testing.T) {
+	rec := newTestRecord("2024-01-01T10:00:00.000000+01:00", "/markers")
+	rec.SetCluster(3)
+	rec.SetClusterMembers([]servicelog.InputRecord{
+		rec,
+		newTestRecord("2024-01-01T10:00:25.500000+01:00", "/markers"),
+		newTestRecord("2024-01-01T10:00:10.000000+01:00", "/search"),
+	})
+	rec.calcSessionMetrics()
+	assert.Nil(t, rec.clusterMembers)
+	out, err := (&Transformer{}).Transform(rec, "mapka", 0, []int{})
+	assert.NoError(t, err)
+	assert.Equal(t, 25.5, out.SessionDurationSecs)
+	assert.Equal(t, 2, out.InteractionCount)
+	_, values := out.ToInfluxDB()
+	assert.Equal(t, 25.5, values["sessionDurationSecs"])
+	assert.Equal(t, 2, values["interactionCount"])
+}
+
+func TestSessionMetricsSingleton(t *testing.T) {
+	rec := newTestRecord("2024-01-01T10:00:00.000000+01:00", "/markers")
+	rec.SetCluster(1)
+	rec.SetClusterMembers([]servicelog.InputRecord{rec})
+	rec.calcSessionMetrics()
+	out, err := (&Transformer{}).Transform(rec, "mapka", 0, []int{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, out.SessionDurationSecs)
+	assert.Equal(t, 1, out.InteractionCount)
+}
+
+func TestAnalyzeProvidesClusterMembers(t *testing.T) {
+	items := []servicelog.InputRecord{
+		newTestRecord("2024-01-01T10:00:00.000000+01:00", "/markers"),
+		newTestRecord("2024-01-01T10:00:10.000000+01:00", "/search"),
+		newTestRecord("2024-01-01T12:00:00.000000+01:00", "/markers"),
+	}
+	for _, item := range clustering.Analyze(1, 30, items) {
+		rec := item.(*InputRecord)
+		assert.Len(t, rec.clusterMembers, rec.ClusterSize())
+	}
+}
@@ -19,6 +19,7 @@ package mapka3
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"klogproc/servicelog"
 	"net"
@@ -45,6 +46,12 @@ type InputRecord struct {
 	Extra         Extra          `json:"extra"`
 	isProcessable bool
 	clusterSize   int
+
+	// clusterMembers are available only between clustering
+	// and calculation of session metrics (see calcSessionMetrics)
+	clusterMembers   []servicelog.InputRecord
+	sessionDuration  float64
+	interactionCount int
 }
 
 type Extra struct {
@@ -118,6 +125,40 @@ func (rec *InputRecord) SetCluster(size int) {
 	rec.clusterSize = size
 }
 
+// SetClusterMembers stores all the records of the cluster the record
+// represents (see clustering.ClusterMembersReceiver)
+func (rec *InputRecord) SetClusterMembers(members []servicelog.InputRecord) {
+	rec.clusterMembers = members
+}
+
+// calcSessionMetrics calculates duration (time between the first and
+// the last interaction) and number of distinct actions (URLs) of the
+// cluster the record represents. The cluster members are released
+// afterwards.
+func (rec *InputRecord) calcSessionMetrics() {
+	if len(rec.clusterMembers) == 0 {
+		return
+	}
+	first := rec.clusterMembers[0].GetTime()
+	last := first
+	actions := make(map[string]bool)
+	for _, item := range rec.clusterMembers {
+		t := item.GetTime()
+		if t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+		if tItem, ok := item.(*InputRecord); ok {
+			actions[tItem.Extra.URL] = true
+		}
+	}
+	rec.sessionDuration = last.Sub(first).Seconds()
+	rec.interactionCount = len(actions)
+	rec.clusterMembers = nil
+}
+
 // GetUserAgent returns a raw HTTP user agent info as provided by the client
 func (r *InputRecord) GetUserAgent() string {
 	return ""
@@ -135,3 +176,38 @@ func (r *InputRecord) ShouldBeAnalyzed() bool {
 func (rec *InputRecord) IsSuspicious() bool {
 	return false
 }
+
+// spilledInputRecord extends the record with its internal state
+// so it survives being moved to a log buffer spill segment
+type spilledInputRecord struct {
+	*InputRecord
+	IsProcessable    bool    `json:"_isProcessable"`
+	ClusterSize      int     `json:"_clusterSize"`
+	SessionDuration  float64 `json:"_sessionDuration"`
+	InteractionCount int     `json:"_interactionCount"`
+}
+
+// MarshalSpill encodes the record including its internal state
+// (see logbuffer.Spillable)
+func (rec *InputRecord) MarshalSpill() ([]byte, error) {
+	return json.Marshal(spilledInputRecord{
+		InputRecord:      rec,
+		IsProcessable:    rec.isProcessable,
+		ClusterSize:      rec.clusterSize,
+		SessionDuration:  rec.sessionDuration,
+		InteractionCount: rec.interactionCount,
+	})
+}
+
+// UnmarshalSpill decodes the record encoded by MarshalSpill
+func (rec *InputRecord) UnmarshalSpill(data []byte) error {
+	tmp := spilledInputRecord{InputRecord: rec}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	rec.isProcessable = tmp.IsProcessable
+	rec.clusterSize = tmp.ClusterSize
+	rec.sessionDuration = tmp.SessionDuration
+	rec.interactionCount = tmp.InteractionCount
+	return nil
+}
@@ -41,6 +41,14 @@ type OutputRecord struct {
 	IsQuery     bool                     `json:"isQuery"`
 	GeoIP       servicelog.GeoDataRecord `json:"geoip,omitempty"`
 	ClusterSize int                      `json:"clusterSize"`
+
+	// SessionDurationSecs is the time between the first and the last
+	// request of the cluster (zero for single-request clusters)
+	SessionDurationSecs float64 `json:"sessionDurationSecs"`
+
+	// InteractionCount is the number of distinct actions (URLs)
+	// within the cluster
+	InteractionCount int `json:"interactionCount"`
 }
 
 // SetLocation sets all the location related properties
@@ -54,6 +62,12 @@ func (r *OutputRecord) SetLocation(countryName string, latitude float32, longitu
 	r.GeoIP.Timezone = timezone
 }
 
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (r *OutputRecord) AnonymizeIP() {
+	r.IPAddress = servicelog.AnonymizeIP(r.IPAddress)
+	r.GeoIP.IP = servicelog.AnonymizeIP(r.GeoIP.IP)
+}
+
 // GetID returns an idempotent ID of the record.
 func (r *OutputRecord) GetID() string {
 	return r.ID
@@ -74,7 +88,16 @@ func (r *OutputRecord) ToJSON() ([]byte, error) {
 	return json.Marshal(r)
 }
 
+// GetAction returns the logged action
+func (r *OutputRecord) GetAction() string {
+	return r.Action
+}
+
 // ToInfluxDB creates tags and values to store in InfluxDB
 func (r *OutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
-	return make(map[string]string), make(map[string]interface{})
+	tags = make(map[string]string)
+	values = make(map[string]interface{})
+	values["sessionDurationSecs"] = r.SessionDurationSecs
+	values["interactionCount"] = r.InteractionCount
+	return
 }
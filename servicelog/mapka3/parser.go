@@ -17,20 +17,26 @@
 package mapka3
 
 import (
-	"encoding/json"
+	"klogproc/servicelog"
 )
 
 // LineParser is a parser for reading Mapka application logs
 type LineParser struct {
+	mode servicelog.ParsingMode
 }
 
 // ParseLine parses a HTTP access log format line
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	parsed := new(InputRecord)
-	err := json.Unmarshal([]byte(s), parsed)
+	err := servicelog.UnmarshalRecord([]byte(s), parsed, lp.mode)
 	if err != nil {
 		return &InputRecord{isProcessable: false}, err
 	}
 	parsed.isProcessable = true
 	return parsed, nil
 }
+
+// NewLineParser is a factory for LineParser
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
+}
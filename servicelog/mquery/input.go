@@ -67,6 +67,11 @@ func (r *InputRecord) GetUserAgent() string {
 	return r.UserAgent
 }
 
+// GetProcTime returns the logged processing time
+func (r *InputRecord) GetProcTime() float64 {
+	return r.Latency
+}
+
 func (r *InputRecord) IsProcessable() bool {
 	// process only http requests
 	return len(r.Method) > 0
@@ -17,20 +17,20 @@
 package mquery
 
 import (
-	"encoding/json"
 	"klogproc/servicelog"
 )
 
 // LineParser is a parser for reading KonText application logs
 type LineParser struct {
 	appErrorRegister servicelog.AppErrorRegister
+	mode             servicelog.ParsingMode
 }
 
 // ParseLine parses a query log line - i.e. it expects
 // that the line contains user interaction log
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	var record InputRecord
-	err := json.Unmarshal([]byte(s), &record)
+	err := servicelog.UnmarshalRecord([]byte(s), &record, lp.mode)
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +38,9 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 }
 
 // NewLineParser is a factory for LineParser
-func NewLineParser(appErrRegister servicelog.AppErrorRegister) *LineParser {
-	return &LineParser{appErrorRegister: appErrRegister}
+func NewLineParser(
+	appErrRegister servicelog.AppErrorRegister,
+	mode servicelog.ParsingMode,
+) *LineParser {
+	return &LineParser{appErrorRegister: appErrRegister, mode: mode}
 }
@@ -80,6 +80,11 @@ func (r *InputRecord) GetUserAgent() string {
 	return ""
 }
 
+// GetProcTime returns the logged processing time
+func (r *InputRecord) GetProcTime() float64 {
+	return r.Latency
+}
+
 func (r *InputRecord) IsProcessable() bool {
 	// process only http requests
 	return r.Method != ""
@@ -0,0 +1,68 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginxjson
+
+import (
+	"klogproc/servicelog"
+	"time"
+)
+
+// Transformer converts a source log object into a destination one
+type Transformer struct {
+	ExcludeIPList     servicelog.ExcludeIPList
+	ConversionActions servicelog.ConversionActionList
+	PathTemplates     servicelog.PathTemplateRules
+}
+
+func (t *Transformer) Transform(logRecord *InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (*OutputRecord, error) {
+	var ip string
+	if clientIP := logRecord.GetClientIP(); clientIP != nil {
+		ip = clientIP.String()
+	}
+	rec := &OutputRecord{
+		Type:         recType,
+		Datetime:     logRecord.GetTime().Add(time.Minute * time.Duration(tzShiftMin)).Format(time.RFC3339),
+		datetime:     logRecord.GetTime(),
+		IPAddress:    ip,
+		UserAgent:    logRecord.GetUserAgent(),
+		IsConversion: t.ConversionActions.Contains(logRecord.GetPath()),
+		Method:       logRecord.RequestMethod,
+		Path:         logRecord.GetPath(),
+		PathTemplate: t.PathTemplates.Apply(logRecord.GetPath()),
+		Status:       int(logRecord.Status),
+		BytesSent:    int(logRecord.BodyBytesSent),
+		ProcTime:     float64(logRecord.RequestTime),
+	}
+	if logRecord.HTTPReferer != "-" {
+		rec.Referer = logRecord.HTTPReferer
+	}
+	rec.ID = CreateID(rec)
+	return rec, nil
+}
+
+func (t *Transformer) HistoryLookupItems() int {
+	return 0
+}
+
+func (t *Transformer) Preprocess(
+	rec servicelog.InputRecord, prevRecs servicelog.ServiceLogBuffer,
+) []servicelog.InputRecord {
+	if t.ExcludeIPList.Excludes(rec) {
+		return []servicelog.InputRecord{}
+	}
+	return []servicelog.InputRecord{rec}
+}
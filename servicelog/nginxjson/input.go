@@ -0,0 +1,117 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginxjson
+
+import (
+	"bytes"
+	"klogproc/servicelog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number represents a numeric value logged by nginx. Depending
+// on the log_format definition, values may be written either
+// as JSON numbers or as strings (incl. "-" for a missing value).
+type Number float64
+
+// UnmarshalJSON accepts both numbers and numeric strings
+func (n *Number) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "-" || string(data) == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return err
+	}
+	*n = Number(v)
+	return nil
+}
+
+// InputRecord represents a parsed nginx JSON access log record
+// (with names matching nginx variables)
+type InputRecord struct {
+	TimeISO8601       string `json:"time_iso8601"`
+	RemoteAddr        string `json:"remote_addr"`
+	RemoteUser        string `json:"remote_user"`
+	HTTPXForwardedFor string `json:"http_x_forwarded_for"`
+	RequestMethod     string `json:"request_method"`
+	RequestURI        string `json:"request_uri"`
+	Status            Number `json:"status"`
+	BodyBytesSent     Number `json:"body_bytes_sent"`
+	RequestTime       Number `json:"request_time"`
+	HTTPReferer       string `json:"http_referer"`
+	HTTPUserAgent     string `json:"http_user_agent"`
+}
+
+// GetTime returns a normalized log date and time information
+func (r *InputRecord) GetTime() time.Time {
+	if strings.HasSuffix(r.TimeISO8601, "Z") {
+		return servicelog.ConvertDatetimeString(r.TimeISO8601[:len(r.TimeISO8601)-1] + "+00:00")
+	}
+	return servicelog.ConvertDatetimeString(r.TimeISO8601)
+}
+
+// GetClientIP returns a client IP address. In case the request
+// went through a proxy, the first address of the X-Forwarded-For
+// header is used.
+func (r *InputRecord) GetClientIP() net.IP {
+	if r.HTTPXForwardedFor != "" && r.HTTPXForwardedFor != "-" {
+		first := strings.TrimSpace(strings.Split(r.HTTPXForwardedFor, ",")[0])
+		if ip := net.ParseIP(first); ip != nil {
+			return ip
+		}
+	}
+	return net.ParseIP(r.RemoteAddr)
+}
+
+func (rec *InputRecord) ClusteringClientID() string {
+	return servicelog.GenerateRandomClusteringID()
+}
+
+func (rec *InputRecord) ClusterSize() int {
+	return 0
+}
+
+func (rec *InputRecord) SetCluster(size int) {
+}
+
+func (r *InputRecord) GetUserAgent() string {
+	return r.HTTPUserAgent
+}
+
+// GetProcTime returns the logged processing time
+func (r *InputRecord) GetProcTime() float64 {
+	return float64(r.RequestTime)
+}
+
+func (r *InputRecord) IsProcessable() bool {
+	return r.RequestURI != "" && r.TimeISO8601 != ""
+}
+
+func (rec *InputRecord) IsSuspicious() bool {
+	return false
+}
+
+// GetPath returns the request URI without the query part
+func (r *InputRecord) GetPath() string {
+	path, _, _ := strings.Cut(r.RequestURI, "?")
+	return path
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginxjson
+
+import (
+	"klogproc/servicelog"
+)
+
+// LineParser is a parser for reading nginx JSON access logs
+type LineParser struct {
+	mode servicelog.ParsingMode
+}
+
+// ParseLine parses a single JSON access log line
+func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
+	var record InputRecord
+	if err := servicelog.UnmarshalRecord([]byte(s), &record, lp.mode); err != nil {
+		return nil, servicelog.NewLineParsingError(lineNum, err.Error())
+	}
+	return &record, nil
+}
+
+// NewLineParser is a factory for LineParser
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nginxjson
+
+import (
+	"testing"
+	"time"
+
+	"klogproc/servicelog"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAndTransform(t *testing.T) {
+	line := `{"time_iso8601":"2024-03-01T10:20:30+01:00","remote_addr":"10.0.0.1",` +
+		`"http_x_forwarded_for":"192.168.1.10, 10.0.0.1","request_method":"GET",` +
+		`"request_uri":"/bonito/run.cgi/first?corpname=bnc","status":"200",` +
+		`"body_bytes_sent":"1234","request_time":"0.250","http_referer":"-",` +
+		`"http_user_agent":"Mozilla/5.0"}`
+	p := &LineParser{}
+	rec, err := p.ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.True(t, rec.IsProcessable())
+	assert.Equal(t, "192.168.1.10", rec.GetClientIP().String())
+	assert.Equal(t, "/bonito/run.cgi/first", rec.GetPath())
+
+	tr := &Transformer{}
+	out, err := tr.Transform(rec, "nginx-json", 0, []int{})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.25, out.ProcTime)
+	assert.Equal(t, 200, out.Status)
+	assert.Equal(t, 1234, out.BytesSent)
+	assert.Equal(t, "", out.Referer)
+	assert.Equal(t, "2024-03-01T10:20:30+01:00", out.Datetime)
+	assert.True(t, out.GetTime().Equal(time.Date(2024, 3, 1, 9, 20, 30, 0, time.UTC)))
+	assert.NotEmpty(t, out.GetID())
+}
+
+func TestParseNumericValues(t *testing.T) {
+	line := `{"time_iso8601":"2024-03-01T10:20:30Z","remote_addr":"10.0.0.1",` +
+		`"request_uri":"/","status":404,"request_time":1.5,"body_bytes_sent":"-"}`
+	rec, err := (&LineParser{}).ParseLine(line, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Number(404), rec.Status)
+	assert.Equal(t, Number(1.5), rec.RequestTime)
+	assert.Equal(t, Number(0), rec.BodyBytesSent)
+	assert.Equal(t, "10.0.0.1", rec.GetClientIP().String())
+}
+
+func TestParseInvalidLine(t *testing.T) {
+	_, err := (&LineParser{}).ParseLine(`127.0.0.1 - - [01/Mar/2024:10:20:30 +0100] "GET / HTTP/1.1"`, 5)
+	assert.Error(t, err)
+}
+
+func TestTransformPathTemplate(t *testing.T) {
+	line := `{"time_iso8601":"2024-03-01T10:20:30+01:00","remote_addr":"10.0.0.1",` +
+		`"request_method":"GET","request_uri":"/corpora/1234/info?format=json","status":"200"}`
+	rec, err := (&LineParser{}).ParseLine(line, 1)
+	assert.NoError(t, err)
+	tr := &Transformer{
+		PathTemplates: servicelog.PathTemplateRules{{Pattern: `/\d+(/|$)`, Replacement: "/:id$1"}},
+	}
+	assert.NoError(t, tr.PathTemplates.Validate())
+	out, err := tr.Transform(rec, "nginx-json", 0, []int{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/corpora/1234/info", out.Path)
+	assert.Equal(t, "/corpora/:id/info", out.PathTemplate)
+	tags, _ := out.ToInfluxDB()
+	assert.Equal(t, "/corpora/:id/info", tags["pathTemplate"])
+
+	out, err = (&Transformer{}).Transform(rec, "nginx-json", 0, []int{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", out.PathTemplate)
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ParsingMode specifies how log parsers handle borderline conditions
+// like missing optional fields or extra tokens/fields in a line.
+type ParsingMode string
+
+const (
+
+	// ParsingModeLenient is a best-effort mode where parsers tolerate
+	// anomalies as long as the essential information can be extracted.
+	// This is the default mode.
+	ParsingModeLenient ParsingMode = "lenient"
+
+	// ParsingModeStrict makes parsers report any anomalies as errors
+	ParsingModeStrict ParsingMode = "strict"
+
+	// ParsingModeStrictSchema parses records just like ParsingModeLenient
+	// but parsers of JSON-based logs supporting the mode log a warning
+	// for each record field not known to the parser. This is intended for
+	// detecting schema changes (e.g. during application upgrades).
+	ParsingModeStrictSchema ParsingMode = "strictSchema"
+)
+
+// Validate tests whether the mode is supported. Empty value
+// is accepted and means ParsingModeLenient.
+func (m ParsingMode) Validate() error {
+	switch m {
+	case "", ParsingModeLenient, ParsingModeStrict, ParsingModeStrictSchema:
+		return nil
+	}
+	return fmt.Errorf("unsupported parsing mode: %s", m)
+}
+
+// IsStrict returns true if the mode is ParsingModeStrict
+func (m ParsingMode) IsStrict() bool {
+	return m == ParsingModeStrict
+}
+
+// ReportsUnknownFields returns true if the mode is ParsingModeStrictSchema
+func (m ParsingMode) ReportsUnknownFields() bool {
+	return m == ParsingModeStrictSchema
+}
+
+// knownJSONFields collects (lowercased) JSON names of the fields
+// of a struct type including the fields of embedded structs
+func knownJSONFields(tp reflect.Type, ans map[string]bool) {
+	for i := 0; i < tp.NumField(); i++ {
+		field := tp.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ftp := field.Type
+			if ftp.Kind() == reflect.Pointer {
+				ftp = ftp.Elem()
+			}
+			if ftp.Kind() == reflect.Struct {
+				knownJSONFields(ftp, ans)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		ans[strings.ToLower(name)] = true
+	}
+}
+
+// UnknownFields returns sorted top-level keys of a JSON-encoded object
+// which are not mapped to any field of v (a struct or a pointer to a struct).
+// Just like encoding/json, the keys are matched case-insensitively.
+func UnknownFields(data []byte, v any) ([]string, error) {
+	tp := reflect.TypeOf(v)
+	for tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+	if tp.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot find unknown fields - %s is not a struct", tp)
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	knownJSONFields(tp, known)
+	ans := make([]string, 0, len(obj))
+	for k := range obj {
+		if !known[strings.ToLower(k)] {
+			ans = append(ans, k)
+		}
+	}
+	sort.Strings(ans)
+	return ans, nil
+}
+
+// UnmarshalRecord decodes a JSON-encoded log record. In the lenient mode,
+// it behaves just like json.Unmarshal. In the strict mode, fields
+// not known to the target type and any data following the JSON value
+// are reported as errors.
+func UnmarshalRecord(data []byte, v any, mode ParsingMode) error {
+	if !mode.IsStrict() {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON record")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct {
+	Name string `json:"name"`
+}
+
+func TestUnmarshalRecordLenient(t *testing.T) {
+	var rec testRecord
+	assert.NoError(t, UnmarshalRecord([]byte(`{"name": "foo", "extra": 1}`), &rec, ParsingModeLenient))
+	assert.Equal(t, "foo", rec.Name)
+	assert.NoError(t, UnmarshalRecord([]byte(`{"name": "bar"}`), &rec, ""))
+	assert.Equal(t, "bar", rec.Name)
+}
+
+func TestUnmarshalRecordStrict(t *testing.T) {
+	var rec testRecord
+	assert.NoError(t, UnmarshalRecord([]byte(`{"name": "foo"} `), &rec, ParsingModeStrict))
+	assert.Equal(t, "foo", rec.Name)
+	assert.Error(t, UnmarshalRecord([]byte(`{"name": "foo", "extra": 1}`), &rec, ParsingModeStrict))
+	assert.Error(t, UnmarshalRecord([]byte(`{"name": "foo"} {}`), &rec, ParsingModeStrict))
+	assert.Error(t, UnmarshalRecord([]byte(`{"name": "foo"`), &rec, ParsingModeStrict))
+}
+
+func TestParsingModeValidate(t *testing.T) {
+	assert.NoError(t, ParsingMode("").Validate())
+	assert.NoError(t, ParsingModeStrict.Validate())
+	assert.NoError(t, ParsingModeLenient.Validate())
+	assert.NoError(t, ParsingModeStrictSchema.Validate())
+	assert.Error(t, ParsingMode("pedantic").Validate())
+}
+
+type testEmbeddingRecord struct {
+	testRecord
+	Level   string `json:"level"`
+	Ignored string `json:"-"`
+	Plain   int
+	private int
+}
+
+func TestUnknownFields(t *testing.T) {
+	fields, err := UnknownFields(
+		[]byte(`{"name": "foo", "LEVEL": "INFO", "plain": 1, "Ignored": "x", "private": 2, "extra": {"a": 1}}`),
+		&testEmbeddingRecord{},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Ignored", "extra", "private"}, fields)
+
+	fields, err = UnknownFields([]byte(`{"name": "foo"}`), testRecord{})
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	_, err = UnknownFields([]byte(`[1, 2]`), &testRecord{})
+	assert.Error(t, err)
+	_, err = UnknownFields([]byte(`{}`), "foo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PathTemplateRule replaces parts of a request path matching Pattern
+// (a regular expression) with Replacement (which may refer to
+// the pattern's groups - e.g. `$1`).
+type PathTemplateRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+	rx          *regexp.Regexp
+}
+
+// PathTemplateRules is an ordered list of rules for creating
+// low-cardinality "templates" of request paths (e.g. `/view/1234`
+// -> `/view/:id`) suitable for aggregation.
+type PathTemplateRules []PathTemplateRule
+
+// Validate compiles all the rules and reports the first invalid one.
+func (rules PathTemplateRules) Validate() error {
+	for i := range rules {
+		if rules[i].Pattern == "" {
+			return fmt.Errorf("path template rule %d: missing pattern", i)
+		}
+		rx, err := regexp.Compile(rules[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("path template rule %d: %w", i, err)
+		}
+		rules[i].rx = rx
+	}
+	return nil
+}
+
+// IsConfigured tests whether there is at least one rule
+func (rules PathTemplateRules) IsConfigured() bool {
+	return len(rules) > 0
+}
+
+// Apply creates a template of a request path. A possible query string
+// is removed and all the rules are applied in the order of their
+// definition. In case no rules are configured, an empty string is returned.
+func (rules PathTemplateRules) Apply(path string) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	ans, _, _ := strings.Cut(path, "?")
+	for i := range rules {
+		if rules[i].rx == nil {
+			rx, err := regexp.Compile(rules[i].Pattern)
+			if err != nil {
+				continue
+			}
+			rules[i].rx = rx
+		}
+		ans = rules[i].rx.ReplaceAllString(ans, rules[i].Replacement)
+	}
+	return ans
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathTemplateRulesApply(t *testing.T) {
+	rules := PathTemplateRules{
+		{Pattern: `/\d+(/|$)`, Replacement: "/:id$1"},
+		{Pattern: `^/session/[a-f0-9]{32}`, Replacement: "/session/:token"},
+	}
+	assert.NoError(t, rules.Validate())
+	assert.Equal(t, "/view/:id", rules.Apply("/view/1234"))
+	assert.Equal(t, "/corpora/:id/info", rules.Apply("/corpora/12/info?format=json"))
+	assert.Equal(t, "/session/:token/query", rules.Apply("/session/0123456789abcdef0123456789abcdef/query"))
+	assert.Equal(t, "/about", rules.Apply("/about"))
+}
+
+func TestPathTemplateRulesWithoutValidation(t *testing.T) {
+	rules := PathTemplateRules{{Pattern: `\d+`, Replacement: ":id"}}
+	assert.Equal(t, "/view/:id", rules.Apply("/view/1234"))
+}
+
+func TestPathTemplateRulesEmpty(t *testing.T) {
+	var rules PathTemplateRules
+	assert.NoError(t, rules.Validate())
+	assert.False(t, rules.IsConfigured())
+	assert.Equal(t, "", rules.Apply("/view/1234"))
+}
+
+func TestPathTemplateRulesValidate(t *testing.T) {
+	assert.Error(t, PathTemplateRules{{Pattern: `(`}}.Validate())
+	assert.Error(t, PathTemplateRules{{Replacement: ":id"}}.Validate())
+}
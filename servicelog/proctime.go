@@ -0,0 +1,139 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"fmt"
+)
+
+// TrivialRecordPolicy specifies what to do with records whose processing
+// time is below a configured threshold (typically cached or static responses)
+type TrivialRecordPolicy string
+
+const (
+
+	// TrivialRecordPolicyDrop drops trivial records before any further
+	// processing. This is the default policy.
+	TrivialRecordPolicyDrop TrivialRecordPolicy = "drop"
+
+	// TrivialRecordPolicyFlag keeps trivial records but stores them
+	// with the `isTrivial` flag set
+	TrivialRecordPolicyFlag TrivialRecordPolicy = "flag"
+)
+
+// ProcTimeRecord is an optional interface of InputRecord types
+// providing a processing time of the logged request
+type ProcTimeRecord interface {
+	GetProcTime() float64
+}
+
+// ProcTimeThreshold configures how records with a processing
+// time below MinProcTime are handled.
+type ProcTimeThreshold struct {
+
+	// MinProcTime is a minimum processing time (in the units the respective
+	// application logs, typically seconds) of a record to be considered
+	// a real request. Zero disables the threshold.
+	MinProcTime float64 `json:"minProcTime"`
+
+	// TrivialRecordPolicy specifies what to do with records below
+	// the threshold (`drop` - default, `flag`)
+	TrivialRecordPolicy TrivialRecordPolicy `json:"trivialRecordPolicy"`
+}
+
+// IsConfigured tests whether the threshold should be applied
+func (t ProcTimeThreshold) IsConfigured() bool {
+	return t.MinProcTime > 0
+}
+
+// Validate tests the threshold and the policy values
+func (t ProcTimeThreshold) Validate() error {
+	if t.MinProcTime < 0 {
+		return fmt.Errorf("minProcTime must be a non-negative number")
+	}
+	switch t.TrivialRecordPolicy {
+	case "", TrivialRecordPolicyDrop, TrivialRecordPolicyFlag:
+		return nil
+	}
+	return fmt.Errorf("unsupported trivialRecordPolicy: %s", t.TrivialRecordPolicy)
+}
+
+// Policy returns the configured policy or the default one
+func (t ProcTimeThreshold) Policy() TrivialRecordPolicy {
+	if t.TrivialRecordPolicy == "" {
+		return TrivialRecordPolicyDrop
+	}
+	return t.TrivialRecordPolicy
+}
+
+// IsTrivial tests whether the record has a known processing
+// time below the threshold. Records without processing time
+// information (or with a negative one) are never trivial.
+func (t ProcTimeThreshold) IsTrivial(rec InputRecord) bool {
+	if !t.IsConfigured() {
+		return false
+	}
+	tRec, ok := rec.(ProcTimeRecord)
+	if !ok {
+		return false
+	}
+	procTime := tRec.GetProcTime()
+	return procTime >= 0 && procTime < t.MinProcTime
+}
+
+// TrivialOutputRecord wraps an output record to mark it as trivial
+// (i.e. with a processing time below a configured threshold).
+type TrivialOutputRecord struct {
+	OutputRecord
+}
+
+// ToJSON serializes the wrapped record with the `isTrivial` field added
+func (r *TrivialOutputRecord) ToJSON() ([]byte, error) {
+	data, err := r.OutputRecord.ToJSON()
+	if err != nil {
+		return data, err
+	}
+	return prependJSONField(data, "isTrivial", true)
+}
+
+// ToInfluxDB creates tags and values of the wrapped record
+// with the `isTrivial` tag added
+func (r *TrivialOutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
+	tags, values = r.OutputRecord.ToInfluxDB()
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	tags["isTrivial"] = "true"
+	return
+}
+
+// GetAction returns action of the wrapped record
+// in case the record provides it.
+func (r *TrivialOutputRecord) GetAction() string {
+	if tRec, ok := r.OutputRecord.(ActionRecord); ok {
+		return tRec.GetAction()
+	}
+	return ""
+}
+
+// AnonymizeIP masks client IP of the wrapped record
+// in case the record supports it.
+func (r *TrivialOutputRecord) AnonymizeIP() {
+	if tRec, ok := r.OutputRecord.(IPAnonymizer); ok {
+		tRec.AnonymizeIP()
+	}
+}
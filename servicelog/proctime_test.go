@@ -0,0 +1,58 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type procTimeTestRecord struct {
+	procTime float64
+}
+
+func (r *procTimeTestRecord) GetTime() time.Time         { return time.Time{} }
+func (r *procTimeTestRecord) GetClientIP() net.IP        { return nil }
+func (r *procTimeTestRecord) GetUserAgent() string       { return "" }
+func (r *procTimeTestRecord) ClusteringClientID() string { return "" }
+func (r *procTimeTestRecord) ClusterSize() int           { return 0 }
+func (r *procTimeTestRecord) SetCluster(size int)        {}
+func (r *procTimeTestRecord) IsProcessable() bool        { return true }
+func (r *procTimeTestRecord) IsSuspicious() bool         { return false }
+
+func (r *procTimeTestRecord) GetProcTime() float64 {
+	return r.procTime
+}
+
+func TestProcTimeThresholdValidate(t *testing.T) {
+	assert.NoError(t, ProcTimeThreshold{}.Validate())
+	assert.NoError(t, ProcTimeThreshold{MinProcTime: 0.001, TrivialRecordPolicy: TrivialRecordPolicyFlag}.Validate())
+	assert.Error(t, ProcTimeThreshold{MinProcTime: -1}.Validate())
+	assert.Error(t, ProcTimeThreshold{TrivialRecordPolicy: "mark"}.Validate())
+}
+
+func TestProcTimeThresholdIsTrivial(t *testing.T) {
+	th := ProcTimeThreshold{MinProcTime: 0.001}
+	assert.Equal(t, TrivialRecordPolicyDrop, th.Policy())
+	assert.True(t, th.IsTrivial(&procTimeTestRecord{procTime: 0.0002}))
+	assert.False(t, th.IsTrivial(&procTimeTestRecord{procTime: 0.01}))
+	assert.False(t, th.IsTrivial(&procTimeTestRecord{procTime: -1}))
+	assert.False(t, ProcTimeThreshold{}.IsTrivial(&procTimeTestRecord{procTime: 0}))
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+)
+
+// RecordIDStrategy specifies how IDs of stored records are created
+type RecordIDStrategy string
+
+const (
+
+	// RecordIDStrategyNatural uses IDs created by the respective
+	// transformers (typically hashes of some record fields).
+	// This is the default strategy.
+	RecordIDStrategyNatural RecordIDStrategy = "natural"
+
+	// RecordIDStrategyFileOffset creates IDs by hashing the source file
+	// name and the record's position (byte offset) within the file. This
+	// makes the IDs idempotent across reruns even for records without
+	// a natural key. Please note that renaming the file (e.g. by a log
+	// rotation) changes the IDs. Files with the same name (e.g. in different
+	// directories) collide unless they are distinguished by a source key
+	// (see BoundOutputRecord.IDSourceKey).
+	RecordIDStrategyFileOffset RecordIDStrategy = "fileOffset"
+)
+
+// Validate tests whether the strategy is supported. Empty value
+// is accepted and means RecordIDStrategyNatural.
+func (s RecordIDStrategy) Validate() error {
+	switch s {
+	case "", RecordIDStrategyNatural, RecordIDStrategyFileOffset:
+		return nil
+	}
+	return fmt.Errorf("unsupported record ID strategy: %s", s)
+}
+
+// FileOffsetSource returns a value identifying a source file for
+// the RecordIDStrategyFileOffset IDs. Only the base name of the file
+// is used so that the ID does not depend on the location of the file.
+// An optional sourceKey is prepended to distinguish files of the same
+// name.
+func FileOffsetSource(sourceKey, filePath string) string {
+	if sourceKey == "" {
+		return filepath.Base(filePath)
+	}
+	return sourceKey + "/" + filepath.Base(filePath)
+}
+
+// fileOffsetID creates an ID out of a source file (see FileOffsetSource),
+// a position of a record within the file and an index of the record among
+// records created out of the same line.
+func fileOffsetID(sourceKey, filePath string, seekStart int64, outputIdx int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf(
+		"%s#%d#%d", FileOffsetSource(sourceKey, filePath), seekStart, outputIdx)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordIDStrategyValidate(t *testing.T) {
+	assert.NoError(t, RecordIDStrategy("").Validate())
+	assert.NoError(t, RecordIDStrategyFileOffset.Validate())
+	assert.Error(t, RecordIDStrategy("random").Validate())
+}
+
+func TestBoundOutputRecordFileOffsetID(t *testing.T) {
+	rec := &BoundOutputRecord{
+		Rec:        &testOutputRecord{},
+		FilePath:   "/var/log/app/access.log",
+		FilePos:    LogRange{SeekStart: 120, SeekEnd: 200},
+		IDStrategy: RecordIDStrategyFileOffset,
+	}
+	id := rec.GetID()
+	assert.Len(t, id, 40)
+
+	rec2 := *rec
+	rec2.FilePath = "access.log"
+	rec2.FilePos.SeekEnd = 210
+	assert.Equal(t, id, rec2.GetID())
+
+	rec2.FilePos.SeekStart = 200
+	assert.NotEqual(t, id, rec2.GetID())
+
+	rec3 := *rec
+	rec3.OutputIdx = 1
+	assert.NotEqual(t, id, rec3.GetID())
+
+	rec4 := *rec
+	rec4.IDSourceKey = "node2"
+	assert.NotEqual(t, id, rec4.GetID())
+	rec5 := rec4
+	rec5.FilePath = "/var/log/other/access.log"
+	assert.Equal(t, rec4.GetID(), rec5.GetID())
+
+	rec.IDStrategy = RecordIDStrategyNatural
+	assert.Equal(t, "1", rec.GetID())
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+)
+
+// SampleRate specifies a fraction (0.0 - 1.0) of transformed records
+// to be kept. Records are selected deterministically based on their ID
+// so the same record is always either kept or dropped. The zero value
+// (i.e. not configured) and 1.0 mean that all the records are kept.
+type SampleRate float64
+
+// IsConfigured tests whether any records should be dropped
+func (sr SampleRate) IsConfigured() bool {
+	return sr > 0 && sr < 1
+}
+
+// Validate tests whether the rate is within the allowed range
+func (sr SampleRate) Validate() error {
+	if sr < 0 || sr > 1 {
+		return fmt.Errorf("sampleRate must be between 0 and 1")
+	}
+	return nil
+}
+
+// Keeps tests whether the record belongs to the sample
+func (sr SampleRate) Keeps(rec OutputRecord) bool {
+	if !sr.IsConfigured() {
+		return true
+	}
+	sum := sha1.Sum([]byte(rec.GetID()))
+	// use the top 53 bits to get a uniform value from [0, 1)
+	v := binary.BigEndian.Uint64(sum[:8]) >> 11
+	return float64(v)/float64(1<<53) < float64(sr)
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sampledTestRecord struct {
+	testOutputRecord
+	id string
+}
+
+func (r *sampledTestRecord) GetID() string { return r.id }
+
+func TestSampleRateValidate(t *testing.T) {
+	assert.NoError(t, SampleRate(0).Validate())
+	assert.NoError(t, SampleRate(0.25).Validate())
+	assert.NoError(t, SampleRate(1).Validate())
+	assert.Error(t, SampleRate(-0.1).Validate())
+	assert.Error(t, SampleRate(1.5).Validate())
+}
+
+func TestSampleRateKeepsFraction(t *testing.T) {
+	sr := SampleRate(0.2)
+	var numKept int
+	for i := 0; i < 10000; i++ {
+		if sr.Keeps(&sampledTestRecord{id: fmt.Sprintf("rec-%d", i)}) {
+			numKept++
+		}
+	}
+	assert.InDelta(t, 2000, numKept, 200)
+}
+
+func TestSampleRateIsDeterministic(t *testing.T) {
+	sr := SampleRate(0.5)
+	for i := 0; i < 100; i++ {
+		rec := &sampledTestRecord{id: fmt.Sprintf("rec-%d", i)}
+		assert.Equal(t, sr.Keeps(rec), sr.Keeps(rec))
+	}
+}
+
+func TestSampleRateNoOp(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		rec := &sampledTestRecord{id: fmt.Sprintf("rec-%d", i)}
+		assert.True(t, SampleRate(1).Keeps(rec))
+		assert.True(t, SampleRate(0).Keeps(rec))
+	}
+}
@@ -17,8 +17,9 @@
 package shiny
 
 import (
-	"encoding/json"
 	"regexp"
+
+	"klogproc/servicelog"
 )
 
 var (
@@ -28,13 +29,19 @@ var (
 // LineParser is a parser for reading KonText application logs
 type LineParser struct {
 	AnonymousUserID int
+	mode            servicelog.ParsingMode
 }
 
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	rec := &InputRecord{}
-	err := json.Unmarshal([]byte(s), rec)
+	err := servicelog.UnmarshalRecord([]byte(s), rec, lp.mode)
 	if err != nil {
 		return rec, err
 	}
 	return rec, nil
 }
+
+// NewLineParser is a factory for LineParser
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
+}
@@ -27,8 +27,9 @@ import (
 
 // Transformer converts a source log object into a destination one
 type Transformer struct {
-	userMap       *users.UserMap
-	excludeIPList servicelog.ExcludeIPList
+	userMap           *users.UserMap
+	excludeIPList     servicelog.ExcludeIPList
+	conversionActions servicelog.ConversionActionList
 }
 
 // Transform creates a new OutputRecord out of an existing InputRecord
@@ -44,19 +45,20 @@ func (t *Transformer) Transform(logRecord *InputRecord, recType string, tzShiftM
 
 	corpname, isLimited := importCorpname(logRecord.Corpus)
 	r := &OutputRecord{
-		Type:        recType,
-		time:        logRecord.GetTime(),
-		Datetime:    logRecord.GetTime().Add(time.Minute * time.Duration(tzShiftMin)).Format(time.RFC3339),
-		IPAddress:   logRecord.Request.RemoteAddr,
-		UserAgent:   logRecord.Request.HTTPUserAgent,
-		IsAnonymous: userID == -1 || servicelog.UserBelongsToList(userID, anonymousUsers),
-		IsQuery:     isEntryQuery(logRecord.Action),
-		UserID:      strconv.Itoa(userID),
-		Action:      logRecord.Action,
-		Corpus:      corpname,
-		Limited:     isLimited,
-		Subcorpus:   logRecord.Subcorpus,
-		ProcTime:    logRecord.ProcTime,
+		Type:         recType,
+		time:         logRecord.GetTime(),
+		Datetime:     logRecord.GetTime().Add(time.Minute * time.Duration(tzShiftMin)).Format(time.RFC3339),
+		IPAddress:    logRecord.Request.RemoteAddr,
+		UserAgent:    logRecord.Request.HTTPUserAgent,
+		IsAnonymous:  userID == -1 || servicelog.UserBelongsToList(userID, anonymousUsers),
+		IsQuery:      isEntryQuery(logRecord.Action),
+		IsConversion: t.conversionActions.Contains(logRecord.Action),
+		UserID:       strconv.Itoa(userID),
+		Action:       logRecord.Action,
+		Corpus:       corpname,
+		Limited:      isLimited,
+		Subcorpus:    logRecord.Subcorpus,
+		ProcTime:     logRecord.ProcTime,
 	}
 	r.ID = createID(r)
 	return r, nil
@@ -77,9 +79,14 @@ func (t *Transformer) Preprocess(
 
 // NewTransformer is a default constructor for the Transformer.
 // It also loads user ID map from a configured file (if exists).
-func NewTransformer(userMap *users.UserMap, excludeIPList servicelog.ExcludeIPList) *Transformer {
+func NewTransformer(
+	userMap *users.UserMap,
+	excludeIPList servicelog.ExcludeIPList,
+	conversionActions servicelog.ConversionActionList,
+) *Transformer {
 	return &Transformer{
-		userMap:       userMap,
-		excludeIPList: excludeIPList,
+		userMap:           userMap,
+		excludeIPList:     excludeIPList,
+		conversionActions: conversionActions,
 	}
 }
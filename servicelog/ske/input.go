@@ -17,6 +17,7 @@
 package ske
 
 import (
+	"encoding/json"
 	"klogproc/servicelog"
 	"net"
 	"time"
@@ -31,6 +32,20 @@ type Request struct {
 	RemoteAddr       string `json:"REMOTE_ADDR"`
 }
 
+// headerValue returns a value of a request header specified
+// by its logged name (e.g. HTTP_X_FORWARDED_FOR)
+func (r *Request) headerValue(name string) string {
+	switch name {
+	case "HTTP_X_FORWARDED_FOR":
+		return r.HTTPForwardedFor
+	case "HTTP_REMOTE_ADDR":
+		return r.HTTPRemoteAddr
+	case "REMOTE_ADDR":
+		return r.RemoteAddr
+	}
+	return ""
+}
+
 // InputRecord represents a raw-parsed version of SkE's access log
 type InputRecord struct {
 	Action        string
@@ -39,11 +54,19 @@ type InputRecord struct {
 	Datetime      string
 	User          string
 	Request       Request
+	Status        int
 	ProcTime      float32
 	isProcessable bool
+	clientIPConf  *servicelog.ClientIPConf
 	// TODO
 }
 
+// GetHTTPStatus returns the HTTP status of the response
+// (zero if not known)
+func (r *InputRecord) GetHTTPStatus() int {
+	return r.Status
+}
+
 // GetTime returns a normalized log date and time information
 func (r *InputRecord) GetTime() time.Time {
 	if r.isProcessable {
@@ -54,7 +77,13 @@ func (r *InputRecord) GetTime() time.Time {
 
 // GetClientIP returns a normalized IP address info
 func (r *InputRecord) GetClientIP() net.IP {
-	return net.ParseIP(r.Request.RemoteAddr)
+	return servicelog.ResolveClientIP(r.clientIPConf, r.Request.headerValue, r.Request.RemoteAddr)
+}
+
+// SetClientIPConf specifies how the client IP is determined
+// (see servicelog.ClientIPConfReceiver)
+func (r *InputRecord) SetClientIPConf(conf *servicelog.ClientIPConf) {
+	r.clientIPConf = conf
 }
 
 func (rec *InputRecord) ClusteringClientID() string {
@@ -81,3 +110,28 @@ func (r *InputRecord) IsProcessable() bool {
 func (rec *InputRecord) IsSuspicious() bool {
 	return false
 }
+
+// spilledInputRecord extends the record with its internal state
+// so it survives being moved to a log buffer spill segment
+type spilledInputRecord struct {
+	*InputRecord
+	IsProcessable bool                     `json:"_isProcessable"`
+	ClientIPConf  *servicelog.ClientIPConf `json:"_clientIPConf"`
+}
+
+// MarshalSpill encodes the record including its internal state
+// (see logbuffer.Spillable)
+func (rec *InputRecord) MarshalSpill() ([]byte, error) {
+	return json.Marshal(spilledInputRecord{InputRecord: rec, IsProcessable: rec.isProcessable, ClientIPConf: rec.clientIPConf})
+}
+
+// UnmarshalSpill decodes the record encoded by MarshalSpill
+func (rec *InputRecord) UnmarshalSpill(data []byte) error {
+	tmp := spilledInputRecord{InputRecord: rec}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	rec.isProcessable = tmp.IsProcessable
+	rec.clientIPConf = tmp.ClientIPConf
+	return nil
+}
@@ -40,21 +40,22 @@ func isEntryQuery(action string) bool {
 
 // OutputRecord represents a polished version of SkE's access log.
 type OutputRecord struct {
-	ID          string `json:"-"`
-	Type        string `json:"type"`
-	Corpus      string `json:"corpus"`
-	Subcorpus   string `json:"subcorpus"`
-	Limited     bool   `json:"limited"`
-	Action      string `json:"action"`
-	Datetime    string `json:"datetime"`
-	time        time.Time
-	IPAddress   string                   `json:"ipAddress"`
-	UserAgent   string                   `json:"userAgent"`
-	UserID      string                   `json:"userId"`
-	IsAnonymous bool                     `json:"isAnonymous"`
-	IsQuery     bool                     `json:"isQuery"`
-	GeoIP       servicelog.GeoDataRecord `json:"geoip,omitempty"`
-	ProcTime    float32                  `json:"procTime"`
+	ID           string `json:"-"`
+	Type         string `json:"type"`
+	Corpus       string `json:"corpus"`
+	Subcorpus    string `json:"subcorpus"`
+	Limited      bool   `json:"limited"`
+	Action       string `json:"action"`
+	Datetime     string `json:"datetime"`
+	time         time.Time
+	IPAddress    string                   `json:"ipAddress"`
+	UserAgent    string                   `json:"userAgent"`
+	UserID       string                   `json:"userId"`
+	IsAnonymous  bool                     `json:"isAnonymous"`
+	IsQuery      bool                     `json:"isQuery"`
+	IsConversion bool                     `json:"isConversion"`
+	GeoIP        servicelog.GeoDataRecord `json:"geoip,omitempty"`
+	ProcTime     float32                  `json:"procTime"`
 	// TODO
 }
 
@@ -69,6 +70,12 @@ func (r *OutputRecord) SetLocation(countryName string, latitude float32, longitu
 	r.GeoIP.Timezone = timezone
 }
 
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (r *OutputRecord) AnonymizeIP() {
+	r.IPAddress = servicelog.AnonymizeIP(r.IPAddress)
+	r.GeoIP.IP = servicelog.AnonymizeIP(r.GeoIP.IP)
+}
+
 // GetID returns an idempotent ID of the record.
 func (r *OutputRecord) GetID() string {
 	return r.ID
@@ -89,6 +96,11 @@ func (r *OutputRecord) ToJSON() ([]byte, error) {
 	return json.Marshal(r)
 }
 
+// GetAction returns the logged action
+func (r *OutputRecord) GetAction() string {
+	return r.Action
+}
+
 // ToInfluxDB creates tags and values to store in InfluxDB
 func (r *OutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
 	return make(map[string]string), make(map[string]interface{})
@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"klogproc/load/accesslog"
+	"klogproc/servicelog"
 )
 
 const (
@@ -37,7 +38,7 @@ func getAction(path string) string {
 
 // LineParser is a parser for reading SkE application logs
 type LineParser struct {
-	parser accesslog.LineParser
+	parser *accesslog.LineParser
 }
 
 // ParseLine parses a HTTP access log format line
@@ -63,7 +64,14 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 			HTTPRemoteAddr: parsed.IPAddress,
 			RemoteAddr:     parsed.IPAddress, // TODO the same stuff as above?
 		},
+		Status:   parsed.Status,
 		ProcTime: parsed.ProcTime,
 	}
 	return ans, nil
 }
+
+// NewLineParser is a factory for LineParser. The fields argument
+// is optional (see accesslog.FieldSpec).
+func NewLineParser(mode servicelog.ParsingMode, fields accesslog.FieldSpec) *LineParser {
+	return &LineParser{parser: accesslog.NewLineParser(mode, fields)}
+}
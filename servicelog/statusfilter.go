@@ -0,0 +1,101 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HTTPStatusRecord is implemented by input records with a known
+// HTTP response status (typically the access-log based ones)
+type HTTPStatusRecord interface {
+
+	// GetHTTPStatus returns the HTTP status code of the response
+	// or zero if the status is not known
+	GetHTTPStatus() int
+}
+
+// ExcludeStatusList represents a list of HTTP status codes
+// whose records should be ignored. Each item is either an exact
+// code (e.g. `404`), a range (e.g. `500-599`) or a class of codes
+// (e.g. `4xx`).
+type ExcludeStatusList []string
+
+func parseStatusRange(item string) (int, int, error) {
+	item = strings.TrimSpace(item)
+	if len(item) == 3 && strings.HasSuffix(strings.ToLower(item), "xx") {
+		class, err := strconv.Atoi(item[:1])
+		if err != nil || class < 1 || class > 5 {
+			return 0, 0, fmt.Errorf("invalid HTTP status class %s", item)
+		}
+		return class * 100, class*100 + 99, nil
+	}
+	if from, to, ok := strings.Cut(item, "-"); ok {
+		fromCode, err1 := strconv.Atoi(strings.TrimSpace(from))
+		toCode, err2 := strconv.Atoi(strings.TrimSpace(to))
+		if err1 != nil || err2 != nil || fromCode > toCode {
+			return 0, 0, fmt.Errorf("invalid HTTP status range %s", item)
+		}
+		return fromCode, toCode, nil
+	}
+	code, err := strconv.Atoi(item)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid HTTP status code %s", item)
+	}
+	return code, code, nil
+}
+
+// IsConfigured tests whether there is anything to exclude
+func (slist ExcludeStatusList) IsConfigured() bool {
+	return len(slist) > 0
+}
+
+// Validate tests whether all the items are valid codes,
+// ranges or classes
+func (slist ExcludeStatusList) Validate() error {
+	for _, item := range slist {
+		if _, _, err := parseStatusRange(item); err != nil {
+			return fmt.Errorf("failed to validate excludeStatus: %w", err)
+		}
+	}
+	return nil
+}
+
+// Excludes tests an input record whether it should be excluded based
+// on its HTTP status. Records without status information are never excluded.
+func (slist ExcludeStatusList) Excludes(rec InputRecord) bool {
+	tRec, ok := rec.(HTTPStatusRecord)
+	if !ok {
+		return false
+	}
+	status := tRec.GetHTTPStatus()
+	if status == 0 {
+		return false
+	}
+	for _, item := range slist {
+		from, to, err := parseStatusRange(item)
+		if err == nil && status >= from && status <= to {
+			log.Debug().Int("status", status).Msg("excluded HTTP status")
+			return true
+		}
+	}
+	return false
+}
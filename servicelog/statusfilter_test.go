@@ -0,0 +1,52 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type statusTestRecord struct {
+	procTimeTestRecord
+	status int
+}
+
+func (r *statusTestRecord) GetHTTPStatus() int {
+	return r.status
+}
+
+func TestExcludeStatusListValidate(t *testing.T) {
+	assert.NoError(t, ExcludeStatusList{}.Validate())
+	assert.NoError(t, ExcludeStatusList{"404", "5xx", "300-308", "4XX"}.Validate())
+	assert.Error(t, ExcludeStatusList{"40x"}.Validate())
+	assert.Error(t, ExcludeStatusList{"9xx"}.Validate())
+	assert.Error(t, ExcludeStatusList{"500-400"}.Validate())
+	assert.Error(t, ExcludeStatusList{"foo"}.Validate())
+}
+
+func TestExcludeStatusListExcludes(t *testing.T) {
+	slist := ExcludeStatusList{"404", "5xx", "301-302"}
+	assert.True(t, slist.Excludes(&statusTestRecord{status: 404}))
+	assert.True(t, slist.Excludes(&statusTestRecord{status: 503}))
+	assert.True(t, slist.Excludes(&statusTestRecord{status: 302}))
+	assert.False(t, slist.Excludes(&statusTestRecord{status: 200}))
+	assert.False(t, slist.Excludes(&statusTestRecord{status: 403}))
+	assert.False(t, slist.Excludes(&statusTestRecord{status: 0}))
+	assert.False(t, slist.Excludes(&procTimeTestRecord{}))
+}
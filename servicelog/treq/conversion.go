@@ -63,6 +63,15 @@ func (t *Transformer) Transform(logRecord *InputRecord, recType string, tzShiftM
 		return nil, err
 	}
 
+	var resultCount *int
+	if logRecord.NumResults != "" {
+		numRes, err := strconv.Atoi(logRecord.NumResults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert number of results [%s]", logRecord.NumResults)
+		}
+		resultCount = &numRes
+	}
+
 	out := &OutputRecord{
 		Type:        "treq",
 		time:        logRecord.GetTime(),
@@ -82,6 +91,7 @@ func (t *Transformer) Transform(logRecord *InputRecord, recType string, tzShiftM
 		QType:       logRecord.QType,
 		Query:       logRecord.Query,
 		Query2:      logRecord.Query2,
+		ResultCount: resultCount,
 		// GeoIP set elsewhere
 	}
 	out.ID = createID(out)
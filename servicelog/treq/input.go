@@ -38,6 +38,11 @@ type InputRecord struct {
 	IsCaseInsen string
 	Query       string
 	Query2      string
+
+	// NumResults is an optional trailing column with the number
+	// of matching results. Older logs do not contain it in which
+	// case the value is empty.
+	NumResults string
 }
 
 func (rec *InputRecord) GetTime() time.Time {
@@ -39,22 +39,26 @@ type OutputRecord struct {
 	ID          string `json:"-"`
 	Type        string `json:"type"`
 	time        time.Time
-	Datetime    string                   `json:"datetime"`
-	QLang       string                   `json:"qLang"`
-	SecondLang  string                   `json:"secondLang"`
-	IPAddress   string                   `json:"ipAddress"`
-	UserID      string                   `json:"userId"`
-	IsAnonymous bool                     `json:"isAnonymous"`
-	Corpus      string                   `json:"corpus"`
-	Subcorpus   string                   `json:"subcorpus"`
-	IsQuery     bool                     `json:"isQuery"`
-	IsRegexp    bool                     `json:"isRegexp"`
-	IsCaseInsen bool                     `json:"isCaseInsen"`
-	IsMultiWord bool                     `json:"isMultiWord"`
-	IsLemma     bool                     `json:"lemma"`
-	QType       string                   `json:"qType"`
-	Query       string                   `json:"query"`
-	Query2      string                   `json:"query2"`
+	Datetime    string `json:"datetime"`
+	QLang       string `json:"qLang"`
+	SecondLang  string `json:"secondLang"`
+	IPAddress   string `json:"ipAddress"`
+	UserID      string `json:"userId"`
+	IsAnonymous bool   `json:"isAnonymous"`
+	Corpus      string `json:"corpus"`
+	Subcorpus   string `json:"subcorpus"`
+	IsQuery     bool   `json:"isQuery"`
+	IsRegexp    bool   `json:"isRegexp"`
+	IsCaseInsen bool   `json:"isCaseInsen"`
+	IsMultiWord bool   `json:"isMultiWord"`
+	IsLemma     bool   `json:"lemma"`
+	QType       string `json:"qType"`
+	Query       string `json:"query"`
+	Query2      string `json:"query2"`
+	// ResultCount is nil for records from logs without
+	// the result count column so they can be told apart
+	// from queries with zero results
+	ResultCount *int                     `json:"resultCount,omitempty"`
 	GeoIP       servicelog.GeoDataRecord `json:"geoip,omitempty"`
 }
 
@@ -69,6 +73,12 @@ func (r *OutputRecord) SetLocation(countryName string, latitude float32, longitu
 	r.GeoIP.Timezone = timezone
 }
 
+// AnonymizeIP replaces stored client IP addresses with their masked variants
+func (r *OutputRecord) AnonymizeIP() {
+	r.IPAddress = servicelog.AnonymizeIP(r.IPAddress)
+	r.GeoIP.IP = servicelog.AnonymizeIP(r.GeoIP.IP)
+}
+
 // ToJSON converts data to a JSON document (typically for ElasticSearch)
 func (r *OutputRecord) ToJSON() ([]byte, error) {
 	return json.Marshal(r)
@@ -76,7 +86,12 @@ func (r *OutputRecord) ToJSON() ([]byte, error) {
 
 // ToInfluxDB creates tags and values to store in InfluxDB
 func (r *OutputRecord) ToInfluxDB() (tags map[string]string, values map[string]interface{}) {
-	return make(map[string]string), make(map[string]interface{})
+	tags = make(map[string]string)
+	values = make(map[string]interface{})
+	if r.ResultCount != nil {
+		values["resultCount"] = *r.ResultCount
+	}
+	return
 }
 
 // GetID Returns an unique ID of the record
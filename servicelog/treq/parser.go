@@ -22,6 +22,13 @@ import (
 	"strings"
 )
 
+func optionalItem(items []string, idx int) string {
+	if idx < len(items) {
+		return strings.TrimSpace(items[idx])
+	}
+	return ""
+}
+
 // LineParser is a parser for reading Treq application log
 // which is basically a TAB separated list of items.
 type LineParser struct {
@@ -32,7 +39,10 @@ type LineParser struct {
 // Format (adopted from Treq production version 2019-07-15)
 // type D:  leftLang[TAB]rightLang[TAB]viceslovne[TAB]lemma[TAB]dataPack[TAB]regularni[TAB]caseInsen[TAB]hledejCo[TAB]...[TAB]
 // type L:  Gleft   [TAB]Gright   [TAB               ]lemma[TAB]dataPack[TAB].........[TAB].........[TAB]Gquery1[TAB]Gquery2
-// please note that for the two query types, the columns are shifted
+// please note that for the two query types, the columns are shifted.
+// Newer Treq versions append a column with the number of results
+// (i.e. the column following Gquery2 resp. hledejCo); when missing or empty,
+// the record simply has no result count.
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 
 	items := strings.Split(s, "\t")
@@ -51,8 +61,9 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 			Subcorpus: strings.ToUpper(items[7]), // we have to normalize because of Treq
 			// No IsRegexp; not even an empty col
 			// No IsCaseInsen; not even an empty col
-			Query:  items[8],
-			Query2: items[9],
+			Query:      items[8],
+			Query2:     items[9],
+			NumResults: optionalItem(items, 10),
 		}, err
 
 	} else if len(items) >= 12 && items[3] == "D" {
@@ -70,6 +81,7 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 			IsCaseInsen: items[10],
 			Query:       items[11],
 			// No query2 in case of the 'D' query
+			NumResults: optionalItem(items, 12),
 		}, err
 	}
 	return nil, servicelog.NewLineParsingError(
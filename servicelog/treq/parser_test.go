@@ -79,3 +79,46 @@ func TestParseLineTypeL(t *testing.T) {
 	assert.Equal(t, "rychlost", rec.Query2)
 
 }
+
+func TestParseLineWithNumResults(t *testing.T) {
+	line := `2019-07-24T11:52:42+02:00	127.0.0.1	1531	D	cs	en	1	2	ACQUIS|EUROPARL|CORE	3	4	mocnost	0`
+	p := LineParser{}
+	rec, err := p.ParseLine(line, 71)
+	assert.Nil(t, err)
+	assert.Equal(t, "0", rec.NumResults)
+
+	line = `2017-03-26T14:27:27+02:00	127.0.0.1	-	L	en	cs	1	PressEurop|Syndicate|Subtitles	gear	rychlost	42`
+	rec, err = p.ParseLine(line, 72)
+	assert.Nil(t, err)
+	assert.Equal(t, "42", rec.NumResults)
+}
+
+func TestTransformResultCount(t *testing.T) {
+	p := LineParser{}
+	tr := Transformer{}
+
+	rec, err := p.ParseLine(`2019-07-24T11:52:42+02:00	127.0.0.1	1531	D	cs	en	1	0	CORE	0	0	mocnost	0`, 1)
+	assert.NoError(t, err)
+	out, err := tr.Transform(rec, "treq", 0, []int{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, out.ResultCount) {
+		assert.Equal(t, 0, *out.ResultCount)
+	}
+	_, values := out.ToInfluxDB()
+	assert.Equal(t, 0, values["resultCount"])
+	data, err := out.ToJSON()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"resultCount":0`)
+
+	// older logs without the column (incl. the empty trailing one)
+	rec, err = p.ParseLine("2019-07-24T11:52:42+02:00\t127.0.0.1\t1531\tD\tcs\ten\t1\t0\tCORE\t0\t0\tmocnost\t\t", 2)
+	assert.NoError(t, err)
+	out, err = tr.Transform(rec, "treq", 0, []int{})
+	assert.NoError(t, err)
+	assert.Nil(t, out.ResultCount)
+	_, values = out.ToInfluxDB()
+	assert.NotContains(t, values, "resultCount")
+	data, err = out.ToJSON()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "resultCount")
+}
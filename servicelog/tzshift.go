@@ -0,0 +1,115 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TZShift specifies a correction of time values of log records
+// which do not contain a proper time zone information. It is either
+// a fixed number of minutes added to the logged values (a JSON number)
+// or a name of an IANA time zone (a JSON string, e.g. `Europe/Prague`)
+// the logged values are in. In the latter case, the correction is
+// calculated for each record individually so daylight saving time
+// transitions are handled properly.
+type TZShift struct {
+	minutes  int
+	location *time.Location
+}
+
+// MinutesAt returns the correction (in minutes) applicable
+// to a record logged with the time t. The time is interpreted
+// as a wall clock time (i.e. its own location is ignored).
+func (s TZShift) MinutesAt(t time.Time) int {
+	if s.location == nil {
+		return s.minutes
+	}
+	local := time.Date(
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), s.location)
+	_, offset := local.Zone()
+	return -offset / 60
+}
+
+// IsZero returns true if no correction is applied
+func (s TZShift) IsZero() bool {
+	return s.location == nil && s.minutes == 0
+}
+
+func (s TZShift) String() string {
+	if s.location != nil {
+		return s.location.String()
+	}
+	return fmt.Sprintf("%d min.", s.minutes)
+}
+
+// UnmarshalJSON accepts either a number of minutes or a time zone name
+func (s *TZShift) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		ans, err := NewTZShiftLocation(name)
+		if err != nil {
+			return err
+		}
+		*s = ans
+		return nil
+	}
+	var minutes int
+	if err := json.Unmarshal(data, &minutes); err != nil {
+		return fmt.Errorf("tzShift must be either a number of minutes or a time zone name")
+	}
+	*s = NewTZShiftMinutes(minutes)
+	return nil
+}
+
+// MarshalJSON writes the value in the same form it has been configured in
+func (s TZShift) MarshalJSON() ([]byte, error) {
+	if s.location != nil {
+		return json.Marshal(s.location.String())
+	}
+	return []byte(strconv.Itoa(s.minutes)), nil
+}
+
+// OrLocation returns the correction itself in case it is configured.
+// Otherwise, a correction for the provided location is returned.
+func (s TZShift) OrLocation(loc *time.Location) TZShift {
+	if !s.IsZero() || loc == nil {
+		return s
+	}
+	return TZShift{location: loc}
+}
+
+// NewTZShiftMinutes creates a fixed time correction
+func NewTZShiftMinutes(minutes int) TZShift {
+	return TZShift{minutes: minutes}
+}
+
+// NewTZShiftLocation creates a time correction for logs written
+// in a local time of the specified IANA time zone
+func NewTZShiftLocation(name string) (TZShift, error) {
+	if name == "" {
+		return TZShift{}, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return TZShift{}, fmt.Errorf("invalid tzShift time zone: %w", err)
+	}
+	return TZShift{location: loc}, nil
+}
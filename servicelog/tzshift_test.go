@@ -0,0 +1,77 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTZShiftUnmarshalMinutes(t *testing.T) {
+	var s TZShift
+	assert.NoError(t, json.Unmarshal([]byte(`120`), &s))
+	assert.Equal(t, 120, s.MinutesAt(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)))
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `120`, string(data))
+}
+
+func TestTZShiftUnmarshalInvalid(t *testing.T) {
+	var s TZShift
+	assert.Error(t, json.Unmarshal([]byte(`"Europe/Nowhere"`), &s))
+	assert.Error(t, json.Unmarshal([]byte(`true`), &s))
+}
+
+func TestTZShiftSpringForward(t *testing.T) {
+	var s TZShift
+	assert.NoError(t, json.Unmarshal([]byte(`"Europe/Prague"`), &s))
+	// 2024-03-31 02:00 CET -> 03:00 CEST
+	assert.Equal(t, -60, s.MinutesAt(time.Date(2024, 3, 31, 1, 59, 59, 0, time.UTC)))
+	assert.Equal(t, -120, s.MinutesAt(time.Date(2024, 3, 31, 3, 0, 0, 0, time.UTC)))
+	data, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `"Europe/Prague"`, string(data))
+}
+
+func TestTZShiftFallBack(t *testing.T) {
+	s, err := NewTZShiftLocation("Europe/Prague")
+	assert.NoError(t, err)
+	// 2024-10-27 03:00 CEST -> 02:00 CET
+	assert.Equal(t, -120, s.MinutesAt(time.Date(2024, 10, 27, 1, 59, 59, 0, time.UTC)))
+	assert.Equal(t, -60, s.MinutesAt(time.Date(2024, 10, 27, 3, 0, 0, 0, time.UTC)))
+}
+
+func TestTZShiftOrLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Prague")
+	assert.NoError(t, err)
+	s := TZShift{}.OrLocation(loc)
+	assert.Equal(t, -120, s.MinutesAt(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)))
+	assert.Equal(t, -60, s.MinutesAt(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)))
+	s = NewTZShiftMinutes(30).OrLocation(loc)
+	assert.Equal(t, 30, s.MinutesAt(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)))
+	assert.True(t, TZShift{}.OrLocation(nil).IsZero())
+}
+
+func TestTZShiftIgnoresRecordLocation(t *testing.T) {
+	s, err := NewTZShiftLocation("Europe/Prague")
+	assert.NoError(t, err)
+	loc := time.FixedZone("X", 5*3600)
+	assert.Equal(t, -60, s.MinutesAt(time.Date(2024, 1, 10, 23, 30, 0, 0, loc)))
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"regexp"
+	"strings"
+
+	"klogproc/load"
+
+	"github.com/rs/zerolog/log"
+)
+
+// userAgentPatterns contains compiled (case-insensitive)
+// user agent substrings and regular expressions
+type userAgentPatterns struct {
+	substrings []string
+	regexps    []*regexp.Regexp
+}
+
+func (p userAgentPatterns) matches(userAgent string) bool {
+	lcUserAgent := strings.ToLower(userAgent)
+	for _, v := range p.substrings {
+		if strings.Contains(lcUserAgent, v) {
+			return true
+		}
+	}
+	for _, rx := range p.regexps {
+		if rx.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileUserAgentPatterns splits patterns into plain substrings and
+// regular expressions (prefixed by load.UserAgentRegexpPrefix).
+// Invalid regular expressions are logged and skipped (they should
+// be reported by the configuration validation).
+func compileUserAgentPatterns(patterns []string) userAgentPatterns {
+	var ans userAgentPatterns
+	for _, p := range patterns {
+		if strings.HasPrefix(p, load.UserAgentRegexpPrefix) {
+			rx, err := regexp.Compile("(?i)" + strings.TrimPrefix(p, load.UserAgentRegexpPrefix))
+			if err != nil {
+				log.Error().Err(err).Str("pattern", p).Msg("invalid user agent pattern, skipping")
+				continue
+			}
+			ans.regexps = append(ans.regexps, rx)
+
+		} else {
+			ans.substrings = append(ans.substrings, strings.ToLower(p))
+		}
+	}
+	return ans
+}
+
+// UserAgentMatcher detects bots and monitoring tools based
+// on (case-insensitive) user agent substrings and regular expressions.
+type UserAgentMatcher struct {
+	botPatterns     userAgentPatterns
+	monitorPatterns userAgentPatterns
+}
+
+// AgentIsBot tests whether the user agent belongs to a known bot
+func (m *UserAgentMatcher) AgentIsBot(userAgent string) bool {
+	if m == nil {
+		return false
+	}
+	return m.botPatterns.matches(userAgent)
+}
+
+// AgentIsMonitor tests whether the user agent belongs to a known
+// monitoring tool
+func (m *UserAgentMatcher) AgentIsMonitor(userAgent string) bool {
+	if m == nil {
+		return false
+	}
+	return m.monitorPatterns.matches(userAgent)
+}
+
+// AgentIsLoggable tests whether a record with the user agent
+// should be stored. A nil matcher accepts all the agents.
+func (m *UserAgentMatcher) AgentIsLoggable(userAgent string) bool {
+	return !m.AgentIsBot(userAgent) && !m.AgentIsMonitor(userAgent)
+}
+
+// NewUserAgentMatcher creates a matcher based on the bot detection
+// configuration. In case the conf is nil or it contains no user agent
+// patterns, nil is returned which means no user agent based filtering.
+func NewUserAgentMatcher(conf *load.BotDetectionConf) *UserAgentMatcher {
+	if conf == nil || len(conf.UserAgentSubstrings) == 0 && len(conf.MonitorSubstrings) == 0 {
+		return nil
+	}
+	return &UserAgentMatcher{
+		botPatterns:     compileUserAgentPatterns(conf.UserAgentSubstrings),
+		monitorPatterns: compileUserAgentPatterns(conf.MonitorSubstrings),
+	}
+}
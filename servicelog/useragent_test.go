@@ -0,0 +1,90 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package servicelog
+
+import (
+	"testing"
+
+	"klogproc/load"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAgentMatcherNoPatterns(t *testing.T) {
+	m := NewUserAgentMatcher(&load.BotDetectionConf{})
+	assert.Nil(t, m)
+	assert.True(t, m.AgentIsLoggable("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"))
+	assert.True(t, m.AgentIsLoggable("curl/8.0.1"))
+}
+
+func TestUserAgentMatcherCustom(t *testing.T) {
+	m := NewUserAgentMatcher(&load.BotDetectionConf{
+		UserAgentSubstrings: []string{"FooCrawl"},
+		MonitorSubstrings:   []string{"MyCheck"},
+	})
+	assert.True(t, m.AgentIsBot("fOOcrawl/1.0"))
+	assert.False(t, m.AgentIsBot("Googlebot/2.1"))
+	assert.True(t, m.AgentIsMonitor("mycheck 1.2"))
+	assert.False(t, m.AgentIsLoggable("MyCheck"))
+}
+
+func TestNilUserAgentMatcher(t *testing.T) {
+	m := NewUserAgentMatcher(nil)
+	assert.Nil(t, m)
+	assert.True(t, m.AgentIsLoggable("Googlebot/2.1"))
+}
+
+func TestUserAgentMatcherOnlyBots(t *testing.T) {
+	m := NewUserAgentMatcher(&load.BotDetectionConf{
+		UserAgentSubstrings: []string{"googlebot"},
+	})
+	assert.True(t, m.AgentIsBot("Mozilla/5.0 (compatible; GOOGLEBOT/2.1)"))
+	assert.False(t, m.AgentIsMonitor("UptimeRobot/2.0"))
+	for _, ua := range []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+		"UptimeRobot/2.0",
+		"curl/8.0.1",
+		"",
+	} {
+		assert.True(t, m.AgentIsLoggable(ua), ua)
+	}
+}
+
+func TestUserAgentMatcherRegexp(t *testing.T) {
+	m := NewUserAgentMatcher(&load.BotDetectionConf{
+		UserAgentSubstrings: []string{"re:^foo[0-9]+/", "barbot"},
+		MonitorSubstrings:   []string{"re:health-?check"},
+	})
+	assert.True(t, m.AgentIsBot("Foo42/1.0"))
+	assert.False(t, m.AgentIsBot("xfoo42/1.0"))
+	assert.True(t, m.AgentIsBot("BarBot"))
+	assert.False(t, m.AgentIsBot("re:^foo[0-9]+/"))
+	assert.True(t, m.AgentIsMonitor("k8s HealthCheck"))
+	assert.True(t, m.AgentIsMonitor("health-check"))
+	assert.True(t, m.AgentIsLoggable("Firefox"))
+}
+
+func TestBotDetectionConfInvalidRegexp(t *testing.T) {
+	bc := load.BufferConf{
+		HistoryLookupItems:   10,
+		AnalysisIntervalSecs: 10,
+		BotDetection:         &load.BotDetectionConf{MonitorSubstrings: []string{"re:[a-"}},
+	}
+	assert.Error(t, bc.Validate())
+	bc.BotDetection.MonitorSubstrings = []string{"re:[a-z]+check"}
+	assert.NoError(t, bc.Validate())
+}
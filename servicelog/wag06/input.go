@@ -17,6 +17,7 @@
 package wag06
 
 import (
+	"encoding/json"
 	"klogproc/servicelog"
 	"net"
 	"time"
@@ -41,12 +42,19 @@ type InputRecord struct {
 	Queries             []string
 	Datetime            string
 	Request             Request
+	Status              int
 	ProcTime            float32
 	isProcessable       bool
 	IsMobileClient      bool
 	HasPosSpecification bool
 }
 
+// GetHTTPStatus returns the HTTP status of the response
+// (zero if not known)
+func (r *InputRecord) GetHTTPStatus() int {
+	return r.Status
+}
+
 // GetTime returns a normalized log date and time information
 func (r *InputRecord) GetTime() time.Time {
 	if r.isProcessable {
@@ -76,6 +84,11 @@ func (r *InputRecord) GetUserAgent() string {
 	return r.Request.HTTPUserAgent
 }
 
+// GetProcTime returns the logged processing time
+func (r *InputRecord) GetProcTime() float64 {
+	return float64(r.ProcTime)
+}
+
 // IsProcessable returns true if there was no error in reading the record
 func (r *InputRecord) IsProcessable() bool {
 	return r.isProcessable
@@ -84,3 +97,26 @@ func (r *InputRecord) IsProcessable() bool {
 func (rec *InputRecord) IsSuspicious() bool {
 	return false
 }
+
+// spilledInputRecord extends the record with its internal state
+// so it survives being moved to a log buffer spill segment
+type spilledInputRecord struct {
+	*InputRecord
+	IsProcessable bool `json:"_isProcessable"`
+}
+
+// MarshalSpill encodes the record including its internal state
+// (see logbuffer.Spillable)
+func (rec *InputRecord) MarshalSpill() ([]byte, error) {
+	return json.Marshal(spilledInputRecord{InputRecord: rec, IsProcessable: rec.isProcessable})
+}
+
+// UnmarshalSpill decodes the record encoded by MarshalSpill
+func (rec *InputRecord) UnmarshalSpill(data []byte) error {
+	tmp := spilledInputRecord{InputRecord: rec}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	rec.isProcessable = tmp.IsProcessable
+	return nil
+}
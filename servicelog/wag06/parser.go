@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"klogproc/load/accesslog"
+	"klogproc/servicelog"
 
 	"github.com/rs/zerolog/log"
 )
@@ -117,7 +118,7 @@ func isProcessable(action string) bool {
 
 // LineParser is a parser for reading KonText application logs
 type LineParser struct {
-	parser accesslog.LineParser
+	parser *accesslog.LineParser
 }
 
 // ParseLine parses a HTTP access log format line
@@ -143,6 +144,7 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 			RemoteAddr:     parsed.IPAddress, // TODO the same stuff as above?
 			Referer:        parsed.Referrer,
 		},
+		Status:              parsed.Status,
 		ProcTime:            parsed.ProcTime,
 		QueryType:           action.action, // for legacy reasons (otherwise it is redundant)
 		Lang1:               action.lang1,
@@ -153,3 +155,9 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	}
 	return ans, nil
 }
+
+// NewLineParser is a factory for LineParser. The fields argument
+// is optional (see accesslog.FieldSpec).
+func NewLineParser(mode servicelog.ParsingMode, fields accesslog.FieldSpec) *LineParser {
+	return &LineParser{parser: accesslog.NewLineParser(mode, fields)}
+}
@@ -71,10 +71,12 @@ func NewTransformer(
 	excludeIPList []string,
 	realtimeClock bool,
 	emailNotifier notifications.Notifier,
+	botExporter analysis.BotCandidateExporter,
 ) *Transformer {
 	var analyzer servicelog.Preprocessor
 	if bufferConf.BotDetection != nil {
-		analyzer = analysis.NewBotAnalyzer[*InputRecord]("wag", bufferConf, realtimeClock, emailNotifier)
+		analyzer = analysis.NewBotAnalyzer[*InputRecord](
+			"wag", bufferConf, realtimeClock, emailNotifier, botExporter)
 
 	} else {
 		analyzer = analysis.NewNullAnalyzer[*InputRecord]("wag")
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 
 	"klogproc/load/accesslog"
+	"klogproc/servicelog"
 )
 
 const (
@@ -38,13 +39,14 @@ const (
 // LineParser is a parser for reading KonText application logs
 type LineParser struct {
 	parser accesslog.LineParser
+	mode   servicelog.ParsingMode
 }
 
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	var record InputRecord
-	err := json.Unmarshal([]byte(s), &record)
+	err := servicelog.UnmarshalRecord([]byte(s), &record, lp.mode)
 	if err != nil {
-		if _, ok := err.(*json.SyntaxError); ok {
+		if _, ok := err.(*json.SyntaxError); ok && !lp.mode.IsStrict() {
 			// we ignore syntax errors as we expect some lines to contain garbage
 			return &record, nil
 		}
@@ -53,3 +55,8 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	record.isProcessable = true
 	return &record, nil
 }
+
+// NewLineParser is a factory for LineParser
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
+}
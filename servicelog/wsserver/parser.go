@@ -17,9 +17,10 @@
 package wsserver
 
 import (
-	"encoding/json"
 	"regexp"
 
+	"klogproc/servicelog"
+
 	"github.com/rs/zerolog/log"
 )
 
@@ -28,14 +29,18 @@ var (
 )
 
 type LineParser struct {
+	mode servicelog.ParsingMode
 }
 
 func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	srch := recMatch.FindStringSubmatch(s)
 	ans := &InputRecord{isProcessable: false}
 	if len(srch) > 0 {
-		err := json.Unmarshal([]byte(srch[1]), ans)
+		err := servicelog.UnmarshalRecord([]byte(srch[1]), ans, lp.mode)
 		if err != nil {
+			if lp.mode.IsStrict() {
+				return ans, servicelog.NewLineParsingError(lineNum, err.Error())
+			}
 			log.Error().Err(err).Msg("")
 
 		} else {
@@ -48,3 +53,8 @@ func (lp *LineParser) ParseLine(s string, lineNum int64) (*InputRecord, error) {
 	}
 	return ans, nil
 }
+
+// NewLineParser is a factory for LineParser
+func NewLineParser(mode servicelog.ParsingMode) *LineParser {
+	return &LineParser{mode: mode}
+}
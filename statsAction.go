@@ -0,0 +1,174 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"klogproc/config"
+	"klogproc/load/batch"
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext018"
+
+	"github.com/rs/zerolog/log"
+)
+
+// logFileStats contains aggregate information about
+// processed log file(s)
+type logFileStats struct {
+	TotalLines      int64          `json:"totalLines"`
+	ParseErrors     int64          `json:"parseErrors"`
+	NonProcessable  int            `json:"nonProcessable"`
+	Ignored         int            `json:"ignored"`
+	TransformErrors int            `json:"transformErrors"`
+	Processed       int            `json:"processed"`
+	UniqueClientIPs int            `json:"uniqueClientIps"`
+	Actions         map[string]int `json:"actions"`
+	FirstRecord     *time.Time     `json:"firstRecord"`
+	LastRecord      *time.Time     `json:"lastRecord"`
+}
+
+func (s *logFileStats) printText() {
+	fmt.Printf("total lines:          %d\n", s.TotalLines)
+	fmt.Printf("parse errors:         %d\n", s.ParseErrors)
+	fmt.Printf("non-processable:      %d\n", s.NonProcessable)
+	fmt.Printf("ignored:              %d\n", s.Ignored)
+	fmt.Printf("transform errors:     %d\n", s.TransformErrors)
+	fmt.Printf("processed:            %d\n", s.Processed)
+	fmt.Printf("unique client IPs:    %d\n", s.UniqueClientIPs)
+	if s.FirstRecord != nil {
+		fmt.Printf("first record:         %s\n", s.FirstRecord.Format(time.RFC3339))
+		fmt.Printf("last record:          %s\n", s.LastRecord.Format(time.RFC3339))
+	}
+	fmt.Printf("distinct actions:     %d\n", len(s.Actions))
+	actions := make([]string, 0, len(s.Actions))
+	for k := range s.Actions {
+		actions = append(actions, k)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return s.Actions[actions[i]] > s.Actions[actions[j]]
+	})
+	for _, action := range actions {
+		fmt.Printf("    %-20s %d\n", action, s.Actions[action])
+	}
+}
+
+// statsProcessor is a LogItemProcessor which collects statistics
+// about records passing through a wrapped CNKLogProcessor (i.e. with
+// the same filters as the `batch` action applied) and produces no output
+// records
+type statsProcessor struct {
+	*CNKLogProcessor
+	clientIPs map[string]bool
+	stats     logFileStats
+}
+
+func (sp *statsProcessor) ProcItem(logRec servicelog.InputRecord, tzShiftMin int) []servicelog.OutputRecord {
+	if logRec.IsProcessable() {
+		if ip := logRec.GetClientIP(); ip != nil {
+			sp.clientIPs[ip.String()] = true
+		}
+		recTime := logRec.GetTime()
+		if sp.stats.FirstRecord == nil || recTime.Before(*sp.stats.FirstRecord) {
+			sp.stats.FirstRecord = &recTime
+		}
+		if sp.stats.LastRecord == nil || recTime.After(*sp.stats.LastRecord) {
+			sp.stats.LastRecord = &recTime
+		}
+	}
+	for _, outRec := range sp.CNKLogProcessor.ProcItem(logRec, tzShiftMin) {
+		if strings.HasSuffix(outRec.GetType(), kontext018.AggregateTypeSuffix) {
+			continue
+		}
+		sp.stats.Processed++
+		if action := recordAction(outRec); action != "" {
+			sp.stats.Actions[action]++
+		}
+	}
+	return []servicelog.OutputRecord{}
+}
+
+// setIgnored fills in numbers of skipped records based
+// on the wrapped processor's ignored-reason counters
+func (sp *statsProcessor) setIgnored() {
+	counts := sp.ignored.Counts()
+	sp.stats.NonProcessable = int(counts[servicelog.IgnoredReasonNotProcessable])
+	sp.stats.TransformErrors = int(counts[servicelog.IgnoredReasonTransformError])
+	sp.stats.Ignored = int(counts.Total()) - sp.stats.NonProcessable - sp.stats.TransformErrors
+}
+
+// recordAction extracts the `action` property of an output
+// record (if the app type provides one)
+func recordAction(rec servicelog.OutputRecord) string {
+	data, err := rec.ToJSON()
+	if err != nil {
+		return ""
+	}
+	var tmp struct {
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return ""
+	}
+	return tmp.Action
+}
+
+// runStatsAction parses configured log file(s) (logFiles section)
+// and prints aggregate statistics. No data are written anywhere.
+func runStatsAction(conf *config.Main, options *ProcessOptions) {
+	if conf.LogFiles == nil {
+		log.Fatal().Msg("missing configuration data (logFiles) for the `stats` action")
+	}
+	if err := conf.LogFiles.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("logFiles validation error")
+	}
+	cnkProcessor, err := newInspectionLogProcessor(conf)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to run stats action")
+	}
+	processor := &statsProcessor{
+		CNKLogProcessor: cnkProcessor,
+		clientIPs:       make(map[string]bool),
+		stats:           logFileStats{Actions: make(map[string]int)},
+	}
+	proc := batch.CreateLogFileProcFunc(processor, options.datetimeRange)
+	// note: we use the lowest possible min. timestamp so even records with
+	// missing/invalid datetime reach the processor and get counted
+	result := proc(context.Background(), conf.LogFiles, math.MinInt64)
+	processor.stats.TotalLines = result.NumLines
+	processor.stats.ParseErrors = result.NumParseErrors
+	processor.stats.UniqueClientIPs = len(processor.clientIPs)
+	processor.setIgnored()
+
+	if options.statsJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(processor.stats); err != nil {
+			log.Fatal().Err(err).Msg("failed to encode stats")
+		}
+
+	} else {
+		processor.stats.printText()
+	}
+}
@@ -17,95 +17,175 @@
 package main
 
 import (
+	"context"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"klogproc/analysis"
 	"klogproc/config"
+	"klogproc/fsop"
+	"klogproc/geodb"
+	"klogproc/load"
 	"klogproc/load/alarm"
 	"klogproc/load/batch"
 	"klogproc/load/tail"
 	"klogproc/logbuffer"
+	"klogproc/monitoring"
 	"klogproc/notifications"
 	"klogproc/save"
+	"klogproc/save/clickhouse"
+	"klogproc/save/couchdb"
+	"klogproc/save/deadletter"
 	"klogproc/save/elastic"
 	"klogproc/save/influx"
+	"klogproc/save/loki"
+	"klogproc/save/stdout"
 	"klogproc/servicelog"
 	"klogproc/trfactory"
 	"klogproc/users"
 
 	"github.com/czcorpus/cnc-gokit/collections"
-	"github.com/oschwald/geoip2-golang"
 	"github.com/rs/zerolog/log"
 )
 
 // -----
 
 type tailProcessor struct {
-	appType           string
-	filePath          string
-	version           string
-	tzShift           int
-	checkIntervalSecs int
-	maxLinesPerCheck  int
-	conf              *config.Main
-	lineParser        batch.LineParser
-	logTransformer    servicelog.LogItemTransformer
-	geoDB             *geoip2.Reader
-	anonymousUsers    []int
-	elasticChunkSize  int
-	influxChunkSize   int
-	alarm             servicelog.AppErrorRegister
-	analysis          chan<- servicelog.InputRecord
-	logBuffer         servicelog.ServiceLogBuffer
-	dryRun            bool
+	appType             string
+	filePath            string
+	version             string
+	tzShift             servicelog.TZShift
+	checkIntervalSecs   int
+	maxLinesPerCheck    int
+	recordDelimiter     byte
+	conf                *config.Main
+	lineParser          batch.LineParser
+	logTransformer      servicelog.LogItemTransformer
+	geoDB               *geodb.Reader
+	anonymousUsers      []int
+	elasticChunkSize    int
+	influxChunkSize     int
+	clickHouseChunkSize int
+	lokiChunkSize       int
+	couchDBChunkSize    int
+	alarm               servicelog.AppErrorRegister
+	lineOutcomes        servicelog.LineOutcomeRegister
+	logBuffer           servicelog.ServiceLogBuffer
+	uaMatcher           *servicelog.UserAgentMatcher
+	parseErrReporter    *servicelog.ParseErrorReporter
+	dryRun              bool
+	idStrategy          servicelog.RecordIDStrategy
+	idSourceKey         string
+	storeRawInput       bool
+	eofMarkerIdleSecs   int
+	transformWorkers    int
+	inactivityLimitSecs int
+	actionFilter        *servicelog.ActionFilter
+	sampleRate          servicelog.SampleRate
+	// preprocMutex serializes preprocessing (which may involve
+	// stateful analysis) in case transform workers are used
+	preprocMutex sync.Mutex
+	// workerPools contains per-check pools of transform workers
+	workerPools      map[*tail.LogDataWriter]*tail.OrderedPool
+	workerPoolsMutex sync.Mutex
+	// lastRecordTime is UnixNano of the most recent record (0 = none)
+	lastRecordTime atomic.Int64
+	// lastActivity is UnixNano of the last processed line
+	// (or of the processor's creation)
+	lastActivity atomic.Int64
+	// startedAt is the time of the processor's creation
+	startedAt    time.Time
+	numProcessed atomic.Int64
+	numErrors    atomic.Int64
+	// numChecks is the number of finished check cycles
+	numChecks atomic.Int64
+	ignored   servicelog.IgnoredCounter
+}
+
+// entryOutput contains everything a single line produces
+type entryOutput struct {
+	records     []*servicelog.BoundOutputRecord
+	ignored     []save.IgnoredItemMsg
+	deadLetters []save.DeadLetterMsg
+}
+
+func (tp *tailProcessor) startTransformPool(dataWriter *tail.LogDataWriter) {
+	pool := tail.NewOrderedPool(
+		tp.transformWorkers,
+		func(item string, logPosition servicelog.LogRange) func() {
+			out := tp.transformEntry(item, logPosition)
+			return func() {
+				tp.writeEntryOutput(dataWriter, out)
+			}
+		},
+	)
+	tp.workerPoolsMutex.Lock()
+	tp.workerPools[dataWriter] = pool
+	tp.workerPoolsMutex.Unlock()
+}
+
+func (tp *tailProcessor) getTransformPool(dataWriter *tail.LogDataWriter) *tail.OrderedPool {
+	tp.workerPoolsMutex.Lock()
+	defer tp.workerPoolsMutex.Unlock()
+	return tp.workerPools[dataWriter]
+}
+
+// stopTransformPool waits for all the lines passed to the pool
+// to be processed
+func (tp *tailProcessor) stopTransformPool(dataWriter *tail.LogDataWriter) {
+	tp.workerPoolsMutex.Lock()
+	pool, ok := tp.workerPools[dataWriter]
+	delete(tp.workerPools, dataWriter)
+	tp.workerPoolsMutex.Unlock()
+	if ok {
+		pool.Close()
+	}
 }
 
 func (tp *tailProcessor) OnCheckStart() (tail.LineProcConfirmChan, *tail.LogDataWriter) {
 	itemConfirm := make(tail.LineProcConfirmChan, 10)
 	dataWriter := tail.LogDataWriter{
-		Elastic: make(chan *servicelog.BoundOutputRecord, tp.elasticChunkSize*2),
-		Influx:  make(chan *servicelog.BoundOutputRecord, tp.influxChunkSize),
-		Ignored: make(chan save.IgnoredItemMsg),
+		Elastic:    make(chan *servicelog.BoundOutputRecord, tp.elasticChunkSize*2),
+		Influx:     make(chan *servicelog.BoundOutputRecord, tp.influxChunkSize),
+		ClickHouse: make(chan *servicelog.BoundOutputRecord, tp.clickHouseChunkSize),
+		Loki:       make(chan *servicelog.BoundOutputRecord, tp.lokiChunkSize),
+		CouchDB:    make(chan *servicelog.BoundOutputRecord, tp.couchDBChunkSize),
+		Stdout:     make(chan *servicelog.BoundOutputRecord),
+		Ignored:    make(chan save.IgnoredItemMsg),
+		DeadLetter: make(chan save.DeadLetterMsg),
 	}
 
 	go func() {
-		var waitMergeEnd sync.WaitGroup
-		waitMergeEnd.Add(3)
+		var confirmChans []<-chan save.ConfirmMsg
 		if tp.dryRun {
-			confirmChan1 := save.RunWriteConsumer(dataWriter.Elastic, false)
-			go func() {
-				for item := range confirmChan1 {
-					itemConfirm <- item
-				}
-				waitMergeEnd.Done()
-			}()
-			confirmChan2 := save.RunWriteConsumer(dataWriter.Influx, false)
-			go func() {
-				for item := range confirmChan2 {
-					itemConfirm <- item
-				}
-				waitMergeEnd.Done()
-			}()
+			for _, ch := range dataWriter.RecordSinks() {
+				confirmChans = append(confirmChans, save.RunWriteConsumer(ch, false))
+			}
 			log.Warn().Msg("using dry-run mode, output goes to stdout")
 
 		} else {
-			confirmChan1 := elastic.RunWriteConsumer(
-				tp.appType, &tp.conf.ElasticSearch, dataWriter.Elastic)
-			go func() {
-				for item := range confirmChan1 {
-					itemConfirm <- item
-				}
-				waitMergeEnd.Done()
-			}()
-			confirmChan2 := influx.RunWriteConsumer(
-				&tp.conf.InfluxDB, dataWriter.Influx)
-			go func() {
-				for item := range confirmChan2 {
+			confirmChans = []<-chan save.ConfirmMsg{
+				elastic.RunWriteConsumer(
+					context.Background(), tp.appType, &tp.conf.ElasticSearch, dataWriter.Elastic),
+				influx.RunWriteConsumer(&tp.conf.InfluxDB, dataWriter.Influx),
+				clickhouse.RunWriteConsumer(&tp.conf.ClickHouse, dataWriter.ClickHouse),
+				loki.RunWriteConsumer(&tp.conf.Loki, dataWriter.Loki),
+				couchdb.RunWriteConsumer(&tp.conf.CouchDB, dataWriter.CouchDB),
+				stdout.RunWriteConsumer(&tp.conf.Stdout, dataWriter.Stdout),
+			}
+		}
+		var waitMergeEnd sync.WaitGroup
+		waitMergeEnd.Add(len(confirmChans) + 2)
+		for _, confirmChan := range confirmChans {
+			go func(confirmChan <-chan save.ConfirmMsg) {
+				for item := range confirmChan {
 					itemConfirm <- item
 				}
 				waitMergeEnd.Done()
-			}()
+			}(confirmChan)
 		}
 		go func() {
 			for msg := range dataWriter.Ignored {
@@ -113,68 +193,326 @@ func (tp *tailProcessor) OnCheckStart() (tail.LineProcConfirmChan, *tail.LogData
 			}
 			waitMergeEnd.Done()
 		}()
+		var deadLetterConf *deadletter.Conf
+		if !tp.dryRun {
+			deadLetterConf = &tp.conf.DeadLetter
+		}
+		deadLetterConfirm := deadletter.RunWriteConsumer(
+			deadLetterConf, &tp.conf.ElasticSearch, dataWriter.DeadLetter)
+		go func() {
+			for msg := range deadLetterConfirm {
+				itemConfirm <- msg
+			}
+			waitMergeEnd.Done()
+		}()
 		waitMergeEnd.Wait()
 		close(itemConfirm)
 	}()
 
+	if tp.transformWorkers > 1 {
+		tp.startTransformPool(&dataWriter)
+	}
 	return itemConfirm, &dataWriter
 }
 
+// OnEntry processes a line either directly or (in case transform
+// workers are configured) by passing it to a worker. In both cases,
+// records are written in the order of lines.
 func (tp *tailProcessor) OnEntry(
 	dataWriter *tail.LogDataWriter,
 	item string,
 	logPosition servicelog.LogRange,
 ) {
-	parsed, err := tp.lineParser.ParseLine(item, -1) // TODO (line num - hard to keep track)
+	if pool := tp.getTransformPool(dataWriter); pool != nil {
+		pool.Add(item, logPosition)
+		return
+	}
+	tp.writeEntryOutput(dataWriter, tp.transformEntry(item, logPosition))
+}
+
+// updateLastRecordTime sets the time of the most recent record
+// in case the provided one is more recent
+func (tp *tailProcessor) updateLastRecordTime(recTime int64) {
+	for {
+		curr := tp.lastRecordTime.Load()
+		if recTime <= curr || tp.lastRecordTime.CompareAndSwap(curr, recTime) {
+			return
+		}
+	}
+}
+
+// transformEntry parses and transforms a line. The produced records
+// (or information about the line being ignored) are not written
+// directly so the method can be called concurrently for more lines
+// (see writeEntryOutput).
+func (tp *tailProcessor) transformEntry(
+	item string,
+	logPosition servicelog.LogRange,
+) *entryOutput {
+	out := &entryOutput{}
+	tp.lastActivity.Store(time.Now().UnixNano())
+	parsed, err := tp.lineParser.ParseLine(item, logPosition.Line)
+	if err == servicelog.ErrEmptyLine {
+		tp.ignoreEntry(out, logPosition, servicelog.IgnoredReasonEmptyLine)
+		return out
+	}
 	if err != nil {
 		switch tErr := err.(type) {
 		case servicelog.LineParsingError:
-			log.Warn().Err(tErr).Msgf("parsing error in file %s", tp.filePath)
+			tp.parseErrReporter.Report(tErr, logPosition.Line)
 		default:
-			log.Error().Err(tErr).Send()
+			log.Error().Err(tErr).Int64("line", logPosition.Line).Send()
 		}
-		dataWriter.Ignored <- save.NewIgnoredItemMsg(tp.filePath, logPosition)
-		return
+		monitoring.ParseErrors.WithLabelValues(tp.appType, tp.filePath).Inc()
+		tp.numErrors.Add(1)
+		tp.registerLineOutcome(true)
+		tp.ignored.Inc(servicelog.IgnoredReasonParseError)
+		out.deadLetters = append(out.deadLetters, save.NewDeadLetterMsg(
+			tp.appType, tp.filePath, logPosition, item, err, servicelog.IgnoredReasonParseError))
+		return out
 	}
-	if parsed.IsProcessable() {
-		for _, precord := range tp.logTransformer.Preprocess(parsed, tp.logBuffer) {
+	monitoring.RecordsParsed.WithLabelValues(tp.appType, tp.filePath).Inc()
+	if parsed.IsProcessable() && tp.uaMatcher.AgentIsLoggable(parsed.GetUserAgent()) {
+		tp.preprocMutex.Lock()
+		prepInp := tp.logTransformer.Preprocess(parsed, tp.logBuffer)
+		for _, precord := range prepInp {
 			tp.logBuffer.AddRecord(precord)
-			outRec, err := tp.logTransformer.Transform(precord, tp.appType, tp.tzShift, tp.anonymousUsers)
+		}
+		tp.preprocMutex.Unlock()
+		var rawInput string
+		if tp.storeRawInput {
+			rawInput = item
+		}
+		if len(prepInp) == 0 {
+			// e.g. an excluded IP or a record rejected by a filter script
+			monitoring.RecordsIgnored.WithLabelValues(tp.appType, tp.filePath).Inc()
+			tp.ignoreEntry(out, logPosition, servicelog.IgnoredReasonExcluded)
+		}
+		outIdx := 0
+		for _, precord := range prepInp {
+			outRecs, err := tp.logTransformer.Transform(
+				precord, tp.appType, tp.tzShift.MinutesAt(precord.GetTime()), tp.anonymousUsers)
 			if err != nil {
 				log.Error().Err(err).Msg("Failed to transform processable record")
-				dataWriter.Ignored <- save.NewIgnoredItemMsg(tp.filePath, logPosition)
-				return
+				monitoring.TransformErrors.WithLabelValues(tp.appType, tp.filePath).Inc()
+				tp.numErrors.Add(1)
+				tp.registerLineOutcome(true)
+				tp.ignored.Inc(servicelog.IgnoredReasonTransformError)
+				out.deadLetters = append(out.deadLetters, save.NewDeadLetterMsg(
+					tp.appType, tp.filePath, logPosition, item, err, servicelog.IgnoredReasonTransformError))
+				return out
 			}
-			applyLocation(precord, tp.geoDB, outRec)
-			dataWriter.Elastic <- &servicelog.BoundOutputRecord{
-				FilePath: tp.filePath,
-				Rec:      outRec,
-				FilePos:  logPosition,
-			}
-			dataWriter.Influx <- &servicelog.BoundOutputRecord{
-				FilePath: tp.filePath,
-				Rec:      outRec,
-				FilePos:  logPosition,
+			for _, outRec := range outRecs {
+				idx := outIdx
+				outIdx++
+				if tp.actionFilter.Ignores(outRec) {
+					monitoring.RecordsIgnored.WithLabelValues(tp.appType, tp.filePath).Inc()
+					tp.ignoreEntry(out, logPosition, servicelog.IgnoredReasonActionFiltered)
+					continue
+				}
+				if !tp.sampleRate.Keeps(outRec) {
+					monitoring.RecordsIgnored.WithLabelValues(tp.appType, tp.filePath).Inc()
+					tp.ignoreEntry(out, logPosition, servicelog.IgnoredReasonSampledOut)
+					continue
+				}
+				tp.updateLastRecordTime(outRec.GetTime().UnixNano())
+				tp.numProcessed.Add(1)
+				trfactory.ApplyLocation(precord, tp.geoDB, outRec, tp.conf.AnonymizeIP)
+				out.records = append(out.records, tp.newBoundRecord(outRec, logPosition, idx, rawInput))
 			}
 		}
+		tp.registerLineOutcome(false)
 
 	} else {
-		dataWriter.Ignored <- save.NewIgnoredItemMsg(tp.filePath, logPosition)
+		monitoring.RecordsIgnored.WithLabelValues(tp.appType, tp.filePath).Inc()
+		reason := servicelog.IgnoredReasonNotProcessable
+		if parsed.IsProcessable() {
+			reason = servicelog.IgnoredReasonBot
+		}
+		tp.ignoreEntry(out, logPosition, reason)
+		tp.registerLineOutcome(false)
+	}
+	return out
+}
+
+// newBoundRecord binds an output record to its position in the file
+func (tp *tailProcessor) newBoundRecord(
+	outRec servicelog.OutputRecord,
+	logPosition servicelog.LogRange,
+	outputIdx int,
+	rawInput string,
+) *servicelog.BoundOutputRecord {
+	return &servicelog.BoundOutputRecord{
+		FilePath:          tp.filePath,
+		Rec:               outRec,
+		FilePos:           logPosition,
+		InstanceID:        tp.conf.InstanceID,
+		InstanceIDInRecID: tp.conf.InstanceIDInRecordID,
+		IDStrategy:        tp.idStrategy,
+		IDSourceKey:       tp.idSourceKey,
+		OutputIdx:         outputIdx,
+		RawInput:          rawInput,
+	}
+}
+
+// ignoreEntry registers an entry which has not produced any record
+// (the entry is confirmed once the output is written)
+func (tp *tailProcessor) ignoreEntry(
+	out *entryOutput,
+	logPosition servicelog.LogRange,
+	reason servicelog.IgnoredReason,
+) {
+	tp.ignored.Inc(reason)
+	out.ignored = append(out.ignored, save.NewIgnoredItemMsg(tp.filePath, logPosition, reason))
+}
+
+// writeEntryOutput sends records produced by a line to all the outputs
+// and confirms ignored and failed items
+func (tp *tailProcessor) writeEntryOutput(dataWriter *tail.LogDataWriter, out *entryOutput) {
+	for _, rec := range out.records {
+		for _, sink := range dataWriter.RecordSinks() {
+			sinkRec := *rec
+			sink <- &sinkRec
+		}
+	}
+	for _, msg := range out.ignored {
+		dataWriter.Ignored <- msg
+	}
+	for _, msg := range out.deadLetters {
+		dataWriter.DeadLetter <- msg
+	}
+}
+
+// OnEOF writes an EOF marker record. As the marker is not an application
+// record, it is written only to outputs able to handle records of different
+// types (i.e. InfluxDB and ClickHouse are skipped). The marker is bound to
+// the position of the last line so it does not affect the worklog
+// (except for a failed write in which case the last line is read again).
+// Please note that the record ID is always created "naturally" as the
+// `fileOffset` strategy would produce the ID of the last record.
+// In case transform workers are used, the marker is written only after
+// all the lines passed to the workers so its confirmation cannot precede
+// confirmations of the lines.
+func (tp *tailProcessor) OnEOF(dataWriter *tail.LogDataWriter, lastPosition servicelog.LogRange) {
+	if pool := tp.getTransformPool(dataWriter); pool != nil {
+		pool.Drain()
+	}
+	marker := servicelog.NewEOFMarker(tp.appType, tp.filePath, lastPosition, time.Now())
+	log.Info().
+		Str("logFile", tp.filePath).
+		Int64("inode", lastPosition.Inode).
+		Int64("size", lastPosition.SeekEnd).
+		Msg("file fully read and idle, writing EOF marker")
+	for _, ch := range []chan *servicelog.BoundOutputRecord{
+		dataWriter.Elastic, dataWriter.Loki, dataWriter.CouchDB, dataWriter.Stdout} {
+		ch <- &servicelog.BoundOutputRecord{
+			FilePath:          tp.filePath,
+			Rec:               marker,
+			FilePos:           lastPosition,
+			InstanceID:        tp.conf.InstanceID,
+			InstanceIDInRecID: tp.conf.InstanceIDInRecordID,
+			IDStrategy:        servicelog.RecordIDStrategyNatural,
+		}
 	}
 }
 
 func (tp *tailProcessor) OnCheckStop(dataWriter *tail.LogDataWriter) {
-	close(dataWriter.Elastic)
-	close(dataWriter.Influx)
+	tp.stopTransformPool(dataWriter)
+	for _, sink := range dataWriter.RecordSinks() {
+		close(sink)
+	}
 	close(dataWriter.Ignored)
+	close(dataWriter.DeadLetter)
+	if lastRecordTime := tp.lastRecordTime.Load(); lastRecordTime > 0 {
+		monitoring.ProcessingLag.WithLabelValues(tp.appType, tp.filePath).Set(
+			time.Since(time.Unix(0, lastRecordTime)).Seconds())
+	}
+	tp.parseErrReporter.FlushExpired()
 	tp.alarm.Evaluate()
+	tp.numChecks.Add(1)
 }
 
 func (tp *tailProcessor) OnQuit() {
+	tp.parseErrReporter.Flush()
 	tp.alarm.Reset()
-	if tp.analysis != nil {
-		close(tp.analysis)
+	ignored := tp.ignored.Counts()
+	log.Info().
+		Str("logFile", tp.filePath).
+		Int64("total", ignored.Total()).
+		Any("reasons", ignored).
+		Msg("ignored entries")
+}
+
+// Status provides the current status of the processed file
+// based on the processor's counters and the worklog
+func (tp *tailProcessor) Status(worklog *tail.Worklog) monitoring.FileStatus {
+	pos := worklog.GetData(tp.filePath)
+	ans := monitoring.FileStatus{
+		FilePath:         tp.filePath,
+		AppType:          tp.appType,
+		Inode:            pos.Inode,
+		SeekPosition:     pos.SeekEnd,
+		Line:             pos.Line,
+		RecordsProcessed: tp.numProcessed.Load(),
+		Errors:           tp.numErrors.Load(),
+	}
+	if lastRecordTime := tp.lastRecordTime.Load(); lastRecordTime > 0 {
+		t := time.Unix(0, lastRecordTime)
+		ans.LastRecordTime = &t
+	}
+	return ans
+}
+
+// Health tells whether the file's lines are processed within
+// the configured inactivity limit (the file's own or the one from monConf)
+// and whether the reader keeps up with the file (by comparing the file
+// size with the position stored in the worklog). Within the startup grace
+// period (until the first check is finished) the file is always reported
+// as healthy.
+func (tp *tailProcessor) Health(worklog *tail.Worklog, monConf *monitoring.Conf) monitoring.FileHealth {
+	limit := tp.inactivityLimitSecs
+	if limit == 0 {
+		limit = monConf.InactivityLimitSecs
+	}
+	lastActivity := time.Unix(0, tp.lastActivity.Load())
+	inactive := limit > 0 && time.Since(lastActivity) > time.Duration(limit)*time.Second
+	ans := monitoring.FileHealth{
+		FilePath:            tp.filePath,
+		AppType:             tp.appType,
+		LastActivity:        lastActivity,
+		InactivityLimitSecs: limit,
+		Status:              monitoring.FileHealthOK,
+	}
+	inode, size, err := fsop.GetFileProps(tp.filePath)
+	if err == nil {
+		ans.FileSize = size
+		pos := worklog.GetData(tp.filePath)
+		if pos.Inode == inode {
+			ans.SeekPosition = pos.SeekEnd
+		}
+		ans.BytesBehind = ans.FileSize - ans.SeekPosition
+		if ans.BytesBehind < 0 {
+			ans.BytesBehind = 0
+		}
+	}
+	if monConf.MaxLagBytes > 0 && ans.BytesBehind > monConf.MaxLagBytes ||
+		monConf.MaxLagSecs > 0 && ans.BytesBehind > 0 &&
+			time.Since(lastActivity) > time.Duration(monConf.MaxLagSecs)*time.Second {
+		ans.Behind = true
+		ans.Status = monitoring.FileHealthBehind
+
+	} else if inactive {
+		ans.Stale = true
+		ans.Status = monitoring.FileHealthNotUpdated
+	}
+	if (ans.Stale || ans.Behind) && tp.numChecks.Load() == 0 &&
+		monConf.IsInStartupGrace(tp.startedAt, tp.checkIntervalSecs, time.Now()) {
+		ans.Stale = false
+		ans.Behind = false
+		ans.Status = monitoring.FileHealthOK
+		ans.StartupGrace = true
 	}
+	return ans
 }
 
 func (tp *tailProcessor) AppType() string {
@@ -193,6 +531,18 @@ func (tp *tailProcessor) MaxLinesPerCheck() int {
 	return tp.maxLinesPerCheck
 }
 
+func (tp *tailProcessor) NumErrors() int64 {
+	return tp.numErrors.Load()
+}
+
+func (tp *tailProcessor) RecordDelimiter() byte {
+	return tp.recordDelimiter
+}
+
+func (tp *tailProcessor) EOFMarkerIdleSecs() int {
+	return tp.eofMarkerIdleSecs
+}
+
 // -----
 
 func newProcAlarm(
@@ -200,6 +550,9 @@ func newProcAlarm(
 	conf *tail.Conf,
 	notifier notifications.Notifier,
 ) (servicelog.AppErrorRegister, error) {
+	if conf.ErrorRatioAlarm != nil && notifier != nil {
+		return alarm.NewTailRatioAlarm(*conf.ErrorRatioAlarm, tailConf, notifier), nil
+	}
 	if conf.NumErrorsAlarm > 0 && conf.ErrCountTimeRangeSecs > 0 && notifier != nil {
 		return alarm.NewTailProcAlarm(
 			conf.NumErrorsAlarm,
@@ -215,7 +568,7 @@ func newProcAlarm(
 func newTailProcessor(
 	tailConf tail.FileConf,
 	conf config.Main,
-	geoDB *geoip2.Reader,
+	geoDB *geodb.Reader,
 	userMap *users.UserMap,
 	logBuffers map[string]servicelog.ServiceLogBuffer,
 	options *ProcessOptions,
@@ -223,7 +576,8 @@ func newTailProcessor(
 
 	var notifier notifications.Notifier
 	notifier, err := notifications.NewNotifier(
-		conf.EmailNotification, conf.ConomiNotification, conf.TimezoneLocation())
+		conf.EmailNotification, conf.ConomiNotification, conf.WebhookNotification,
+		conf.TimezoneLocation())
 	if err != nil {
 		log.Fatal().Msgf("Failed to initialize e-mail notifier: %s", err)
 	}
@@ -232,24 +586,49 @@ func newTailProcessor(
 	if err != nil {
 		log.Fatal().Msgf("Failed to initialize alarm: %s", err)
 	}
-	lineParser, err := batch.NewLineParser(tailConf.AppType, tailConf.Version, procAlarm)
+	lineOutcomes, _ := procAlarm.(servicelog.LineOutcomeRegister)
+	lineParser, err := batch.NewLineParser(
+		tailConf.AppType, tailConf.Version, tailConf.TraceIDField, tailConf.ParsingMode,
+		tailConf.AccessLogFields, procAlarm)
+	if err != nil {
+		log.Fatal().Msgf("Failed to initialize parser: %s", err)
+	}
+	lineParser, err = batch.WrapWithInputFormat(
+		lineParser, tailConf.InputFormat, tailConf.AppType, tailConf.Version)
+	if err != nil {
+		log.Fatal().Msgf("Failed to initialize parser: %s", err)
+	}
+	lineParser, err = batch.WrapWithFraming(lineParser, tailConf.InputFraming)
+	if err != nil {
+		log.Fatal().Msgf("Failed to initialize parser: %s", err)
+	}
+	lineParser = batch.WrapWithClientIPConf(lineParser, tailConf.ClientIP)
+	lineParser, err = batch.WrapWithUnwrapPattern(lineParser, tailConf.UnwrapPattern)
 	if err != nil {
 		log.Fatal().Msgf("Failed to initialize parser: %s", err)
 	}
+	recordDelimiter, err := load.ParseRecordDelimiter(tailConf.RecordDelimiter)
+	if err != nil {
+		log.Fatal().Msgf("Failed to initialize file reader: %s", err)
+	}
+	actionFilter, err := servicelog.NewActionFilter(tailConf.AppType, conf.IgnoreActions[tailConf.AppType])
+	if err != nil {
+		log.Fatal().Msgf("Failed to initialize action filter: %s", err)
+	}
 	logTransformer, err := trfactory.GetLogTransformer(
-		tailConf.AppType,
-		tailConf.Version,
-		tailConf.Buffer,
-		userMap,
-		tailConf.ExcludeIPList,
-		true,
-		notifier,
+		tailConf.ProcessingConf,
+		trfactory.TransformerDeps{
+			UserMap:       userMap,
+			RealtimeClock: true,
+			Notifier:      notifier,
+			BotExporter:   newBotCandidateExporter(&conf, options),
+		},
 	)
 	if err != nil {
 		log.Fatal().Msgf("Failed to initialize transformer: %s", err)
 	}
 	log.Info().Msgf(
-		"Creating tail processor for %s, app type: %s, app version: %s, tzShift: %d",
+		"Creating tail processor for %s, app type: %s, app version: %s, tzShift: %s",
 		filepath.Clean(tailConf.Path), tailConf.AppType, tailConf.Version, tailConf.TZShift)
 
 	var buffStorage analysis.BufferedRecords
@@ -316,39 +695,79 @@ func newTailProcessor(
 		)
 	}
 
-	return &tailProcessor{
-		appType:           tailConf.AppType,
-		filePath:          filepath.Clean(tailConf.Path), // note: this is not a full path normalization !
-		version:           tailConf.Version,
-		tzShift:           tailConf.TZShift,
-		checkIntervalSecs: conf.LogTail.IntervalSecs,     // TODO maybe per-app type here ??
-		maxLinesPerCheck:  conf.LogTail.MaxLinesPerCheck, // TODO dtto
-		conf:              &conf,
-		lineParser:        lineParser,
-		logTransformer:    logTransformer,
-		geoDB:             geoDB,
-		anonymousUsers:    conf.AnonymousUsers,
-		elasticChunkSize:  conf.ElasticSearch.PushChunkSize,
-		influxChunkSize:   conf.InfluxDB.PushChunkSize,
-		alarm:             procAlarm,
-		logBuffer:         buffStorage,
-		dryRun:            options.dryRun,
+	ans := &tailProcessor{
+		appType:             tailConf.AppType,
+		filePath:            filepath.Clean(tailConf.Path), // note: this is not a full path normalization !
+		version:             tailConf.Version,
+		tzShift:             tailConf.TZShift,
+		idStrategy:          tailConf.RecordIDStrategy,
+		idSourceKey:         tailConf.RecordIDSourceKey,
+		storeRawInput:       tailConf.StoreRawInput,
+		eofMarkerIdleSecs:   tailConf.EOFMarkerIdleSecs,
+		transformWorkers:    tailConf.TransformWorkers,
+		inactivityLimitSecs: tailConf.InactivityLimitSecs,
+		startedAt:           time.Now(),
+		workerPools:         make(map[*tail.LogDataWriter]*tail.OrderedPool),
+		checkIntervalSecs:   conf.LogTail.IntervalSecs,     // TODO maybe per-app type here ??
+		maxLinesPerCheck:    conf.LogTail.MaxLinesPerCheck, // TODO dtto
+		recordDelimiter:     recordDelimiter,
+		conf:                &conf,
+		lineParser:          lineParser,
+		logTransformer:      logTransformer,
+		geoDB:               geoDB,
+		anonymousUsers:      conf.AnonymousUsers,
+		elasticChunkSize:    conf.ElasticSearch.PushChunkSize,
+		influxChunkSize:     conf.InfluxDB.PushChunkSize,
+		clickHouseChunkSize: conf.ClickHouse.PushChunkSize,
+		lokiChunkSize:       conf.Loki.PushChunkSize,
+		couchDBChunkSize:    conf.CouchDB.PushChunkSize,
+		alarm:               procAlarm,
+		lineOutcomes:        lineOutcomes,
+		logBuffer:           buffStorage,
+		uaMatcher:           newUserAgentMatcher(tailConf.Buffer),
+		actionFilter:        actionFilter,
+		sampleRate:          tailConf.SampleRate,
+		parseErrReporter: servicelog.NewParseErrorReporter(
+			filepath.Clean(tailConf.Path), conf.LogTail.ParseErrorLogWindowSecs),
+		dryRun: options.dryRun,
+	}
+	ans.lastActivity.Store(ans.startedAt.UnixNano())
+	return ans
+}
+
+// registerLineOutcome reports a result of a line processing
+// to an alarm interested in it (if any)
+func (tp *tailProcessor) registerLineOutcome(failed bool) {
+	if tp.lineOutcomes == nil {
+		return
+	}
+	if failed {
+		tp.lineOutcomes.OnLineFailed()
+
+	} else {
+		tp.lineOutcomes.OnLineProcessed()
 	}
 }
 
 // -----
 
+func filterFilesByAppType(files []tail.FileConf, appType string) []tail.FileConf {
+	ans := make([]tail.FileConf, 0, len(files))
+	for _, f := range files {
+		if f.AppType == appType {
+			ans = append(ans, f)
+		}
+	}
+	return ans
+}
+
 func runTailAction(
 	conf *config.Main,
 	options *ProcessOptions,
-	geoDB *geoip2.Reader,
+	geoDB *geodb.Reader,
 	userMap *users.UserMap,
 	finishEvt chan bool,
 ) {
-	tailProcessors := make([]tail.FileTailProcessor, len(conf.LogTail.Files))
-	var wg sync.WaitGroup
-	wg.Add(len(conf.LogTail.Files))
-
 	logBuffers := make(map[string]servicelog.ServiceLogBuffer)
 	fullFiles, err := conf.LogTail.FullFiles()
 	if err != nil {
@@ -356,12 +775,100 @@ func runTailAction(
 		finishEvt <- true
 		return
 	}
+	if options.onlyAppType != "" {
+		fullFiles = filterFilesByAppType(fullFiles, options.onlyAppType)
+		log.Warn().
+			Str("appType", options.onlyAppType).
+			Int("numFiles", len(fullFiles)).
+			Msg("processing only files of the selected app type")
+		if len(fullFiles) == 0 {
+			log.Error().Msgf("no files of app type %s configured", options.onlyAppType)
+			finishEvt <- true
+			return
+		}
+	}
+
+	tailProcessors := make([]tail.FileTailProcessor, len(fullFiles))
+	var wg sync.WaitGroup
+	wg.Add(len(fullFiles))
 
 	for i, f := range fullFiles {
 		tailProcessors[i] = newTailProcessor(f, *conf, geoDB, userMap, logBuffers, options)
 	}
+	// allProcessors contains also processors of files found later
+	// by expanding configured glob patterns (the last processor
+	// of a file is kept in case the file is found repeatedly)
+	var allProcessorsMu sync.Mutex
+	allProcessors := make(map[string]tail.FileTailProcessor)
+	for _, tp := range tailProcessors {
+		allProcessors[tp.FilePath()] = tp
+	}
+	newProcessor := func(fc tail.FileConf) tail.FileTailProcessor {
+		if options.onlyAppType != "" && fc.AppType != options.onlyAppType {
+			return nil
+		}
+		tp := newTailProcessor(fc, *conf, geoDB, userMap, logBuffers, options)
+		allProcessorsMu.Lock()
+		allProcessors[tp.FilePath()] = tp
+		allProcessorsMu.Unlock()
+		return tp
+	}
+	worklog := tail.NewWorklog(conf.LogTail.WorklogPath)
+	// ctx is cancelled (and the monitoring server is shut down)
+	// once the tailing finishes
+	ctx, cancel := context.WithCancel(context.Background())
+	var srv *monitoring.Server
+	tailFinishEvt := make(chan bool)
+	go func() {
+		v := <-tailFinishEvt
+		cancel()
+		if srv != nil {
+			srv.Stop()
+		}
+		finishEvt <- v
+	}()
+	if conf.Monitoring.IsConfigured() {
+		srv = monitoring.NewServer(&conf.Monitoring)
+		srv.RegisterProbes(ctx, func() bool {
+			// only the initially configured files are considered
+			// (files found later via glob patterns do not affect readiness)
+			for _, tp := range tailProcessors {
+				if tp.(*tailProcessor).numChecks.Load() == 0 {
+					return false
+				}
+			}
+			return true
+		})
+		srv.RegisterFileStatus(func() []monitoring.FileStatus {
+			allProcessorsMu.Lock()
+			defer allProcessorsMu.Unlock()
+			ans := make([]monitoring.FileStatus, 0, len(allProcessors))
+			for _, tp := range allProcessors {
+				ans = append(ans, tp.(*tailProcessor).Status(worklog))
+			}
+			sort.Slice(ans, func(i, j int) bool {
+				return ans[i].FilePath < ans[j].FilePath
+			})
+			return ans
+		})
+		if conf.Monitoring.HasHealthCheck() {
+			srv.RegisterHealth(func() []monitoring.FileHealth {
+				allProcessorsMu.Lock()
+				defer allProcessorsMu.Unlock()
+				ans := make([]monitoring.FileHealth, 0, len(allProcessors))
+				for _, tp := range allProcessors {
+					ans = append(ans, tp.(*tailProcessor).Health(worklog, &conf.Monitoring))
+				}
+				sort.Slice(ans, func(i, j int) bool {
+					return ans[i].FilePath < ans[j].FilePath
+				})
+				return ans
+			})
+		}
+		srv.Start()
+	}
 	go func() {
 		wg.Wait()
 	}()
-	go tail.Run(conf.LogTail, tailProcessors, finishEvt)
+	go tail.Run(conf.LogTail, tailProcessors, worklog, newProcessor, tailFinishEvt)
 }
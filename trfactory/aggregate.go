@@ -0,0 +1,44 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trfactory
+
+import (
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext018"
+)
+
+// queryAggregateTransformer wraps a KonText 0.18 transformer and adds
+// a kontext018.QueryAggregateRecord for each record with a corpus.
+type queryAggregateTransformer struct {
+	servicelog.LogItemTransformer
+}
+
+func (qt *queryAggregateTransformer) Transform(
+	logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int,
+) ([]servicelog.OutputRecord, error) {
+	ans, err := qt.LogItemTransformer.Transform(logRec, recType, tzShiftMin, anonymousUsers)
+	if err != nil {
+		return ans, err
+	}
+	for _, rec := range ans {
+		tRec, ok := rec.(*kontext018.OutputRecord)
+		if ok && tRec.Corpus != "" {
+			ans = append(ans, kontext018.NewQueryAggregateRecord(tRec))
+		}
+	}
+	return ans, nil
+}
@@ -26,10 +26,10 @@ type apiguardTransformer struct {
 
 // Transform transforms APIGuard app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (k *apiguardTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (k *apiguardTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*apiguard.InputRecord)
 	if ok {
-		return k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by APIGuard transformer %T", logRec)
 }
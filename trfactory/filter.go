@@ -0,0 +1,99 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trfactory
+
+import (
+	"encoding/json"
+	"time"
+
+	"klogproc/scripting"
+	"klogproc/servicelog"
+
+	"github.com/rs/zerolog/log"
+)
+
+// scriptInputProvider is implemented by transformers supporting
+// the Lua `should_record(input)` function (i.e. scripting support
+// is opt-in per transformer). In case the record is not of the type
+// the transformer expects, false is returned.
+type scriptInputProvider interface {
+	ScriptInput(rec servicelog.InputRecord) (scripting.Input, bool)
+}
+
+// filteringTransformer wraps an app-specific transformer and drops
+// input records rejected by a user-defined Lua `filter(record)`
+// or `should_record(input)` function.
+// The filter is evaluated before the wrapped Preprocess so rejected
+// records do not enter any (possibly expensive) analysis.
+type filteringTransformer struct {
+	servicelog.LogItemTransformer
+	filter      *scripting.Filter
+	inpProvider scriptInputProvider
+}
+
+// filterInput converts an input record to data passed to the Lua
+// filter. Besides record's own fields (named by their JSON encoding),
+// some normalized values are provided (`_clientIp`, `_userAgent`
+// and `_datetime`).
+func filterInput(rec servicelog.InputRecord) (map[string]any, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	ans := make(map[string]any)
+	if err := json.Unmarshal(data, &ans); err != nil {
+		return nil, err
+	}
+	if ip := rec.GetClientIP(); ip != nil {
+		ans["_clientIp"] = ip.String()
+	}
+	ans["_userAgent"] = rec.GetUserAgent()
+	ans["_datetime"] = rec.GetTime().Format(time.RFC3339)
+	return ans, nil
+}
+
+func (ft *filteringTransformer) accepts(rec servicelog.InputRecord) (bool, error) {
+	if ft.filter.HasFilter() {
+		input, err := filterInput(rec)
+		if err != nil {
+			return true, err
+		}
+		if ok, err := ft.filter.Accepts(input); err != nil || !ok {
+			return ok, err
+		}
+	}
+	if ft.filter.HasShouldRecord() {
+		if input, ok := ft.inpProvider.ScriptInput(rec); ok {
+			return ft.filter.ShouldRecord(input)
+		}
+	}
+	return true, nil
+}
+
+func (ft *filteringTransformer) Preprocess(
+	rec servicelog.InputRecord, prevRecs servicelog.ServiceLogBuffer,
+) []servicelog.InputRecord {
+	accepted, err := ft.accepts(rec)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to evaluate record filter, keeping the record")
+		accepted = true
+	}
+	if !accepted {
+		return []servicelog.InputRecord{}
+	}
+	return ft.LogItemTransformer.Preprocess(rec, prevRecs)
+}
@@ -16,6 +16,7 @@ package trfactory
 
 import (
 	"fmt"
+	"klogproc/scripting"
 	"klogproc/servicelog"
 	"klogproc/servicelog/kontext013"
 	"klogproc/servicelog/kontext015"
@@ -29,10 +30,10 @@ type konText013Transformer struct {
 
 // Transform transforms KonText app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (k *konText013Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (k *konText013Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*kontext013.InputRecord)
 	if ok {
-		return k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by KonText transformer %T", logRec)
 }
@@ -56,10 +57,10 @@ type konText015Transformer struct {
 
 // Transform transforms KonText app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (k *konText015Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (k *konText015Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*kontext015.InputRecord)
 	if ok {
-		return k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by KonText transformer %T", logRec)
 }
@@ -83,10 +84,10 @@ type konText018Transformer struct {
 
 // Transform transforms KonText app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (k *konText018Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (k *konText018Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*kontext018.QueryInputRecord)
 	if ok {
-		return k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(k.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by KonText transformer %T", logRec)
 }
@@ -95,6 +96,12 @@ func (k *konText018Transformer) HistoryLookupItems() int {
 	return k.t.HistoryLookupItems()
 }
 
+// ScriptInput provides the record for the Lua `should_record` function
+func (k *konText018Transformer) ScriptInput(rec servicelog.InputRecord) (scripting.Input, bool) {
+	tRec, ok := rec.(*kontext018.QueryInputRecord)
+	return tRec, ok
+}
+
 func (k *konText018Transformer) Preprocess(
 	rec servicelog.InputRecord, prevRecs servicelog.ServiceLogBuffer,
 ) []servicelog.InputRecord {
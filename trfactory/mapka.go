@@ -30,10 +30,10 @@ type mapkaTransformer struct {
 
 // Transform transforms Mapka app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *mapkaTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *mapkaTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*mapka.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by Mapka transformer %T", logRec)
 }
@@ -56,10 +56,10 @@ type mapka2Transformer struct {
 
 // Transform transforms Mapka (v2) app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *mapka2Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *mapka2Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*mapka2.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by Mapka2 transformer %T", logRec)
 }
@@ -82,10 +82,10 @@ type mapka3Transformer struct {
 
 // Transform transforms Mapka (v2) app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *mapka3Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *mapka3Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*mapka3.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by Mapka3 transformer %T", logRec)
 }
@@ -31,10 +31,10 @@ func (s *mquerySRUTransformer) Transform(
 	recType string,
 	tzShiftMin int,
 	anonymousUsers []int,
-) (servicelog.OutputRecord, error) {
+) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*mquerysru.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by masm transformer %T", logRec)
 }
@@ -0,0 +1,52 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trfactory
+
+import (
+	"fmt"
+	"klogproc/servicelog"
+	"klogproc/servicelog/nginxjson"
+)
+
+type nginxJSONTransformer struct {
+	t *nginxjson.Transformer
+}
+
+// Transform transforms nginx JSON access log record types as general InputRecord
+// In case of type mismatch, error is returned.
+func (s *nginxJSONTransformer) Transform(
+	logRec servicelog.InputRecord,
+	recType string,
+	tzShiftMin int,
+	anonymousUsers []int,
+) ([]servicelog.OutputRecord, error) {
+	tRec, ok := logRec.(*nginxjson.InputRecord)
+	if ok {
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
+	}
+	return nil, fmt.Errorf("invalid type for servicelog.by nginx-json transformer %T", logRec)
+}
+
+func (k *nginxJSONTransformer) HistoryLookupItems() int {
+	return k.t.HistoryLookupItems()
+}
+
+func (k *nginxJSONTransformer) Preprocess(
+	rec servicelog.InputRecord, prevRecs servicelog.ServiceLogBuffer,
+) []servicelog.InputRecord {
+	return k.t.Preprocess(rec, prevRecs)
+}
@@ -37,10 +37,10 @@ type kwordsTransformer struct {
 
 // Transform transforms KWords app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *kwordsTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *kwordsTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*kwords.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by KWords transformer %T", logRec)
 }
@@ -63,10 +63,10 @@ type kwords2Transformer struct {
 
 // Transform transforms KWords app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *kwords2Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *kwords2Transformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*kwords2.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by KWords2 transformer %T", logRec)
 }
@@ -89,10 +89,10 @@ type korpusDBTransformer struct {
 
 // Transform transforms KorpusDB app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *korpusDBTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *korpusDBTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*korpusdb.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by KonText transformer %T", logRec)
 }
@@ -120,10 +120,10 @@ func (s *morfioTransformer) Transform(
 	recType string,
 	tzShiftMin int,
 	anonymousUsers []int,
-) (servicelog.OutputRecord, error) {
+) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*morfio.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by Morfio transformer %T", logRec)
 }
@@ -146,10 +146,10 @@ type skeTransformer struct {
 
 // Transform transforms SkE app log record (= web access log) types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *skeTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *skeTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*ske.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by SkE transformer %T", logRec)
 }
@@ -172,10 +172,10 @@ type shinyTransformer struct {
 
 // Transform transforms WaG app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *shinyTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *shinyTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*shiny.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by Shiny transformer %T", logRec)
 }
@@ -198,10 +198,10 @@ type sydTransformer struct {
 
 // Transform transforms SyD app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *sydTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *sydTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*syd.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by SyD transformer %T", logRec)
 }
@@ -224,10 +224,10 @@ type treqTransformer struct {
 
 // Transform transforms Treq app log record types as general InputRecord
 // In case of type mismatch, error is returned.
-func (s *treqTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) (servicelog.OutputRecord, error) {
+func (s *treqTransformer) Transform(logRec servicelog.InputRecord, recType string, tzShiftMin int, anonymousUsers []int) ([]servicelog.OutputRecord, error) {
 	tRec, ok := logRec.(*treq.InputRecord)
 	if ok {
-		return s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers)
+		return singleRecord(s.t.Transform(tRec, recType, tzShiftMin, anonymousUsers))
 	}
 	return nil, fmt.Errorf("invalid type for servicelog.by Treq transformer %T", logRec)
 }
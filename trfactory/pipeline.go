@@ -0,0 +1,362 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trfactory
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"klogproc/analysis"
+	"klogproc/load/accesslog"
+	"klogproc/load/alarm"
+	"klogproc/load/appconf"
+	"klogproc/load/batch"
+	"klogproc/logbuffer"
+	"klogproc/notifications"
+	"klogproc/servicelog"
+	"klogproc/users"
+
+	"github.com/oschwald/geoip2-golang"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrRecordSkipped is returned by Pipeline.ProcessLine in case
+	// the line is valid but it does not produce any output record
+	// (e.g. a non-query action, an excluded IP address).
+	ErrRecordSkipped = errors.New("record skipped")
+)
+
+// GeoIPLookup provides geographical data for IP addresses
+// (e.g. geoip2.Reader or a reloadable geodb.Reader)
+type GeoIPLookup interface {
+	City(ipAddress net.IP) (*geoip2.City, error)
+}
+
+// ApplyLocation fills in geographical information based
+// on the client IP of the input record (in case db is nil,
+// no lookup is done). If anonymizeIP is true, IP addresses
+// stored in the output record are masked after the lookup.
+func ApplyLocation(
+	rec servicelog.InputRecord,
+	db GeoIPLookup,
+	outRec servicelog.OutputRecord,
+	anonymizeIP bool,
+) {
+	if anonymizeIP {
+		defer anonymizeRecordIP(outRec)
+	}
+	if db == nil {
+		return
+	}
+	ip := rec.GetClientIP()
+	if len(ip) > 0 {
+		city, err := db.City(ip)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to fetch GeoIP data for IP %s.", ip.String())
+
+		} else {
+			outRec.SetLocation(city.Country.Names["en"], float32(city.Location.Latitude),
+				float32(city.Location.Longitude), city.Location.TimeZone)
+		}
+	}
+}
+
+func anonymizeRecordIP(outRec servicelog.OutputRecord) {
+	if tRec, ok := outRec.(servicelog.IPAnonymizer); ok {
+		tRec.AnonymizeIP()
+	}
+}
+
+// Pipeline converts raw log lines of a specific application
+// into output records. It is intended for use of klogproc
+// as a library (i.e. without configuration files, workers
+// and storage backends).
+type Pipeline interface {
+
+	// ProcessLine parses and transforms a single log line. In case
+	// the line does not produce any output record, ErrRecordSkipped
+	// is returned. In case a line produces more records, only the
+	// first one is returned (see ProcessLineAll).
+	ProcessLine(line string) (servicelog.OutputRecord, error)
+
+	// ProcessLineAll is a variant of ProcessLine returning all
+	// the produced records (possibly none)
+	ProcessLineAll(line string) ([]servicelog.OutputRecord, error)
+}
+
+// Option configures optional properties of a Pipeline
+type Option func(*pipeline)
+
+// WithGeoIPDB enables filling in geographical information
+// based on the client IP address
+func WithGeoIPDB(db *geoip2.Reader) Option {
+	return func(p *pipeline) {
+		if db != nil {
+			p.geoDB = db
+		}
+	}
+}
+
+// WithAnonymousUsers specifies IDs of users considered anonymous
+func WithAnonymousUsers(ids []int) Option {
+	return func(p *pipeline) {
+		p.anonymousUsers = ids
+	}
+}
+
+// WithUserMap specifies mapping of user IDs (required by some
+// application types, e.g. SkE)
+func WithUserMap(userMap *users.UserMap) Option {
+	return func(p *pipeline) {
+		p.userMap = userMap
+	}
+}
+
+// WithExcludeIPList specifies IP addresses whose records are skipped
+func WithExcludeIPList(ipList servicelog.ExcludeIPList) Option {
+	return func(p *pipeline) {
+		p.excludeIPList = ipList
+	}
+}
+
+// WithConversionActions specifies actions to be marked as conversions
+func WithConversionActions(actions servicelog.ConversionActionList) Option {
+	return func(p *pipeline) {
+		p.conversionActions = actions
+	}
+}
+
+// WithAnonymizedIP enables masking of client IP addresses
+// (see servicelog.AnonymizeIP)
+func WithAnonymizedIP() Option {
+	return func(p *pipeline) {
+		p.anonymizeIP = true
+	}
+}
+
+// WithTZShift specifies a time-zone correction (in minutes)
+func WithTZShift(tzShiftMin int) Option {
+	return func(p *pipeline) {
+		p.tzShift = tzShiftMin
+	}
+}
+
+// WithTZShiftConf specifies a time-zone correction which may
+// depend on the record time (see servicelog.TZShift). It takes
+// precedence over WithTZShift.
+func WithTZShiftConf(shift servicelog.TZShift) Option {
+	return func(p *pipeline) {
+		p.tzShiftConf = shift
+	}
+}
+
+// WithTraceIDField specifies a source field containing a trace ID
+// (see tail.FileConf.TraceIDField)
+func WithTraceIDField(field string) Option {
+	return func(p *pipeline) {
+		p.traceIDField = field
+	}
+}
+
+// WithParsingMode specifies how strictly lines are parsed
+// (see tail.FileConf.ParsingMode)
+func WithParsingMode(mode servicelog.ParsingMode) Option {
+	return func(p *pipeline) {
+		p.parsingMode = mode
+	}
+}
+
+// WithAccessLogFields specifies names and order of fields of
+// HTTP access log based logs (see tail.FileConf.AccessLogFields)
+func WithAccessLogFields(fields accesslog.FieldSpec) Option {
+	return func(p *pipeline) {
+		p.accessLogFields = fields
+	}
+}
+
+// WithClientIPConf specifies how client IP addresses are
+// determined (see tail.FileConf.ClientIP)
+func WithClientIPConf(conf *servicelog.ClientIPConf) Option {
+	return func(p *pipeline) {
+		p.clientIPConf = conf
+	}
+}
+
+// WithScriptPath specifies a Lua script applied to transformed
+// records (see tail.FileConf.ScriptPath)
+func WithScriptPath(path string) Option {
+	return func(p *pipeline) {
+		p.scriptPath = path
+	}
+}
+
+// WithPathTemplates specifies rules for creating templates
+// of request paths (see tail.FileConf.PathTemplates)
+func WithPathTemplates(rules servicelog.PathTemplateRules) Option {
+	return func(p *pipeline) {
+		p.pathTemplates = rules
+	}
+}
+
+// WithFilterScript specifies a Lua script with a `filter(record)`
+// function deciding which records are processed
+// (see tail.FileConf.FilterScriptPath)
+func WithFilterScript(path string) Option {
+	return func(p *pipeline) {
+		p.filterScriptPath = path
+	}
+}
+
+// WithProcTimeThreshold specifies how records with a processing
+// time below a threshold are handled (see servicelog.ProcTimeThreshold)
+func WithProcTimeThreshold(threshold servicelog.ProcTimeThreshold) Option {
+	return func(p *pipeline) {
+		p.procTimeThreshold = threshold
+	}
+}
+
+// WithExcludeStatus specifies HTTP status codes whose records
+// are skipped (see servicelog.ExcludeStatusList)
+func WithExcludeStatus(statusList servicelog.ExcludeStatusList) Option {
+	return func(p *pipeline) {
+		p.excludeStatus = statusList
+	}
+}
+
+// WithQueryAggregates enables additional aggregate records
+// (see kontext018.QueryAggregateRecord). KonText 0.18 only.
+func WithQueryAggregates(enabled bool) Option {
+	return func(p *pipeline) {
+		p.queryAggregates = enabled
+	}
+}
+
+type pipeline struct {
+	appType           string
+	geoDB             GeoIPLookup
+	anonymousUsers    []int
+	anonymizeIP       bool
+	userMap           *users.UserMap
+	excludeIPList     servicelog.ExcludeIPList
+	conversionActions servicelog.ConversionActionList
+	tzShift           int
+	tzShiftConf       servicelog.TZShift
+	traceIDField      string
+	parsingMode       servicelog.ParsingMode
+	accessLogFields   accesslog.FieldSpec
+	clientIPConf      *servicelog.ClientIPConf
+	scriptPath        string
+	pathTemplates     servicelog.PathTemplateRules
+	filterScriptPath  string
+	procTimeThreshold servicelog.ProcTimeThreshold
+	excludeStatus     servicelog.ExcludeStatusList
+	queryAggregates   bool
+	lineParser        batch.LineParser
+	logTransformer    servicelog.LogItemTransformer
+	logBuffer         servicelog.ServiceLogBuffer
+	lineNum           int64
+}
+
+func (p *pipeline) ProcessLineAll(line string) ([]servicelog.OutputRecord, error) {
+	p.lineNum++
+	rec, err := p.lineParser.ParseLine(line, p.lineNum)
+	if err != nil {
+		return []servicelog.OutputRecord{}, err
+	}
+	if !rec.IsProcessable() {
+		return []servicelog.OutputRecord{}, nil
+	}
+	ans := make([]servicelog.OutputRecord, 0, 1)
+	for _, precord := range p.logTransformer.Preprocess(rec, p.logBuffer) {
+		p.logBuffer.AddRecord(precord)
+		tzShift := p.tzShift
+		if !p.tzShiftConf.IsZero() {
+			tzShift = p.tzShiftConf.MinutesAt(precord.GetTime())
+		}
+		outRecs, err := p.logTransformer.Transform(precord, p.appType, tzShift, p.anonymousUsers)
+		if err != nil {
+			return []servicelog.OutputRecord{}, err
+		}
+		for _, outRec := range outRecs {
+			ApplyLocation(precord, p.geoDB, outRec, p.anonymizeIP)
+			ans = append(ans, outRec)
+		}
+	}
+	return ans, nil
+}
+
+func (p *pipeline) ProcessLine(line string) (servicelog.OutputRecord, error) {
+	recs, err := p.ProcessLineAll(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, ErrRecordSkipped
+	}
+	return recs[0], nil
+}
+
+// NewPipeline creates a line processing pipeline for a specified
+// application type and version. Please note that features requiring
+// a log buffer (e.g. bot detection) are not available.
+func NewPipeline(appType, version string, opts ...Option) (Pipeline, error) {
+	ans := &pipeline{
+		appType: appType,
+		userMap: users.EmptyUserMap(),
+	}
+	for _, opt := range opts {
+		opt(ans)
+	}
+	var err error
+	ans.lineParser, err = batch.NewLineParser(
+		appType, version, ans.traceIDField, ans.parsingMode, ans.accessLogFields,
+		&alarm.NullAlarm{})
+	if err != nil {
+		return nil, err
+	}
+	ans.lineParser = batch.WrapWithClientIPConf(ans.lineParser, ans.clientIPConf)
+	notifier, err := notifications.NewNotifier(nil, nil, nil, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	ans.logTransformer, err = GetLogTransformer(
+		appconf.ProcessingConf{
+			AppType:           appType,
+			Version:           version,
+			ExcludeIPList:     ans.excludeIPList,
+			ConversionActions: ans.conversionActions,
+			ScriptPath:        ans.scriptPath,
+			PathTemplates:     ans.pathTemplates,
+			FilterScriptPath:  ans.filterScriptPath,
+			ProcTimeThreshold: ans.procTimeThreshold,
+			ExcludeStatus:     ans.excludeStatus,
+			QueryAggregates:   ans.queryAggregates,
+		},
+		TransformerDeps{UserMap: ans.userMap, Notifier: notifier},
+	)
+	if err != nil {
+		return nil, err
+	}
+	ans.logBuffer = logbuffer.NewDummyStorage[servicelog.InputRecord, logbuffer.SerializableState](
+		func() logbuffer.SerializableState {
+			return &analysis.SimpleAnalysisState{}
+		},
+	)
+	return ans, nil
+}
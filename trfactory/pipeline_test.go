@@ -0,0 +1,190 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trfactory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"klogproc/scripting"
+	"klogproc/servicelog"
+	"klogproc/servicelog/kontext018"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKontextLine = `{"logger": "QUERY", "level": "INFO", "date": "2024-02-11T11:02:31.880",` +
+	` "user_id": 2, "proc_time": 0.5, "action": "query_submit",` +
+	` "request": {"REMOTE_ADDR": "192.168.1.10", "HTTP_USER_AGENT": "Mozilla/5.0"},` +
+	` "args": {"corpname": "syn2020"}}`
+
+func TestPipelineProcessLine(t *testing.T) {
+	p, err := NewPipeline(
+		servicelog.AppTypeKontext, "0.18",
+		WithAnonymousUsers([]int{2}),
+		WithConversionActions([]string{"query_submit"}),
+	)
+	assert.NoError(t, err)
+	rec, err := p.ProcessLine(testKontextLine)
+	assert.NoError(t, err)
+	tRec, ok := rec.(*kontext018.OutputRecord)
+	assert.True(t, ok)
+	assert.Equal(t, "query_submit", tRec.Action)
+	assert.Equal(t, "192.168.1.10", tRec.IPAddress)
+	assert.True(t, tRec.IsAnonymous)
+	assert.True(t, tRec.IsConversion)
+	assert.NotEmpty(t, rec.GetID())
+}
+
+func TestPipelineSkippedAndInvalidLines(t *testing.T) {
+	p, err := NewPipeline(
+		servicelog.AppTypeKontext, "0.18",
+		WithExcludeIPList([]string{"192.168.1.10"}),
+	)
+	assert.NoError(t, err)
+	_, err = p.ProcessLine(testKontextLine)
+	assert.ErrorIs(t, err, ErrRecordSkipped)
+	_, err = p.ProcessLine(`{"logger": "SYSTEM", "level": "INFO", "date": "2024-02-11T11:02:31.880"}`)
+	assert.ErrorIs(t, err, ErrRecordSkipped)
+	_, err = p.ProcessLine("foo")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrRecordSkipped)
+}
+
+func TestPipelineUnsupportedVersion(t *testing.T) {
+	_, err := NewPipeline(servicelog.AppTypeKontext, "0.99")
+	assert.Error(t, err)
+}
+
+func TestPipelineAnonymizedIP(t *testing.T) {
+	p, err := NewPipeline(servicelog.AppTypeKontext, "0.18", WithAnonymizedIP())
+	assert.NoError(t, err)
+	rec, err := p.ProcessLine(testKontextLine)
+	assert.NoError(t, err)
+	tRec, ok := rec.(*kontext018.OutputRecord)
+	assert.True(t, ok)
+	assert.Equal(t, "192.168.1.0", tRec.IPAddress)
+}
+
+func TestPipelineFilterScript(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "filter.lua")
+	assert.NoError(t, os.WriteFile(scriptPath, []byte(`
+function filter(record)
+  return record.args.corpname ~= "syn2020" or record._clientIp ~= "192.168.1.10"
+end
+`), 0644))
+	p, err := NewPipeline(servicelog.AppTypeKontext, "0.18", WithFilterScript(scriptPath))
+	assert.NoError(t, err)
+	_, err = p.ProcessLine(testKontextLine)
+	assert.ErrorIs(t, err, ErrRecordSkipped)
+	rec, err := p.ProcessLine(strings.Replace(testKontextLine, "syn2020", "syn2015", 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "syn2015", rec.(*kontext018.OutputRecord).Corpus)
+}
+
+func TestPipelineShouldRecordScript(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "filter.lua")
+	assert.NoError(t, os.WriteFile(scriptPath, []byte(`
+function should_record(input)
+  return input:args().corpname ~= "syn2020" or input:ip() ~= "192.168.1.10"
+end
+`), 0644))
+	p, err := NewPipeline(servicelog.AppTypeKontext, "0.18", WithFilterScript(scriptPath))
+	assert.NoError(t, err)
+	_, err = p.ProcessLine(testKontextLine)
+	assert.ErrorIs(t, err, ErrRecordSkipped)
+	rec, err := p.ProcessLine(strings.Replace(testKontextLine, "syn2020", "syn2015", 1))
+	assert.NoError(t, err)
+	assert.Equal(t, "syn2015", rec.(*kontext018.OutputRecord).Corpus)
+
+	_, err = NewPipeline(servicelog.AppTypeTreq, "", WithFilterScript(scriptPath))
+	assert.ErrorIs(t, err, scripting.ErrScriptingNotSupported)
+}
+
+func TestPipelineExcludeStatus(t *testing.T) {
+	line := `195.113.53.123 - johndoe [16/Sep/2019:08:24:05 +0200] ` +
+		`"GET /ske/run.cgi/first?corpname=preloaded/bnc2 HTTP/2.0" 200 332 ` +
+		`"https://www.korpus.cz/ske/" "Mozilla/5.0 (X11; Linux x86_64)" rt=0.012`
+	p, err := NewPipeline(servicelog.AppTypeSke, "", WithExcludeStatus(servicelog.ExcludeStatusList{"4xx"}))
+	assert.NoError(t, err)
+	_, err = p.ProcessLine(line)
+	assert.NoError(t, err)
+	_, err = p.ProcessLine(strings.Replace(line, `HTTP/2.0" 200`, `HTTP/2.0" 404`, 1))
+	assert.ErrorIs(t, err, ErrRecordSkipped)
+}
+
+func TestPipelineProcTimeThresholdDrop(t *testing.T) {
+	p, err := NewPipeline(
+		servicelog.AppTypeKontext, "0.18",
+		WithProcTimeThreshold(servicelog.ProcTimeThreshold{MinProcTime: 0.6}))
+	assert.NoError(t, err)
+	_, err = p.ProcessLine(testKontextLine)
+	assert.ErrorIs(t, err, ErrRecordSkipped)
+	_, err = p.ProcessLine(strings.Replace(testKontextLine, `"proc_time": 0.5`, `"proc_time": 0.7`, 1))
+	assert.NoError(t, err)
+}
+
+func TestPipelineProcTimeThresholdFlag(t *testing.T) {
+	p, err := NewPipeline(
+		servicelog.AppTypeKontext, "0.18",
+		WithProcTimeThreshold(servicelog.ProcTimeThreshold{
+			MinProcTime:         0.6,
+			TrivialRecordPolicy: servicelog.TrivialRecordPolicyFlag,
+		}))
+	assert.NoError(t, err)
+	rec, err := p.ProcessLine(testKontextLine)
+	assert.NoError(t, err)
+	data, err := rec.ToJSON()
+	assert.NoError(t, err)
+	var parsed map[string]any
+	assert.NoError(t, json.Unmarshal(data, &parsed))
+	assert.Equal(t, true, parsed["isTrivial"])
+	assert.Equal(t, "syn2020", parsed["corpus"])
+	tags, _ := rec.ToInfluxDB()
+	assert.Equal(t, "true", tags["isTrivial"])
+
+	rec, err = p.ProcessLine(strings.Replace(testKontextLine, `"proc_time": 0.5`, `"proc_time": 0.7`, 1))
+	assert.NoError(t, err)
+	_, ok := rec.(*kontext018.OutputRecord)
+	assert.True(t, ok)
+}
+
+func TestPipelineQueryAggregates(t *testing.T) {
+	p, err := NewPipeline(servicelog.AppTypeKontext, "0.18", WithQueryAggregates(true))
+	assert.NoError(t, err)
+	recs, err := p.ProcessLineAll(testKontextLine)
+	assert.NoError(t, err)
+	assert.Len(t, recs, 2)
+	tRec, ok := recs[0].(*kontext018.OutputRecord)
+	assert.True(t, ok)
+	aRec, ok := recs[1].(*kontext018.QueryAggregateRecord)
+	assert.True(t, ok)
+	assert.Equal(t, "syn2020", aRec.Corpus)
+	assert.Equal(t, tRec.Action, aRec.Action)
+	assert.Equal(t, tRec.GetTime().Format("2006-01-02"), aRec.Day)
+	assert.Equal(t, 1, aRec.Count)
+	assert.Equal(t, tRec.Type+kontext018.AggregateTypeSuffix, aRec.GetType())
+	assert.NotEqual(t, tRec.GetID(), aRec.GetID())
+}
+
+func TestPipelineQueryAggregatesUnsupported(t *testing.T) {
+	_, err := NewPipeline(servicelog.AppTypeKontext, "0.17", WithQueryAggregates(true))
+	assert.Error(t, err)
+}
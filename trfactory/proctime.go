@@ -0,0 +1,54 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trfactory
+
+import (
+	"klogproc/servicelog"
+)
+
+// procTimeTransformer wraps an app-specific transformer and either drops
+// or flags records with a processing time below a configured threshold
+// (see servicelog.ProcTimeThreshold).
+type procTimeTransformer struct {
+	servicelog.LogItemTransformer
+	threshold servicelog.ProcTimeThreshold
+}
+
+func (pt *procTimeTransformer) Preprocess(
+	rec servicelog.InputRecord, prevRecs servicelog.ServiceLogBuffer,
+) []servicelog.InputRecord {
+	if pt.threshold.Policy() == servicelog.TrivialRecordPolicyDrop && pt.threshold.IsTrivial(rec) {
+		return []servicelog.InputRecord{}
+	}
+	return pt.LogItemTransformer.Preprocess(rec, prevRecs)
+}
+
+func (pt *procTimeTransformer) Transform(
+	logRec servicelog.InputRecord,
+	recType string,
+	tzShiftMin int,
+	anonymousUsers []int,
+) ([]servicelog.OutputRecord, error) {
+	ans, err := pt.LogItemTransformer.Transform(logRec, recType, tzShiftMin, anonymousUsers)
+	if err != nil || pt.threshold.Policy() != servicelog.TrivialRecordPolicyFlag || !pt.threshold.IsTrivial(logRec) {
+		return ans, err
+	}
+	for i, rec := range ans {
+		ans[i] = &servicelog.TrivialOutputRecord{OutputRecord: rec}
+	}
+	return ans, nil
+}
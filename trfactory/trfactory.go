@@ -17,8 +17,10 @@ package trfactory
 import (
 	"fmt"
 
-	"klogproc/load"
+	"klogproc/analysis"
+	"klogproc/load/appconf"
 	"klogproc/notifications"
+	"klogproc/scripting"
 	"klogproc/servicelog"
 	"klogproc/servicelog/apiguard"
 	"klogproc/servicelog/kontext013"
@@ -34,6 +36,7 @@ import (
 	"klogproc/servicelog/morfio"
 	"klogproc/servicelog/mquery"
 	"klogproc/servicelog/mquerysru"
+	"klogproc/servicelog/nginxjson"
 	"klogproc/servicelog/shiny"
 	"klogproc/servicelog/ske"
 	"klogproc/servicelog/syd"
@@ -44,50 +47,147 @@ import (
 	"klogproc/users"
 )
 
+// singleRecord converts a result of an app-specific transformer
+// producing a single record into the LogItemTransformer.Transform
+// result
+func singleRecord[T servicelog.OutputRecord](rec T, err error) ([]servicelog.OutputRecord, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []servicelog.OutputRecord{rec}, nil
+}
+
+// supportsScripting tells whether a transformer of the app type
+// and version is able to run a user-defined Lua script
+func supportsScripting(appType, version string) bool {
+	return (appType == servicelog.AppTypeKontext || appType == servicelog.AppTypeKontextAPI) &&
+		version == "0.18"
+}
+
+// TransformerDeps contains runtime dependencies of a log transformer
+// which are not part of an app's processing configuration.
+type TransformerDeps struct {
+	UserMap *users.UserMap
+
+	// RealtimeClock should be set in case records are processed
+	// as they are written (e.g. the `tail` action) so time-based
+	// analyses can use the current time
+	RealtimeClock bool
+
+	Notifier notifications.Notifier
+
+	// BotExporter is optional
+	BotExporter analysis.BotCandidateExporter
+}
+
 // GetLogTransformer returns a type-safe transformer for a concrete app type
+// as specified by the provided processing configuration.
+// In case FilterScriptPath is set, records rejected by the script's `filter`
+// or `should_record` function are dropped before they are preprocessed
+// (`should_record` requires the transformer to support scripting). Records with processing
+// time below the configured ProcTimeThreshold are dropped or flagged and
+// records with an HTTP status listed in ExcludeStatus are dropped.
+// With QueryAggregates set (KonText 0.18 only), an additional aggregate
+// record (see kontext018.QueryAggregateRecord) is produced for each record.
 func GetLogTransformer(
-	appType string,
-	version string,
-	bufferConf *load.BufferConf,
-	userMap *users.UserMap,
-	excludeIpList servicelog.ExcludeIPList,
-	realtimeClock bool,
-	emailNotifier notifications.Notifier,
+	conf appconf.ProcessingConf,
+	deps TransformerDeps,
 ) (servicelog.LogItemTransformer, error) {
+	appType, version := conf.AppType, conf.Version
+	ans, err := getAppLogTransformer(conf, deps)
+	if err != nil {
+		return nil, err
+	}
+	inpProvider, _ := ans.(scriptInputProvider)
+	if conf.QueryAggregates {
+		if _, ok := ans.(*konText018Transformer); !ok {
+			return nil, fmt.Errorf("cannot use query aggregates for %s %s - not supported", appType, version)
+		}
+		ans = &queryAggregateTransformer{LogItemTransformer: ans}
+	}
+	if conf.ExcludeStatus.IsConfigured() {
+		ans = &statusFilterTransformer{LogItemTransformer: ans, excludeStatus: conf.ExcludeStatus}
+	}
+	if conf.ProcTimeThreshold.IsConfigured() {
+		ans = &procTimeTransformer{LogItemTransformer: ans, threshold: conf.ProcTimeThreshold}
+	}
+	if conf.FilterScriptPath == "" {
+		return ans, nil
+	}
+	filter, err := scripting.NewFilter(conf.FilterScriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create transformer: %w", err)
+	}
+	if filter.HasShouldRecord() && inpProvider == nil {
+		filter.Close()
+		return nil, fmt.Errorf(
+			"cannot use %s for %s %s: %w",
+			scripting.ShouldRecordFnName, appType, version, scripting.ErrScriptingNotSupported)
+	}
+	return &filteringTransformer{LogItemTransformer: ans, filter: filter, inpProvider: inpProvider}, nil
+}
+
+func getAppLogTransformer(
+	conf appconf.ProcessingConf,
+	deps TransformerDeps,
+) (servicelog.LogItemTransformer, error) {
+	appType, version := conf.AppType, conf.Version
+
+	var scriptEngine *scripting.Engine
+	if conf.ScriptPath != "" {
+		if !supportsScripting(appType, version) {
+			return nil, fmt.Errorf(
+				"cannot use script for %s %s: %w", appType, version, scripting.ErrScriptingNotSupported)
+		}
+		var err error
+		scriptEngine, err = scripting.NewEngine(conf.ScriptPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create transformer: %w", err)
+		}
+	}
+
+	if conf.PathTemplates.IsConfigured() && appType != servicelog.AppTypeNginxJSON {
+		return nil, fmt.Errorf("cannot use path templates for %s - not supported", appType)
+	}
 
 	switch appType {
 	case servicelog.AppTypeAPIGuard:
 		return &apiguardTransformer{
 			t: &apiguard.Transformer{
-				ExcludeIPList: excludeIpList,
+				ExcludeIPList: conf.ExcludeIPList,
 			},
 		}, nil
 	case servicelog.AppTypeAkalex, servicelog.AppTypeCalc, servicelog.AppTypeLists,
 		servicelog.AppTypeQuitaUp, servicelog.AppTypeGramatikat:
 		return &shinyTransformer{
-			t: shiny.NewTransformer(excludeIpList),
+			t: shiny.NewTransformer(conf.ExcludeIPList),
 		}, nil
 	case servicelog.AppTypeKontext, servicelog.AppTypeKontextAPI:
 		switch version {
 		case "0.13", "0.14":
 			return &konText013Transformer{
 				t: &kontext013.Transformer{
-					ExcludeIPList: excludeIpList,
+					ExcludeIPList:     conf.ExcludeIPList,
+					ConversionActions: conf.ConversionActions,
 				},
 			}, nil
 		case "0.15", "0.16", "0.17":
 			return &konText015Transformer{
 				t: &kontext015.Transformer{
-					ExcludeIPList: excludeIpList,
+					ExcludeIPList:     conf.ExcludeIPList,
+					ConversionActions: conf.ConversionActions,
 				},
 			}, nil
 		case "0.18":
 			return &konText018Transformer{
 				t: kontext018.NewTransformer(
-					bufferConf,
-					realtimeClock,
-					emailNotifier,
-					excludeIpList,
+					conf.Buffer,
+					deps.RealtimeClock,
+					deps.Notifier,
+					deps.BotExporter,
+					conf.ExcludeIPList,
+					conf.ConversionActions,
+					scriptEngine,
 				),
 			}, nil
 		default:
@@ -98,13 +198,13 @@ func GetLogTransformer(
 		case "1":
 			return &kwordsTransformer{
 				t: &kwords.Transformer{
-					ExcludeIPList: excludeIpList,
+					ExcludeIPList: conf.ExcludeIPList,
 				},
 			}, nil
 		case "2":
 			return &kwords2Transformer{
 				t: &kwords2.Transformer{
-					ExcludeIPList: excludeIpList,
+					ExcludeIPList: conf.ExcludeIPList,
 				}}, nil
 		default:
 			return nil, fmt.Errorf("cannot create transformer, unsupported KWords version: %s", version)
@@ -112,24 +212,24 @@ func GetLogTransformer(
 
 	case servicelog.AppTypeKorpusDB:
 		return &korpusDBTransformer{t: &korpusdb.Transformer{
-			ExcludeIPList: excludeIpList,
+			ExcludeIPList: conf.ExcludeIPList,
 		}}, nil
 	case servicelog.AppTypeMapka:
 		switch version {
 		case "1":
 			return &mapkaTransformer{
-				t: mapka.NewTransformer(excludeIpList),
+				t: mapka.NewTransformer(conf.ExcludeIPList),
 			}, nil
 		case "2":
 			return &mapka2Transformer{
-				t: mapka2.NewTransformer(excludeIpList),
+				t: mapka2.NewTransformer(conf.ExcludeIPList),
 			}, nil
 		case "3":
 			return &mapka3Transformer{
 				t: mapka3.NewTransformer(
-					bufferConf,
-					excludeIpList,
-					realtimeClock,
+					conf.Buffer,
+					conf.ExcludeIPList,
+					deps.RealtimeClock,
 				),
 			}, nil
 		default:
@@ -137,36 +237,37 @@ func GetLogTransformer(
 		}
 	case servicelog.AppTypeMorfio:
 		return &morfioTransformer{t: &morfio.Transformer{
-			ExcludeIPList: excludeIpList,
+			ExcludeIPList: conf.ExcludeIPList,
 		}}, nil
 	case servicelog.AppTypeSke:
 		return &skeTransformer{
-				t: ske.NewTransformer(userMap, excludeIpList),
+				t: ske.NewTransformer(deps.UserMap, conf.ExcludeIPList, conf.ConversionActions),
 			},
 			nil
 	case servicelog.AppTypeSyd:
 		return &sydTransformer{
-			t: syd.NewTransformer(version, excludeIpList),
+			t: syd.NewTransformer(version, conf.ExcludeIPList),
 		}, nil
 	case servicelog.AppTypeTreq:
 		return &treqTransformer{t: &treq.Transformer{
-			ExcludeIPList: excludeIpList,
+			ExcludeIPList: conf.ExcludeIPList,
 		}}, nil
 	case servicelog.AppTypeWag:
 		switch version {
 		case "0.6":
 			return &wag06Transformer{
 				t: &wag06.Transformer{
-					ExcludeIPList: excludeIpList,
+					ExcludeIPList: conf.ExcludeIPList,
 				},
 			}, nil
 		case "0.7":
 			return &wag07Transformer{
 				t: wag07.NewTransformer(
-					bufferConf,
-					excludeIpList,
-					realtimeClock,
-					emailNotifier,
+					conf.Buffer,
+					conf.ExcludeIPList,
+					deps.RealtimeClock,
+					deps.Notifier,
+					deps.BotExporter,
 				),
 			}, nil
 		default:
@@ -175,24 +276,32 @@ func GetLogTransformer(
 	case servicelog.AppTypeWsserver:
 		return &wsserverTransformer{
 			t: &wsserver.Transformer{
-				ExcludeIPList: excludeIpList,
+				ExcludeIPList: conf.ExcludeIPList,
 			},
 		}, nil
 	case servicelog.AppTypeMasm:
 		return &masmTransformer{t: &masm.Transformer{
-			ExcludeIPList: excludeIpList,
+			ExcludeIPList: conf.ExcludeIPList,
 		}}, nil
 	case servicelog.AppTypeMquery:
 		return &mqueryTransformer{t: &mquery.Transformer{
-			ExcludeIPList: excludeIpList,
+			ExcludeIPList: conf.ExcludeIPList,
 		}}, nil
 	case servicelog.AppTypeMquerySRU:
 		return &mquerySRUTransformer{
 				t: &mquerysru.Transformer{
-					ExcludeIPList: excludeIpList,
+					ExcludeIPList: conf.ExcludeIPList,
 				},
 			},
 			nil
+	case servicelog.AppTypeNginxJSON:
+		return &nginxJSONTransformer{
+			t: &nginxjson.Transformer{
+				ExcludeIPList:     conf.ExcludeIPList,
+				ConversionActions: conf.ConversionActions,
+				PathTemplates:     conf.PathTemplates,
+			},
+		}, nil
 	default:
 		return nil, fmt.Errorf("cannot find log transformer for app type %s", appType)
 	}
@@ -0,0 +1,138 @@
+// Copyright 2024 Tomas Machalek <tomas.machalek@gmail.com>
+// Copyright 2024 Institute of the Czech National Corpus,
+//                Faculty of Arts, Charles University
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"klogproc/config"
+	"klogproc/load/alarm"
+	"klogproc/load/appconf"
+	"klogproc/load/batch"
+	"klogproc/notifications"
+	"klogproc/trfactory"
+	"klogproc/users"
+)
+
+// checkWritableDir tests whether we are able to create
+// files in the provided directory
+func checkWritableDir(path string) error {
+	f, err := os.CreateTemp(path, ".klogproc-validate-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkLogProcessing tests whether a parser and a transformer
+// can be created for the provided processing configuration
+func checkLogProcessing(
+	procConf appconf.ProcessingConf,
+	notifier notifications.Notifier,
+) []error {
+	appType, version := procConf.AppType, procConf.Version
+	ans := make([]error, 0, 2)
+	lp, err := batch.NewLineParser(
+		appType, version, procConf.TraceIDField, procConf.ParsingMode, procConf.AccessLogFields,
+		&alarm.NullAlarm{})
+	if err != nil {
+		ans = append(ans, fmt.Errorf("failed to create parser: %w", err))
+
+	} else if _, err := batch.WrapWithInputFormat(lp, procConf.InputFormat, appType, version); err != nil {
+		ans = append(ans, fmt.Errorf("failed to create parser: %w", err))
+	}
+	_, err = trfactory.GetLogTransformer(
+		procConf,
+		trfactory.TransformerDeps{UserMap: users.EmptyUserMap(), Notifier: notifier},
+	)
+	if err != nil {
+		ans = append(ans, fmt.Errorf("failed to create transformer: %w", err))
+	}
+	return ans
+}
+
+// validateConfig checks the whole configuration and returns
+// all the found problems. In addition to config.Validate, it tests
+// whether parsers and transformers can be created for the configured
+// log files. No connections to external services are made and no log
+// processing is started.
+func validateConfig(conf *config.Main) []error {
+	ans := config.Validate(conf, config.ActionValidateConfig)
+	addErr := func(prefix string, err error) {
+		ans = append(ans, fmt.Errorf("%s: %w", prefix, err))
+	}
+
+	if _, ok := logLevelMapping[conf.LogLevel]; conf.LogLevel != "" && !ok {
+		ans = append(ans, fmt.Errorf("logLevel: invalid value '%s'", conf.LogLevel))
+	}
+	notifier, err := notifications.NewNotifier(
+		conf.EmailNotification, conf.ConomiNotification, conf.WebhookNotification,
+		conf.TimezoneLocation())
+	if err != nil {
+		addErr("notifications", err)
+		notifier, _ = notifications.NewNotifier(nil, nil, nil, conf.TimezoneLocation())
+	}
+
+	if conf.LogFiles != nil {
+		for _, err := range checkLogProcessing(conf.LogFiles.ProcessingConf, notifier) {
+			addErr("logFiles", err)
+		}
+	}
+
+	if conf.LogTail != nil {
+		if conf.LogTail.ValidateGlobals() == nil {
+			if err := checkWritableDir(conf.LogTail.LogBufferStateDir); err != nil {
+				addErr("logTail.logBufferStateDir", err)
+			}
+		}
+		fullFiles, err := conf.LogTail.FullFiles()
+		if err != nil {
+			fullFiles = conf.LogTail.Files
+		}
+		for i, fc := range fullFiles {
+			prefix := fmt.Sprintf("logTail.files[%d] (%s)", i, fc.Path)
+			for _, err := range checkLogProcessing(fc.ProcessingConf, notifier) {
+				addErr(prefix, err)
+			}
+		}
+	}
+
+	if conf.HTTPIngest != nil {
+		for _, app := range conf.HTTPIngest.Apps {
+			prefix := fmt.Sprintf("httpIngest.apps (%s)", app.AppType)
+			for _, err := range checkLogProcessing(app.ProcessingConf, notifier) {
+				addErr(prefix, err)
+			}
+		}
+	}
+	return ans
+}
+
+func runValidateConfigAction(conf *config.Main) {
+	problems := validateConfig(conf)
+	if len(problems) == 0 {
+		fmt.Println("configuration OK")
+		return
+	}
+	fmt.Printf("found %d configuration problem(s):\n", len(problems))
+	for _, p := range problems {
+		fmt.Printf("\t- %s\n", p)
+	}
+	os.Exit(1)
+}